@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"ccdash-backend/internal/config"
+	"ccdash-backend/internal/database"
+	"ccdash-backend/internal/services"
+)
+
+func main() {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Apply the same pricing overrides as the server
+	if err := services.LoadPricingOverrides(cfg.PricingOverridesPath); err != nil {
+		log.Fatalf("Failed to load pricing overrides: %v", err)
+	}
+	services.SetNullModelFallback(cfg.NullModelFallback)
+
+	// Initialize database (adds the messages.cost column if needed)
+	db, err := database.Initialize()
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	fmt.Println("Starting message cost backfill...")
+
+	tokenService := services.NewTokenService(db)
+	count, err := tokenService.BackfillMessageCosts()
+	if err != nil {
+		log.Fatalf("Failed to backfill message costs: %v", err)
+	}
+
+	fmt.Printf("Successfully updated cost for %d assistant messages\n", count)
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"text/tabwriter"
+	"time"
 	
 	_ "github.com/marcboeker/go-duckdb"
 )
@@ -20,6 +21,7 @@ func main() {
 		configPath = flag.String("config", "", "Path to config file")
 		dbPath     = flag.String("db", "", "Database path (overrides config)")
 		port       = flag.Int("port", 0, "Server port (overrides config)")
+		dir        = flag.String("dir", "migrations", "Migrations directory (create only)")
 		help       = flag.Bool("help", false, "Show help")
 	)
 	
@@ -28,8 +30,9 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Commands:\n")
 		fmt.Fprintf(os.Stderr, "  up      Run all pending migrations\n")
 		fmt.Fprintf(os.Stderr, "  down    Roll back the last migration\n")
+		fmt.Fprintf(os.Stderr, "  redo    Roll back the last migration and apply it again\n")
 		fmt.Fprintf(os.Stderr, "  status  Show migration status\n")
-		fmt.Fprintf(os.Stderr, "  create  Create a new migration (not implemented yet)\n\n")
+		fmt.Fprintf(os.Stderr, "  create  Create a new migration: create <name>\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
 		flag.PrintDefaults()
 	}
@@ -43,6 +46,21 @@ func main() {
 	
 	command := flag.Arg(0)
 	
+	// create only writes files, so it does not need the database
+	if command == "create" {
+		if flag.NArg() != 2 {
+			fmt.Fprintf(os.Stderr, "Usage: %s [-dir migrations] create <name>\n", os.Args[0])
+			os.Exit(1)
+		}
+		upPath, downPath, err := migration.CreateMigration(*dir, flag.Arg(1), time.Now())
+		if err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		fmt.Printf("Created %s\n", upPath)
+		fmt.Printf("Created %s\n", downPath)
+		return
+	}
+	
 	// Load config
 	cfg, err := loadConfig(*configPath, *dbPath, *port)
 	if err != nil {
@@ -76,6 +94,22 @@ func main() {
 		}
 		fmt.Println("Rollback completed successfully")
 		
+	case "redo":
+		before, err := engine.Status()
+		if err != nil {
+			log.Fatalf("Failed to get status: %v", err)
+		}
+		fmt.Printf("Before: version %s\n", formatVersion(before))
+		if err := engine.Redo(); err != nil {
+			log.Fatalf("Redo failed: %v", err)
+		}
+		after, err := engine.Status()
+		if err != nil {
+			log.Fatalf("Failed to get status: %v", err)
+		}
+		fmt.Printf("After:  version %s\n", formatVersion(after))
+		fmt.Println("Redo completed successfully")
+		
 	case "status":
 		status, err := engine.Status()
 		if err != nil {
@@ -83,10 +117,6 @@ func main() {
 		}
 		printStatus(status)
 		
-	case "create":
-		fmt.Println("Create command not implemented yet")
-		os.Exit(1)
-		
 	default:
 		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
 		flag.Usage()
@@ -127,11 +157,32 @@ func initDB(cfg *config.Config) (*sql.DB, error) {
 	return db, nil
 }
 
+// formatVersion returns the current version of status, marked when dirty
+func formatVersion(status *migration.Status) string {
+	version := status.CurrentVersion
+	if version == "" {
+		version = "none"
+	}
+	if status.Dirty {
+		version += " (dirty)"
+	}
+	return version
+}
+
 func printStatus(status *migration.Status) {
 	fmt.Printf("Current Version: %s\n", status.CurrentVersion)
 	if status.Dirty {
 		fmt.Printf("WARNING: Database is in dirty state!\n")
 	}
+	modified := 0
+	for _, v := range status.Applied {
+		if v.Status == "modified" {
+			modified++
+		}
+	}
+	if modified > 0 {
+		fmt.Printf("WARNING: %d applied migration(s) were modified after being applied!\n", modified)
+	}
 	fmt.Println()
 	
 	if len(status.Applied) > 0 {
@@ -144,7 +195,11 @@ func printStatus(status *migration.Status) {
 			if v.AppliedAt != nil {
 				appliedAt = v.AppliedAt.Format("2006-01-02 15:04:05")
 			}
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Version, v.Name, appliedAt, v.Status)
+			state := v.Status
+			if state == "modified" {
+				state = "MODIFIED"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Version, v.Name, appliedAt, state)
 		}
 		w.Flush()
 		fmt.Println()
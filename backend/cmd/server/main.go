@@ -1,24 +1,41 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"ccdash-backend/internal/config"
 	"ccdash-backend/internal/database"
 	"ccdash-backend/internal/handlers"
+	"ccdash-backend/internal/logging"
 	"ccdash-backend/internal/middleware"
+	"ccdash-backend/internal/models"
 	"ccdash-backend/internal/services"
+	"ccdash-backend/internal/version"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
+const (
+	// SHUTDOWN_HTTP_TIMEOUT bounds how long in-flight requests may take to complete on shutdown
+	SHUTDOWN_HTTP_TIMEOUT = 10 * time.Second
+	// SHUTDOWN_SYNC_TIMEOUT bounds how long shutdown waits for a background log sync
+	SHUTDOWN_SYNC_TIMEOUT = 30 * time.Second
+)
+
 // isPrivateIP checks if an IP address is in private ranges
 func isPrivateIP(ip string) bool {
 	privateRanges := []string{
@@ -26,6 +43,14 @@ func isPrivateIP(ip string) bool {
 		"172.16.0.0/12",  // Class B private
 		"192.168.0.0/16", // Class C private
 		"127.0.0.0/8",    // Loopback
+		"::1/128",        // IPv6 loopback
+		"fc00::/7",       // IPv6 unique local
+		"fe80::/10",      // IPv6 link-local
+	}
+	
+	// Drop the zone of a link-local address (fe80::1%eth0)
+	if i := strings.IndexByte(ip, '%'); i >= 0 {
+		ip = ip[:i]
 	}
 	
 	parsedIP := net.ParseIP(ip)
@@ -46,8 +71,9 @@ func isPrivateIP(ip string) bool {
 	return false
 }
 
-// isAllowedOrigin checks if an origin should be allowed for CORS
-func isAllowedOrigin(origin string, allowedOrigins []string) bool {
+// isAllowedOrigin checks if an origin should be allowed for CORS.
+// Private IP origins are only allowed on the default port or one of allowedPrivatePorts.
+func isAllowedOrigin(origin string, allowedOrigins []string, allowedPrivatePorts []string) bool {
 	// Check explicit allowed origins first
 	for _, allowed := range allowedOrigins {
 		if origin == allowed {
@@ -67,8 +93,8 @@ func isAllowedOrigin(origin string, allowedOrigins []string) bool {
 		return false
 	}
 	
-	// Allow localhost and 127.0.0.1 always
-	if hostname == "localhost" || hostname == "127.0.0.1" {
+	// Allow localhost, 127.0.0.1 and ::1 always
+	if hostname == "localhost" || hostname == "127.0.0.1" || hostname == "::1" {
 		return true
 	}
 	
@@ -84,9 +110,14 @@ func isAllowedOrigin(origin string, allowedOrigins []string) bool {
 		
 		// SECURITY: Only allow specific development ports for local development
 		// No arbitrary port access allowed
-		if port == "" || port == "3000" { // Only allow frontend dev server port
+		if port == "" { // Default port of the scheme
 			return true
 		}
+		for _, allowedPort := range allowedPrivatePorts { // Frontend dev server ports (default: 3000)
+			if port == allowedPort {
+				return true
+			}
+		}
 		// Allow standard web ports only if explicitly configured in production
 		if (port == "80" || port == "443") && os.Getenv("GIN_MODE") == "release" {
 			return true
@@ -104,7 +135,58 @@ func parseInt(s string, defaultValue int) int {
 	return defaultValue
 }
 
+// serverFlags are command line overrides for the environment-based configuration,
+// e.g. to run separate instances on different databases and ports
+type serverFlags struct {
+	configPath string
+	dbPath     string
+	port       int
+}
+
+// parseServerFlags parses the server's command line flags
+func parseServerFlags(args []string) (serverFlags, error) {
+	var flags serverFlags
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&flags.configPath, "config", "", "Env file with configuration (overrides .env and the environment)")
+	fs.StringVar(&flags.dbPath, "db", "", "Database path (overrides config)")
+	fs.IntVar(&flags.port, "port", 0, "Server port (overrides config)")
+	if err := fs.Parse(args); err != nil {
+		return flags, err
+	}
+	if flags.port < 0 || flags.port > 65535 {
+		return flags, fmt.Errorf("invalid -port %d", flags.port)
+	}
+	return flags, nil
+}
+
+// apply overrides the loaded configuration with the flags that were set
+func (f serverFlags) apply(cfg *config.Config) {
+	if f.dbPath != "" {
+		cfg.DatabasePath = f.dbPath
+		cfg.DatabaseDir = filepath.Dir(f.dbPath)
+	}
+	if f.port != 0 {
+		cfg.ServerPort = strconv.Itoa(f.port)
+	}
+}
+
 func main() {
+	flags, err := parseServerFlags(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+
+	// An explicit config file takes precedence over .env and the environment
+	if flags.configPath != "" {
+		if err := godotenv.Overload(flags.configPath); err != nil {
+			log.Fatalf("Failed to load config file %s: %v", flags.configPath, err)
+		}
+		log.Printf("Loaded config file %s", flags.configPath)
+	}
+
 	// Load .env file if it exists (must be done before checking GIN_MODE)
 	if err := godotenv.Load(); err != nil {
 		// .env file is optional, so just log if not found
@@ -123,6 +205,20 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to load configuration:", err)
 	}
+	flags.apply(cfg)
+	logging.Setup(cfg.LogFormat)
+	log.Printf("Starting ccdash %s", version.Get())
+
+	// Load custom per-model pricing before any services create pricing calculators
+	if cfg.PricingOverridesPath != "" {
+		if err := services.LoadPricingOverrides(cfg.PricingOverridesPath); err != nil {
+			log.Fatal("Failed to load pricing overrides:", err)
+		}
+		log.Printf("Loaded pricing overrides from %s", cfg.PricingOverridesPath)
+	}
+	services.SetNullModelFallback(cfg.NullModelFallback)
+	services.SetPlan(cfg.Plan)
+	services.SetWindowIncludeCacheTokens(cfg.WindowIncludeCacheTokens)
 
 	// Check if database exists and perform initial sync if needed
 	isNewDatabase := !cfg.DatabaseExists()
@@ -134,10 +230,10 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
-	defer db.Close()
 
 	tokenService := services.NewTokenService(db)
 	sessionService := services.NewSessionService(db)
+	sessionService.SetActiveTimeout(cfg.SessionActiveTimeout)
 	sessionWindowService := services.NewSessionWindowService(db)
 	p90PredictionService := services.NewP90PredictionService(db)
 	projectService := services.NewProjectService(db) // Phase 3: Add ProjectService
@@ -152,8 +248,9 @@ func main() {
 		log.Println("Starting initial log sync in background...")
 
 		// Run initialization using safe goroutine with panic recovery
-		middleware.SafeGoRoutineWithErrorCallback("initialization", func() error {
+		middleware.SafeGoRoutineWithErrorCallback(context.Background(), "initialization", func() error {
 			diffSyncService := services.NewDiffSyncService(db, tokenService, sessionService)
+			diffSyncService.SetProgressCallback(initService.UpdateProgress)
 			stats, err := diffSyncService.SyncAllLogs()
 			if err != nil {
 				log.Printf("Warning: Initial log sync failed: %v", err)
@@ -162,7 +259,7 @@ func main() {
 			
 			log.Printf("Initial sync completed: %d files processed, %d new lines",
 				stats.ProcessedFiles, stats.NewLines)
-			initService.CompleteInitialization(stats.ProcessedFiles, stats.NewLines)
+			initService.CompleteInitialization(stats.TotalFiles, stats.NewLines)
 			return nil
 		}, func(err error) {
 			initService.FailInitialization(err)
@@ -170,18 +267,65 @@ func main() {
 	}
 
 	// Start job executor
+	jobExecutor.SetMonitoring(cfg.StaleJobThreshold, cfg.JobQueueMonitorInterval)
+	jobExecutor.SetResourceLimits(models.ResourceLimits{
+		MemoryMB:   cfg.JobMemoryLimitMB,
+		CPUSeconds: int(cfg.JobCPUTimeLimit.Seconds()),
+	})
+	jobExecutor.SetMaxOutputBytes(cfg.MaxJobOutputBytes)
+	if len(cfg.JobEnvPassthrough) > 0 {
+		jobExecutor.SetEnvPassthrough(cfg.JobEnvPassthrough)
+	}
+	jobExecutor.SetAllowedRoots(cfg.JobAllowedRoots)
+	jobExecutor.SetMaxConcurrentPerProject(cfg.JobMaxConcurrentPerProject)
+	if cfg.CommandWhitelistPath != "" {
+		whitelist, err := services.NewCommandWhitelist(cfg.CommandWhitelistPath)
+		if err != nil {
+			log.Fatal("Failed to load command whitelist:", err)
+		}
+		jobExecutor.SetCommandWhitelist(whitelist)
+		log.Printf("Loaded command whitelist from %s", cfg.CommandWhitelistPath)
+	}
 	jobExecutor.Start()
-	defer jobExecutor.Stop()
 
 	// Start job scheduler
 	jobScheduler := services.NewJobScheduler(db, jobService, jobExecutor, sessionWindowService, cfg.JobSchedulerPollingInterval)
+	jobScheduler.SetRunGuards(cfg.JobSkipOverlappingRuns, cfg.JobMinRunInterval)
+	jobScheduler.SetBatchSize(cfg.JobSchedulerBatchSize)
 	jobScheduler.Start()
-	defer jobScheduler.Stop()
 
-	handler := handlers.NewHandler(tokenService, sessionService, sessionWindowService, p90PredictionService, projectService, jobService, jobExecutor) // Phase 2: Add JobService and JobExecutor
+	// Keep the dashboard current by syncing logs periodically
+	var syncScheduler *services.SyncScheduler
+	if cfg.SyncInterval > 0 {
+		syncScheduler = services.NewSyncScheduler(services.NewDiffSyncService(db, tokenService, sessionService), cfg.SyncInterval)
+		syncScheduler.Start()
+	}
+
+	// Sync a project shortly after its logs are written
+	var logWatcher *services.LogWatcher
+	if cfg.WatchLogs {
+		logWatcher = services.NewLogWatcher(services.NewDiffSyncService(db, tokenService, sessionService), cfg.ClaudeProjectsDirs, services.LOG_WATCH_DEBOUNCE)
+		if err := logWatcher.Start(); err != nil {
+			log.Printf("Warning: Failed to start log watcher: %v", err)
+			logWatcher = nil
+		}
+	}
+
+	// Notify a webhook when the token limit is projected to be hit soon
+	var exhaustionAlerts *services.ExhaustionAlertService
+	if cfg.ExhaustionWebhookURL != "" {
+		exhaustionAlerts = services.NewExhaustionAlertService(tokenService, cfg.ExhaustionWebhookURL, cfg.ExhaustionAlertThreshold, cfg.ExhaustionAlertCooldown)
+		exhaustionAlerts.Start(1 * time.Minute)
+	}
+
+	// Maintenance mode can be toggled at runtime via the admin endpoint
+	maintenanceMode := middleware.NewMaintenanceMode(cfg.MaintenanceMode, cfg.MaintenanceRetryAfter)
+
+	handler := handlers.NewHandler(tokenService, sessionService, sessionWindowService, p90PredictionService, projectService, jobService, jobExecutor, maintenanceMode) // Phase 2: Add JobService and JobExecutor
 
 	// Initialize authentication middleware
 	authMiddleware := middleware.NewAuthMiddleware()
+	handler.SetServerConfig(cfg, authMiddleware.IsAuthEnabled())
 
 	// Initialize rate limiting (60 requests per minute by default)
 	rateLimitRequests := 60
@@ -191,15 +335,21 @@ func main() {
 		}
 	}
 
-	r := gin.Default()
+	r := gin.New()
+	
+	// Assign each request an ID first, so the access log and error responses carry it
+	r.Use(middleware.RequestID())
+	r.Use(middleware.AccessLog(cfg.LogFormat), gin.Recovery())
 
 	// Apply global panic recovery middleware
 	r.Use(middleware.RecoveryMiddleware())
 
-	// Apply rate limiting globally (except for OPTIONS requests)
+	// Apply rate limiting per valid API key or client IP (except for OPTIONS requests).
+	// The limiter is created once so its buckets persist across requests.
+	rateLimit := middleware.RateLimitMiddleware(rateLimitRequests, authMiddleware.IsValidKey)
 	r.Use(func(c *gin.Context) {
 		if c.Request.Method != "OPTIONS" {
-			middleware.RateLimitMiddleware(rateLimitRequests)(c)
+			rateLimit(c)
 		} else {
 			c.Next()
 		}
@@ -229,11 +379,11 @@ func main() {
 			
 			// Handle preflight requests
 			if c.Request.Method == "OPTIONS" {
-				if origin != "" && isAllowedOrigin(origin, explicitlyAllowedOrigins) {
+				if origin != "" && isAllowedOrigin(origin, explicitlyAllowedOrigins, cfg.CORSAllowedPrivatePorts) {
 					c.Header("Access-Control-Allow-Origin", origin)
 					c.Header("Access-Control-Allow-Credentials", "true")
 					c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-					c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With, DNT, User-Agent, If-Modified-Since, Cache-Control, Range, X-API-Key")
+					c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With, DNT, User-Agent, If-Modified-Since, Cache-Control, Range, X-API-Key, X-Request-ID")
 					c.Header("Access-Control-Max-Age", "86400")
 					c.AbortWithStatus(204)
 					return
@@ -241,17 +391,17 @@ func main() {
 			}
 			
 			// Handle actual requests
-			if origin != "" && isAllowedOrigin(origin, explicitlyAllowedOrigins) {
+			if origin != "" && isAllowedOrigin(origin, explicitlyAllowedOrigins, cfg.CORSAllowedPrivatePorts) {
 				c.Header("Access-Control-Allow-Origin", origin)
 				c.Header("Access-Control-Allow-Credentials", "true")
-				c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Range")
+				c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Range, X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After")
 			}
 			
 			c.Next()
 		})
 		
 		log.Printf("CORS: Allowing explicit origins: %v", explicitlyAllowedOrigins)
-		log.Println("CORS: Also allowing private IP addresses (10.x.x.x, 172.16-31.x.x, 192.168.x.x, localhost) with strict port validation")
+		log.Printf("CORS: Also allowing private IP addresses (10.x.x.x, 172.16-31.x.x, 192.168.x.x, fc00::/7, fe80::/10, localhost) on ports %v", cfg.CORSAllowedPrivatePorts)
 	}
 
 	r.Use(func(c *gin.Context) {
@@ -262,6 +412,8 @@ func main() {
 	api := r.Group("/api")
 	// Apply authentication middleware to all API routes
 	api.Use(authMiddleware.Authenticate())
+	// Reject API calls with 503 while in maintenance mode (health and toggle stay accessible)
+	api.Use(maintenanceMode.Middleware())
 	{
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{
@@ -270,41 +422,85 @@ func main() {
 			})
 		})
 
+		api.GET("/health/ready", handler.GetReadiness)
+		api.GET("/version", handler.GetVersion)
+		api.GET("/config", handler.GetConfig)
+		api.GET("/admin/maintenance", handler.GetMaintenanceMode)
+		api.PUT("/admin/maintenance", handler.SetMaintenanceMode)
+		api.POST("/admin/whitelist/reload", handler.ReloadCommandWhitelist)
+		api.POST("/admin/recalculate-costs", handler.RecalculateCosts)
+		api.GET("/admin/recalculate-costs", handler.GetCostRecalculationStatus)
+		api.POST("/admin/recalculate-windows", handler.RecalculateWindows)
+		api.GET("/admin/session-windows/validate", handler.ValidateSessionWindows)
+		api.POST("/admin/repair/sessions", handler.RepairOrphanedSessions)
+		api.GET("/admin/integrity", handler.GetIntegrity)
+
 		api.GET("/initialization-status", handler.GetInitializationStatus)
 		api.GET("/token-usage", handler.GetTokenUsage)
-		api.GET("/sessions", handler.GetSessions)
+		api.GET("/token-usage/range", handler.GetTokenUsageInRange)
+		api.GET("/sessions", middleware.ETag(), handler.GetSessions)
+		api.GET("/sessions/search", handler.SearchMessages)
 		api.GET("/sessions/:id", handler.GetSessionDetails)
 		api.GET("/sessions/:id/activity", handler.GetSessionActivityReport)
+		api.GET("/sessions/:id/export", handler.ExportSession)
+		api.POST("/sessions/:id/tags", handler.AddSessionTag)
+		api.DELETE("/sessions/:id/tags/:tag", handler.RemoveSessionTag)
 		api.GET("/claude/sessions/recent", handler.GetRecentSessions)
 		api.GET("/claude/available-tokens", handler.GetAvailableTokens)
 		api.GET("/costs/current-month", handler.GetCurrentMonthCosts)
+		api.GET("/stats/daily", handler.GetDailyUsage)
+		api.GET("/stats/models", handler.GetModelUsageBreakdown)
+		api.GET("/stats/heatmap", handler.GetActivityHeatmap)
+		api.GET("/stats/projects/leaderboard", handler.GetProjectLeaderboard)
 		api.GET("/tasks", handler.GetTasks)
-		api.GET("/session-windows", handler.GetSessionWindows)
-		api.GET("/predictions/p90", handler.GetP90Predictions)
+		api.GET("/session-windows", middleware.ETag(), handler.GetSessionWindows)
+		api.GET("/session-windows/for-time", handler.GetSessionWindowForTime)
+		api.GET("/session-windows/active", handler.GetActiveSessionWindow)
+		api.GET("/session-windows/export.csv", handler.ExportSessionWindowsCSV)
+		api.GET("/session-windows/preview", handler.PreviewSessionWindows)
+		api.GET("/predictions/p90", middleware.ETag("predicted_at"), handler.GetP90Predictions) // predicted_at changes on every call
 		api.GET("/predictions/p90/project/:project", handler.GetP90PredictionsByProject)
 		api.GET("/predictions/burn-rate-history", handler.GetBurnRateHistory)
+		api.GET("/predictions/exhaustion", handler.GetProjectedExhaustion)
 		api.POST("/sync-logs", handler.SyncLogs)
 		
 		// Phase 3: Projects API endpoints
 		api.GET("/projects", handler.GetAllProjects)
+		api.POST("/projects/merge", handler.MergeProjects)
 		api.GET("/projects/:id", handler.GetProject)
 		api.PUT("/projects/:id", handler.UpdateProject)
 		api.DELETE("/projects/:id", handler.DeleteProject)
+		api.POST("/projects/:id/restore", handler.RestoreProject)
 		api.GET("/projects/:id/sessions", handler.GetProjectSessions)
+		api.GET("/projects/:id/stats", handler.GetProjectStats)
+		api.GET("/projects/:id/cost-stats", handler.GetProjectCostStats)
+		api.POST("/projects/:id/jobs/cancel-pending", handler.CancelProjectPendingJobs)
+		api.GET("/projects/:id/allowed-commands", handler.GetProjectAllowedCommands)
+		api.POST("/projects/:id/allowed-commands", handler.AddProjectAllowedCommand)
+		api.DELETE("/projects/:id/allowed-commands", handler.RemoveProjectAllowedCommand)
 		// Note: migrate-sessions endpoint removed - migration is handled automatically by DiffSyncService
 		
 		// Phase 2: Jobs API endpoints
 		api.POST("/jobs", handler.CreateJob)
+		api.POST("/jobs/schedule-preview", handler.PreviewJobSchedule)
 		api.GET("/jobs", handler.GetJobs)
+		api.GET("/jobs/stats", handler.GetJobStats)
+		api.GET("/jobs/summary", handler.GetJobSummary)
+		api.GET("/jobs/stale", handler.GetStaleJobs)
+		api.POST("/jobs/stale/cleanup", handler.CleanupStaleJobs)
 		api.GET("/jobs/:id", handler.GetJobByID)
+		api.GET("/jobs/:id/logs", handler.GetJobLogs)
 		api.POST("/jobs/:id/cancel", handler.CancelJob)
+		api.POST("/jobs/:id/rerun", handler.RerunJob)
 		api.DELETE("/jobs/:id", handler.DeleteJob)
 		api.GET("/jobs/queue/status", handler.GetJobQueueStatus)
+		api.POST("/jobs/queue/pause", handler.PauseJobQueue)
+		api.POST("/jobs/queue/resume", handler.ResumeJobQueue)
 	}
 
 	log.Printf("Server starting on %s:%s", cfg.ServerHost, cfg.ServerPort)
 	log.Printf("Database path: %s", cfg.DatabasePath)
-	log.Printf("Claude projects directory: %s", cfg.ClaudeProjectsDir)
+	log.Printf("Claude projects directories: %s", strings.Join(cfg.ClaudeProjectsDirs, ", "))
 	log.Printf("Frontend URL: %s", cfg.FrontendURL)
 	log.Printf("Job Scheduler polling interval: %v", cfg.JobSchedulerPollingInterval)
 	log.Printf("Job Executor worker count: %d", cfg.JobExecutorWorkerCount)
@@ -316,7 +512,59 @@ func main() {
 		log.Println("API Key authentication: DISABLED (development mode)")
 	}
 
-	if err := r.Run(cfg.ServerHost + ":" + cfg.ServerPort); err != nil {
-		log.Fatal("Failed to start server:", err)
+	if maintenanceMode.IsEnabled() {
+		log.Println("Maintenance mode: ENABLED")
 	}
+
+	srv := &http.Server{
+		Addr:    cfg.ServerHost + ":" + cfg.ServerPort,
+		Handler: r,
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// Wait for a shutdown signal, then stop everything in dependency order
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	log.Printf("Received %v, shutting down...", sig)
+
+	log.Println("Shutdown: stopping HTTP server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_HTTP_TIMEOUT)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
+
+	log.Println("Shutdown: stopping job scheduler")
+	jobScheduler.Stop()
+	if syncScheduler != nil {
+		syncScheduler.Stop()
+	}
+	if logWatcher != nil {
+		logWatcher.Stop()
+	}
+	if exhaustionAlerts != nil {
+		exhaustionAlerts.Stop()
+	}
+
+	log.Println("Shutdown: draining job executor")
+	jobExecutor.Drain(cfg.JobDrainTimeout)
+	jobExecutor.Stop()
+
+	log.Println("Shutdown: waiting for in-progress log sync")
+	if !services.WaitForSyncs(SHUTDOWN_SYNC_TIMEOUT) {
+		log.Println("Log sync still running after timeout; closing database anyway")
+	}
+
+	log.Println("Shutdown: closing database")
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close database: %v", err)
+	}
+
+	log.Println("Server stopped")
 }
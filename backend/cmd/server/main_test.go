@@ -0,0 +1,105 @@
+package main
+
+import (
+	"testing"
+
+	"ccdash-backend/internal/config"
+)
+
+func TestIsPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"192.168.1.10", true},
+		{"127.0.0.1", true},
+		{"::ffff:192.168.1.10", true},
+		{"172.32.0.1", false},
+		{"8.8.8.8", false},
+		{"::1", true},
+		{"fc00::1", true},
+		{"fd12:3456:789a::1", true},
+		{"fe80::1", true},
+		{"fe80::1%eth0", true},
+		{"febf::1", true},
+		{"fec0::1", false},
+		{"2001:4860:4860::8888", false},
+		{"not-an-ip", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPrivateIP(tt.ip); got != tt.want {
+				t.Errorf("isPrivateIP(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAllowedOrigin_PrivatePorts(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+		ports  []string
+		want   bool
+	}{
+		{"default frontend port", "http://192.168.1.10:3000", []string{"3000"}, true},
+		{"vite port not allowed by default", "http://192.168.1.10:5173", []string{"3000"}, false},
+		{"configured custom port", "http://192.168.1.10:5173", []string{"3000", "5173"}, true},
+		{"custom port on 10.x", "https://10.0.0.5:5173", []string{"5173"}, true},
+		{"port not in allowlist", "http://192.168.1.10:8080", []string{"3000", "5173"}, false},
+		{"no port", "http://192.168.1.10", []string{"5173"}, true},
+		{"non-http scheme", "ftp://192.168.1.10:5173", []string{"5173"}, false},
+		{"public IP on allowed port", "http://8.8.8.8:5173", []string{"5173"}, false},
+		{"explicit origin", "https://ccdash.example.com", nil, true},
+		{"IPv6 loopback", "http://[::1]:5173", []string{"3000"}, true},
+		{"IPv6 unique local", "http://[fd12:3456:789a::1]:3000", []string{"3000"}, true},
+		{"IPv6 link-local", "http://[fe80::1]:3000", []string{"3000"}, true},
+		{"IPv6 private on port not in allowlist", "http://[fd12:3456:789a::1]:8080", []string{"3000"}, false},
+		{"IPv6 public", "http://[2001:4860:4860::8888]:3000", []string{"3000"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := isAllowedOrigin(tt.origin, []string{"https://ccdash.example.com"}, tt.ports)
+			if got != tt.want {
+				t.Errorf("isAllowedOrigin(%q, ports=%v) = %v, want %v", tt.origin, tt.ports, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseServerFlags(t *testing.T) {
+	flags, err := parseServerFlags([]string{"-db", "/data/work.db", "-port", "6061", "-config", "work.env"})
+	if err != nil {
+		t.Fatalf("parseServerFlags failed: %v", err)
+	}
+
+	cfg := &config.Config{DatabasePath: "/home/me/.ccdash/ccdash.db", DatabaseDir: "/home/me/.ccdash", ServerPort: "6060"}
+	flags.apply(cfg)
+	if cfg.DatabasePath != "/data/work.db" || cfg.DatabaseDir != "/data" || cfg.ServerPort != "6061" {
+		t.Errorf("Expected flags to override the config, got %+v", cfg)
+	}
+	if flags.configPath != "work.env" {
+		t.Errorf("Expected config path work.env, got %q", flags.configPath)
+	}
+
+	// Without flags the environment-based config is kept
+	flags, err = parseServerFlags(nil)
+	if err != nil {
+		t.Fatalf("parseServerFlags failed: %v", err)
+	}
+	cfg = &config.Config{DatabasePath: "/home/me/.ccdash/ccdash.db", DatabaseDir: "/home/me/.ccdash", ServerPort: "6060"}
+	flags.apply(cfg)
+	if cfg.DatabasePath != "/home/me/.ccdash/ccdash.db" || cfg.ServerPort != "6060" {
+		t.Errorf("Expected config to be unchanged, got %+v", cfg)
+	}
+
+	if _, err := parseServerFlags([]string{"-port", "70000"}); err == nil {
+		t.Error("Expected an error for an out-of-range port")
+	}
+}
@@ -1,10 +1,15 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
+
+	"ccdash-backend/internal/logging"
+	"ccdash-backend/internal/models"
 )
 
 type Config struct {
@@ -15,9 +20,66 @@ type Config struct {
 	FrontendURL      string
 	ClaudeProjectsDir string
 	
+	// Claude projects directories scanned by sync (default: ClaudeProjectsDir alone)
+	ClaudeProjectsDirs []string
+	
+	// Log output format ("text" or "json")
+	LogFormat string
+	
+	// Ports allowed for CORS origins on private IP addresses
+	CORSAllowedPrivatePorts []string
+	
+	// Files modified within this grace are still being written; their unterminated last line is deferred
+	SyncBusyGrace time.Duration
+	
+	// Interval between automatic log syncs (0 disables them)
+	SyncInterval time.Duration
+	
+	// Sync a project as soon as its logs are written
+	WatchLogs bool
+	
+	// Inactivity after which a session is no longer considered active
+	SessionActiveTimeout time.Duration
+	
+	// Optional JSON file with per-model pricing overrides
+	PricingOverridesPath string
+	
+	// Model used to cost assistant messages without a model ("session" or a model name)
+	NullModelFallback string
+	
+	// Claude plan new session windows are rated against
+	Plan string
+	
+	// Count cache tokens in session window total_tokens
+	WindowIncludeCacheTokens bool
+	
 	// Job Scheduler configuration
 	JobSchedulerPollingInterval time.Duration
+	JobSchedulerBatchSize       int
 	JobExecutorWorkerCount      int
+	JobMaxConcurrentPerProject  int
+	JobSkipOverlappingRuns      bool
+	JobMinRunInterval           time.Duration
+	JobDrainTimeout             time.Duration
+	StaleJobThreshold           time.Duration
+	JobQueueMonitorInterval     time.Duration
+	JobMemoryLimitMB            int
+	JobCPUTimeLimit             time.Duration
+	MaxJobOutputBytes           int
+	JobEnvPassthrough           []string // Backend environment variables passed to jobs; nil keeps the default list
+	JobAllowedRoots             []string // Directories jobs may run under; empty allows any directory
+	
+	// Optional file of extra commands that skip the safety check, reloadable at runtime
+	CommandWhitelistPath string
+
+	// Token exhaustion alerts
+	ExhaustionWebhookURL     string
+	ExhaustionAlertThreshold time.Duration
+	ExhaustionAlertCooldown  time.Duration
+
+	// Maintenance mode configuration
+	MaintenanceMode       bool
+	MaintenanceRetryAfter time.Duration
 }
 
 // GetConfig returns the application configuration based on environment variables
@@ -55,6 +117,31 @@ func GetConfig() (*Config, error) {
 		config.FrontendURL = "http://localhost:3000"
 	}
 
+	// Log format (default: text)
+	config.LogFormat = logging.FormatText
+	if logFormat := os.Getenv("LOG_FORMAT"); logFormat != "" {
+		if !logging.IsValidFormat(logFormat) {
+			return nil, fmt.Errorf("invalid LOG_FORMAT %q: use text or json", logFormat)
+		}
+		config.LogFormat = logFormat
+	}
+
+	// CORS ports for private IP origins (default: 3000, the frontend dev server)
+	config.CORSAllowedPrivatePorts = []string{"3000"}
+	if ports := os.Getenv("CORS_ALLOWED_PRIVATE_PORTS"); ports != "" {
+		config.CORSAllowedPrivatePorts = nil
+		for _, port := range strings.Split(ports, ",") {
+			port = strings.TrimSpace(port)
+			if port == "" {
+				continue
+			}
+			if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				return nil, fmt.Errorf("invalid port in CORS_ALLOWED_PRIVATE_PORTS: %q", port)
+			}
+			config.CORSAllowedPrivatePorts = append(config.CORSAllowedPrivatePorts, port)
+		}
+	}
+
 	// Claude projects directory
 	if claudeDir := os.Getenv("CLAUDE_PROJECTS_DIR"); claudeDir != "" {
 		config.ClaudeProjectsDir = claudeDir
@@ -67,6 +154,79 @@ func GetConfig() (*Config, error) {
 		config.ClaudeProjectsDir = filepath.Join(homeDir, ".claude", "projects")
 	}
 
+	// Several projects directories can be scanned, e.g. personal and work logs
+	config.ClaudeProjectsDirs = []string{config.ClaudeProjectsDir}
+	if claudeDirs := os.Getenv("CLAUDE_PROJECTS_DIRS"); claudeDirs != "" {
+		config.ClaudeProjectsDirs = nil
+		for _, dir := range strings.Split(claudeDirs, ",") {
+			dir = strings.TrimSpace(dir)
+			if dir == "" {
+				continue
+			}
+			config.ClaudeProjectsDirs = append(config.ClaudeProjectsDirs, dir)
+		}
+		if len(config.ClaudeProjectsDirs) == 0 {
+			return nil, fmt.Errorf("CLAUDE_PROJECTS_DIRS contains no directories")
+		}
+	}
+
+	// Files modified more recently than this may be mid-write (default: 2 seconds)
+	config.SyncBusyGrace = 2 * time.Second
+	if busyGrace := os.Getenv("SYNC_BUSY_GRACE"); busyGrace != "" {
+		duration, err := time.ParseDuration(busyGrace)
+		if err != nil {
+			return nil, err
+		}
+		config.SyncBusyGrace = duration
+	}
+
+	// Sessions without messages for this long are inactive (default: 30 minutes)
+	config.SessionActiveTimeout = 30 * time.Minute
+	if activeTimeout := os.Getenv("SESSION_ACTIVE_TIMEOUT"); activeTimeout != "" {
+		duration, err := time.ParseDuration(activeTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("invalid SESSION_ACTIVE_TIMEOUT %q: must be positive", activeTimeout)
+		}
+		config.SessionActiveTimeout = duration
+	}
+
+	// Logs are synced automatically at this interval (default: 0, disabled)
+	if syncInterval := os.Getenv("SYNC_INTERVAL"); syncInterval != "" {
+		duration, err := time.ParseDuration(syncInterval)
+		if err != nil {
+			return nil, err
+		}
+		if duration < 0 {
+			return nil, fmt.Errorf("invalid SYNC_INTERVAL %q: must not be negative", syncInterval)
+		}
+		config.SyncInterval = duration
+	}
+
+	// Watch the projects directories for log writes (default: false)
+	config.WatchLogs = os.Getenv("WATCH_LOGS") == "true"
+
+	// Pricing overrides (default: none, use built-in rates)
+	config.PricingOverridesPath = os.Getenv("CCDASH_PRICING_OVERRIDES_PATH")
+
+	// Null-model cost fallback (default: disabled, such messages cost nothing)
+	config.NullModelFallback = os.Getenv("CCDASH_NULL_MODEL_FALLBACK")
+
+	// Claude plan (default: pro)
+	config.Plan = models.PlanPro
+	if plan := os.Getenv("CCDASH_PLAN"); plan != "" {
+		plan = strings.ToLower(strings.TrimSpace(plan))
+		if !models.IsValidPlan(plan) {
+			return nil, fmt.Errorf("invalid CCDASH_PLAN %q: use %s", plan, strings.Join(models.Plans, ", "))
+		}
+		config.Plan = plan
+	}
+
+	// Cache tokens in window totals (default: false, input and output tokens only)
+	config.WindowIncludeCacheTokens = os.Getenv("WINDOW_INCLUDE_CACHE_TOKENS") == "true"
+
 	// Job Scheduler configuration
 	// Polling interval (default: 1 minute)
 	if pollingInterval := os.Getenv("JOB_SCHEDULER_POLLING_INTERVAL"); pollingInterval != "" {
@@ -90,6 +250,167 @@ func GetConfig() (*Config, error) {
 		config.JobExecutorWorkerCount = 3
 	}
 
+	// Jobs of one project running at once (default: 0, unlimited)
+	if maxPerProject := os.Getenv("JOB_MAX_CONCURRENT_PER_PROJECT"); maxPerProject != "" {
+		max, err := strconv.Atoi(maxPerProject)
+		if err != nil {
+			return nil, err
+		}
+		if max < 0 {
+			return nil, fmt.Errorf("invalid JOB_MAX_CONCURRENT_PER_PROJECT %q: must not be negative", maxPerProject)
+		}
+		config.JobMaxConcurrentPerProject = max
+	}
+
+	// Maximum number of due scheduled jobs queued per scheduler tick (default: 10)
+	config.JobSchedulerBatchSize = 10
+	if batchSize := os.Getenv("JOB_SCHEDULER_BATCH_SIZE"); batchSize != "" {
+		size, err := strconv.Atoi(batchSize)
+		if err != nil {
+			return nil, err
+		}
+		if size < 1 {
+			return nil, fmt.Errorf("invalid JOB_SCHEDULER_BATCH_SIZE %q: must be at least 1", batchSize)
+		}
+		config.JobSchedulerBatchSize = size
+	}
+
+	// Skip starting a job while an identical one is running (default: false)
+	config.JobSkipOverlappingRuns = os.Getenv("JOB_SKIP_OVERLAPPING_RUNS") == "true"
+
+	// Minimum interval between starts of identical jobs (default: 0, disabled)
+	if minInterval := os.Getenv("JOB_MIN_RUN_INTERVAL"); minInterval != "" {
+		duration, err := time.ParseDuration(minInterval)
+		if err != nil {
+			return nil, err
+		}
+		config.JobMinRunInterval = duration
+	}
+
+	// How long running jobs may finish on shutdown before being interrupted (default: 30 seconds)
+	config.JobDrainTimeout = 30 * time.Second
+	if drainTimeout := os.Getenv("JOB_DRAIN_TIMEOUT"); drainTimeout != "" {
+		duration, err := time.ParseDuration(drainTimeout)
+		if err != nil {
+			return nil, err
+		}
+		config.JobDrainTimeout = duration
+	}
+
+	// Untracked running jobs older than this (or the execution timeout, if longer) are stale (default: 30 minutes)
+	config.StaleJobThreshold = 30 * time.Minute
+	if staleThreshold := os.Getenv("JOB_STALE_THRESHOLD"); staleThreshold != "" {
+		duration, err := time.ParseDuration(staleThreshold)
+		if err != nil {
+			return nil, err
+		}
+		config.StaleJobThreshold = duration
+	}
+
+	// How often the executor polls for pending and stale jobs (default: 10 seconds)
+	config.JobQueueMonitorInterval = 10 * time.Second
+	if monitorInterval := os.Getenv("JOB_QUEUE_MONITOR_INTERVAL"); monitorInterval != "" {
+		duration, err := time.ParseDuration(monitorInterval)
+		if err != nil {
+			return nil, err
+		}
+		config.JobQueueMonitorInterval = duration
+	}
+
+	// Default per-job virtual memory limit in MB (default: 0, disabled)
+	if memoryLimit := os.Getenv("JOB_MEMORY_LIMIT_MB"); memoryLimit != "" {
+		limit, err := strconv.Atoi(memoryLimit)
+		if err != nil {
+			return nil, err
+		}
+		config.JobMemoryLimitMB = limit
+	}
+
+	// Default per-job CPU time limit (default: 30 minutes, matching the job timeout; 0 disables)
+	config.JobCPUTimeLimit = 30 * time.Minute
+	if cpuLimit := os.Getenv("JOB_CPU_TIME_LIMIT"); cpuLimit != "" {
+		duration, err := time.ParseDuration(cpuLimit)
+		if err != nil {
+			return nil, err
+		}
+		config.JobCPUTimeLimit = duration
+	}
+
+	// Bytes of each job output stream kept; older output is dropped (default: 5 MB, 0 keeps everything)
+	config.MaxJobOutputBytes = 5 * 1024 * 1024
+	if maxOutput := os.Getenv("JOB_MAX_OUTPUT_BYTES"); maxOutput != "" {
+		maxBytes, err := strconv.Atoi(maxOutput)
+		if err != nil {
+			return nil, err
+		}
+		if maxBytes < 0 {
+			return nil, fmt.Errorf("invalid JOB_MAX_OUTPUT_BYTES %q: must not be negative", maxOutput)
+		}
+		config.MaxJobOutputBytes = maxBytes
+	}
+
+	// Backend environment variables passed to jobs (default: a minimal list, secrets are stripped)
+	if passthrough := os.Getenv("JOB_ENV_PASSTHROUGH"); passthrough != "" {
+		for _, name := range strings.Split(passthrough, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			config.JobEnvPassthrough = append(config.JobEnvPassthrough, name)
+		}
+	}
+
+	// Jobs only run in project directories under these roots (default: none, any directory)
+	if allowedRoots := os.Getenv("JOB_ALLOWED_ROOTS"); allowedRoots != "" {
+		for _, root := range strings.Split(allowedRoots, ",") {
+			root = strings.TrimSpace(root)
+			if root == "" {
+				continue
+			}
+			config.JobAllowedRoots = append(config.JobAllowedRoots, root)
+		}
+	}
+
+	// Command whitelist file (default: none, built-in safe commands only)
+	config.CommandWhitelistPath = os.Getenv("CCDASH_COMMAND_WHITELIST_PATH")
+
+	// Exhaustion alert webhook (default: none, alerts disabled)
+	config.ExhaustionWebhookURL = os.Getenv("CCDASH_EXHAUSTION_WEBHOOK_URL")
+
+	// Alert when exhaustion is projected within this duration (default: 30 minutes)
+	config.ExhaustionAlertThreshold = 30 * time.Minute
+	if threshold := os.Getenv("CCDASH_EXHAUSTION_ALERT_THRESHOLD"); threshold != "" {
+		duration, err := time.ParseDuration(threshold)
+		if err != nil {
+			return nil, err
+		}
+		config.ExhaustionAlertThreshold = duration
+	}
+
+	// Minimum time between alerts (default: 1 hour)
+	config.ExhaustionAlertCooldown = 1 * time.Hour
+	if cooldown := os.Getenv("CCDASH_EXHAUSTION_ALERT_COOLDOWN"); cooldown != "" {
+		duration, err := time.ParseDuration(cooldown)
+		if err != nil {
+			return nil, err
+		}
+		config.ExhaustionAlertCooldown = duration
+	}
+
+	// Maintenance mode (default: disabled, can be toggled at runtime)
+	config.MaintenanceMode = os.Getenv("CCDASH_MAINTENANCE_MODE") == "true"
+
+	// Retry-After advertised during maintenance (default: 5 minutes)
+	if retryAfter := os.Getenv("CCDASH_MAINTENANCE_RETRY_AFTER"); retryAfter != "" {
+		duration, err := time.ParseDuration(retryAfter)
+		if err != nil {
+			return nil, err
+		}
+		config.MaintenanceRetryAfter = duration
+	} else {
+		config.MaintenanceRetryAfter = 5 * time.Minute
+	}
+
 	return config, nil
 }
 
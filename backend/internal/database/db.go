@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"log/slog"
 
 	"ccdash-backend/internal/config"
 	"ccdash-backend/internal/services"
@@ -115,8 +116,17 @@ func createTables(db *sql.DB) error {
 		// Add total_cost column to existing session_windows table if it doesn't exist
 		`ALTER TABLE session_windows ADD COLUMN IF NOT EXISTS total_cost DOUBLE DEFAULT 0.0`,
 
+		// Add cache token columns to session_windows so totals can match the cost basis
+		`ALTER TABLE session_windows ADD COLUMN IF NOT EXISTS total_cache_creation_tokens INTEGER DEFAULT 0`,
+		`ALTER TABLE session_windows ADD COLUMN IF NOT EXISTS total_cache_read_tokens INTEGER DEFAULT 0`,
+
+		// Record the plan in effect when each window was created so historical usage rates keep their limit
+		`ALTER TABLE session_windows ADD COLUMN IF NOT EXISTS plan TEXT`,
+
 		`CREATE INDEX IF NOT EXISTS idx_sessions_project_name ON sessions (project_name)`,
-		`CREATE INDEX IF NOT EXISTS idx_sessions_project_id ON sessions (project_id)`,
+		// sessions.project_id is not indexed: DuckDB cannot update an indexed column of a row that
+		// messages reference, which would block moving sessions between projects in a transaction
+		`DROP INDEX IF EXISTS idx_sessions_project_id`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_start_time ON sessions (start_time)`,
 		`CREATE INDEX IF NOT EXISTS idx_sessions_status ON sessions (status)`,
 		`CREATE INDEX IF NOT EXISTS idx_messages_session_id ON messages (session_id)`,
@@ -181,6 +191,38 @@ func createTables(db *sql.DB) error {
 		// Add schedule_params column to existing jobs table if it doesn't exist
 		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS schedule_params TEXT`,
 		
+		// Add cost column so session/window/monthly costs are a simple SUM
+		`ALTER TABLE messages ADD COLUMN IF NOT EXISTS cost DOUBLE DEFAULT 0.0`,
+		
+		// Add output_rules column for per-job output match rules
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS output_rules TEXT`,
+		
+		// Add webhook_url column for job completion notifications
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS webhook_url TEXT`,
+		
+		// Add per-job resource limits (JSON) applied with setrlimit on Unix
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS resource_limits TEXT`,
+		
+		// Add per-job environment variables (JSON) set on top of the passthrough allowlist
+		`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS env TEXT`,
+		
+		// Free-form session tags, unique per session
+		`CREATE TABLE IF NOT EXISTS session_tags (
+			session_id VARCHAR NOT NULL,
+			tag VARCHAR NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (session_id, tag)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_session_tags_tag ON session_tags(tag)`,
+		
+		// Commands whitelisted for a single project on top of the global whitelist
+		`CREATE TABLE IF NOT EXISTS project_allowed_commands (
+			project_id VARCHAR NOT NULL,
+			command VARCHAR NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (project_id, command)
+		)`,
+		
 		// Phase 3: Add foreign key constraint from sessions to projects
 		// Note: In DuckDB, foreign key constraints must be added during table creation or with specific ALTER syntax
 		// We'll check if the constraint exists and add it if needed
@@ -197,6 +239,40 @@ func createTables(db *sql.DB) error {
 		return fmt.Errorf("failed to add foreign key constraints: %w", err)
 	}
 
+	if err := addWindowRangeUniqueIndex(db); err != nil {
+		return fmt.Errorf("failed to add session window range index: %w", err)
+	}
+
+	return nil
+}
+
+// addWindowRangeUniqueIndex makes each window time range unique so concurrent syncs cannot
+// create the same window twice. Databases that already hold duplicate windows keep working
+// without the index until the windows are recalculated.
+func addWindowRangeUniqueIndex(db *sql.DB) error {
+	var duplicateCount int
+	err := db.QueryRow(`
+		SELECT COUNT(*) FROM (
+			SELECT window_start, window_end
+			FROM session_windows
+			GROUP BY window_start, window_end
+			HAVING COUNT(*) > 1
+		)
+	`).Scan(&duplicateCount)
+	if err != nil {
+		return fmt.Errorf("failed to check for duplicate session windows: %w", err)
+	}
+
+	if duplicateCount > 0 {
+		slog.Warn("Duplicate session windows found, skipping unique range index; recalculate windows to fix",
+			"duplicate_ranges", duplicateCount)
+		return nil
+	}
+
+	if _, err := db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_session_windows_range ON session_windows(window_start, window_end)`); err != nil {
+		return fmt.Errorf("failed to create unique index: %w", err)
+	}
+
 	return nil
 }
 
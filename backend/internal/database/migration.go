@@ -2,6 +2,8 @@ package database
 
 import (
 	"ccdash-backend/internal/migration"
+	"ccdash-backend/internal/models"
+	"ccdash-backend/internal/version"
 	"database/sql"
 	"embed"
 	"fmt"
@@ -28,4 +30,35 @@ func RunMigrations(db *sql.DB, migrationsFS embed.FS) error {
 	}
 	
 	return engine.Up()
-}
\ No newline at end of file
+}
+
+// GetVersionInfo reports the running build and the current migration version, and whether the schema is dirty or behind
+func GetVersionInfo(db *sql.DB, migrationsFS embed.FS, build version.Info) (*models.VersionInfo, error) {
+	engine, err := InitializeMigrationEngine(db, migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+	
+	status, err := engine.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %w", err)
+	}
+	
+	info := &models.VersionInfo{
+		AppVersion:        build.Version,
+		Commit:            build.Commit,
+		BuildTime:         build.BuildTime,
+		CommitTime:        build.CommitTime,
+		GoVersion:         build.GoVersion,
+		SchemaVersion:     status.CurrentVersion,
+		LatestVersion:     status.CurrentVersion,
+		Dirty:             status.Dirty,
+		Behind:            len(status.Pending) > 0,
+		PendingMigrations: len(status.Pending),
+	}
+	if len(status.Pending) > 0 {
+		info.LatestVersion = status.Pending[len(status.Pending)-1].Version
+	}
+	
+	return info, nil
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ccdash-backend/internal/models"
+)
+
+// Response formats accepted by the format query parameter of the analytics endpoints
+const (
+	responseFormatJSON = "json"
+	responseFormatCSV  = "csv"
+)
+
+// csvTable is the tabular form of an analytics response
+type csvTable struct {
+	Header []string
+	Rows   [][]string
+}
+
+// responseFormat reads the format query parameter (json by default).
+// It responds with 400 and returns false for an unsupported format.
+func responseFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", responseFormatJSON)
+	if format != responseFormatJSON && format != responseFormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid format parameter",
+			"valid_values": []string{responseFormatJSON, responseFormatCSV},
+		})
+		return "", false
+	}
+	return format, true
+}
+
+// respondFormatted writes body as JSON, or the table built by toTable as a CSV
+// download named <name>-<date>.csv when format is csv
+func respondFormatted(c *gin.Context, format, name string, body interface{}, toTable func() csvTable) {
+	if format != responseFormatCSV {
+		c.JSON(http.StatusOK, body)
+		return
+	}
+
+	table := toTable()
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, name, time.Now().UTC().Format("20060102")))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(table.Header)
+	w.WriteAll(table.Rows)
+	if err := w.Error(); err != nil {
+		log.Printf("Failed to write %s CSV: %v", name, err)
+	}
+}
+
+func formatCSVFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// tokenUsageRangeTable has one row per day when usage is grouped by day, otherwise a single totals row
+func tokenUsageRangeTable(usage *models.TokenUsageRange) csvTable {
+	counts := func(input, output, cacheCreation, cacheRead, total int, cost float64, messages int) []string {
+		return []string{
+			strconv.Itoa(input),
+			strconv.Itoa(output),
+			strconv.Itoa(cacheCreation),
+			strconv.Itoa(cacheRead),
+			strconv.Itoa(total),
+			formatCSVFloat(cost),
+			strconv.Itoa(messages),
+		}
+	}
+	countHeader := []string{"input_tokens", "output_tokens", "cache_creation_input_tokens", "cache_read_input_tokens", "total_tokens", "total_cost", "total_messages"}
+
+	if usage.Daily != nil {
+		table := csvTable{Header: append([]string{"date"}, countHeader...)}
+		for _, day := range usage.Daily {
+			row := counts(day.InputTokens, day.OutputTokens, day.CacheCreationInputTokens, day.CacheReadInputTokens,
+				day.TotalTokens, day.TotalCost, day.TotalMessages)
+			table.Rows = append(table.Rows, append([]string{day.Date}, row...))
+		}
+		return table
+	}
+
+	row := counts(usage.InputTokens, usage.OutputTokens, usage.CacheCreationInputTokens, usage.CacheReadInputTokens,
+		usage.TotalTokens, usage.TotalCost, usage.TotalMessages)
+	return csvTable{
+		Header: append([]string{"from", "to"}, countHeader...),
+		Rows: [][]string{
+			append([]string{usage.From.Format(time.RFC3339), usage.To.Format(time.RFC3339)}, row...),
+		},
+	}
+}
+
+// monthlyCostTable lists the cost by model, then by project, sorted by name
+func monthlyCostTable(summary *models.MonthlyCostSummary) csvTable {
+	table := csvTable{Header: []string{"group", "name", "cost"}}
+	for _, group := range []struct {
+		name  string
+		costs map[string]float64
+	}{
+		{"model", summary.ByModel},
+		{"project", summary.ByProject},
+	} {
+		names := make([]string, 0, len(group.costs))
+		for name := range group.costs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			table.Rows = append(table.Rows, []string{group.name, name, formatCSVFloat(group.costs[name])})
+		}
+	}
+	return table
+}
+
+func dailyUsageTable(series []models.DailyUsage) csvTable {
+	table := csvTable{Header: []string{"date", "input_tokens", "output_tokens", "total_tokens", "cost", "message_count"}}
+	for _, day := range series {
+		table.Rows = append(table.Rows, []string{
+			day.Date,
+			strconv.Itoa(day.InputTokens),
+			strconv.Itoa(day.OutputTokens),
+			strconv.Itoa(day.TotalTokens),
+			formatCSVFloat(day.Cost),
+			strconv.Itoa(day.MessageCount),
+		})
+	}
+	return table
+}
+
+func modelUsageTable(breakdown []models.ModelUsage) csvTable {
+	table := csvTable{Header: []string{"model", "input_tokens", "output_tokens", "cache_creation_input_tokens",
+		"cache_read_input_tokens", "total_tokens", "message_count", "total_cost", "cost_share", "token_share"}}
+	for _, usage := range breakdown {
+		table.Rows = append(table.Rows, []string{
+			usage.Model,
+			strconv.Itoa(usage.InputTokens),
+			strconv.Itoa(usage.OutputTokens),
+			strconv.Itoa(usage.CacheCreationInputTokens),
+			strconv.Itoa(usage.CacheReadInputTokens),
+			strconv.Itoa(usage.TotalTokens),
+			strconv.Itoa(usage.MessageCount),
+			formatCSVFloat(usage.TotalCost),
+			formatCSVFloat(usage.CostShare),
+			formatCSVFloat(usage.TokenShare),
+		})
+	}
+	return table
+}
+
+func burnRateHistoryTable(history []models.BurnRatePoint) csvTable {
+	table := csvTable{Header: []string{"timestamp", "tokens_per_hour"}}
+	for _, point := range history {
+		table.Rows = append(table.Rows, []string{point.Timestamp.UTC().Format(time.RFC3339), strconv.Itoa(point.TokensPerHour)})
+	}
+	return table
+}
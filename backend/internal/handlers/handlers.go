@@ -1,18 +1,31 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 	
 	"github.com/gin-gonic/gin"
+	"ccdash-backend/internal/config"
+	"ccdash-backend/internal/database"
+	"ccdash-backend/internal/middleware"
 	"ccdash-backend/internal/models"
 	"ccdash-backend/internal/services"
+	"ccdash-backend/internal/version"
+	"ccdash-backend/migrations"
 )
 
+
+// READINESS_DB_TIMEOUT bounds the database check of the readiness probe
+const READINESS_DB_TIMEOUT = 2 * time.Second
+
 type Handler struct {
 	tokenService        *services.TokenService
 	sessionService      *services.SessionService
@@ -21,9 +34,11 @@ type Handler struct {
 	projectService      *services.ProjectService // Phase 3: Add ProjectService
 	jobService          *services.JobService     // Phase 2: Add JobService
 	jobExecutor         *services.JobExecutor    // Phase 2: Add JobExecutor
+	maintenanceMode     *middleware.MaintenanceMode
+	serverConfig        models.ServerConfig
 }
 
-func NewHandler(tokenService *services.TokenService, sessionService *services.SessionService, sessionWindowService *services.SessionWindowService, p90PredictionService *services.P90PredictionService, projectService *services.ProjectService, jobService *services.JobService, jobExecutor *services.JobExecutor) *Handler {
+func NewHandler(tokenService *services.TokenService, sessionService *services.SessionService, sessionWindowService *services.SessionWindowService, p90PredictionService *services.P90PredictionService, projectService *services.ProjectService, jobService *services.JobService, jobExecutor *services.JobExecutor, maintenanceMode *middleware.MaintenanceMode) *Handler {
 	return &Handler{
 		tokenService:        tokenService,
 		sessionService:      sessionService,
@@ -32,9 +47,72 @@ func NewHandler(tokenService *services.TokenService, sessionService *services.Se
 		projectService:      projectService, // Phase 3: Initialize ProjectService
 		jobService:          jobService,     // Phase 2: Initialize JobService
 		jobExecutor:         jobExecutor,    // Phase 2: Initialize JobExecutor
+		maintenanceMode:     maintenanceMode,
+	}
+}
+
+// GetVersion returns the app version and the database migration version
+func (h *Handler) GetVersion(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	
+	info, err := database.GetVersionInfo(db, migrations.FS, version.Get())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get version info",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, info)
+}
+
+// SetServerConfig sets the settings returned by GetConfig. Call before serving requests.
+func (h *Handler) SetServerConfig(cfg *config.Config, authEnabled bool) {
+	plan := services.CurrentPlan()
+	// Only non-secret settings; never add the API key or JWT secret here
+	h.serverConfig = models.ServerConfig{
+		Version:                     version.Version,
+		Plan:                        plan,
+		UsageLimit:                  services.UsageLimitForPlan(plan),
+		WindowDurationSeconds:       int64(services.WINDOW_DURATION.Seconds()),
+		JobWorkerCount:              cfg.JobExecutorWorkerCount,
+		SchedulerPollingSeconds:     int64(cfg.JobSchedulerPollingInterval.Seconds()),
+		SessionActiveTimeoutSeconds: int64(cfg.SessionActiveTimeout.Seconds()),
+		AuthEnabled:                 authEnabled,
 	}
 }
 
+// GetConfig returns the non-secret server settings so the frontend can configure itself
+func (h *Handler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, h.serverConfig)
+}
+
+// GetReadiness checks the database connection, the claude CLI and initialization.
+// Responds with 503 when the database is unreachable, so it can be used as a readiness probe.
+func (h *Handler) GetReadiness(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	
+	readiness := models.Readiness{
+		Ready:           true,
+		Database:        "ok",
+		ClaudeAvailable: services.IsClaudeCodeAvailable(),
+		Initialization:  string(services.GetGlobalInitializationService().GetState().Status),
+	}
+	
+	ctx, cancel := context.WithTimeout(c.Request.Context(), READINESS_DB_TIMEOUT)
+	defer cancel()
+	var one int
+	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		readiness.Ready = false
+		readiness.Database = err.Error()
+		c.JSON(http.StatusServiceUnavailable, readiness)
+		return
+	}
+	
+	c.JSON(http.StatusOK, readiness)
+}
+
 func (h *Handler) GetTokenUsage(c *gin.Context) {
 	usage, err := h.tokenService.GetCurrentTokenUsage()
 	if err != nil {
@@ -48,8 +126,144 @@ func (h *Handler) GetTokenUsage(c *gin.Context) {
 	c.JSON(http.StatusOK, usage)
 }
 
+// GetTokenUsageInRange returns token usage for an arbitrary time range.
+// format=csv returns the daily rows (group_by=day) or the totals as CSV.
+func (h *Handler) GetTokenUsageInRange(c *gin.Context) {
+	format, ok := responseFormat(c)
+	if !ok {
+		return
+	}
+	
+	fromStr := c.Query("from")
+	toStr := c.Query("to")
+	if fromStr == "" || toStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Both from and to query parameters are required",
+		})
+		return
+	}
+	
+	from, err := time.Parse(time.RFC3339, fromStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid from parameter, expected RFC3339",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	to, err := time.Parse(time.RFC3339, toStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid to parameter, expected RFC3339",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be before to",
+		})
+		return
+	}
+	
+	if to.Sub(from) > services.MAX_TOKEN_USAGE_RANGE {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Time range is too large",
+			"max_days": int(services.MAX_TOKEN_USAGE_RANGE.Hours() / 24),
+		})
+		return
+	}
+	
+	groupBy := c.Query("group_by")
+	if groupBy != "" && groupBy != "day" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid group_by parameter",
+			"valid_values": []string{"day"},
+		})
+		return
+	}
+	
+	usage, err := h.tokenService.GetTokenUsageInRange(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get token usage for range",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if groupBy == "day" {
+		daily, err := h.tokenService.GetDailyTokenUsageInRange(from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to get daily token usage",
+				"details": err.Error(),
+			})
+			return
+		}
+		if daily == nil {
+			daily = []models.DailyTokenUsage{}
+		}
+		usage.Daily = daily
+	}
+	
+	respondFormatted(c, format, "token-usage", usage, func() csvTable {
+		return tokenUsageRangeTable(usage)
+	})
+}
+
 func (h *Handler) GetSessions(c *gin.Context) {
-	sessions, err := h.sessionService.GetAllSessions()
+	filters := models.SessionFilters{}
+	
+	if fromStr := c.Query("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid from parameter, expected RFC3339",
+				"details": err.Error(),
+			})
+			return
+		}
+		filters.From = &from
+	}
+	
+	if toStr := c.Query("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid to parameter, expected RFC3339",
+				"details": err.Error(),
+			})
+			return
+		}
+		filters.To = &to
+	}
+	
+	if status := c.Query("status"); status != "" {
+		filters.Status = &status
+	}
+	
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		filters.Tag = &tag
+	}
+	
+	filters.ActiveOnly = c.Query("active_only") == "true"
+	
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if limit, err := strconv.Atoi(limitStr); err == nil && limit > 0 {
+			filters.Limit = limit
+		}
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if offset, err := strconv.Atoi(offsetStr); err == nil && offset >= 0 {
+			filters.Offset = offset
+		}
+	}
+	
+	// Empty filters return every session as before
+	sessions, err := h.sessionService.GetSessionsFiltered(filters)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get sessions",
@@ -64,6 +278,76 @@ func (h *Handler) GetSessions(c *gin.Context) {
 	})
 }
 
+// AddSessionTag adds a free-form tag to a session
+func (h *Handler) AddSessionTag(c *gin.Context) {
+	sessionID := c.Param("id")
+	
+	var req struct {
+		Tag string `json:"tag" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	tags, err := h.sessionService.AddTag(sessionID, req.Tag)
+	if err != nil {
+		errStr := err.Error()
+		if strings.Contains(errStr, "session not found") {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Session not found",
+			})
+			return
+		}
+		if strings.Contains(errStr, "invalid tag") {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid tag",
+				"details": errStr,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to add session tag",
+			"details": errStr,
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"tags": tags,
+	})
+}
+
+// RemoveSessionTag removes a tag from a session
+func (h *Handler) RemoveSessionTag(c *gin.Context) {
+	sessionID := c.Param("id")
+	
+	tags, err := h.sessionService.RemoveTag(sessionID, c.Param("tag"))
+	if err != nil {
+		if strings.Contains(err.Error(), "tag not found") {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Tag not found",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to remove session tag",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"tags": tags,
+	})
+}
+
 func (h *Handler) GetSessionDetails(c *gin.Context) {
 	sessionID := c.Param("id")
 	if sessionID == "" {
@@ -82,6 +366,15 @@ func (h *Handler) GetSessionDetails(c *gin.Context) {
 		return
 	}
 	
+	costByModel, err := h.sessionService.GetSessionCostByModel(sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get session cost by model",
+			"details": err.Error(),
+		})
+		return
+	}
+	
 	// Check if pagination is requested
 	pageStr := c.Query("page")
 	pageSizeStr := c.Query("page_size")
@@ -125,6 +418,7 @@ func (h *Handler) GetSessionDetails(c *gin.Context) {
 			"session": session,
 			"messages": paginatedMessages,
 			"token_usage": tokenUsage,
+			"cost_by_model": costByModel,
 		})
 	} else {
 		// Use existing non-paginated method for backward compatibility
@@ -150,6 +444,7 @@ func (h *Handler) GetSessionDetails(c *gin.Context) {
 			"session": session,
 			"messages": messages,
 			"token_usage": tokenUsage,
+			"cost_by_model": costByModel,
 		})
 	}
 }
@@ -168,14 +463,36 @@ func (h *Handler) SyncLogs(c *gin.Context) {
 	
 	db := c.MustGet("db").(*sql.DB)
 	
+	// sinceが指定された場合はそれ以降に更新されたファイルのみ対象にする
+	var since time.Time
+	if sinceStr := c.Query("since"); sinceStr != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid since parameter",
+				"details": "since must be an RFC3339 timestamp",
+			})
+			return
+		}
+		if parsed.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid since parameter",
+				"details": "since must not be in the future",
+			})
+			return
+		}
+		since = parsed
+	}
+	
 	// Enable differential sync to fix partial log reading issues
 	useDiffSync := true
 	
 	if useDiffSync {
 		// Use new differential sync service
 		diffSyncService := services.NewDiffSyncService(db, h.tokenService, h.sessionService)
+		diffSyncService.SetRequestContext(c.Request.Context())
 		
-		stats, err := diffSyncService.SyncAllLogs()
+		stats, err := diffSyncService.SyncLogsSince(since)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Failed to sync logs",
@@ -222,6 +539,38 @@ func (h *Handler) GetSessionActivityReport(c *gin.Context) {
 	c.JSON(http.StatusOK, report)
 }
 
+// ExportSession downloads a session transcript as Markdown (default), JSON or Claude JSONL
+func (h *Handler) ExportSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	format := c.DefaultQuery("format", services.SessionExportFormatMarkdown)
+	if !services.IsValidSessionExportFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid export format",
+			"details": "format must be 'markdown', 'json' or 'jsonl'",
+		})
+		return
+	}
+	
+	export, err := h.sessionService.ExportSession(sessionID, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export session",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if export == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Session not found",
+		})
+		return
+	}
+	
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.Filename))
+	c.Data(http.StatusOK, export.ContentType, export.Content)
+}
+
 func (h *Handler) GetRecentSessions(c *gin.Context) {
 	hours := c.DefaultQuery("hours", "720")
 	
@@ -240,8 +589,17 @@ func (h *Handler) GetRecentSessions(c *gin.Context) {
 	})
 }
 
+// GetAvailableTokens returns the tokens left in the active window.
+// The plan query parameter overrides the plan the window was created with.
 func (h *Handler) GetAvailableTokens(c *gin.Context) {
-	plan := c.DefaultQuery("plan", "pro")
+	plan := strings.ToLower(strings.TrimSpace(c.Query("plan")))
+	if plan != "" && !models.IsValidPlan(plan) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid plan parameter",
+			"valid_values": models.Plans,
+		})
+		return
+	}
 	
 	usage, err := h.tokenService.GetCurrentTokenUsage()
 	if err != nil {
@@ -252,7 +610,14 @@ func (h *Handler) GetAvailableTokens(c *gin.Context) {
 		return
 	}
 	
-	availableTokens := usage.UsageLimit - usage.TotalTokens
+	usageLimit := usage.UsageLimit
+	if plan == "" {
+		plan = usage.Plan
+	} else {
+		usageLimit = services.UsageLimitForPlan(plan)
+	}
+	
+	availableTokens := usageLimit - usage.TotalTokens
 	if availableTokens < 0 {
 		availableTokens = 0
 	}
@@ -260,74 +625,400 @@ func (h *Handler) GetAvailableTokens(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"available_tokens": availableTokens,
 		"plan": plan,
-		"usage_limit": usage.UsageLimit,
+		"usage_limit": usageLimit,
 		"used_tokens": usage.TotalTokens,
 	})
 }
 
+// GetCurrentMonthCosts returns the cost for the current calendar month in the server's local timezone.
+// format=csv returns the cost by model and by project as CSV.
 func (h *Handler) GetCurrentMonthCosts(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"current_month_cost": 0.0,
-		"currency": "USD",
-		"note": "Cost tracking not implemented yet",
-	})
-}
-
-func (h *Handler) GetTasks(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"tasks": []interface{}{},
-		"count": 0,
-		"note": "Task scheduling not implemented yet",
+	format, ok := responseFormat(c)
+	if !ok {
+		return
+	}
+	
+	summary, err := h.tokenService.GetMonthlyCosts(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get current month costs",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	respondFormatted(c, format, "costs", summary, func() csvTable {
+		return monthlyCostTable(summary)
 	})
 }
 
-func (h *Handler) GetSessionWindows(c *gin.Context) {
-	limitStr := c.DefaultQuery("limit", "50")
+// GetDailyUsage returns a daily usage series for the last days days (default 30, max 365)
+// in the server's local timezone. format=csv returns the series as CSV.
+func (h *Handler) GetDailyUsage(c *gin.Context) {
+	format, ok := responseFormat(c)
+	if !ok {
+		return
+	}
 	
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 50
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(services.DEFAULT_DAILY_USAGE_DAYS)))
+	if err != nil || days <= 0 {
+		days = services.DEFAULT_DAILY_USAGE_DAYS
 	}
-	if limit > 100 {
-		limit = 100
+	if days > services.MAX_DAILY_USAGE_DAYS {
+		days = services.MAX_DAILY_USAGE_DAYS
 	}
 	
-	windows, err := h.sessionWindowService.GetRecentWindows(limit)
+	series, err := h.tokenService.GetDailyUsage(days, time.Now())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get session windows",
+			"error": "Failed to get daily usage",
 			"details": err.Error(),
 		})
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"windows": windows,
-		"count": len(windows),
+	respondFormatted(c, format, "daily-usage", gin.H{
+		"daily": series,
+		"days": days,
+	}, func() csvTable {
+		return dailyUsageTable(series)
 	})
 }
 
-// GetP90Predictions returns p90 limit predictions for tokens, messages, and costs
-func (h *Handler) GetP90Predictions(c *gin.Context) {
-	prediction, err := h.p90PredictionService.CalculateP90Limits()
+// GetActivityHeatmap returns message counts by day of week and hour of day for the last
+// days days (default 90, max 365) in the server's local timezone
+func (h *Handler) GetActivityHeatmap(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(services.DEFAULT_HEATMAP_DAYS)))
+	if err != nil || days <= 0 {
+		days = services.DEFAULT_HEATMAP_DAYS
+	}
+	if days > services.MAX_HEATMAP_DAYS {
+		days = services.MAX_HEATMAP_DAYS
+	}
+	
+	heatmap, err := h.sessionService.GetActivityHeatmap(days, time.Now())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to calculate p90 predictions",
+			"error": "Failed to get activity heatmap",
 			"details": err.Error(),
 		})
 		return
 	}
 	
-	c.JSON(http.StatusOK, prediction)
+	c.JSON(http.StatusOK, heatmap)
 }
 
-// GetP90PredictionsByProject returns p90 predictions for a specific project
-func (h *Handler) GetP90PredictionsByProject(c *gin.Context) {
-	projectName := c.Param("project")
-	if projectName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Project name is required",
-		})
+// GetModelUsageBreakdown returns token usage, cost and share per model across all data.
+// format=csv returns the breakdown as CSV.
+func (h *Handler) GetModelUsageBreakdown(c *gin.Context) {
+	format, ok := responseFormat(c)
+	if !ok {
+		return
+	}
+	
+	breakdown, err := h.tokenService.GetModelUsageBreakdown()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get model usage",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	respondFormatted(c, format, "model-usage", gin.H{
+		"models": breakdown,
+		"count": len(breakdown),
+	}, func() csvTable {
+		return modelUsageTable(breakdown)
+	})
+}
+
+// GetProjectLeaderboard ranks projects by the tokens (metric=tokens, default) or cost
+// (metric=cost) of their sessions, optionally only counting sessions started in [from, to)
+func (h *Handler) GetProjectLeaderboard(c *gin.Context) {
+	metric := c.DefaultQuery("metric", services.LeaderboardMetricTokens)
+	if !services.IsValidLeaderboardMetric(metric) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid metric parameter",
+			"valid_values": []string{services.LeaderboardMetricTokens, services.LeaderboardMetricCost},
+		})
+		return
+	}
+	
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(services.DEFAULT_LEADERBOARD_LIMIT)))
+	if err != nil || limit <= 0 {
+		limit = services.DEFAULT_LEADERBOARD_LIMIT
+	}
+	if limit > services.MAX_LEADERBOARD_LIMIT {
+		limit = services.MAX_LEADERBOARD_LIMIT
+	}
+	
+	from, ok := optionalTimeQuery(c, "from")
+	if !ok {
+		return
+	}
+	to, ok := optionalTimeQuery(c, "to")
+	if !ok {
+		return
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be before to",
+		})
+		return
+	}
+	
+	leaderboard, err := h.projectService.GetLeaderboard(metric, limit, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get project leaderboard",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"metric": metric,
+		"projects": leaderboard,
+		"count": len(leaderboard),
+	})
+}
+
+// optionalTimeQuery parses an optional RFC3339 query parameter.
+// It responds with 400 and returns false when the value is invalid.
+func optionalTimeQuery(c *gin.Context, name string) (*time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid %s parameter, expected RFC3339", name),
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return &t, true
+}
+
+func (h *Handler) GetTasks(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"tasks": []interface{}{},
+		"count": 0,
+		"note": "Task scheduling not implemented yet",
+	})
+}
+
+func (h *Handler) GetSessionWindows(c *gin.Context) {
+	limitStr := c.DefaultQuery("limit", "50")
+	
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	
+	windows, err := h.sessionWindowService.GetRecentWindows(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get session windows",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"windows": windows,
+		"count": len(windows),
+	})
+}
+
+// ExportSessionWindowsCSV streams every session window as a CSV download.
+// Optional from/to (RFC3339) bound window_start.
+func (h *Handler) ExportSessionWindowsCSV(c *gin.Context) {
+	var from, to *time.Time
+	
+	if fromStr := c.Query("from"); fromStr != "" {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid from parameter, expected RFC3339",
+				"details": err.Error(),
+			})
+			return
+		}
+		from = &parsed
+	}
+	
+	if toStr := c.Query("to"); toStr != "" {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid to parameter, expected RFC3339",
+				"details": err.Error(),
+			})
+			return
+		}
+		to = &parsed
+	}
+	
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="session-windows-%s.csv"`, time.Now().UTC().Format("20060102")))
+	
+	if err := h.sessionWindowService.ExportWindowsCSV(c.Writer, from, to); err != nil {
+		if c.Writer.Written() {
+			// Headers and some rows are already sent; the download ends early
+			middleware.RequestLogf(c, "Session window CSV export failed mid-stream: %v", err)
+			return
+		}
+		c.Writer.Header().Del("Content-Disposition")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to export session windows",
+			"details": err.Error(),
+		})
+	}
+}
+
+// PreviewSessionWindows returns the windows a recalculation would produce between from and to (RFC3339)
+func (h *Handler) PreviewSessionWindows(c *gin.Context) {
+	fromStr, toStr := c.Query("from"), c.Query("to")
+	if fromStr == "" || toStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from and to query parameters are required",
+		})
+		return
+	}
+	
+	from, err := time.Parse(time.RFC3339, fromStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid from parameter, expected RFC3339",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	to, err := time.Parse(time.RFC3339, toStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid to parameter, expected RFC3339",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "to must be after from",
+		})
+		return
+	}
+	
+	windows, err := h.sessionWindowService.PreviewWindows(from.UTC(), to.UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to preview session windows",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"from": from.UTC(),
+		"to": to.UTC(),
+		"windows": windows,
+		"count": len(windows),
+	})
+}
+
+// GetSessionWindowForTime returns the window a given timestamp maps to
+func (h *Handler) GetSessionWindowForTime(c *gin.Context) {
+	tStr := c.Query("t")
+	if tStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "t query parameter is required",
+		})
+		return
+	}
+	
+	t, err := time.Parse(time.RFC3339, tStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid t parameter, expected RFC3339",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	existing, proposed, err := h.sessionWindowService.ResolveWindowForTime(t.UTC())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to resolve session window",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if existing != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"timestamp": t,
+			"exists": true,
+			"window": existing,
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"timestamp": t,
+		"exists": false,
+		"proposed_window": proposed,
+	})
+}
+
+// GetActiveSessionWindow returns the currently active session window with its live stats,
+// usage against the plan limit and time until reset, or 204 No Content when no window is active
+func (h *Handler) GetActiveSessionWindow(c *gin.Context) {
+	status, err := h.sessionWindowService.GetActiveWindowStatus(time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get active session window",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if status == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	
+	c.JSON(http.StatusOK, status)
+}
+
+// GetP90Predictions returns p90 limit predictions for tokens, messages, and costs
+func (h *Handler) GetP90Predictions(c *gin.Context) {
+	prediction, err := h.p90PredictionService.CalculateP90Limits()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to calculate p90 predictions",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, prediction)
+}
+
+// GetP90PredictionsByProject returns p90 predictions for a specific project
+func (h *Handler) GetP90PredictionsByProject(c *gin.Context) {
+	projectName := c.Param("project")
+	if projectName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Project name is required",
+		})
 		return
 	}
 	
@@ -340,64 +1031,537 @@ func (h *Handler) GetP90PredictionsByProject(c *gin.Context) {
 		return
 	}
 	
-	c.JSON(http.StatusOK, prediction)
-}
-
-// GetBurnRateHistory returns historical burn rate data
-func (h *Handler) GetBurnRateHistory(c *gin.Context) {
-	hoursStr := c.DefaultQuery("hours", "24")
-	
-	hours, err := strconv.Atoi(hoursStr)
-	if err != nil || hours <= 0 {
-		hours = 24
-	}
-	if hours > 168 { // Max 1 week
-		hours = 168
-	}
-	
-	history, err := h.p90PredictionService.GetBurnRateHistory(hours)
-	if err != nil {
+	c.JSON(http.StatusOK, prediction)
+}
+
+// GetProjectedExhaustion returns when the active window's token limit is projected to be reached
+func (h *Handler) GetProjectedExhaustion(c *gin.Context) {
+	projection, err := h.tokenService.GetProjectedExhaustion()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get exhaustion projection",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, projection)
+}
+
+// GetBurnRateHistory returns historical burn rate data. format=csv returns the points as CSV.
+func (h *Handler) GetBurnRateHistory(c *gin.Context) {
+	format, ok := responseFormat(c)
+	if !ok {
+		return
+	}
+	
+	hoursStr := c.DefaultQuery("hours", "24")
+	
+	hours, err := strconv.Atoi(hoursStr)
+	if err != nil || hours <= 0 {
+		hours = 24
+	}
+	if hours > 168 { // Max 1 week
+		hours = 168
+	}
+	
+	history, err := h.p90PredictionService.GetBurnRateHistory(hours)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get burn rate history",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	respondFormatted(c, format, "burn-rate-history", gin.H{
+		"burn_rate_history": history,
+		"hours": hours,
+	}, func() csvTable {
+		return burnRateHistoryTable(history)
+	})
+}
+
+// GetInitializationStatus returns the current initialization status
+func (h *Handler) GetInitializationStatus(c *gin.Context) {
+	initService := services.GetGlobalInitializationService()
+	state := initService.GetState()
+	c.JSON(http.StatusOK, state)
+}
+
+// GetMaintenanceMode returns the current maintenance mode state
+func (h *Handler) GetMaintenanceMode(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.maintenanceMode.IsEnabled(),
+		"retry_after_seconds": int(h.maintenanceMode.RetryAfter().Seconds()),
+	})
+}
+
+// SetMaintenanceMode enables or disables maintenance mode at runtime
+func (h *Handler) SetMaintenanceMode(c *gin.Context) {
+	var req struct {
+		Enabled *bool `json:"enabled" binding:"required"`
+	}
+	
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if *req.Enabled {
+		h.maintenanceMode.Enable()
+		log.Println("Maintenance mode: ENABLED")
+	} else {
+		h.maintenanceMode.Disable()
+		log.Println("Maintenance mode: DISABLED")
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": h.maintenanceMode.IsEnabled(),
+		"message": "Maintenance mode updated successfully",
+	})
+}
+
+// ReloadCommandWhitelist re-reads the command whitelist file and returns the effective whitelist
+func (h *Handler) ReloadCommandWhitelist(c *gin.Context) {
+	whitelist := h.jobExecutor.CommandWhitelist()
+	if err := whitelist.Reload(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to reload command whitelist",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	commands := whitelist.Commands()
+	middleware.RequestLogf(c, "Command whitelist reloaded: %d commands", len(commands))
+	
+	c.JSON(http.StatusOK, gin.H{
+		"path": whitelist.Path(),
+		"commands": commands,
+		"message": "Command whitelist reloaded successfully",
+	})
+}
+
+// RecalculateCosts recomputes session costs with the current pricing.
+// With session_id, that session is recalculated in the request; otherwise every session
+// is recalculated in the background and progress is reported by GetCostRecalculationStatus.
+func (h *Handler) RecalculateCosts(c *gin.Context) {
+	if sessionID := c.Query("session_id"); sessionID != "" {
+		cost, err := h.tokenService.RecalculateSessionCost(sessionID)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if strings.Contains(err.Error(), "session not found") {
+				status = http.StatusNotFound
+			}
+			c.JSON(status, gin.H{
+				"error": "Failed to recalculate session cost",
+				"details": err.Error(),
+			})
+			return
+		}
+		
+		c.JSON(http.StatusOK, gin.H{
+			"session_id": sessionID,
+			"total_cost": cost,
+			"updated_sessions": 1,
+		})
+		return
+	}
+	
+	tracker := services.GetGlobalCostRecalculationTracker()
+	if err := tracker.Start(); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Cost recalculation already running",
+			"state": tracker.GetState(),
+		})
+		return
+	}
+	
+	middleware.RequestLogf(c, "Starting cost recalculation for all sessions")
+	tokenService := h.tokenService
+	middleware.SafeGoRoutineWithErrorCallback(c.Request.Context(), "cost-recalculation", func() error {
+		updated, err := tokenService.RecalculateAllSessionCosts(tracker.UpdateProgress)
+		if err != nil {
+			return err
+		}
+		tracker.Complete(updated)
+		return nil
+	}, tracker.Fail)
+	
+	c.JSON(http.StatusAccepted, tracker.GetState())
+}
+
+// GetCostRecalculationStatus returns the progress of the last all-sessions cost recalculation
+func (h *Handler) GetCostRecalculationStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, services.GetGlobalCostRecalculationTracker().GetState())
+}
+
+// RecalculateWindows rebuilds every session window from the stored messages.
+// It is rejected while the initial import or a log sync is running, and blocks
+// new syncs until the rebuild finishes.
+func (h *Handler) RecalculateWindows(c *gin.Context) {
+	if services.GetGlobalInitializationService().IsInitializing() {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Cannot recalculate windows while initialization is running",
+		})
+		return
+	}
+	
+	start := time.Now()
+	err := services.RunExclusiveOfSyncs(h.sessionWindowService.RecalculateAllWindows)
+	if errors.Is(err, services.ErrSyncInProgress) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Cannot recalculate windows while a log sync is running",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to recalculate session windows",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	count, err := h.sessionWindowService.CountWindows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count session windows",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	middleware.RequestLogf(c, "Session windows recalculated: %d windows", count)
+	c.JSON(http.StatusOK, gin.H{
+		"window_count": count,
+		"duration_ms": time.Since(start).Milliseconds(),
+		"message": "Session windows recalculated successfully",
+	})
+}
+
+// GetIntegrity reports counts of broken references in the database, such as orphaned sessions
+// and messages assigned to more than one window
+func (h *Handler) GetIntegrity(c *gin.Context) {
+	db := c.MustGet("db").(*sql.DB)
+	
+	report, err := services.NewIntegrityService(db).Check()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check database integrity",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, report)
+}
+
+// RepairOrphanedSessions reassigns sessions with a NULL or dangling project_id to their project.
+// dry_run=true reports the changes without modifying data.
+func (h *Handler) RepairOrphanedSessions(c *gin.Context) {
+	if services.GetGlobalInitializationService().IsInitializing() {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Cannot repair sessions while initialization is running",
+		})
+		return
+	}
+	
+	dryRun := c.Query("dry_run") == "true"
+	var result *models.SessionRepairResult
+	err := services.RunExclusiveOfSyncs(func() error {
+		var err error
+		result, err = h.projectService.RepairOrphanedSessions(dryRun)
+		return err
+	})
+	if errors.Is(err, services.ErrSyncInProgress) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "Cannot repair sessions while a log sync is running",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to repair orphaned sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if !dryRun {
+		middleware.RequestLogf(c, "Orphaned sessions repaired: %d reassigned, %d cleared, %d projects created",
+			result.Reassigned, result.Cleared, result.ProjectsCreated)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ValidateSessionWindows reports overlapping windows and messages assigned to more than one window
+func (h *Handler) ValidateSessionWindows(c *gin.Context) {
+	validation, err := h.sessionWindowService.ValidateWindows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to validate session windows",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, validation)
+}
+
+// Phase 3: Projects API Handlers
+
+// GetAllProjects returns all active projects
+func (h *Handler) GetAllProjects(c *gin.Context) {
+	includeInactive := c.Query("include_inactive") == "true"
+	
+	projects, err := h.projectService.GetProjects(includeInactive)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get projects",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"projects": projects,
+		"count": len(projects),
+	})
+}
+
+// GetProject returns a specific project by ID
+func (h *Handler) GetProject(c *gin.Context) {
+	projectID := c.Param("id")
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Project ID is required",
+		})
+		return
+	}
+	
+	project, err := h.projectService.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get project",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if project == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return
+	}
+	
+	// Get sessions for this project
+	sessions, err := h.sessionService.GetSessionsByProject(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get project sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"project": project,
+		"sessions": sessions,
+		"session_count": len(sessions),
+	})
+}
+
+// UpdateProject updates an existing project
+func (h *Handler) UpdateProject(c *gin.Context) {
+	projectID := c.Param("id")
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Project ID is required",
+		})
+		return
+	}
+	
+	// Get existing project
+	project, err := h.projectService.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get project",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if project == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return
+	}
+	
+	// Parse request body
+	var updateRequest struct {
+		Description   *string `json:"description"`
+		RepositoryURL *string `json:"repository_url"`
+		Language      *string `json:"language"`
+		Framework     *string `json:"framework"`
+		IsActive      *bool   `json:"is_active"`
+	}
+	
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	// Update fields if provided
+	if updateRequest.Description != nil {
+		project.Description = updateRequest.Description
+	}
+	if updateRequest.RepositoryURL != nil {
+		project.RepositoryURL = updateRequest.RepositoryURL
+	}
+	if updateRequest.Language != nil {
+		project.Language = updateRequest.Language
+	}
+	if updateRequest.Framework != nil {
+		project.Framework = updateRequest.Framework
+	}
+	if updateRequest.IsActive != nil {
+		project.IsActive = *updateRequest.IsActive
+	}
+	
+	// Update project
+	err = h.projectService.UpdateProject(project)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update project",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"project": project,
+		"message": "Project updated successfully",
+	})
+}
+
+// DeleteProject soft deletes a project
+func (h *Handler) DeleteProject(c *gin.Context) {
+	projectID := c.Param("id")
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Project ID is required",
+		})
+		return
+	}
+	
+	err := h.projectService.DeleteProject(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete project",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Project deleted successfully",
+	})
+}
+
+// MergeProjects moves all sessions of the source project to the target project and deletes the source
+func (h *Handler) MergeProjects(c *gin.Context) {
+	var req models.MergeProjectsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	moved, err := h.projectService.MergeProjects(req.SourceID, req.TargetID)
+	if err != nil {
+		errStr := err.Error()
+		if strings.Contains(errStr, "project not found") {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Project not found",
+				"details": errStr,
+			})
+			return
+		}
+		if strings.Contains(errStr, "must be different") {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request",
+				"details": errStr,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to merge projects",
+			"details": errStr,
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Projects merged successfully",
+		"source_id": req.SourceID,
+		"target_id": req.TargetID,
+		"reassigned_sessions": moved,
+	})
+}
+
+// RestoreProject restores a soft deleted project
+func (h *Handler) RestoreProject(c *gin.Context) {
+	projectID := c.Param("id")
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Project ID is required",
+		})
+		return
+	}
+	
+	if err := h.projectService.RestoreProject(projectID); err != nil {
+		if strings.Contains(err.Error(), "project not found") {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Project not found",
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get burn rate history",
+			"error": "Failed to restore project",
 			"details": err.Error(),
 		})
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"burn_rate_history": history,
-		"hours": hours,
-	})
-}
-
-// GetInitializationStatus returns the current initialization status
-func (h *Handler) GetInitializationStatus(c *gin.Context) {
-	initService := services.GetGlobalInitializationService()
-	state := initService.GetState()
-	c.JSON(http.StatusOK, state)
-}
-
-// Phase 3: Projects API Handlers
-
-// GetAllProjects returns all active projects
-func (h *Handler) GetAllProjects(c *gin.Context) {
-	projects, err := h.projectService.GetAllProjects()
+	project, err := h.projectService.GetProjectByID(projectID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get projects",
+			"error": "Failed to get project",
 			"details": err.Error(),
 		})
 		return
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
-		"projects": projects,
-		"count": len(projects),
+		"message": "Project restored successfully",
+		"project": project,
 	})
 }
 
-// GetProject returns a specific project by ID
-func (h *Handler) GetProject(c *gin.Context) {
+// GetProjectSessions returns all sessions for a specific project
+func (h *Handler) GetProjectSessions(c *gin.Context) {
 	projectID := c.Param("id")
 	if projectID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -406,24 +1570,25 @@ func (h *Handler) GetProject(c *gin.Context) {
 		return
 	}
 	
-	project, err := h.projectService.GetProjectByID(projectID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get project",
-			"details": err.Error(),
+	sort := c.DefaultQuery("sort", services.SessionSortStartTime)
+	if !services.IsValidSessionSort(sort) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid sort parameter",
+			"valid_values": []string{services.SessionSortStartTime, services.SessionSortTotalTokens, services.SessionSortTotalCost},
 		})
 		return
 	}
 	
-	if project == nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Project not found",
-		})
-		return
+	page := 1
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	pageSize := 20
+	if ps, err := strconv.Atoi(c.Query("page_size")); err == nil && ps > 0 && ps <= 100 {
+		pageSize = ps
 	}
 	
-	// Get sessions for this project
-	sessions, err := h.sessionService.GetSessionsByProject(projectID)
+	result, err := h.sessionService.GetSessionsByProjectPaginated(projectID, sort, page, pageSize)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get project sessions",
@@ -433,14 +1598,21 @@ func (h *Handler) GetProject(c *gin.Context) {
 	}
 	
 	c.JSON(http.StatusOK, gin.H{
-		"project": project,
-		"sessions": sessions,
-		"session_count": len(sessions),
+		"sessions": result.Sessions,
+		"count": len(result.Sessions),
+		"project_id": projectID,
+		"sort": result.Sort,
+		"total": result.Total,
+		"page": result.Page,
+		"page_size": result.PageSize,
+		"total_pages": result.TotalPages,
+		"has_next": result.HasNext,
+		"has_previous": result.HasPrevious,
 	})
 }
 
-// UpdateProject updates an existing project
-func (h *Handler) UpdateProject(c *gin.Context) {
+// GetProjectStats returns aggregate statistics for a project
+func (h *Handler) GetProjectStats(c *gin.Context) {
 	projectID := c.Param("id")
 	if projectID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -449,7 +1621,6 @@ func (h *Handler) UpdateProject(c *gin.Context) {
 		return
 	}
 	
-	// Get existing project
 	project, err := h.projectService.GetProjectByID(projectID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -466,104 +1637,92 @@ func (h *Handler) UpdateProject(c *gin.Context) {
 		return
 	}
 	
-	// Parse request body
-	var updateRequest struct {
-		Description   *string `json:"description"`
-		RepositoryURL *string `json:"repository_url"`
-		Language      *string `json:"language"`
-		Framework     *string `json:"framework"`
-		IsActive      *bool   `json:"is_active"`
-	}
-	
-	if err := c.ShouldBindJSON(&updateRequest); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid request body",
+	stats, err := h.projectService.GetProjectStats(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get project stats",
 			"details": err.Error(),
 		})
 		return
 	}
 	
-	// Update fields if provided
-	if updateRequest.Description != nil {
-		project.Description = updateRequest.Description
-	}
-	if updateRequest.RepositoryURL != nil {
-		project.RepositoryURL = updateRequest.RepositoryURL
-	}
-	if updateRequest.Language != nil {
-		project.Language = updateRequest.Language
-	}
-	if updateRequest.Framework != nil {
-		project.Framework = updateRequest.Framework
-	}
-	if updateRequest.IsActive != nil {
-		project.IsActive = *updateRequest.IsActive
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetProjectCostStats returns the distribution of session costs for a project
+func (h *Handler) GetProjectCostStats(c *gin.Context) {
+	projectID := c.Param("id")
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Project ID is required",
+		})
+		return
 	}
 	
-	// Update project
-	err = h.projectService.UpdateProject(project)
+	project, err := h.projectService.GetProjectByID(projectID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to update project",
+			"error": "Failed to get project",
 			"details": err.Error(),
 		})
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"project": project,
-		"message": "Project updated successfully",
-	})
-}
-
-// DeleteProject soft deletes a project
-func (h *Handler) DeleteProject(c *gin.Context) {
-	projectID := c.Param("id")
-	if projectID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Project ID is required",
+	if project == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
 		})
 		return
 	}
 	
-	err := h.projectService.DeleteProject(projectID)
+	stats, err := h.projectService.GetProjectCostStats(projectID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to delete project",
+			"error": "Failed to get project cost stats",
 			"details": err.Error(),
 		})
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Project deleted successfully",
-	})
+	c.JSON(http.StatusOK, stats)
 }
 
-// GetProjectSessions returns all sessions for a specific project
-func (h *Handler) GetProjectSessions(c *gin.Context) {
-	projectID := c.Param("id")
-	if projectID == "" {
+// SearchMessages searches message content across sessions
+func (h *Handler) SearchMessages(c *gin.Context) {
+	query := c.Query("q")
+	if strings.TrimSpace(query) == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Project ID is required",
+			"error": "Query parameter 'q' is required",
 		})
 		return
 	}
 	
-	sessions, err := h.sessionService.GetSessionsByProject(projectID)
+	page := 1
+	pageSize := 20
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	if ps, err := strconv.Atoi(c.Query("page_size")); err == nil && ps > 0 && ps <= 100 {
+		pageSize = ps
+	}
+	
+	result, err := h.sessionService.SearchMessagesPaginated(query, c.Query("project_id"), page, pageSize)
 	if err != nil {
+		if strings.Contains(err.Error(), "exceeds") {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid search query",
+				"details": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to get project sessions",
+			"error": "Failed to search messages",
 			"details": err.Error(),
 		})
 		return
 	}
 	
-	c.JSON(http.StatusOK, gin.H{
-		"sessions": sessions,
-		"count": len(sessions),
-		"project_id": projectID,
-	})
+	c.JSON(http.StatusOK, result)
 }
 
 // MigrateSessionsToProjects migrates sessions without project_id to use projects
@@ -622,9 +1781,164 @@ func (h *Handler) CreateJob(c *gin.Context) {
 	
 	// Log incoming request for debugging
 	reqJSON, _ := json.Marshal(req)
-	log.Printf("CreateJob request: %s", string(reqJSON))
+	middleware.RequestLogf(c, "CreateJob request: %s", string(reqJSON))
+	
+	if !validateScheduleType(c, &req) {
+		return
+	}
+	
+	job, err := h.jobService.CreateJob(&req)
+	if err != nil {
+		// Check if it's a validation error
+		if isJobValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request",
+				"details": err.Error(),
+			})
+			return
+		}
+		
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create job",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	// Log created job details
+	jobJSON, _ := json.Marshal(job)
+	middleware.RequestLogf(c, "Created job: %s", string(jobJSON))
+	
+	// Queue job for immediate execution only
+	if req.ScheduleType == models.ScheduleTypeImmediate {
+		middleware.RequestLogf(c, "Queueing immediate job %s for execution", job.ID)
+		if err := h.jobExecutor.QueueJobContext(c.Request.Context(), job.ID); err != nil {
+			// Job was created but couldn't be queued - log warning but don't fail
+			middleware.RequestLogf(c, "Warning: Job %s created but couldn't be queued: %v", job.ID, err)
+		}
+	} else {
+		middleware.RequestLogf(c, "Job %s has schedule type %s, will be executed by scheduler", job.ID, req.ScheduleType)
+	}
+	
+	// A ballpark usage estimate; the job is created even if it cannot be computed
+	estimate, err := h.jobService.EstimateJobTokens(job.ProjectID, job.Command)
+	if err != nil {
+		middleware.RequestLogf(c, "Warning: Failed to estimate usage of job %s: %v", job.ID, err)
+	}
+	
+	c.JSON(http.StatusCreated, gin.H{
+		"job": job,
+		"estimate": estimate,
+		"message": "Job created successfully",
+	})
+}
+
+// RerunJob queues a new job with the same command and settings as a finished job
+func (h *Handler) RerunJob(c *gin.Context) {
+	jobID := c.Param("id")
+	
+	job, err := h.jobService.GetJobByID(jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get job",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	}
+	
+	if job.Status != models.JobStatusCompleted && job.Status != models.JobStatusFailed {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Job cannot be re-run",
+			"current_status": job.Status,
+			"message": "Only completed or failed jobs can be re-run",
+		})
+		return
+	}
+	
+	newJob, err := h.jobService.CloneJob(jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to re-run job",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	middleware.RequestLogf(c, "Re-running job %s as %s", jobID, newJob.ID)
+	if err := h.jobExecutor.QueueJobContext(c.Request.Context(), newJob.ID); err != nil {
+		// The queue monitor picks up pending jobs that couldn't be queued
+		middleware.RequestLogf(c, "Warning: Job %s created but couldn't be queued: %v", newJob.ID, err)
+	}
+	
+	c.JSON(http.StatusCreated, gin.H{
+		"job": newJob,
+		"job_id": newJob.ID,
+		"rerun_of": jobID,
+		"message": "Job re-run queued successfully",
+	})
+}
+
+// PreviewJobSchedule returns when a proposed job would run, using the same validation
+// and scheduling as CreateJob, without creating it
+func (h *Handler) PreviewJobSchedule(c *gin.Context) {
+	var req models.CreateJobRequest
+	
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if !validateScheduleType(c, &req) {
+		return
+	}
+	
+	preview, err := h.jobService.PreviewSchedule(&req)
+	if err != nil {
+		if isJobValidationError(err) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request",
+				"details": err.Error(),
+			})
+			return
+		}
+		
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to preview job schedule",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	switch req.ScheduleType {
+	case models.ScheduleTypeImmediate:
+		preview.Note = "Runs as soon as it is created"
+	case models.ScheduleTypeAfterReset:
+		// after_reset jobs are scheduled at the reset time of the active window
+		if preview.ScheduledAt != nil {
+			resetTime := *preview.ScheduledAt
+			preview.ResetTime = &resetTime
+			preview.Note = "Runs when the active session window resets at reset_time"
+		} else {
+			preview.Note = "No upcoming reset: scheduled at the reset time of the next session window once it starts"
+		}
+	}
 	
-	// Validate schedule type
+	c.JSON(http.StatusOK, preview)
+}
+
+// validateScheduleType defaults an empty schedule type to immediate and
+// responds with 400 if it is not a supported type
+func validateScheduleType(c *gin.Context, req *models.CreateJobRequest) bool {
 	if req.ScheduleType == "" {
 		req.ScheduleType = models.ScheduleTypeImmediate
 	}
@@ -637,7 +1951,7 @@ func (h *Handler) CreateJob(c *gin.Context) {
 	}
 	
 	if !validScheduleTypes[req.ScheduleType] {
-		c.JSON(http.StatusBadRequest, gin.H{
+		response := gin.H{
 			"error": "Invalid schedule type",
 			"valid_types": []string{
 				models.ScheduleTypeImmediate,
@@ -645,52 +1959,28 @@ func (h *Handler) CreateJob(c *gin.Context) {
 				models.ScheduleTypeDelayed,
 				models.ScheduleTypeScheduled,
 			},
-		})
-		return
-	}
-	
-	job, err := h.jobService.CreateJob(&req)
-	if err != nil {
-		// Check if it's a validation error
-		errStr := err.Error()
-		if strings.Contains(errStr, "invalid schedule parameters") ||
-			strings.Contains(errStr, "must be in the future") ||
-			strings.Contains(errStr, "is required for") ||
-			strings.Contains(errStr, "must be between") ||
-			strings.Contains(errStr, "project not found") {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid request",
-				"details": err.Error(),
-			})
-			return
 		}
-		
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create job",
-			"details": err.Error(),
-		})
-		return
-	}
-	
-	// Log created job details
-	jobJSON, _ := json.Marshal(job)
-	log.Printf("Created job: %s", string(jobJSON))
-	
-	// Queue job for immediate execution only
-	if req.ScheduleType == models.ScheduleTypeImmediate {
-		log.Printf("Queueing immediate job %s for execution", job.ID)
-		if err := h.jobExecutor.QueueJob(job.ID); err != nil {
-			// Job was created but couldn't be queued - log warning but don't fail
-			log.Printf("Warning: Job %s created but couldn't be queued: %v", job.ID, err)
+		if req.ScheduleType == models.ScheduleTypeCustom {
+			response["details"] = "schedule type custom is no longer supported: use scheduled with schedule_params.scheduled_time"
 		}
-	} else {
-		log.Printf("Job %s has schedule type %s, will be executed by scheduler", job.ID, req.ScheduleType)
+		c.JSON(http.StatusBadRequest, response)
+		return false
 	}
-	
-	c.JSON(http.StatusCreated, gin.H{
-		"job": job,
-		"message": "Job created successfully",
-	})
+	return true
+}
+
+// isJobValidationError reports whether a job creation error was caused by the request
+func isJobValidationError(err error) bool {
+	errStr := err.Error()
+	return strings.Contains(errStr, "invalid schedule parameters") ||
+		strings.Contains(errStr, "invalid output rules") ||
+		strings.Contains(errStr, "invalid webhook_url") ||
+		strings.Contains(errStr, "invalid resource_limits") ||
+		strings.Contains(errStr, "invalid env:") ||
+		strings.Contains(errStr, "must be in the future") ||
+		strings.Contains(errStr, "is required for") ||
+		strings.Contains(errStr, "must be between") ||
+		strings.Contains(errStr, "project not found")
 }
 
 // GetJobs retrieves jobs with optional filtering
@@ -706,6 +1996,21 @@ func (h *Handler) GetJobs(c *gin.Context) {
 		filters.Status = &status
 	}
 	
+	if commandContains := c.Query("command_contains"); commandContains != "" {
+		filters.CommandContains = &commandContains
+	}
+	
+	createdAfter, ok := optionalTimeQuery(c, "created_after")
+	if !ok {
+		return
+	}
+	createdBefore, ok := optionalTimeQuery(c, "created_before")
+	if !ok {
+		return
+	}
+	filters.CreatedAfter = createdAfter
+	filters.CreatedBefore = createdBefore
+	
 	// Parse limit with default
 	limit := 50
 	if limitStr := c.Query("limit"); limitStr != "" {
@@ -742,6 +2047,71 @@ func (h *Handler) GetJobs(c *gin.Context) {
 	})
 }
 
+// GetJobLogs returns a slice of a job's log lines
+// Query: tail (lines, default 200, max 5000), offset (lines skipped from the end), stream (stdout|stderr|both)
+func (h *Handler) GetJobLogs(c *gin.Context) {
+	jobID := c.Param("id")
+	if jobID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Job ID is required",
+		})
+		return
+	}
+	
+	tail := 200
+	if tailStr := c.Query("tail"); tailStr != "" {
+		parsedTail, err := strconv.Atoi(tailStr)
+		if err != nil || parsedTail <= 0 || parsedTail > 5000 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid tail parameter",
+				"details": "tail must be between 1 and 5000",
+			})
+			return
+		}
+		tail = parsedTail
+	}
+	
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err != nil || parsedOffset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid offset parameter",
+				"details": "offset must be a non-negative integer",
+			})
+			return
+		}
+		offset = parsedOffset
+	}
+	
+	stream := c.DefaultQuery("stream", models.JobLogStreamBoth)
+	if stream != models.JobLogStreamStdout && stream != models.JobLogStreamStderr && stream != models.JobLogStreamBoth {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid stream parameter",
+			"details": "stream must be stdout, stderr or both",
+		})
+		return
+	}
+	
+	logs, err := h.jobService.GetJobLogs(jobID, stream, tail, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get job logs",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if logs == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Job not found",
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, logs)
+}
+
 // GetJobByID retrieves a specific job by ID
 func (h *Handler) GetJobByID(c *gin.Context) {
 	jobID := c.Param("id")
@@ -849,6 +2219,57 @@ func (h *Handler) CancelJob(c *gin.Context) {
 	})
 }
 
+// CancelProjectPendingJobs cancels all pending and running jobs of a project
+func (h *Handler) CancelProjectPendingJobs(c *gin.Context) {
+	projectID := c.Param("id")
+	if projectID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Project ID is required",
+		})
+		return
+	}
+	
+	project, err := h.projectService.GetProjectByID(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get project",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	if project == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return
+	}
+	
+	cancelledPending, err := h.jobService.CancelPendingJobs(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel pending jobs",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	cancelledRunning, err := h.jobExecutor.CancelProjectJobs(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to cancel running jobs",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": projectID,
+		"cancelled_pending": cancelledPending,
+		"cancelled_running": cancelledRunning,
+	})
+}
+
 // DeleteJob deletes a job
 func (h *Handler) DeleteJob(c *gin.Context) {
 	jobID := c.Param("id")
@@ -901,3 +2322,196 @@ func (h *Handler) GetJobQueueStatus(c *gin.Context) {
 	})
 }
 
+// PauseJobQueue stops the executor from starting new jobs
+func (h *Handler) PauseJobQueue(c *gin.Context) {
+	h.jobExecutor.Pause()
+	
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Job queue paused",
+		"queue_status": h.jobExecutor.GetQueueStatus(),
+	})
+}
+
+// ResumeJobQueue lets the executor start jobs again
+func (h *Handler) ResumeJobQueue(c *gin.Context) {
+	h.jobExecutor.Resume()
+	
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Job queue resumed",
+		"queue_status": h.jobExecutor.GetQueueStatus(),
+	})
+}
+
+// GetJobStats returns job counts and run durations, optionally for one project.
+// Durations cover completed jobs unless include_failed or include_cancelled is "true".
+func (h *Handler) GetJobStats(c *gin.Context) {
+	var filters models.JobStatsFilters
+	
+	if projectID := c.Query("project_id"); projectID != "" {
+		filters.ProjectID = &projectID
+	}
+	filters.IncludeFailed = c.Query("include_failed") == "true"
+	filters.IncludeCancelled = c.Query("include_cancelled") == "true"
+	
+	stats, err := h.jobService.GetJobStats(filters)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get job stats",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetJobSummary returns job counts by status and for today and this week
+func (h *Handler) GetJobSummary(c *gin.Context) {
+	var projectID *string
+	if id := c.Query("project_id"); id != "" {
+		projectID = &id
+	}
+	
+	summary, err := h.jobService.GetJobSummary(projectID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get job summary",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetStaleJobs lists running jobs whose process is gone or which exceeded the stale timeout
+func (h *Handler) GetStaleJobs(c *gin.Context) {
+	staleJobs, err := h.jobExecutor.FindStaleRunningJobs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get stale jobs",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"stale_jobs": staleJobs,
+		"count": len(staleJobs),
+	})
+}
+
+// CleanupStaleJobs marks stale running jobs as failed immediately
+func (h *Handler) CleanupStaleJobs(c *gin.Context) {
+	cleaned, err := h.jobExecutor.CleanupStaleRunningJobs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to clean up stale jobs",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Stale jobs cleaned up",
+		"cleaned_jobs": cleaned,
+		"count": len(cleaned),
+	})
+}
+
+// GetProjectAllowedCommands returns the commands whitelisted for a project's jobs
+func (h *Handler) GetProjectAllowedCommands(c *gin.Context) {
+	projectID := c.Param("id")
+	
+	commands, err := h.projectService.GetAllowedCommands(projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get allowed commands",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": projectID,
+		"commands": commands,
+	})
+}
+
+// AddProjectAllowedCommand whitelists a command for a project's jobs
+func (h *Handler) AddProjectAllowedCommand(c *gin.Context) {
+	projectID := c.Param("id")
+	
+	var req struct {
+		Command string `json:"command" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	commands, err := h.projectService.AddAllowedCommand(projectID, req.Command)
+	if err != nil {
+		errStr := err.Error()
+		if strings.Contains(errStr, "project not found") {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Project not found",
+			})
+			return
+		}
+		if strings.Contains(errStr, "invalid command") {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid command",
+				"details": errStr,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to add allowed command",
+			"details": errStr,
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": projectID,
+		"commands": commands,
+	})
+}
+
+// RemoveProjectAllowedCommand removes a command, given in the command query parameter, from a project's whitelist
+func (h *Handler) RemoveProjectAllowedCommand(c *gin.Context) {
+	projectID := c.Param("id")
+	
+	command := c.Query("command")
+	if command == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "command parameter is required",
+		})
+		return
+	}
+	
+	commands, err := h.projectService.RemoveAllowedCommand(projectID, command)
+	if err != nil {
+		if strings.Contains(err.Error(), "allowed command not found") {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Allowed command not found",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to remove allowed command",
+			"details": err.Error(),
+		})
+		return
+	}
+	
+	c.JSON(http.StatusOK, gin.H{
+		"project_id": projectID,
+		"commands": commands,
+	})
+}
@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"ccdash-backend/internal/config"
+	"ccdash-backend/internal/migration"
+	"ccdash-backend/internal/models"
+	"ccdash-backend/internal/services"
+	"ccdash-backend/internal/version"
+	"ccdash-backend/migrations"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func getVersion(t *testing.T, db *sql.DB) models.VersionInfo {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", db)
+		c.Next()
+	})
+	router.GET("/api/version", (&Handler{}).GetVersion)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/version", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var info models.VersionInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return info
+}
+
+func TestGetVersion(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	all, err := migration.NewEmbedScanner(migrations.FS).Scan()
+	if err != nil || len(all) == 0 {
+		t.Fatalf("Failed to scan embedded migrations: %v", err)
+	}
+	latest := all[len(all)-1].Version
+
+	// Before migrating, every migration is pending
+	info := getVersion(t, db)
+	if !info.Behind || info.PendingMigrations != len(all) || info.SchemaVersion != "" || info.LatestVersion != latest {
+		t.Errorf("Expected unmigrated schema to be behind, got %+v", info)
+	}
+
+	// Record every migration as applied, the way the executor does
+	vm := migration.NewVersionManager(db)
+	for _, m := range all {
+		if err := vm.RecordMigration(m.Version, m.Name, "", "", 0, "success", nil); err != nil {
+			t.Fatalf("Failed to record migration: %v", err)
+		}
+		if err := vm.SetVersion(m.Version, true); err != nil {
+			t.Fatalf("Failed to mark version dirty: %v", err)
+		}
+		if err := vm.SetVersion(m.Version, false); err != nil {
+			t.Fatalf("Failed to clear dirty flag: %v", err)
+		}
+	}
+
+	info = getVersion(t, db)
+	if info.AppVersion != version.Version || info.GoVersion != runtime.Version() {
+		t.Errorf("Expected app version %s built with %s, got %+v", version.Version, runtime.Version(), info)
+	}
+	if info.SchemaVersion != latest || info.LatestVersion != latest {
+		t.Errorf("Expected schema version %s, got %s (latest %s)", latest, info.SchemaVersion, info.LatestVersion)
+	}
+	if info.Behind || info.Dirty || info.PendingMigrations != 0 {
+		t.Errorf("Expected migrated schema to be current and clean, got %+v", info)
+	}
+}
+
+func TestGetTokenUsageInRange_CSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE messages (
+			id VARCHAR PRIMARY KEY,
+			message_role VARCHAR,
+			model VARCHAR,
+			input_tokens INTEGER,
+			output_tokens INTEGER,
+			cache_creation_input_tokens INTEGER,
+			cache_read_input_tokens INTEGER,
+			timestamp TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create messages table: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO messages VALUES
+			('m1', 'assistant', 'claude-sonnet-4-20250514', 100, 50, 0, 0, '2025-06-01 10:00:00'),
+			('m2', 'assistant', 'claude-sonnet-4-20250514', 200, 80, 10, 20, '2025-06-01 12:00:00'),
+			('m3', 'user', NULL, 0, 0, 0, 0, '2025-06-01 12:00:00'),
+			('m4', 'assistant', 'claude-sonnet-4-20250514', 300, 90, 0, 0, '2025-06-02 09:00:00')
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert messages: %v", err)
+	}
+
+	handler := &Handler{tokenService: services.NewTokenService(db)}
+	router := gin.New()
+	router.GET("/api/token-usage/range", handler.GetTokenUsageInRange)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/token-usage/range?"+query, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("from=2025-06-01T00:00:00Z&to=2025-06-03T00:00:00Z&group_by=day&format=csv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Errorf("Expected text/csv content type, got %s", ct)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("Response is not valid CSV: %v", err)
+	}
+	expected := [][]string{
+		{"date", "input_tokens", "output_tokens", "cache_creation_input_tokens", "cache_read_input_tokens", "total_tokens", "total_cost", "total_messages"},
+		{"2025-06-01", "300", "130", "10", "20", "430"},
+		{"2025-06-02", "300", "90", "0", "0", "390"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("Expected %d CSV records, got %d: %v", len(expected), len(records), records)
+	}
+	for i, want := range expected {
+		if len(records[i]) != len(expected[0]) {
+			t.Fatalf("Record %d has %d fields, expected %d", i, len(records[i]), len(expected[0]))
+		}
+		for j, field := range want {
+			if records[i][j] != field {
+				t.Errorf("Record %d field %s: expected %s, got %s", i, expected[0][j], field, records[i][j])
+			}
+		}
+	}
+	if records[1][7] != "2" || records[2][7] != "1" {
+		t.Errorf("Expected message counts 2 and 1, got %s and %s", records[1][7], records[2][7])
+	}
+
+	// JSON stays the default
+	w = get("from=2025-06-01T00:00:00Z&to=2025-06-03T00:00:00Z&group_by=day")
+	var usage models.TokenUsageRange
+	if err := json.Unmarshal(w.Body.Bytes(), &usage); err != nil {
+		t.Fatalf("Expected JSON by default: %v", err)
+	}
+	if len(usage.Daily) != 2 {
+		t.Errorf("Expected 2 daily entries, got %d", len(usage.Daily))
+	}
+
+	w = get("from=2025-06-01T00:00:00Z&to=2025-06-03T00:00:00Z&format=xml")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unsupported format, got %d", w.Code)
+	}
+}
+
+func TestGetReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("db", db)
+		c.Next()
+	})
+	router.GET("/api/health/ready", (&Handler{}).GetReadiness)
+
+	getReadiness := func() (int, models.Readiness) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/health/ready", nil)
+		router.ServeHTTP(w, req)
+
+		var readiness models.Readiness
+		if err := json.Unmarshal(w.Body.Bytes(), &readiness); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, readiness
+	}
+
+	code, readiness := getReadiness()
+	if code != http.StatusOK || !readiness.Ready || readiness.Database != "ok" {
+		t.Errorf("Expected ready with a reachable database, got %d %+v", code, readiness)
+	}
+	if readiness.ClaudeAvailable != services.IsClaudeCodeAvailable() {
+		t.Errorf("Expected claude_available %v, got %v", services.IsClaudeCodeAvailable(), readiness.ClaudeAvailable)
+	}
+	if readiness.Initialization != string(services.StatusCompleted) {
+		t.Errorf("Expected initialization completed, got %s", readiness.Initialization)
+	}
+
+	// A closed database is unreachable
+	db.Close()
+	code, readiness = getReadiness()
+	if code != http.StatusServiceUnavailable || readiness.Ready || readiness.Database == "ok" {
+		t.Errorf("Expected 503 with an unreachable database, got %d %+v", code, readiness)
+	}
+}
+
+// setTestPlan sets the configured plan for the duration of the test
+func setTestPlan(t *testing.T, plan string) {
+	t.Helper()
+	previous := services.CurrentPlan()
+	services.SetPlan(plan)
+	t.Cleanup(func() { services.SetPlan(previous) })
+}
+
+func TestGetAvailableTokens_PlanOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setTestPlan(t, models.PlanPro)
+
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE session_windows (
+			id TEXT PRIMARY KEY,
+			window_start TIMESTAMP NOT NULL,
+			window_end TIMESTAMP NOT NULL,
+			reset_time TIMESTAMP NOT NULL,
+			total_input_tokens INTEGER DEFAULT 0,
+			total_output_tokens INTEGER DEFAULT 0,
+			total_tokens INTEGER DEFAULT 0,
+			total_cache_creation_tokens INTEGER DEFAULT 0,
+			total_cache_read_tokens INTEGER DEFAULT 0,
+			message_count INTEGER DEFAULT 0,
+			session_count INTEGER DEFAULT 0,
+			total_cost DOUBLE DEFAULT 0.0,
+			is_active BOOLEAN DEFAULT false,
+			plan TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create session_windows table: %v", err)
+	}
+	windowStart := time.Now().UTC().Add(-time.Hour)
+	windowEnd := windowStart.Add(services.WINDOW_DURATION)
+	_, err = db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, total_tokens, is_active, plan)
+		VALUES ('w1', ?, ?, ?, 10000, true, 'pro')
+	`, windowStart, windowEnd, windowEnd)
+	if err != nil {
+		t.Fatalf("Failed to insert window: %v", err)
+	}
+
+	handler := &Handler{tokenService: services.NewTokenService(db)}
+	router := gin.New()
+	router.GET("/api/claude/available-tokens", handler.GetAvailableTokens)
+
+	get := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/claude/available-tokens"+query, nil)
+		router.ServeHTTP(w, req)
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, body
+	}
+
+	tests := []struct {
+		query     string
+		plan      string
+		limit     int
+		available int
+	}{
+		// Without a plan, the window's own plan applies and the pro limit is exceeded
+		{"", "pro", services.CLAUDE_PRO_LIMIT, 0},
+		{"?plan=pro", "pro", services.CLAUDE_PRO_LIMIT, 0},
+		{"?plan=max5", "max5", services.CLAUDE_MAX5_LIMIT, services.CLAUDE_MAX5_LIMIT - 10000},
+		{"?plan=max20", "max20", services.CLAUDE_MAX20_LIMIT, services.CLAUDE_MAX20_LIMIT - 10000},
+	}
+	for _, tt := range tests {
+		code, body := get(tt.query)
+		if code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %v", tt.query, code, body)
+		}
+		if body["plan"] != tt.plan || int(body["usage_limit"].(float64)) != tt.limit ||
+			int(body["available_tokens"].(float64)) != tt.available {
+			t.Errorf("%s: expected plan %s, limit %d and %d available, got %v", tt.query, tt.plan, tt.limit, tt.available, body)
+		}
+	}
+
+	if code, _ := get("?plan=enterprise"); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown plan, got %d", code)
+	}
+}
+
+func TestGetActiveSessionWindow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setTestPlan(t, models.PlanPro)
+
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE session_windows (
+			id TEXT PRIMARY KEY,
+			window_start TIMESTAMP NOT NULL,
+			window_end TIMESTAMP NOT NULL,
+			reset_time TIMESTAMP NOT NULL,
+			total_input_tokens INTEGER DEFAULT 0,
+			total_output_tokens INTEGER DEFAULT 0,
+			total_tokens INTEGER DEFAULT 0,
+			total_cache_creation_tokens INTEGER DEFAULT 0,
+			total_cache_read_tokens INTEGER DEFAULT 0,
+			message_count INTEGER DEFAULT 0,
+			session_count INTEGER DEFAULT 0,
+			total_cost DOUBLE DEFAULT 0.0,
+			is_active BOOLEAN DEFAULT false,
+			plan TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create session_windows table: %v", err)
+	}
+
+	handler := &Handler{sessionWindowService: services.NewSessionWindowService(db)}
+	router := gin.New()
+	router.GET("/api/session-windows/active", handler.GetActiveSessionWindow)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/session-windows/active", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get(); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 without windows, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A window left flagged active after its reset time is not the active window
+	staleStart := time.Now().UTC().Add(-7 * time.Hour)
+	staleEnd := staleStart.Add(services.WINDOW_DURATION)
+	_, err = db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, total_tokens, is_active, plan)
+		VALUES ('stale', ?, ?, ?, 500, true, 'pro')
+	`, staleStart, staleEnd, staleEnd)
+	if err != nil {
+		t.Fatalf("Failed to insert window: %v", err)
+	}
+	if w := get(); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for an expired window, got %d: %s", w.Code, w.Body.String())
+	}
+
+	windowStart := time.Now().UTC().Add(-time.Hour)
+	windowEnd := windowStart.Add(services.WINDOW_DURATION)
+	_, err = db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, total_tokens, message_count, total_cost, is_active, plan)
+		VALUES ('current', ?, ?, ?, 1200, 7, 0.42, true, 'pro')
+	`, windowStart, windowEnd, windowEnd)
+	if err != nil {
+		t.Fatalf("Failed to insert window: %v", err)
+	}
+
+	w := get()
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body services.ActiveWindowStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Window == nil || body.Window.ID != "current" || body.Window.TotalTokens != 1200 || body.Window.MessageCount != 7 || body.Window.TotalCost != 0.42 {
+		t.Errorf("Expected the current window with its stats, got %+v", body.Window)
+	}
+	if body.UsageLimit != services.CLAUDE_PRO_LIMIT || body.TokensRemaining != services.CLAUDE_PRO_LIMIT-1200 {
+		t.Errorf("Expected usage against the pro limit, got %+v", body)
+	}
+	expected := int64((services.WINDOW_DURATION - time.Hour).Seconds())
+	if body.SecondsUntilReset > expected || body.SecondsUntilReset < expected-60 {
+		t.Errorf("Expected about %d seconds until reset, got %d", expected, body.SecondsUntilReset)
+	}
+}
+
+func TestGetConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setTestPlan(t, models.PlanMax5)
+
+	cfg := &config.Config{
+		JobExecutorWorkerCount:      4,
+		JobSchedulerPollingInterval: 2 * time.Minute,
+		SessionActiveTimeout:        45 * time.Minute,
+	}
+	handler := &Handler{}
+	handler.SetServerConfig(cfg, true)
+
+	router := gin.New()
+	router.GET("/api/config", handler.GetConfig)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/config", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	expected := map[string]interface{}{
+		"version":                        version.Version,
+		"plan":                           "max5",
+		"usage_limit":                    float64(services.CLAUDE_MAX5_LIMIT),
+		"window_duration_seconds":        services.WINDOW_DURATION.Seconds(),
+		"job_worker_count":               float64(4),
+		"scheduler_polling_seconds":      float64(120),
+		"session_active_timeout_seconds": float64(2700),
+		"auth_enabled":                   true,
+	}
+	for key, value := range expected {
+		if body[key] != value {
+			t.Errorf("Expected %s to be %v, got %v", key, value, body[key])
+		}
+	}
+	// Only the settings above are exposed, so no secret can leak
+	if len(body) != len(expected) {
+		t.Errorf("Expected exactly %d settings, got %v", len(expected), body)
+	}
+}
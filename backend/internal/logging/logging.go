@@ -0,0 +1,26 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+)
+
+// Log output formats accepted by LOG_FORMAT
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// IsValidFormat reports whether format is a supported log format
+func IsValidFormat(format string) bool {
+	return format == FormatText || format == FormatJSON
+}
+
+// Setup configures the default logger for the given format.
+// Text keeps the standard log output, where structured fields follow the message as key=value.
+// JSON writes one object per line to stderr, including the output of the log package.
+func Setup(format string) {
+	if format == FormatJSON {
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	}
+}
@@ -23,6 +23,7 @@ func NewAuthMiddleware() *AuthMiddleware {
 	publicPaths := []string{
 		"/api/v1/health",
 		"/api/health",
+		"/api/config",
 	}
 	
 	// API Key is now always required - if not set, it will be auto-generated
@@ -70,18 +71,8 @@ func (a *AuthMiddleware) Authenticate() gin.HandlerFunc {
 
 		// API key is now always required (no development bypass)
 
-		// Check for API key in header
-		providedKey := c.GetHeader("X-API-Key")
-		if providedKey == "" {
-			// Also check Authorization header with Bearer token format
-			authHeader := c.GetHeader("Authorization")
-			if strings.HasPrefix(authHeader, "Bearer ") {
-				providedKey = strings.TrimPrefix(authHeader, "Bearer ")
-			}
-		}
-
 		// Validate API key
-		if providedKey == "" || providedKey != a.apiKey {
+		if !a.IsValidKey(providedAPIKey(c)) {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": "Unauthorized: Invalid or missing API key",
 			})
@@ -93,6 +84,22 @@ func (a *AuthMiddleware) Authenticate() gin.HandlerFunc {
 	}
 }
 
+// IsValidKey reports whether key is the configured API key
+func (a *AuthMiddleware) IsValidKey(key string) bool {
+	return key != "" && key == a.apiKey
+}
+
+// providedAPIKey returns the API key sent in the X-API-Key header, or as a Bearer token
+func providedAPIKey(c *gin.Context) string {
+	if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	return ""
+}
+
 // IsAuthEnabled returns whether authentication is enabled
 func (a *AuthMiddleware) IsAuthEnabled() bool {
 	return a.apiKey != ""
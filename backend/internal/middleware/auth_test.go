@@ -63,6 +63,14 @@ func TestAuthMiddleware(t *testing.T) {
 			path:           "/api/v1/health",
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "Config endpoint without API key",
+			apiKey:         "test-api-key-123",
+			requestHeader:  "",
+			requestValue:   "",
+			path:           "/api/config",
+			expectedStatus: http.StatusOK,
+		},
 		{
 			name:           "Development mode without API key set",
 			apiKey:         "",
@@ -115,6 +123,9 @@ func TestAuthMiddleware(t *testing.T) {
 			router.GET("/api/v1/health", func(c *gin.Context) {
 				c.JSON(http.StatusOK, gin.H{"status": "ok"})
 			})
+			router.GET("/api/config", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"plan": "pro"})
+			})
 
 			// Create test request
 			req, _ := http.NewRequest("GET", tt.path, nil)
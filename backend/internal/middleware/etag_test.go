@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sessions := []string{"a"}
+	failing := false
+
+	router := gin.New()
+	router.GET("/sessions", ETag(), func(c *gin.Context) {
+		if failing {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+	})
+
+	request := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/sessions", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// First poll returns the body with an ETag
+	first := request("")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.JSONEq(t, `{"sessions":["a"]}`, first.Body.String())
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	assert.Equal(t, "application/json; charset=utf-8", first.Header().Get("Content-Type"))
+
+	// Unchanged data: 304 without a body
+	notModified := request(etag)
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Empty(t, notModified.Body.String())
+	assert.Equal(t, etag, notModified.Header().Get("ETag"))
+	assert.Equal(t, http.StatusNotModified, request(`"other", W/`+etag).Code)
+
+	// A stale tag gets the full body
+	assert.Equal(t, http.StatusOK, request(`"stale"`).Code)
+
+	// Changed data: new ETag and the full body
+	sessions = append(sessions, "b")
+	changed := request(etag)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.JSONEq(t, `{"sessions":["a","b"]}`, changed.Body.String())
+	assert.NotEqual(t, etag, changed.Header().Get("ETag"))
+
+	// Errors are passed through untagged
+	failing = true
+	failed := request(etag)
+	assert.Equal(t, http.StatusInternalServerError, failed.Code)
+	assert.Empty(t, failed.Header().Get("ETag"))
+	assert.JSONEq(t, `{"error":"boom"}`, failed.Body.String())
+}
+
+func TestETag_IgnoredFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	limit := 1000
+	calls := 0
+
+	router := gin.New()
+	router.GET("/predictions", ETag("predicted_at"), func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"token_limit": limit, "predicted_at": calls})
+	})
+
+	request := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/predictions", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := request("")
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// Only the ignored field changed
+	assert.Equal(t, http.StatusNotModified, request(etag).Code)
+
+	// The data changed
+	limit = 2000
+	changed := request(etag)
+	assert.Equal(t, http.StatusOK, changed.Code)
+	assert.NotEqual(t, etag, changed.Header().Get("ETag"))
+}
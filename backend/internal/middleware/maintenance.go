@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceMode tracks whether the API is in maintenance mode and rejects requests while enabled
+type MaintenanceMode struct {
+	mu         sync.RWMutex
+	enabled    bool
+	retryAfter time.Duration
+	// Paths that remain accessible during maintenance
+	exemptPaths []string
+}
+
+// NewMaintenanceMode creates a new maintenance mode toggle
+func NewMaintenanceMode(enabled bool, retryAfter time.Duration) *MaintenanceMode {
+	return &MaintenanceMode{
+		enabled:    enabled,
+		retryAfter: retryAfter,
+		exemptPaths: []string{
+			"/api/v1/health",
+			"/api/health",
+			"/api/admin/maintenance",
+		},
+	}
+}
+
+// Enable puts the API into maintenance mode
+func (m *MaintenanceMode) Enable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enabled = true
+}
+
+// Disable takes the API out of maintenance mode
+func (m *MaintenanceMode) Disable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enabled = false
+}
+
+// IsEnabled returns whether maintenance mode is active
+func (m *MaintenanceMode) IsEnabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled
+}
+
+// RetryAfter returns the Retry-After duration advertised to clients
+func (m *MaintenanceMode) RetryAfter() time.Duration {
+	return m.retryAfter
+}
+
+// Middleware returns a Gin middleware that responds with 503 while maintenance mode is enabled
+func (m *MaintenanceMode) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !m.IsEnabled() {
+			c.Next()
+			return
+		}
+
+		path := c.Request.URL.Path
+		for _, exemptPath := range m.exemptPaths {
+			if strings.HasPrefix(path, exemptPath) {
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("Retry-After", strconv.Itoa(int(m.retryAfter.Seconds())))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Service is under maintenance",
+			"message": "Please try again later",
+		})
+		c.Abort()
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	maintenance := NewMaintenanceMode(false, 2*time.Minute)
+
+	router := gin.New()
+	api := router.Group("/api")
+	api.Use(maintenance.Middleware())
+	api.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
+	})
+	api.GET("/sessions", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"sessions": []string{}})
+	})
+	api.PUT("/admin/maintenance", func(c *gin.Context) {
+		maintenance.Disable()
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+	})
+
+	request := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Disabled: API calls pass through
+	assert.Equal(t, http.StatusOK, request("GET", "/api/sessions").Code)
+
+	maintenance.Enable()
+	assert.True(t, maintenance.IsEnabled())
+
+	// Enabled: API calls get 503 with Retry-After
+	w := request("GET", "/api/sessions")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "120", w.Header().Get("Retry-After"))
+
+	// Health remains accessible
+	assert.Equal(t, http.StatusOK, request("GET", "/api/health").Code)
+
+	// The toggle endpoint remains accessible and can disable maintenance
+	assert.Equal(t, http.StatusOK, request("PUT", "/api/admin/maintenance").Code)
+	assert.False(t, maintenance.IsEnabled())
+	assert.Equal(t, http.StatusOK, request("GET", "/api/sessions").Code)
+}
@@ -1,25 +1,39 @@
 package middleware
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// RateLimiter tracks request rates per IP address
+// RateLimiter tracks request rates per client (API key, or IP address without a valid key)
 type RateLimiter struct {
 	visitors map[string]*Visitor
-	mu       sync.RWMutex
+	mu       sync.Mutex
 	rate     time.Duration // Rate limit interval
 	capacity int           // Number of requests allowed per interval
 }
 
-// Visitor represents a client's request history
+// Visitor is a client's token bucket, refilled lazily on each request
 type Visitor struct {
-	limiter  chan struct{}
-	lastSeen time.Time
+	tokens     float64
+	lastRefill time.Time
+	lastSeen   time.Time
+}
+
+// RateLimitStatus is the outcome of a rate limit check for one request
+type RateLimitStatus struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Time     // When the bucket is full again
+	RetryAfter time.Duration // Wait until the next request is allowed; zero when allowed
 }
 
 // NewRateLimiter creates a new rate limiter
@@ -35,56 +49,9 @@ func NewRateLimiter(requestsPerMinute int) *RateLimiter {
 	return rl
 }
 
-// getVisitor returns the rate limiter for the given IP
-func (rl *RateLimiter) getVisitor(ip string) *Visitor {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	v, exists := rl.visitors[ip]
-	if !exists {
-		// Create a new visitor with a buffered channel as a token bucket
-		limiter := make(chan struct{}, rl.capacity)
-		
-		// Fill the bucket initially
-		for i := 0; i < rl.capacity; i++ {
-			limiter <- struct{}{}
-		}
-
-		v = &Visitor{
-			limiter:  limiter,
-			lastSeen: time.Now(),
-		}
-		rl.visitors[ip] = v
-
-		// Start token refill goroutine for this visitor
-		go rl.refillTokens(v)
-	}
-
-	v.lastSeen = time.Now()
-	return v
-}
-
-// refillTokens refills the token bucket at the specified rate
-func (rl *RateLimiter) refillTokens(v *Visitor) {
-	ticker := time.NewTicker(rl.rate / time.Duration(rl.capacity))
-	defer ticker.Stop()
-
-	for range ticker.C {
-		select {
-		case v.limiter <- struct{}{}:
-			// Token added successfully
-		default:
-			// Bucket is full, skip
-		}
-
-		// Check if visitor is still active
-		rl.mu.RLock()
-		if time.Since(v.lastSeen) > 5*time.Minute {
-			rl.mu.RUnlock()
-			return // Stop refilling for inactive visitors
-		}
-		rl.mu.RUnlock()
-	}
+// refillInterval is the time it takes to add one token to a bucket
+func (rl *RateLimiter) refillInterval() time.Duration {
+	return rl.rate / time.Duration(rl.capacity)
 }
 
 // cleanupVisitors removes visitors that haven't been seen for a while
@@ -94,35 +61,81 @@ func (rl *RateLimiter) cleanupVisitors() {
 
 	for range ticker.C {
 		rl.mu.Lock()
-		for ip, v := range rl.visitors {
+		for key, v := range rl.visitors {
 			if time.Since(v.lastSeen) > 5*time.Minute {
-				delete(rl.visitors, ip)
+				delete(rl.visitors, key)
 			}
 		}
 		rl.mu.Unlock()
 	}
 }
 
+// Check takes a token from the client's bucket if one is available and reports the client's quota
+func (rl *RateLimiter) Check(key string) RateLimitStatus {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	v, exists := rl.visitors[key]
+	if !exists {
+		// New clients start with a full bucket
+		v = &Visitor{tokens: float64(rl.capacity), lastRefill: now}
+		rl.visitors[key] = v
+	}
+	v.lastSeen = now
+
+	// Refill for the time elapsed since the last request
+	elapsed := now.Sub(v.lastRefill)
+	v.tokens = math.Min(float64(rl.capacity), v.tokens+float64(elapsed)/float64(rl.refillInterval()))
+	v.lastRefill = now
+
+	status := RateLimitStatus{Limit: rl.capacity}
+	if v.tokens >= 1 {
+		v.tokens--
+		status.Allowed = true
+	} else {
+		status.RetryAfter = time.Duration((1 - v.tokens) * float64(rl.refillInterval()))
+	}
+	status.Remaining = int(v.tokens)
+	status.Reset = now.Add(time.Duration((float64(rl.capacity) - v.tokens) * float64(rl.refillInterval())))
+
+	return status
+}
+
 // Allow checks if a request should be allowed
-func (rl *RateLimiter) Allow(ip string) bool {
-	visitor := rl.getVisitor(ip)
-
-	select {
-	case <-visitor.limiter:
-		return true
-	default:
-		return false
+func (rl *RateLimiter) Allow(key string) bool {
+	return rl.Check(key).Allowed
+}
+
+// rateLimitKey identifies the caller by API key, or by client IP unless isValidKey accepts
+// the key sent. Unvalidated keys are ignored so a caller cannot get a fresh bucket, and grow
+// the visitor map, by sending a different key with every request.
+// Keys are hashed so raw API keys are not kept in memory.
+func rateLimitKey(c *gin.Context, isValidKey func(string) bool) string {
+	apiKey := providedAPIKey(c)
+	if apiKey == "" || !isValidKey(apiKey) {
+		return "ip:" + c.ClientIP()
 	}
+
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key:" + hex.EncodeToString(sum[:16])
 }
 
-// RateLimitMiddleware returns a Gin middleware for rate limiting
-func RateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
+// RateLimitMiddleware returns a Gin middleware for rate limiting.
+// Each API key accepted by isValidKey (or client IP otherwise) has its own quota,
+// reported in X-RateLimit-* headers.
+func RateLimitMiddleware(requestsPerMinute int, isValidKey func(string) bool) gin.HandlerFunc {
 	limiter := NewRateLimiter(requestsPerMinute)
 
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		
-		if !limiter.Allow(ip) {
+		status := limiter.Check(rateLimitKey(c, isValidKey))
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(status.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(status.Reset.Unix(), 10))
+
+		if !status.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(status.RetryAfter.Seconds()))))
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error": "Rate limit exceeded. Please try again later.",
 			})
@@ -132,4 +145,4 @@ func RateLimitMiddleware(requestsPerMinute int) gin.HandlerFunc {
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
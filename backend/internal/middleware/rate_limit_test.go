@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	validKeys := map[string]bool{"key-a": true, "key-b": true}
+	router.Use(RateLimitMiddleware(2, func(key string) bool { return validKeys[key] }))
+	router.GET("/api/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	request := func(apiKey, ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/test", nil)
+		req.RemoteAddr = ip + ":12345"
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Headers report the quota left for the caller
+	w := request("key-a", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Now().Add(30*time.Second).Unix(), reset, 2)
+
+	w = request("key-a", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	// The exhausted key is rejected with Retry-After
+	w = request("key-a", "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.True(t, retryAfter > 0 && retryAfter <= 30, "unexpected Retry-After %d", retryAfter)
+
+	// Another key from the same address has its own bucket
+	w = request("key-b", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+
+	// Unauthenticated callers are limited by IP
+	assert.Equal(t, http.StatusOK, request("", "10.0.0.2").Code)
+	assert.Equal(t, http.StatusOK, request("", "10.0.0.2").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("", "10.0.0.2").Code)
+	assert.Equal(t, http.StatusOK, request("", "10.0.0.3").Code)
+
+	// Invalid keys don't get their own bucket, so new keys cannot evade the IP limit
+	assert.Equal(t, http.StatusOK, request("random-1", "10.0.0.4").Code)
+	assert.Equal(t, http.StatusOK, request("random-2", "10.0.0.4").Code)
+	assert.Equal(t, http.StatusTooManyRequests, request("random-3", "10.0.0.4").Code)
+}
+
+func TestRateLimiter_Refill(t *testing.T) {
+	limiter := &RateLimiter{
+		visitors: make(map[string]*Visitor),
+		rate:     time.Minute,
+		capacity: 60,
+	}
+
+	for i := 0; i < 60; i++ {
+		assert.True(t, limiter.Allow("client"), "request %d should be allowed", i)
+	}
+	assert.False(t, limiter.Allow("client"))
+
+	// One token is added back per second
+	limiter.visitors["client"].lastRefill = time.Now().Add(-2 * time.Second)
+	status := limiter.Check("client")
+	assert.True(t, status.Allowed)
+	assert.Equal(t, 1, status.Remaining)
+}
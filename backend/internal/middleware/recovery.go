@@ -1,8 +1,9 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"runtime"
 	"runtime/debug"
@@ -19,8 +20,8 @@ func RecoveryMiddleware() gin.HandlerFunc {
 				stack := debug.Stack()
 				
 				// Log the panic with stack trace
-				log.Printf("PANIC RECOVERED: %v\nRequest: %s %s\nStack trace:\n%s", 
-					r, c.Request.Method, c.Request.URL.Path, stack)
+				slog.Error("Panic recovered", "panic", fmt.Sprint(r), "method", c.Request.Method,
+					"path", c.Request.URL.Path, "request_id", GetRequestID(c), "stack", string(stack))
 
 				// Return 500 Internal Server Error
 				c.JSON(http.StatusInternalServerError, gin.H{
@@ -35,8 +36,9 @@ func RecoveryMiddleware() gin.HandlerFunc {
 	}
 }
 
-// SafeGoRoutine runs a function in a goroutine with panic recovery
-func SafeGoRoutine(name string, fn func()) {
+// SafeGoRoutine runs a function in a goroutine with panic recovery.
+// ctx is only used for logging: a panic is logged with the request ID it carries.
+func SafeGoRoutine(ctx context.Context, name string, fn func()) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -44,7 +46,7 @@ func SafeGoRoutine(name string, fn func()) {
 				buf := make([]byte, 1024*64)
 				buf = buf[:runtime.Stack(buf, false)]
 
-				log.Printf("PANIC in goroutine '%s': %v\nStack trace:\n%s", name, r, buf)
+				ContextLogger(ctx).Error("Panic in goroutine", "goroutine", name, "panic", fmt.Sprint(r), "stack", string(buf))
 			}
 		}()
 
@@ -52,8 +54,9 @@ func SafeGoRoutine(name string, fn func()) {
 	}()
 }
 
-// SafeGoRoutineWithErrorCallback runs a function in a goroutine with panic recovery and error callback
-func SafeGoRoutineWithErrorCallback(name string, fn func() error, onError func(error)) {
+// SafeGoRoutineWithErrorCallback runs a function in a goroutine with panic recovery and error callback.
+// ctx is only used for logging, as in SafeGoRoutine.
+func SafeGoRoutineWithErrorCallback(ctx context.Context, name string, fn func() error, onError func(error)) {
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
@@ -61,7 +64,7 @@ func SafeGoRoutineWithErrorCallback(name string, fn func() error, onError func(e
 				buf := make([]byte, 1024*64)
 				buf = buf[:runtime.Stack(buf, false)]
 
-				log.Printf("PANIC in goroutine '%s': %v\nStack trace:\n%s", name, r, buf)
+				ContextLogger(ctx).Error("Panic in goroutine", "goroutine", name, "panic", fmt.Sprint(r), "stack", string(buf))
 				
 				// Call error callback with panic error
 				panicErr := fmt.Errorf("goroutine panic: %v", r)
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"ccdash-backend/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// RequestIDHeader carries the request ID in requests and responses
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey is the gin context key holding the request ID
+	RequestIDKey = "request_id"
+)
+
+// requestIDContextKey is the context key holding the request ID
+type requestIDContextKey struct{}
+
+// Incoming request IDs are honored only when they are short and made of safe characters
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestIDWriter holds back error responses so the request ID can be added to their JSON body
+type requestIDWriter struct {
+	gin.ResponseWriter
+	buffered bool
+	body     bytes.Buffer
+}
+
+func (w *requestIDWriter) WriteHeader(code int) {
+	w.buffered = code >= http.StatusBadRequest
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *requestIDWriter) Write(data []byte) (int, error) {
+	if w.buffered {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *requestIDWriter) WriteString(s string) (int, error) {
+	if w.buffered {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// RequestID returns a Gin middleware that assigns each request an ID, or keeps a valid
+// incoming X-Request-ID, stores it in the gin and request contexts and echoes it in the
+// response header. JSON error responses get the ID as a request_id field.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set(RequestIDKey, requestID)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+
+		original := c.Writer
+		writer := &requestIDWriter{ResponseWriter: original}
+		c.Writer = writer
+		c.Next()
+		c.Writer = original
+
+		if writer.body.Len() == 0 {
+			return
+		}
+
+		body := writer.body.Bytes()
+		var fields map[string]interface{}
+		if strings.Contains(original.Header().Get("Content-Type"), "json") && json.Unmarshal(body, &fields) == nil && fields != nil {
+			if _, exists := fields[RequestIDKey]; !exists {
+				fields[RequestIDKey] = requestID
+				if encoded, err := json.Marshal(fields); err == nil {
+					body = encoded
+				}
+			}
+		}
+		original.Write(body)
+	}
+}
+
+// GetRequestID returns the ID of the current request, empty outside the RequestID middleware
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// WithRequestID returns a copy of ctx carrying requestID, so work started by a request
+// can log the request's ID after it has been answered
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, empty if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
+
+// ContextLogger returns the default logger, with the request ID carried by ctx as the
+// request_id field if there is one
+func ContextLogger(ctx context.Context) *slog.Logger {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		return slog.Default().With("request_id", requestID)
+	}
+	return slog.Default()
+}
+
+// RequestLogf logs a message with the ID of the current request as the request_id field
+func RequestLogf(c *gin.Context, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if requestID := GetRequestID(c); requestID != "" {
+		slog.Info(message, "request_id", requestID)
+		return
+	}
+	slog.Info(message)
+}
+
+// AccessLog returns the access log middleware for the given log format.
+// Text uses gin's log line with the request ID appended; JSON logs structured fields.
+func AccessLog(format string) gin.HandlerFunc {
+	if format != logging.FormatJSON {
+		return gin.LoggerWithFormatter(RequestLogFormatter)
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip", c.ClientIP(),
+			"request_id", GetRequestID(c),
+		}
+		if errors := c.Errors.ByType(gin.ErrorTypePrivate).String(); errors != "" {
+			attrs = append(attrs, "errors", errors)
+		}
+		slog.Info("Request", attrs...)
+	}
+}
+
+// RequestLogFormatter formats access log lines like gin's default logger, with the request ID
+func RequestLogFormatter(param gin.LogFormatterParams) string {
+	requestID, _ := param.Keys[RequestIDKey].(string)
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v | %s\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency.Round(time.Microsecond),
+		param.ClientIP,
+		param.Method,
+		param.Path,
+		requestID,
+		param.ErrorMessage,
+	)
+}
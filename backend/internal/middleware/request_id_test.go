@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ccdash-backend/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var seen, seenInRequest string
+	router := gin.New()
+	router.Use(RequestID(), RecoveryMiddleware())
+	router.GET("/ok", func(c *gin.Context) {
+		seen = GetRequestID(c)
+		seenInRequest = RequestIDFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	request := func(path, requestID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if requestID != "" {
+			req.Header.Set(RequestIDHeader, requestID)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A generated ID is stored in the context and echoed back; success bodies are untouched
+	w := request("/ok", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, seen)
+	assert.Equal(t, seen, w.Header().Get(RequestIDHeader))
+	assert.Equal(t, seen, seenInRequest)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+
+	// A valid incoming ID is kept, an invalid one replaced
+	w = request("/ok", "trace-123")
+	assert.Equal(t, "trace-123", w.Header().Get(RequestIDHeader))
+	w = request("/ok", "bad id\n")
+	assert.NotEqual(t, "bad id\n", w.Header().Get(RequestIDHeader))
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+
+	// Error bodies include the ID
+	errorBody := func(w *httptest.ResponseRecorder) map[string]interface{} {
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body
+	}
+
+	w = request("/missing", "trace-404")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	body := errorBody(w)
+	assert.Equal(t, "trace-404", body["request_id"])
+	assert.Equal(t, "Session not found", body["error"])
+
+	w = request("/panic", "trace-500")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "trace-500", errorBody(w)["request_id"])
+}
+
+func TestAccessLog_JSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	router := gin.New()
+	router.Use(RequestID(), AccessLog(logging.FormatJSON))
+	router.GET("/jobs", func(c *gin.Context) {
+		RequestLogf(c, "Listing jobs for %s", "project-1")
+		c.JSON(http.StatusOK, gin.H{"jobs": []string{}})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/jobs", nil)
+	req.Header.Set(RequestIDHeader, "trace-1")
+	router.ServeHTTP(w, req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var handlerLog, accessLog map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &handlerLog))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &accessLog))
+
+	assert.Equal(t, "Listing jobs for project-1", handlerLog["msg"])
+	assert.Equal(t, "trace-1", handlerLog["request_id"])
+
+	assert.Equal(t, "GET", accessLog["method"])
+	assert.Equal(t, "/jobs", accessLog["path"])
+	assert.Equal(t, float64(http.StatusOK), accessLog["status"])
+	assert.Equal(t, "trace-1", accessLog["request_id"])
+}
+
+func TestSafeGoRoutine_LogsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	done := make(chan error, 1)
+	ctx := WithRequestID(context.Background(), "trace-go")
+	SafeGoRoutineWithErrorCallback(ctx, "worker", func() error {
+		panic("boom")
+	}, func(err error) { done <- err })
+	require.Error(t, <-done)
+
+	var panicLog map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &panicLog))
+	assert.Equal(t, "Panic in goroutine", panicLog["msg"])
+	assert.Equal(t, "worker", panicLog["goroutine"])
+	assert.Equal(t, "trace-go", panicLog["request_id"])
+}
@@ -0,0 +1,85 @@
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var migrationNamePattern = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// NormalizeMigrationName turns a name like "Add foo-column" into "add_foo_column"
+// and checks it only contains letters, digits and underscores
+func NormalizeMigrationName(name string) (string, error) {
+	normalized := strings.ToLower(strings.TrimSpace(name))
+	normalized = strings.Join(strings.FieldsFunc(normalized, func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_'
+	}), "_")
+
+	if !migrationNamePattern.MatchString(normalized) {
+		return "", fmt.Errorf("invalid migration name %q: use letters, digits and underscores", name)
+	}
+	return normalized, nil
+}
+
+// CreateMigration writes an up/down migration pair for name into dir, versioned with now in UTC.
+// It refuses to overwrite files or to reuse the name of an existing migration,
+// and returns the paths of the created files.
+func CreateMigration(dir, name string, now time.Time) (upPath, downPath string, err error) {
+	name, err = NormalizeMigrationName(name)
+	if err != nil {
+		return "", "", err
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("migrations directory not found: %w", err)
+	}
+	if !info.IsDir() {
+		return "", "", fmt.Errorf("migrations directory %s is not a directory", dir)
+	}
+
+	existing, err := NewScanner(os.DirFS(dir)).Scan()
+	if err != nil {
+		return "", "", err
+	}
+	version := now.UTC().Format("20060102150405")
+	description := strings.ReplaceAll(name, "_", " ")
+	for _, pair := range existing {
+		if pair.Name == description {
+			return "", "", fmt.Errorf("migration %s_%s already exists", pair.Version, name)
+		}
+		if pair.Version == version {
+			return "", "", fmt.Errorf("migration version %s already exists", version)
+		}
+	}
+
+	base := filepath.Join(dir, version+"_"+name)
+	upPath, downPath = base+".up.sql", base+".down.sql"
+
+	if err := writeNewFile(upPath, fmt.Sprintf("-- Migration: %s\n-- Version: %s\n-- Write the forward migration below\n\n", description, version)); err != nil {
+		return "", "", err
+	}
+	if err := writeNewFile(downPath, fmt.Sprintf("-- Migration: %s (rollback)\n-- Version: %s\n-- Undo everything the up migration does\n\n", description, version)); err != nil {
+		os.Remove(upPath)
+		return "", "", err
+	}
+
+	return upPath, downPath, nil
+}
+
+// writeNewFile creates path with content, failing if it already exists
+func writeNewFile(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create migration file: %w", err)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write migration file %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,68 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 8, 20, 9, 30, 15, 0, time.FixedZone("JST", 9*60*60))
+
+	upPath, downPath, err := CreateMigration(dir, "Add foo-column", now)
+	if err != nil {
+		t.Fatalf("CreateMigration failed: %v", err)
+	}
+
+	// The version is the UTC timestamp
+	if filepath.Base(upPath) != "20250820003015_add_foo_column.up.sql" {
+		t.Errorf("Unexpected up file %s", upPath)
+	}
+	if filepath.Base(downPath) != "20250820003015_add_foo_column.down.sql" {
+		t.Errorf("Unexpected down file %s", downPath)
+	}
+	content, err := os.ReadFile(upPath)
+	if err != nil {
+		t.Fatalf("Failed to read up file: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "-- Migration: add foo column\n-- Version: 20250820003015\n") {
+		t.Errorf("Unexpected up file header:\n%s", content)
+	}
+
+	// The files are picked up by the scanner as one pair
+	pairs, err := NewScanner(os.DirFS(dir)).Scan()
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if len(pairs) != 1 || pairs[0].Version != "20250820003015" || pairs[0].Name != "add foo column" || pairs[0].Up == nil || pairs[0].Down == nil {
+		t.Errorf("Expected one complete migration pair, got %+v", pairs)
+	}
+
+	// Same name later, or another name in the same second, is refused
+	if _, _, err := CreateMigration(dir, "add_foo_column", now.Add(time.Hour)); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected duplicate name to be refused, got %v", err)
+	}
+	if _, _, err := CreateMigration(dir, "add_bar_column", now); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("Expected duplicate version to be refused, got %v", err)
+	}
+
+	for _, name := range []string{"", "  ", "add.foo", "drop 'x'"} {
+		if _, _, err := CreateMigration(dir, name, now.Add(2*time.Hour)); err == nil {
+			t.Errorf("Expected name %q to be rejected", name)
+		}
+	}
+	if _, _, err := CreateMigration(filepath.Join(dir, "missing"), "add_baz", now); err == nil {
+		t.Error("Expected a missing directory to be rejected")
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to list directory: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected only the first pair to be written, found %d files", len(files))
+	}
+}
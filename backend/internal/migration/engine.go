@@ -83,17 +83,71 @@ func (e *Engine) Up() error {
 
 // Down rolls back the last migration
 func (e *Engine) Down() error {
-	// Get the last applied migration
-	applied, err := e.vm.GetAppliedVersions()
+	migrationPair, err := e.lastAppliedMigration()
 	if err != nil {
-		return fmt.Errorf("failed to get applied versions: %w", err)
+		return err
 	}
 	
-	if len(applied) == 0 {
+	if migrationPair == nil {
 		log.Println("No migrations to roll back")
 		return nil
 	}
 	
+	log.Printf("Rolling back migration %s: %s", migrationPair.Version, migrationPair.Name)
+	if err := e.executor.ExecuteDown(*migrationPair); err != nil {
+		return fmt.Errorf("failed to roll back migration %s: %w", migrationPair.Version, err)
+	}
+	log.Printf("Successfully rolled back migration %s", migrationPair.Version)
+	
+	return nil
+}
+
+// Redo rolls back the last applied migration and applies it again.
+// It refuses to run when the database is in a dirty state.
+func (e *Engine) Redo() error {
+	currentVersion, dirty, err := e.vm.GetCurrentVersion()
+	if err != nil {
+		return fmt.Errorf("failed to get current version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("database is in a dirty state at version %s, fix it before running redo", currentVersion)
+	}
+	
+	migrationPair, err := e.lastAppliedMigration()
+	if err != nil {
+		return err
+	}
+	
+	if migrationPair == nil {
+		log.Println("No migrations to redo")
+		return nil
+	}
+	if migrationPair.Up == nil {
+		return fmt.Errorf("no up migration found for version %s", migrationPair.Version)
+	}
+	
+	log.Printf("Rolling back migration %s: %s", migrationPair.Version, migrationPair.Name)
+	if err := e.executor.ExecuteDown(*migrationPair); err != nil {
+		return fmt.Errorf("failed to roll back migration %s: %w", migrationPair.Version, err)
+	}
+	
+	log.Printf("Re-applying migration %s: %s", migrationPair.Version, migrationPair.Name)
+	if err := e.executor.ExecuteUp(*migrationPair); err != nil {
+		return fmt.Errorf("failed to re-apply migration %s: %w", migrationPair.Version, err)
+	}
+	log.Printf("Successfully redid migration %s", migrationPair.Version)
+	
+	return nil
+}
+
+// lastAppliedMigration returns the migration pair of the last successfully applied migration,
+// or nil if there is none
+func (e *Engine) lastAppliedMigration() (*MigrationPair, error) {
+	applied, err := e.vm.GetAppliedVersions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied versions: %w", err)
+	}
+	
 	// Get the last successful migration
 	var lastMigration *Version
 	for i := len(applied) - 1; i >= 0; i-- {
@@ -104,35 +158,22 @@ func (e *Engine) Down() error {
 	}
 	
 	if lastMigration == nil {
-		log.Println("No successful migrations to roll back")
-		return nil
+		return nil, nil
 	}
 	
 	// Find the migration pair
 	allMigrations, err := e.scanner.Scan()
 	if err != nil {
-		return fmt.Errorf("failed to scan migrations: %w", err)
+		return nil, fmt.Errorf("failed to scan migrations: %w", err)
 	}
 	
-	var migrationPair *MigrationPair
 	for _, m := range allMigrations {
 		if m.Version == lastMigration.Version {
-			migrationPair = &m
-			break
+			return &m, nil
 		}
 	}
 	
-	if migrationPair == nil {
-		return fmt.Errorf("migration file not found for version %s", lastMigration.Version)
-	}
-	
-	log.Printf("Rolling back migration %s: %s", migrationPair.Version, migrationPair.Name)
-	if err := e.executor.ExecuteDown(*migrationPair); err != nil {
-		return fmt.Errorf("failed to roll back migration %s: %w", migrationPair.Version, err)
-	}
-	log.Printf("Successfully rolled back migration %s", migrationPair.Version)
-	
-	return nil
+	return nil, fmt.Errorf("migration file not found for version %s", lastMigration.Version)
 }
 
 // Status returns the current migration status
@@ -152,6 +193,10 @@ func (e *Engine) Status() (*Status, error) {
 		return nil, fmt.Errorf("failed to scan pending migrations: %w", err)
 	}
 	
+	if err := e.markModified(applied); err != nil {
+		return nil, err
+	}
+	
 	return &Status{
 		CurrentVersion: currentVersion,
 		Dirty:          dirty,
@@ -160,6 +205,43 @@ func (e *Engine) Status() (*Status, error) {
 	}, nil
 }
 
+// markModified sets the status of applied migrations whose up file no longer matches
+// the checksum recorded when they were applied to "modified".
+// Records from before checksums were stored are compared against the stored up script.
+func (e *Engine) markModified(applied []Version) error {
+	allMigrations, err := e.scanner.Scan()
+	if err != nil {
+		return fmt.Errorf("failed to scan migrations: %w", err)
+	}
+	
+	upContent := make(map[string]string, len(allMigrations))
+	for _, m := range allMigrations {
+		if m.Up != nil {
+			upContent[m.Version] = m.Up.Content
+		}
+	}
+	
+	for i := range applied {
+		if applied[i].Status != "success" {
+			continue
+		}
+		content, ok := upContent[applied[i].Version]
+		if !ok {
+			continue
+		}
+		
+		recorded := Checksum(applied[i].UpScript)
+		if applied[i].Checksum != nil && *applied[i].Checksum != "" {
+			recorded = *applied[i].Checksum
+		}
+		if Checksum(content) != recorded {
+			applied[i].Status = "modified"
+		}
+	}
+	
+	return nil
+}
+
 // Status represents the migration status
 type Status struct {
 	CurrentVersion string
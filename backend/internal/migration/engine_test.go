@@ -0,0 +1,152 @@
+package migration
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func setupEngineTest(t *testing.T) (*sql.DB, *Engine, string) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"20250101000000_create_foo.up.sql":   "CREATE TABLE foo (id INTEGER PRIMARY KEY);",
+		"20250101000000_create_foo.down.sql": "DROP TABLE foo;",
+		"20250102000000_create_bar.up.sql":   "CREATE TABLE bar (id INTEGER PRIMARY KEY); INSERT INTO bar VALUES (1);",
+		"20250102000000_create_bar.down.sql": "DROP TABLE bar;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	db, err := sql.Open("duckdb", "")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	engine, err := NewEngine(db, dir)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	return db, engine, dir
+}
+
+func TestEngineRedo(t *testing.T) {
+	db, engine, _ := setupEngineTest(t)
+
+	// Nothing applied yet
+	if err := engine.Redo(); err != nil {
+		t.Fatalf("Redo with no applied migrations failed: %v", err)
+	}
+
+	if err := engine.Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO bar VALUES (2)"); err != nil {
+		t.Fatalf("Failed to insert row: %v", err)
+	}
+
+	if err := engine.Redo(); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+
+	// Only the last migration was rolled back and applied again
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM bar").Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected bar to be recreated with 1 row, got %d", count)
+	}
+	if _, err := db.Exec("SELECT * FROM foo"); err != nil {
+		t.Errorf("Expected foo to be kept: %v", err)
+	}
+
+	status, err := engine.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.CurrentVersion != "20250102000000" || status.Dirty {
+		t.Errorf("Expected clean version 20250102000000, got %s (dirty=%v)", status.CurrentVersion, status.Dirty)
+	}
+	if len(status.Applied) != 2 || status.Applied[1].Status != "success" || len(status.Pending) != 0 {
+		t.Errorf("Expected both migrations applied once, got applied=%+v pending=%+v", status.Applied, status.Pending)
+	}
+
+	// A dirty database is refused
+	if err := engine.vm.SetVersion("20250102000000", true); err != nil {
+		t.Fatalf("Failed to set dirty flag: %v", err)
+	}
+	if err := engine.Redo(); err == nil || !strings.Contains(err.Error(), "dirty") {
+		t.Errorf("Expected redo to refuse a dirty database, got %v", err)
+	}
+}
+
+func TestEngineDownThenUp(t *testing.T) {
+	_, engine, _ := setupEngineTest(t)
+
+	if err := engine.Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if err := engine.Down(); err != nil {
+		t.Fatalf("Down failed: %v", err)
+	}
+	// Re-applying a rolled back migration replaces its history record
+	if err := engine.Up(); err != nil {
+		t.Fatalf("Up after down failed: %v", err)
+	}
+
+	status, err := engine.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status.Applied) != 2 || status.Applied[1].Status != "success" {
+		t.Errorf("Expected the rolled back migration to be applied again, got %+v", status.Applied)
+	}
+}
+
+func TestEngineStatus_DetectsModifiedMigrations(t *testing.T) {
+	db, engine, dir := setupEngineTest(t)
+
+	if err := engine.Up(); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	var checksum string
+	if err := db.QueryRow("SELECT checksum FROM migration_history WHERE version = '20250101000000'").Scan(&checksum); err != nil {
+		t.Fatalf("Failed to get checksum: %v", err)
+	}
+	if checksum != Checksum("CREATE TABLE foo (id INTEGER PRIMARY KEY);") {
+		t.Errorf("Expected the checksum of the up script to be recorded, got %s", checksum)
+	}
+
+	// Edit the first migration after it was applied
+	err := os.WriteFile(filepath.Join(dir, "20250101000000_create_foo.up.sql"), []byte("CREATE TABLE foo (id INTEGER PRIMARY KEY, name VARCHAR);"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to edit migration: %v", err)
+	}
+	// Records without a checksum are compared against the stored up script
+	if _, err := db.Exec("UPDATE migration_history SET checksum = NULL WHERE version = '20250102000000'"); err != nil {
+		t.Fatalf("Failed to clear checksum: %v", err)
+	}
+
+	status, err := engine.Status()
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if len(status.Applied) != 2 {
+		t.Fatalf("Expected 2 applied migrations, got %d", len(status.Applied))
+	}
+	if status.Applied[0].Status != "modified" {
+		t.Errorf("Expected the edited migration to be modified, got %s", status.Applied[0].Status)
+	}
+	if status.Applied[1].Status != "success" {
+		t.Errorf("Expected the unchanged migration to be success, got %s", status.Applied[1].Status)
+	}
+}
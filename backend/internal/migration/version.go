@@ -1,7 +1,9 @@
 package migration
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"regexp"
 	"strings"
@@ -18,6 +20,7 @@ type Version struct {
 	ExecutionTime *int
 	Status        string
 	ErrorMessage  *string
+	Checksum      *string
 }
 
 // VersionManager manages migration versions
@@ -76,7 +79,7 @@ func (vm *VersionManager) CreateTables() error {
 // GetAppliedVersions returns all applied migrations
 func (vm *VersionManager) GetAppliedVersions() ([]Version, error) {
 	rows, err := vm.db.Query(`
-		SELECT version, name, applied_at, execution_time_ms, status, error_message, up_script, down_script
+		SELECT version, name, applied_at, execution_time_ms, status, error_message, up_script, down_script, checksum
 		FROM migration_history
 		ORDER BY version ASC
 	`)
@@ -88,7 +91,7 @@ func (vm *VersionManager) GetAppliedVersions() ([]Version, error) {
 	var versions []Version
 	for rows.Next() {
 		var v Version
-		err := rows.Scan(&v.Version, &v.Name, &v.AppliedAt, &v.ExecutionTime, &v.Status, &v.ErrorMessage, &v.UpScript, &v.DownScript)
+		err := rows.Scan(&v.Version, &v.Name, &v.AppliedAt, &v.ExecutionTime, &v.Status, &v.ErrorMessage, &v.UpScript, &v.DownScript, &v.Checksum)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
@@ -102,7 +105,7 @@ func (vm *VersionManager) GetAppliedVersions() ([]Version, error) {
 func (vm *VersionManager) GetCurrentVersion() (string, bool, error) {
 	var version string
 	var dirty bool
-	err := vm.db.QueryRow("SELECT version, dirty FROM schema_version ORDER BY updated_at DESC LIMIT 1").Scan(&version, &dirty)
+	err := vm.db.QueryRow("SELECT version, dirty FROM schema_version ORDER BY updated_at DESC, version DESC LIMIT 1").Scan(&version, &dirty)
 	if err == sql.ErrNoRows {
 		return "", false, nil
 	}
@@ -115,7 +118,7 @@ func (vm *VersionManager) GetCurrentVersion() (string, bool, error) {
 // SetVersion sets the current schema version
 func (vm *VersionManager) SetVersion(version string, dirty bool) error {
 	_, err := vm.db.Exec(`
-		INSERT INTO schema_version (version, dirty, updated_at)
+		INSERT OR REPLACE INTO schema_version (version, dirty, updated_at)
 		VALUES (?, ?, CURRENT_TIMESTAMP)
 	`, version, dirty)
 	if err != nil {
@@ -124,12 +127,18 @@ func (vm *VersionManager) SetVersion(version string, dirty bool) error {
 	return nil
 }
 
-// RecordMigration records a migration execution
+// RecordMigration records a migration execution.
+// A previous record of the version that was rolled back or failed is replaced.
 func (vm *VersionManager) RecordMigration(version, name, upScript, downScript string, executionTime int, status string, errorMsg *string) error {
-	_, err := vm.db.Exec(`
-		INSERT INTO migration_history (version, name, up_script, down_script, execution_time_ms, status, error_message)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, version, name, upScript, downScript, executionTime, status, errorMsg)
+	_, err := vm.db.Exec("DELETE FROM migration_history WHERE version = ? AND status <> 'success'", version)
+	if err != nil {
+		return fmt.Errorf("failed to clear previous migration record: %w", err)
+	}
+	
+	_, err = vm.db.Exec(`
+		INSERT INTO migration_history (version, name, up_script, down_script, checksum, execution_time_ms, status, error_message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, version, name, upScript, downScript, Checksum(upScript), executionTime, status, errorMsg)
 	if err != nil {
 		return fmt.Errorf("failed to record migration: %w", err)
 	}
@@ -155,4 +164,10 @@ func ParseVersionFromFilename(filename string) (string, string, error) {
 		return "", "", fmt.Errorf("invalid migration filename format: %s", filename)
 	}
 	return matches[1], strings.ReplaceAll(matches[2], "_", " "), nil
-}
\ No newline at end of file
+}
+
+// Checksum returns the SHA-256 hex digest of a migration script
+func Checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -36,6 +37,7 @@ type Message struct {
 	OutputTokens             int       `json:"output_tokens" db:"output_tokens"`
 	ServiceTier              *string   `json:"service_tier" db:"service_tier"`
 	RequestID                *string   `json:"request_id" db:"request_id"`
+	Cost                     float64   `json:"cost" db:"cost"`
 	Timestamp                time.Time `json:"timestamp" db:"timestamp"`
 	CreatedAt                time.Time `json:"created_at" db:"created_at"`
 }
@@ -48,16 +50,115 @@ type SessionWindowMessage struct {
 }
 
 type TokenUsage struct {
-	TotalTokens      int     `json:"total_tokens"`
-	InputTokens      int     `json:"input_tokens"`
-	OutputTokens     int     `json:"output_tokens"`
-	UsageLimit       int     `json:"usage_limit"`
-	UsageRate        float64 `json:"usage_rate"`
-	WindowStart      time.Time `json:"window_start"`
-	WindowEnd        time.Time `json:"window_end"`
-	ActiveSessions   int     `json:"active_sessions"`
-	TotalCost        float64 `json:"total_cost"`
-	TotalMessages    int     `json:"total_messages"`
+	// TotalTokens is what counts against UsageLimit: input plus output tokens, and cache
+	// tokens too when WINDOW_INCLUDE_CACHE_TOKENS is set. Cache tokens are always
+	// reported separately and priced into TotalCost.
+	TotalTokens              int       `json:"total_tokens"`
+	InputTokens              int       `json:"input_tokens"`
+	OutputTokens             int       `json:"output_tokens"`
+	CacheCreationInputTokens int       `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int       `json:"cache_read_input_tokens"`
+	UsageLimit               int       `json:"usage_limit"`
+	UsageRate                float64   `json:"usage_rate"`
+	Plan                     string    `json:"plan"`
+	WindowStart              time.Time `json:"window_start"`
+	WindowEnd                time.Time `json:"window_end"`
+	ActiveSessions           int       `json:"active_sessions"`
+	TotalCost                float64   `json:"total_cost"`
+	TotalMessages            int       `json:"total_messages"`
+}
+
+// Claude plans selectable via CCDASH_PLAN
+const (
+	PlanPro   = "pro"
+	PlanMax5  = "max5"
+	PlanMax20 = "max20"
+)
+
+// Plans lists the plans in order of increasing limit
+var Plans = []string{PlanPro, PlanMax5, PlanMax20}
+
+// IsValidPlan reports whether plan is one of Plans
+func IsValidPlan(plan string) bool {
+	for _, p := range Plans {
+		if plan == p {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenUsageRange represents aggregated token usage over an arbitrary time range
+type TokenUsageRange struct {
+	From                     time.Time         `json:"from"`
+	To                       time.Time         `json:"to"`
+	InputTokens              int               `json:"input_tokens"`
+	OutputTokens             int               `json:"output_tokens"`
+	CacheCreationInputTokens int               `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int               `json:"cache_read_input_tokens"`
+	TotalTokens              int               `json:"total_tokens"`
+	TotalCost                float64           `json:"total_cost"`
+	TotalMessages            int               `json:"total_messages"`
+	Daily                    []DailyTokenUsage `json:"daily,omitempty"`
+}
+
+// DailyTokenUsage represents token usage aggregated for a single day
+type DailyTokenUsage struct {
+	Date                     string  `json:"date"`
+	InputTokens              int     `json:"input_tokens"`
+	OutputTokens             int     `json:"output_tokens"`
+	CacheCreationInputTokens int     `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int     `json:"cache_read_input_tokens"`
+	TotalTokens              int     `json:"total_tokens"`
+	TotalCost                float64 `json:"total_cost"`
+	TotalMessages            int     `json:"total_messages"`
+}
+
+// DailyUsage is one point of the daily usage time series
+type DailyUsage struct {
+	Date         string  `json:"date"` // YYYY-MM-DD in the server's local timezone
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalTokens  int     `json:"total_tokens"`
+	Cost         float64 `json:"cost"`
+	MessageCount int     `json:"message_count"`
+}
+
+// ActivityHeatmap counts messages by day of week and hour of day.
+// Rows are weekdays starting with Sunday (0), columns are hours 0-23.
+type ActivityHeatmap struct {
+	Days       int        `json:"days"`
+	From       time.Time  `json:"from"`
+	To         time.Time  `json:"to"`
+	Timezone   string     `json:"timezone"`
+	Matrix     [7][24]int `json:"matrix"`
+	DayTotals  [7]int     `json:"day_totals"`
+	HourTotals [24]int    `json:"hour_totals"`
+	Total      int        `json:"total"`
+}
+
+// ModelUsage represents assistant message usage and cost for one model across all data
+type ModelUsage struct {
+	Model                    string  `json:"model"`
+	InputTokens              int     `json:"input_tokens"`
+	OutputTokens             int     `json:"output_tokens"`
+	CacheCreationInputTokens int     `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int     `json:"cache_read_input_tokens"`
+	TotalTokens              int     `json:"total_tokens"`
+	MessageCount             int     `json:"message_count"`
+	TotalCost                float64 `json:"total_cost"`
+	CostShare                float64 `json:"cost_share"`  // Fraction of the cost of all models, 0-1
+	TokenShare               float64 `json:"token_share"` // Fraction of the tokens of all models, 0-1
+}
+
+// MonthlyCostSummary represents the cost breakdown for a calendar month
+type MonthlyCostSummary struct {
+	MonthStart       time.Time          `json:"month_start"`
+	MonthEnd         time.Time          `json:"month_end"`
+	CurrentMonthCost float64            `json:"current_month_cost"`
+	Currency         string             `json:"currency"`
+	ByModel          map[string]float64 `json:"by_model"`
+	ByProject        map[string]float64 `json:"by_project"`
 }
 
 type SessionSummary struct {
@@ -66,6 +167,28 @@ type SessionSummary struct {
 	IsActive        bool          `json:"is_active"`
 	LastActivity    time.Time     `json:"last_activity"`
 	GeneratedCode   []string      `json:"generated_code"`
+	Tags            []string      `json:"tags"`
+}
+
+// SessionFilters for session list queries
+type SessionFilters struct {
+	From       *time.Time // Sessions starting at or after this time
+	To         *time.Time // Sessions starting at or before this time
+	Status     *string
+	Tag        *string // Sessions with this tag
+	ActiveOnly bool
+	Limit      int
+	Offset     int
+}
+
+// MessageSearchHit represents a message matching a full-text search query
+type MessageSearchHit struct {
+	MessageID   string    `json:"message_id"`
+	SessionID   string    `json:"session_id"`
+	ProjectName *string   `json:"project_name"`
+	MessageRole *string   `json:"message_role"`
+	Timestamp   time.Time `json:"timestamp"`
+	Snippet     string    `json:"snippet"`
 }
 
 type LogEntry struct {
@@ -99,6 +222,21 @@ type Usage struct {
 	ServiceTier              string `json:"service_tier"`
 }
 
+// ExhaustionProjection estimates when the active window's token limit will be reached
+type ExhaustionProjection struct {
+	GeneratedAt         time.Time  `json:"generated_at"`
+	WindowStart         time.Time  `json:"window_start"`
+	WindowEnd           time.Time  `json:"window_end"`
+	TotalTokens         int        `json:"total_tokens"`
+	UsageLimit          int        `json:"usage_limit"`
+	RemainingTokens     int        `json:"remaining_tokens"`
+	BurnRatePerHour     float64    `json:"burn_rate_per_hour"`
+	ProjectedExhaustion *time.Time `json:"projected_exhaustion"`
+	MinutesToExhaustion *int       `json:"minutes_to_exhaustion"`
+	ExhaustsBeforeReset bool       `json:"exhausts_before_reset"`
+	Confidence          float64    `json:"confidence"` // 0-1, grows with the amount of recent data
+}
+
 type BurnRatePoint struct {
 	Timestamp     time.Time `json:"timestamp"`
 	TokensPerHour int       `json:"tokens_per_hour"`
@@ -118,6 +256,72 @@ type Project struct {
 	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// ProjectStats represents aggregate usage statistics for a project
+type ProjectStats struct {
+	ProjectID                 string     `json:"project_id"`
+	SessionCount              int        `json:"session_count"`
+	MessageCount              int        `json:"message_count"`
+	TotalTokens               int        `json:"total_tokens"`
+	TotalCost                 float64    `json:"total_cost"`
+	FirstActivity             *time.Time `json:"first_activity"`
+	LastActivity              *time.Time `json:"last_activity"`
+	AverageSessionDurationSec float64    `json:"average_session_duration_seconds"`
+}
+
+// ProjectCostStats describes the distribution of session costs within a project
+type ProjectCostStats struct {
+	ProjectID    string  `json:"project_id"`
+	SessionCount int     `json:"session_count"`
+	TotalCost    float64 `json:"total_cost"`
+	MeanCost     float64 `json:"mean_cost"`
+	MedianCost   float64 `json:"median_cost"`
+	P90Cost      float64 `json:"p90_cost"`
+}
+
+// ProjectLeaderboardEntry is a project ranked by the tokens or cost of its sessions
+type ProjectLeaderboardEntry struct {
+	Rank         int     `json:"rank"`
+	ProjectID    string  `json:"project_id"`
+	ProjectName  string  `json:"project_name"`
+	ProjectPath  string  `json:"project_path"`
+	SessionCount int     `json:"session_count"`
+	TotalTokens  int     `json:"total_tokens"`
+	TotalCost    float64 `json:"total_cost"`
+}
+
+// MergeProjectsRequest represents a request to merge the source project into the target
+type MergeProjectsRequest struct {
+	SourceID string `json:"source_id" binding:"required"`
+	TargetID string `json:"target_id" binding:"required"`
+}
+
+// SessionRepair actions
+const (
+	SessionRepairReassign = "reassign" // Assigned to the project derived from the session's name and path
+	SessionRepairClear    = "clear"    // Dangling project_id set to NULL; the session has no name or path
+)
+
+// SessionRepair is one session changed, or to be changed, by an orphaned-session repair
+type SessionRepair struct {
+	SessionID    string  `json:"session_id"`
+	ProjectName  string  `json:"project_name"`
+	ProjectPath  string  `json:"project_path"`
+	OldProjectID *string `json:"old_project_id"`
+	NewProjectID *string `json:"new_project_id"` // nil when cleared, or when a dry run would create the project
+	Action       string  `json:"action"`
+}
+
+// SessionRepairResult reports the sessions an orphaned-session repair changed, or would change in a dry run
+type SessionRepairResult struct {
+	DryRun          bool            `json:"dry_run"`
+	Orphaned        int             `json:"orphaned"` // Sessions with a NULL or dangling project_id
+	Reassigned      int             `json:"reassigned"`
+	ProjectsCreated int             `json:"projects_created"`
+	Cleared         int             `json:"cleared"`
+	Unresolved      int             `json:"unresolved"` // Left with a NULL project_id
+	Changes         []SessionRepair `json:"changes"`
+}
+
 // Job represents a task execution job
 type Job struct {
 	ID                  string     `json:"id" db:"id"`
@@ -137,11 +341,47 @@ type Job struct {
 	ScheduledAt        *time.Time `json:"scheduled_at" db:"scheduled_at"`
 	ScheduleType       *string    `json:"schedule_type" db:"schedule_type"`
 	ScheduleParams     *string    `json:"schedule_params" db:"schedule_params"`
+	OutputRules        *string    `json:"output_rules" db:"output_rules"`
+	WebhookURL         *string    `json:"webhook_url" db:"webhook_url"`
+	ResourceLimits     *string    `json:"resource_limits" db:"resource_limits"`
+	Env                *string    `json:"env" db:"env"`
 	
 	// リレーション情報（JOIN時に使用）
 	Project            *Project   `json:"project,omitempty"`
 }
 
+// RedactedEnvValue replaces the values of a job's env when the job is encoded as JSON
+const RedactedEnvValue = "[redacted]"
+
+// MarshalJSON encodes the job with the values of its env redacted, so secrets passed to a job
+// never appear in API responses or logs. The variable names are kept.
+func (j Job) MarshalJSON() ([]byte, error) {
+	type job Job // without the MarshalJSON method
+	redacted := job(j)
+	redacted.Env = redactEnv(j.Env)
+	return json.Marshal(redacted)
+}
+
+// redactEnv returns a job env (a JSON object of variables) with every value replaced
+func redactEnv(env *string) *string {
+	if env == nil || *env == "" {
+		return env
+	}
+	var vars map[string]string
+	if err := json.Unmarshal([]byte(*env), &vars); err != nil {
+		return nil
+	}
+	for name := range vars {
+		vars[name] = RedactedEnvValue
+	}
+	encoded, err := json.Marshal(vars)
+	if err != nil {
+		return nil
+	}
+	redacted := string(encoded)
+	return &redacted
+}
+
 // JobStatus constants
 const (
 	JobStatusPending   = "pending"
@@ -151,12 +391,119 @@ const (
 	JobStatusCancelled = "cancelled"
 )
 
+// JobUpdate lists the job columns to change; nil fields are left unchanged
+type JobUpdate struct {
+	Status      *string
+	PID         *int
+	ClearPID    bool // set pid to NULL (ignored if PID is set)
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+	ScheduledAt *time.Time
+	OutputLog   *string
+	ErrorLog    *string
+	ExitCode    *int
+}
+
+// StaleJob reasons
+const (
+	StaleJobReasonProcessGone = "process_not_found"
+	StaleJobReasonTimeout     = "timeout"
+)
+
+// StaleJob is a job marked as running that the executor no longer tracks
+type StaleJob struct {
+	Job            *Job   `json:"job"`
+	Reason         string `json:"reason"`
+	RunningSeconds int64  `json:"running_seconds"`
+}
+
+// VersionInfo reports the running app version and the database migration state
+type VersionInfo struct {
+	AppVersion        string `json:"app_version"`
+	Commit            string `json:"commit"`
+	BuildTime         string `json:"build_time"`
+	CommitTime        string `json:"commit_time,omitempty"`
+	GoVersion         string `json:"go_version"`
+	SchemaVersion     string `json:"schema_version"`
+	LatestVersion     string `json:"latest_version"`
+	Dirty             bool   `json:"dirty"`
+	Behind            bool   `json:"behind"`
+	PendingMigrations int    `json:"pending_migrations"`
+}
+
+// Readiness reports whether the server can serve requests.
+// Only an unreachable database makes the server not ready.
+type Readiness struct {
+	Ready           bool   `json:"ready"`
+	Database        string `json:"database"` // "ok" or the connection error
+	ClaudeAvailable bool   `json:"claude_available"`
+	Initialization  string `json:"initialization"` // initializing, completed or failed
+}
+
+// ServerConfig is the non-secret server configuration the frontend uses to configure itself
+type ServerConfig struct {
+	Version                     string `json:"version"`
+	Plan                        string `json:"plan"`
+	UsageLimit                  int    `json:"usage_limit"`
+	WindowDurationSeconds       int64  `json:"window_duration_seconds"`
+	JobWorkerCount              int    `json:"job_worker_count"`
+	SchedulerPollingSeconds     int64  `json:"scheduler_polling_seconds"`
+	SessionActiveTimeoutSeconds int64  `json:"session_active_timeout_seconds"`
+	AuthEnabled                 bool   `json:"auth_enabled"`
+}
+
+// JobLogStream values for selecting job log output
+const (
+	JobLogStreamStdout = "stdout"
+	JobLogStreamStderr = "stderr"
+	JobLogStreamBoth   = "both"
+)
+
+// JobLogChunk is a slice of lines from one job log stream
+type JobLogChunk struct {
+	Lines      []string `json:"lines"`
+	TotalLines int      `json:"total_lines"`
+	StartLine  int      `json:"start_line"` // 0-based index of the first returned line
+}
+
+// JobLogs holds the requested slice of a job's stdout and/or stderr
+type JobLogs struct {
+	JobID  string       `json:"job_id"`
+	Stream string       `json:"stream"`
+	Tail   int          `json:"tail"`
+	Offset int          `json:"offset"`
+	Stdout *JobLogChunk `json:"stdout,omitempty"`
+	Stderr *JobLogChunk `json:"stderr,omitempty"`
+}
+
+// QueueStatus represents the job executor state with a per-project breakdown
+type QueueStatus struct {
+	RunningJobs         int                  `json:"running_jobs"`
+	QueuedJobs          int                  `json:"queued_jobs"`
+	PendingJobs         int                  `json:"pending_jobs"`
+	WorkerCount         int                  `json:"worker_count"`
+	Paused              bool                 `json:"paused"`
+	ClaudeAvailable     bool                 `json:"claude_available"`
+	SafetyCheckEnabled  bool                 `json:"safety_check_enabled"`
+	OldestPendingAgeSec *int64               `json:"oldest_pending_age_seconds"`
+	Projects            []ProjectQueueStatus `json:"projects"`
+}
+
+// ProjectQueueStatus represents running and pending job counts for a single project
+type ProjectQueueStatus struct {
+	ProjectID           string `json:"project_id"`
+	RunningJobs         int    `json:"running_jobs"`
+	PendingJobs         int    `json:"pending_jobs"`
+	OldestPendingAgeSec *int64 `json:"oldest_pending_age_seconds"`
+}
+
 // ScheduleType constants
 const (
 	ScheduleTypeImmediate  = "immediate"
 	ScheduleTypeAfterReset = "after_reset"
 	ScheduleTypeDelayed    = "delayed"    // N時間後実行
 	ScheduleTypeScheduled  = "scheduled"  // 時刻指定（customを廃止）
+	ScheduleTypeCustom     = "custom"     // 廃止済み: 受け付けず、scheduledへの移行を案内する
 )
 
 // ScheduleParams stores additional scheduling parameters
@@ -165,19 +512,125 @@ type ScheduleParams struct {
 	ScheduledTime *time.Time `json:"scheduled_time,omitempty"` // For scheduled execution
 }
 
+// OutputRule action types
+const (
+	OutputRuleActionWebhook = "webhook"
+	OutputRuleActionEvent   = "event"
+)
+
+// OutputRule triggers an action when a job's captured output matches at completion
+type OutputRule struct {
+	OnOutputContains string           `json:"on_output_contains,omitempty"` // Substring match
+	OnOutputMatches  string           `json:"on_output_matches,omitempty"`  // Regular expression match
+	Action           OutputRuleAction `json:"action"`
+}
+
+// OutputRuleAction describes what to do when an OutputRule matches
+type OutputRuleAction struct {
+	Type       string `json:"type"`                  // webhook or event
+	WebhookURL string `json:"webhook_url,omitempty"` // For webhook actions
+	Event      string `json:"event,omitempty"`       // Event name for event actions
+}
+
 // JobFilters for queries
 type JobFilters struct {
-	ProjectID *string
-	Status    *string
-	Limit     int
-	Offset    int
+	ProjectID       *string
+	Status          *string
+	CommandContains *string    // Case-insensitive substring of the command
+	CreatedAfter    *time.Time // Inclusive
+	CreatedBefore   *time.Time // Exclusive
+	Limit           int
+	Offset          int
+}
+
+// JobStatsFilters selects the jobs counted by job stats. Duration metrics cover
+// completed jobs only unless failed or cancelled jobs are included.
+type JobStatsFilters struct {
+	ProjectID        *string
+	IncludeFailed    bool
+	IncludeCancelled bool
+}
+
+// JobStats summarizes job counts by status and run durations
+type JobStats struct {
+	ProjectID          *string  `json:"project_id,omitempty"`
+	TotalJobs          int      `json:"total_jobs"`
+	PendingJobs        int      `json:"pending_jobs"`
+	RunningJobs        int      `json:"running_jobs"`
+	CompletedJobs      int      `json:"completed_jobs"`
+	FailedJobs         int      `json:"failed_jobs"`
+	CancelledJobs      int      `json:"cancelled_jobs"`
+	DurationStatuses   []string `json:"duration_statuses"`
+	DurationJobCount   int      `json:"duration_job_count"`
+	AverageDurationSec float64  `json:"average_duration_seconds"`
+	MaxDurationSec     float64  `json:"max_duration_seconds"`
+}
+
+// JobSummary is a quick tally of jobs by status and of the jobs created recently
+type JobSummary struct {
+	ProjectID *string        `json:"project_id,omitempty"`
+	Total     int            `json:"total"`
+	ByStatus  map[string]int `json:"by_status"` // Every job status, including those without jobs
+	Today     int            `json:"today"`     // Jobs created since TodayFrom
+	ThisWeek  int            `json:"this_week"` // Jobs created since WeekFrom
+	TodayFrom time.Time      `json:"today_from"`
+	WeekFrom  time.Time      `json:"week_from"` // Monday 00:00 of the current week
 }
 
 // CreateJobRequest represents job creation request
 type CreateJobRequest struct {
-	ProjectID      string          `json:"project_id" binding:"required"`
-	Command        string          `json:"command" binding:"required"`
-	YoloMode       bool            `json:"yolo_mode"`
-	ScheduleType   string          `json:"schedule_type"`
-	ScheduleParams *ScheduleParams `json:"schedule_params,omitempty"`
+	ProjectID      string            `json:"project_id" binding:"required"`
+	Command        string            `json:"command" binding:"required"`
+	YoloMode       bool              `json:"yolo_mode"`
+	ScheduleType   string            `json:"schedule_type"`
+	ScheduleParams *ScheduleParams   `json:"schedule_params,omitempty"`
+	OutputRules    []OutputRule      `json:"output_rules,omitempty"`
+	WebhookURL     string            `json:"webhook_url,omitempty"`
+	ResourceLimits *ResourceLimits   `json:"resource_limits,omitempty"`
+	Env            map[string]string `json:"env,omitempty"` // Extra environment variables for the job
+}
+
+// MarshalJSON encodes the request with its env values redacted, as Job does, for logging
+func (r CreateJobRequest) MarshalJSON() ([]byte, error) {
+	type request CreateJobRequest // without the MarshalJSON method
+	redacted := request(r)
+	if r.Env != nil {
+		redacted.Env = make(map[string]string, len(r.Env))
+		for name := range r.Env {
+			redacted.Env[name] = RedactedEnvValue
+		}
+	}
+	return json.Marshal(redacted)
+}
+
+// JobEstimate is a rough guess of the tokens and cost a job will use, not a prediction
+type JobEstimate struct {
+	IsEstimate      bool     `json:"is_estimate"` // Always true; the numbers are ballpark figures
+	Basis           string   `json:"basis"`       // project_history, global_history or command_only
+	SampleJobs      int      `json:"sample_jobs"` // Completed jobs the average is taken from
+	EstimatedTokens int      `json:"estimated_tokens"`
+	EstimatedCost   *float64 `json:"estimated_cost"` // nil without any job history to price it from
+	Note            string   `json:"note"`
+}
+
+// JobEstimate basis values
+const (
+	JobEstimateBasisProjectHistory = "project_history"
+	JobEstimateBasisGlobalHistory  = "global_history"
+	JobEstimateBasisCommandOnly    = "command_only"
+)
+
+// SchedulePreview is when a proposed job would run, computed without creating it
+type SchedulePreview struct {
+	ScheduleType string     `json:"schedule_type"`
+	ScheduledAt  *time.Time `json:"scheduled_at"`         // UTC, as stored; nil for immediate, and for after_reset without an upcoming reset
+	ResetTime    *time.Time `json:"reset_time,omitempty"` // after_reset: reset time of the active window
+	Note         string     `json:"note,omitempty"`
+}
+
+// ResourceLimits caps a job's memory and CPU time (enforced with setrlimit on Unix).
+// Zero values fall back to the executor defaults.
+type ResourceLimits struct {
+	MemoryMB   int `json:"memory_mb,omitempty"`
+	CPUSeconds int `json:"cpu_seconds,omitempty"`
 }
\ No newline at end of file
@@ -15,6 +15,8 @@ type CommandSafetyChecker struct {
 	workingDir     string
 	enabled        bool
 	maxCheckTime   time.Duration
+	whitelist      *CommandWhitelist
+	projectID      string // Project whose whitelist overrides apply, if any
 }
 
 // NewCommandSafetyChecker creates a new command safety checker
@@ -24,6 +26,7 @@ func NewCommandSafetyChecker(workingDir string) *CommandSafetyChecker {
 		workingDir:     workingDir,
 		enabled:        false, // Default to disabled (yolo mode)
 		maxCheckTime:   30 * time.Second, // Max time for safety check
+		whitelist:      newDefaultCommandWhitelist(),
 	}
 
 	// Enable safety checking if explicitly requested
@@ -70,31 +73,7 @@ func (c *CommandSafetyChecker) CheckCommandSafety(command string) error {
 
 // isObviouslySafe checks for commands that are clearly safe and don't need AI analysis
 func (c *CommandSafetyChecker) isObviouslySafe(command string) bool {
-	safeCommands := []string{
-		"git status",
-		"git diff",
-		"git log",
-		"ls",
-		"pwd",
-		"whoami",
-		"date",
-		"echo",
-		"cat package.json",
-		"npm list",
-		"go version",
-		"node --version",
-		"python --version",
-	}
-
-	command = strings.TrimSpace(strings.ToLower(command))
-	
-	for _, safe := range safeCommands {
-		if command == safe || strings.HasPrefix(command, safe+" ") {
-			return true
-		}
-	}
-
-	return false
+	return c.whitelist.AllowsInProject(c.projectID, command)
 }
 
 // createSafetyPrompt creates a prompt for Claude Code to analyze command safety
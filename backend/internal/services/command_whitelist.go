@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultSafeCommands are always on the whitelist
+var defaultSafeCommands = []string{
+	"git status",
+	"git diff",
+	"git log",
+	"ls",
+	"pwd",
+	"whoami",
+	"date",
+	"echo",
+	"cat package.json",
+	"npm list",
+	"go version",
+	"node --version",
+	"python --version",
+}
+
+// CommandWhitelist is the set of commands that skip the Claude Code safety check.
+// Besides the defaults it holds the commands listed in a file, one per line,
+// which can be reloaded at runtime without a restart.
+type CommandWhitelist struct {
+	path     string
+	mu       sync.RWMutex
+	commands []string
+	projects map[string][]string // Extra commands allowed only in one project, by project ID
+}
+
+// NewCommandWhitelist creates a whitelist of the default commands plus those in path.
+// An empty path means the defaults only.
+func NewCommandWhitelist(path string) (*CommandWhitelist, error) {
+	w := &CommandWhitelist{path: path}
+	if err := w.Reload(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// newDefaultCommandWhitelist creates a whitelist of the default commands only
+func newDefaultCommandWhitelist() *CommandWhitelist {
+	return &CommandWhitelist{commands: defaultSafeCommands}
+}
+
+// Reload re-reads the whitelist file and swaps in the new command set.
+// The current set is kept if the file cannot be read.
+func (w *CommandWhitelist) Reload() error {
+	commands := append([]string{}, defaultSafeCommands...)
+
+	if w.path != "" {
+		file, err := os.Open(w.path)
+		if err != nil {
+			return fmt.Errorf("failed to read command whitelist: %w", err)
+		}
+		defer file.Close()
+
+		seen := make(map[string]bool, len(commands))
+		for _, command := range commands {
+			seen[command] = true
+		}
+
+		// Blank lines and lines starting with # are ignored
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			command := NormalizeWhitelistCommand(scanner.Text())
+			if command == "" || strings.HasPrefix(command, "#") || seen[command] {
+				continue
+			}
+			seen[command] = true
+			commands = append(commands, command)
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read command whitelist: %w", err)
+		}
+	}
+
+	w.mu.Lock()
+	w.commands = commands
+	w.mu.Unlock()
+	return nil
+}
+
+// Path returns the whitelist file, empty when only the defaults are used
+func (w *CommandWhitelist) Path() string {
+	return w.path
+}
+
+// Commands returns the effective whitelist
+func (w *CommandWhitelist) Commands() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return append([]string{}, w.commands...)
+}
+
+// SetProjectCommands replaces the commands allowed only in the given project
+func (w *CommandWhitelist) SetProjectCommands(projectID string, commands []string) {
+	normalized := make([]string, 0, len(commands))
+	for _, command := range commands {
+		if command = NormalizeWhitelistCommand(command); command != "" {
+			normalized = append(normalized, command)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(normalized) == 0 {
+		delete(w.projects, projectID)
+		return
+	}
+	if w.projects == nil {
+		w.projects = make(map[string][]string)
+	}
+	w.projects[projectID] = normalized
+}
+
+// Allows reports whether command is a whitelisted command, optionally followed by arguments
+func (w *CommandWhitelist) Allows(command string) bool {
+	return w.AllowsInProject("", command)
+}
+
+// AllowsInProject reports whether command is whitelisted globally or for the given project
+func (w *CommandWhitelist) AllowsInProject(projectID, command string) bool {
+	w.mu.RLock()
+	commands := w.commands
+	projectCommands := w.projects[projectID]
+	w.mu.RUnlock()
+
+	command = strings.TrimSpace(strings.ToLower(command))
+
+	return matchesWhitelist(command, commands) || matchesWhitelist(command, projectCommands)
+}
+
+// matchesWhitelist reports whether command is one of commands, optionally followed by arguments
+func matchesWhitelist(command string, commands []string) bool {
+	for _, safe := range commands {
+		if command == safe || strings.HasPrefix(command, safe+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeWhitelistCommand lowercases a whitelist entry and collapses its whitespace
+func NormalizeWhitelistCommand(command string) string {
+	return strings.ToLower(strings.Join(strings.Fields(command), " "))
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandWhitelist_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "whitelist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# build commands\nmake   build\n\nNPM test\ngit status\n"), 0644))
+
+	whitelist, err := NewCommandWhitelist(path)
+	require.NoError(t, err)
+
+	assert.True(t, whitelist.Allows("make build"))
+	assert.True(t, whitelist.Allows("npm test -- --watch"))
+	assert.True(t, whitelist.Allows("git status"), "defaults are always included")
+	assert.False(t, whitelist.Allows("make install"))
+	assert.Equal(t, len(defaultSafeCommands)+2, len(whitelist.Commands()), "duplicates and comments are skipped")
+
+	// Changes to the file take effect on reload
+	require.NoError(t, os.WriteFile(path, []byte("make install\n"), 0644))
+	assert.False(t, whitelist.Allows("make install"))
+	require.NoError(t, whitelist.Reload())
+	assert.True(t, whitelist.Allows("make install"))
+	assert.False(t, whitelist.Allows("make build"))
+
+	// A failed reload keeps the current set
+	require.NoError(t, os.Remove(path))
+	assert.Error(t, whitelist.Reload())
+	assert.True(t, whitelist.Allows("make install"))
+
+	_, err = NewCommandWhitelist(path)
+	assert.Error(t, err)
+}
+
+func TestCommandWhitelist_ConcurrentReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "whitelist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("make build\n"), 0644))
+
+	whitelist, err := NewCommandWhitelist(path)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if !whitelist.Allows("make build") {
+					t.Error("Expected make build to stay allowed during reload")
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		require.NoError(t, whitelist.Reload())
+	}
+	wg.Wait()
+}
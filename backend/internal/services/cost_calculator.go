@@ -1,12 +1,82 @@
 package services
 
 import (
+	"encoding/json"
+	"fmt"
+	"os"
 	"strings"
+	"sync"
 )
 
 // PricingCalculator provides cost calculation for Claude models
 type PricingCalculator struct {
-	pricing map[string]map[string]float64
+	pricing   map[string]map[string]float64
+	overrides map[string]map[string]float64 // Custom rates consulted before the defaults
+}
+
+var (
+	pricingOverridesMu sync.RWMutex
+	pricingOverrides   map[string]map[string]float64
+
+	nullModelFallbackMu sync.RWMutex
+	nullModelFallback   string
+)
+
+// NullModelFallbackSession costs null-model messages as the session's most common model
+const NullModelFallbackSession = "session"
+
+// SetNullModelFallback configures how assistant messages without a model are costed.
+// "session" uses the most common model in the same session, any other value is used
+// as the model name, and an empty value leaves them uncosted.
+func SetNullModelFallback(fallback string) {
+	nullModelFallbackMu.Lock()
+	defer nullModelFallbackMu.Unlock()
+	nullModelFallback = strings.TrimSpace(fallback)
+}
+
+func getNullModelFallback() string {
+	nullModelFallbackMu.RLock()
+	defer nullModelFallbackMu.RUnlock()
+	return nullModelFallback
+}
+
+// LoadPricingOverrides loads a pricing override table from a JSON file.
+// The file maps model names to per-million rates keyed by input, output, cache_creation and cache_read.
+// Overrides apply to every PricingCalculator created afterwards. An empty path clears them.
+func LoadPricingOverrides(path string) error {
+	if path == "" {
+		pricingOverridesMu.Lock()
+		pricingOverrides = nil
+		pricingOverridesMu.Unlock()
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read pricing overrides: %w", err)
+	}
+
+	var overrides map[string]map[string]float64
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return fmt.Errorf("failed to parse pricing overrides: %w", err)
+	}
+
+	validKeys := map[string]bool{"input": true, "output": true, "cache_creation": true, "cache_read": true}
+	for model, rates := range overrides {
+		for key, rate := range rates {
+			if !validKeys[key] {
+				return fmt.Errorf("invalid pricing key %q for model %s", key, model)
+			}
+			if rate < 0 {
+				return fmt.Errorf("negative %s rate for model %s", key, model)
+			}
+		}
+	}
+
+	pricingOverridesMu.Lock()
+	pricingOverrides = overrides
+	pricingOverridesMu.Unlock()
+	return nil
 }
 
 // NewPricingCalculator creates a new pricing calculator with fallback pricing
@@ -44,9 +114,26 @@ func NewPricingCalculator() *PricingCalculator {
 		"claude-opus-4-20250514":      fallbackPricing["opus"],
 	}
 
-	return &PricingCalculator{
-		pricing: pricing,
+	pc := &PricingCalculator{
+		pricing:   pricing,
+		overrides: make(map[string]map[string]float64),
+	}
+
+	// Merge overrides over the default rates for each model
+	pricingOverridesMu.RLock()
+	defer pricingOverridesMu.RUnlock()
+	for model, rates := range pricingOverrides {
+		merged := make(map[string]float64)
+		for key, rate := range pc.getPricingForModel(model) {
+			merged[key] = rate
+		}
+		for key, rate := range rates {
+			merged[key] = rate
+		}
+		pc.overrides[strings.ToLower(strings.TrimSpace(model))] = merged
 	}
+
+	return pc
 }
 
 // CalculateCost calculates the cost for given token usage and model
@@ -80,6 +167,14 @@ func (pc *PricingCalculator) getPricingForModel(model string) map[string]float64
 	// Normalize model name
 	normalized := normalizeModelName(model)
 
+	// Check custom overrides first
+	if pricing, exists := pc.overrides[strings.ToLower(strings.TrimSpace(model))]; exists {
+		return pricing
+	}
+	if pricing, exists := pc.overrides[normalized]; exists {
+		return pricing
+	}
+
 	// Check configured pricing
 	if pricing, exists := pc.pricing[normalized]; exists {
 		return pricing
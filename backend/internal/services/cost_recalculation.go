@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type CostRecalculationStatus string
+
+const (
+	CostRecalculationIdle      CostRecalculationStatus = "idle"
+	CostRecalculationRunning   CostRecalculationStatus = "running"
+	CostRecalculationCompleted CostRecalculationStatus = "completed"
+	CostRecalculationFailed    CostRecalculationStatus = "failed"
+)
+
+// CostRecalculationState is the progress of the last all-sessions cost recalculation
+type CostRecalculationState struct {
+	Status            CostRecalculationStatus `json:"status"`
+	Message           string                  `json:"message"`
+	ProcessedSessions int                     `json:"processed_sessions"`
+	TotalSessions     int                     `json:"total_sessions"`
+	UpdatedSessions   int                     `json:"updated_sessions"`
+	StartTime         *time.Time              `json:"start_time,omitempty"`
+	EndTime           *time.Time              `json:"end_time,omitempty"`
+	Error             *string                 `json:"error,omitempty"`
+}
+
+// CostRecalculationTracker records the state of the background cost recalculation,
+// allowing only one to run at a time
+type CostRecalculationTracker struct {
+	mu    sync.RWMutex
+	state CostRecalculationState
+}
+
+var globalCostRecalculationTracker = NewCostRecalculationTracker()
+
+func NewCostRecalculationTracker() *CostRecalculationTracker {
+	return &CostRecalculationTracker{
+		state: CostRecalculationState{
+			Status:  CostRecalculationIdle,
+			Message: "No cost recalculation has run",
+		},
+	}
+}
+
+func GetGlobalCostRecalculationTracker() *CostRecalculationTracker {
+	return globalCostRecalculationTracker
+}
+
+// Start marks a recalculation as running. It fails if one is already running.
+func (t *CostRecalculationTracker) Start() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.state.Status == CostRecalculationRunning {
+		return fmt.Errorf("cost recalculation already running")
+	}
+
+	now := time.Now()
+	t.state = CostRecalculationState{
+		Status:    CostRecalculationRunning,
+		Message:   "Recalculating session costs...",
+		StartTime: &now,
+	}
+	return nil
+}
+
+func (t *CostRecalculationTracker) UpdateProgress(processedSessions, totalSessions int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.state.Status == CostRecalculationRunning {
+		t.state.ProcessedSessions = processedSessions
+		t.state.TotalSessions = totalSessions
+	}
+}
+
+func (t *CostRecalculationTracker) Complete(updatedSessions int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.state.Status = CostRecalculationCompleted
+	t.state.Message = "Session costs recalculated successfully"
+	t.state.ProcessedSessions = t.state.TotalSessions
+	t.state.UpdatedSessions = updatedSessions
+	t.state.EndTime = &now
+}
+
+func (t *CostRecalculationTracker) Fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	errorMsg := err.Error()
+	t.state.Status = CostRecalculationFailed
+	t.state.Message = "Session cost recalculation failed"
+	t.state.EndTime = &now
+	t.state.Error = &errorMsg
+}
+
+func (t *CostRecalculationTracker) GetState() CostRecalculationState {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.state
+}
+
+// RecalculateSessionCost recomputes the cost of a session's messages with the current
+// pricing and stores their sum as the session's total_cost
+func (s *TokenService) RecalculateSessionCost(sessionID string) (float64, error) {
+	var exists bool
+	if err := s.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ?)`, sessionID).Scan(&exists); err != nil {
+		return 0, fmt.Errorf("failed to check session: %w", err)
+	}
+	if !exists {
+		return 0, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	if _, err := s.backfillMessageCosts(sessionID); err != nil {
+		return 0, err
+	}
+
+	cost, err := s.CalculateSessionCost(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.db.Exec(`UPDATE sessions SET total_cost = ? WHERE id = ?`, cost, sessionID); err != nil {
+		return 0, fmt.Errorf("failed to update session cost: %w", err)
+	}
+	return cost, nil
+}
+
+// RecalculateAllSessionCosts recomputes every message cost with the current pricing, then
+// refreshes total_cost of each session, calling progress after each one.
+// Returns how many sessions were updated.
+func (s *TokenService) RecalculateAllSessionCosts(progress func(processedSessions, totalSessions int)) (int, error) {
+	if _, err := s.BackfillMessageCosts(); err != nil {
+		return 0, err
+	}
+
+	rows, err := s.db.Query(`SELECT id FROM sessions ORDER BY id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query sessions for cost recalculation: %w", err)
+	}
+	var sessionIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan session for cost recalculation: %w", err)
+		}
+		sessionIDs = append(sessionIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, fmt.Errorf("error iterating over sessions for cost recalculation: %w", err)
+	}
+	rows.Close()
+
+	updated := 0
+	for i, sessionID := range sessionIDs {
+		cost, err := s.CalculateSessionCost(sessionID)
+		if err != nil {
+			return updated, err
+		}
+		if _, err := s.db.Exec(`UPDATE sessions SET total_cost = ? WHERE id = ?`, cost, sessionID); err != nil {
+			return updated, fmt.Errorf("failed to update cost for session %s: %w", sessionID, err)
+		}
+		updated++
+		if progress != nil {
+			progress(i+1, len(sessionIDs))
+		}
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecalculateSessionCosts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+	if _, err := db.Exec(`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0.0`); err != nil {
+		t.Fatalf("Failed to add total_cost column: %v", err)
+	}
+
+	// Both sessions have stale message and session costs
+	now := time.Now()
+	for _, sessionID := range []string{"recalc-a", "recalc-b"} {
+		if _, err := db.Exec(`
+			INSERT INTO sessions (id, project_name, project_path, start_time, total_cost)
+			VALUES (?, ?, ?, ?, 99.0)
+		`, sessionID, "project", "/project", now); err != nil {
+			t.Fatalf("Failed to insert test session: %v", err)
+		}
+		if _, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens, model, cost)
+			VALUES (?, ?, 'assistant', 'test', ?, 1000, 500, 'claude-3-5-sonnet', 42.0)
+		`, sessionID+"-msg", sessionID, now); err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	service := NewTokenService(db)
+	expected := NewPricingCalculator().CalculateCost("claude-3-5-sonnet", 1000, 500, 0, 0)
+	sessionCost := func(sessionID string) float64 {
+		var cost float64
+		if err := db.QueryRow(`SELECT total_cost FROM sessions WHERE id = ?`, sessionID).Scan(&cost); err != nil {
+			t.Fatalf("Failed to query session cost: %v", err)
+		}
+		return cost
+	}
+
+	cost, err := service.RecalculateSessionCost("recalc-a")
+	if err != nil {
+		t.Fatalf("RecalculateSessionCost failed: %v", err)
+	}
+	if cost != expected || sessionCost("recalc-a") != expected {
+		t.Errorf("Expected recalc-a cost %f, got %f (stored %f)", expected, cost, sessionCost("recalc-a"))
+	}
+	if sessionCost("recalc-b") != 99.0 {
+		t.Errorf("Expected other sessions to be left alone, got %f", sessionCost("recalc-b"))
+	}
+
+	if _, err := service.RecalculateSessionCost("missing"); err == nil || !strings.Contains(err.Error(), "session not found") {
+		t.Errorf("Expected session not found error, got %v", err)
+	}
+
+	var reports []int
+	updated, err := service.RecalculateAllSessionCosts(func(processed, total int) {
+		if total != 2 {
+			t.Errorf("Expected 2 total sessions, got %d", total)
+		}
+		reports = append(reports, processed)
+	})
+	if err != nil {
+		t.Fatalf("RecalculateAllSessionCosts failed: %v", err)
+	}
+	if updated != 2 || len(reports) != 2 || reports[1] != 2 {
+		t.Errorf("Expected 2 updated sessions with progress reported for each, got %d %v", updated, reports)
+	}
+	if sessionCost("recalc-b") != expected {
+		t.Errorf("Expected recalc-b cost %f, got %f", expected, sessionCost("recalc-b"))
+	}
+}
+
+func TestCostRecalculationTracker(t *testing.T) {
+	tracker := NewCostRecalculationTracker()
+	if tracker.GetState().Status != CostRecalculationIdle {
+		t.Errorf("Expected idle tracker, got %s", tracker.GetState().Status)
+	}
+
+	if err := tracker.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if err := tracker.Start(); err == nil {
+		t.Error("Expected a second start to fail while running")
+	}
+
+	tracker.UpdateProgress(3, 10)
+	state := tracker.GetState()
+	if state.Status != CostRecalculationRunning || state.ProcessedSessions != 3 || state.TotalSessions != 10 {
+		t.Errorf("Unexpected running state: %+v", state)
+	}
+
+	tracker.Complete(10)
+	state = tracker.GetState()
+	if state.Status != CostRecalculationCompleted || state.UpdatedSessions != 10 || state.ProcessedSessions != 10 || state.EndTime == nil {
+		t.Errorf("Unexpected completed state: %+v", state)
+	}
+
+	if err := tracker.Start(); err != nil {
+		t.Fatalf("Expected a new run to start after completion: %v", err)
+	}
+	tracker.Fail(errors.New("boom"))
+	state = tracker.GetState()
+	if state.Status != CostRecalculationFailed || state.Error == nil || *state.Error != "boom" {
+		t.Errorf("Unexpected failed state: %+v", state)
+	}
+}
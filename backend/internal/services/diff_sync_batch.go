@@ -0,0 +1,179 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ccdash-backend/internal/models"
+)
+
+// DEFAULT_SYNC_BATCH_SIZE is how many messages are written per multi-row insert when a file is imported in batches
+const DEFAULT_SYNC_BATCH_SIZE = 500
+
+// messageBatch buffers the messages of one file being imported from its first line.
+// Window statistics and session tokens are recomputed once per file instead of per message.
+type messageBatch struct {
+	d        *DiffSyncService
+	size     int
+	messages []*models.Message
+	sessions map[string]bool // sessions already created or linked for this file
+	windows  map[string]bool // windows that received messages
+}
+
+func (d *DiffSyncService) newMessageBatch() *messageBatch {
+	return &messageBatch{
+		d:        d,
+		size:     d.batchSize,
+		sessions: make(map[string]bool),
+		windows:  make(map[string]bool),
+	}
+}
+
+// add buffers the message of a log entry, writing the batch once it is full.
+// It returns how many buffered messages were written.
+func (b *messageBatch) add(entry *models.LogEntry, projectName string) (int, error) {
+	if !b.sessions[entry.SessionID] {
+		if err := b.d.ensureSession(entry, projectName); err != nil {
+			return 0, err
+		}
+		b.sessions[entry.SessionID] = true
+	}
+
+	b.messages = append(b.messages, b.d.buildMessage(entry))
+	if len(b.messages) < b.size {
+		return 0, nil
+	}
+	return b.flush()
+}
+
+// flush writes the buffered messages and links them to their windows.
+// If the batch insert fails the messages are inserted one by one, so as in a per-line
+// import only the messages that fail on their own are skipped.
+// It returns how many messages were written; on error the remaining messages are dropped.
+func (b *messageBatch) flush() (int, error) {
+	messages := b.messages
+	b.messages = nil
+	if len(messages) == 0 {
+		return 0, nil
+	}
+
+	if err := b.d.insertMessages(messages); err != nil {
+		b.d.logger.Warn("Batch insert failed, inserting messages one by one", "messages", len(messages), "error", err)
+		messages = b.insertEach(messages)
+	}
+
+	var windowOrder []string
+	windowMessages := make(map[string][]string)
+	for _, message := range messages {
+		window, err := b.d.windowService.GetOrCreateWindowForMessage(message.Timestamp)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get/create session window: %w", err)
+		}
+		if _, ok := windowMessages[window.ID]; !ok {
+			windowOrder = append(windowOrder, window.ID)
+		}
+		windowMessages[window.ID] = append(windowMessages[window.ID], message.ID)
+	}
+
+	for _, windowID := range windowOrder {
+		if err := b.d.relationService.AddMessagesToWindow(windowID, windowMessages[windowID]); err != nil {
+			return 0, fmt.Errorf("failed to add messages to window: %w", err)
+		}
+		b.windows[windowID] = true
+	}
+
+	return len(messages), nil
+}
+
+// insertEach inserts the messages individually and returns the ones that were written
+func (b *messageBatch) insertEach(messages []*models.Message) []*models.Message {
+	inserted := make([]*models.Message, 0, len(messages))
+	for _, message := range messages {
+		if err := b.d.insertMessage(message); err != nil {
+			b.d.logger.Error("Error inserting message", "message_id", message.ID, "session_id", message.SessionID, "error", err)
+			continue
+		}
+		inserted = append(inserted, message)
+	}
+	return inserted
+}
+
+// finish recomputes the statistics of every window and session the file touched
+func (b *messageBatch) finish() error {
+	for windowID := range b.windows {
+		if err := b.d.windowService.UpdateWindowStats(windowID); err != nil {
+			return fmt.Errorf("failed to update window stats: %w", err)
+		}
+	}
+	for sessionID := range b.sessions {
+		if err := b.d.tokenService.UpdateSessionTokens(sessionID); err != nil {
+			return fmt.Errorf("failed to update session tokens: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertMessages upserts messages with one multi-row statement in a transaction.
+// A message logged more than once keeps its last occurrence, as with insertMessage.
+func (d *DiffSyncService) insertMessages(messages []*models.Message) error {
+	latest := make(map[string]int, len(messages))
+	for i, message := range messages {
+		latest[message.ID] = i
+	}
+
+	now := time.Now()
+	rows := make([]string, 0, len(latest))
+	args := make([]interface{}, 0, len(latest)*19)
+	for i, message := range messages {
+		if latest[message.ID] != i {
+			continue
+		}
+		rows = append(rows, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE((SELECT created_at FROM messages WHERE id = ?), ?))")
+		args = append(args,
+			message.ID,
+			message.SessionID,
+			message.ParentUUID,
+			message.IsSidechain,
+			message.UserType,
+			message.MessageType,
+			message.MessageRole,
+			message.Model,
+			message.Content,
+			message.InputTokens,
+			message.CacheCreationInputTokens,
+			message.CacheReadInputTokens,
+			message.OutputTokens,
+			message.ServiceTier,
+			message.RequestID,
+			message.Cost,
+			message.Timestamp,
+			message.ID, // for COALESCE subquery
+			now,        // created_at for new records
+		)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO messages (
+			id, session_id, parent_uuid, is_sidechain, user_type, message_type,
+			message_role, model, content, input_tokens, cache_creation_input_tokens,
+			cache_read_input_tokens, output_tokens, service_tier, request_id,
+			cost, timestamp, created_at
+		) VALUES `+strings.Join(rows, ", "), args...)
+	if err != nil {
+		return fmt.Errorf("failed to upsert messages: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	d.logger.Debug("Inserted message batch", "messages", len(rows))
+	return nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupTestDBForBatchSync extends the diff sync test schema with the window tables
+// and session columns the full message pipeline writes to
+func setupTestDBForBatchSync(t *testing.T) (*sql.DB, *DiffSyncService) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+
+	_, err := db.Exec(`
+		ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0.0;
+
+		CREATE TABLE IF NOT EXISTS session_windows (
+			id TEXT PRIMARY KEY,
+			window_start TIMESTAMP NOT NULL,
+			window_end TIMESTAMP NOT NULL,
+			reset_time TIMESTAMP NOT NULL,
+			total_input_tokens INTEGER DEFAULT 0,
+			total_output_tokens INTEGER DEFAULT 0,
+			total_tokens INTEGER DEFAULT 0,
+			total_cache_creation_tokens INTEGER DEFAULT 0,
+			total_cache_read_tokens INTEGER DEFAULT 0,
+			message_count INTEGER DEFAULT 0,
+			session_count INTEGER DEFAULT 0,
+			total_cost DOUBLE DEFAULT 0.0,
+			is_active BOOLEAN DEFAULT false,
+			plan TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS session_window_messages (
+			id TEXT PRIMARY KEY,
+			session_window_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(session_window_id, message_id)
+		);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to extend test schema: %v", err)
+	}
+
+	return db, diffSyncService
+}
+
+func TestProcessFileLines_BatchedImportMatchesPerLine(t *testing.T) {
+	logFile := filepath.Join(t.TempDir(), "batch.jsonl")
+	lines := []string{
+		`{"uuid":"batch-1","sessionId":"batch-a","userType":"human","cwd":"/test","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Hello"}}`,
+		`{"uuid":"batch-2","sessionId":"batch-a","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:01:00Z","message":{"role":"assistant","model":"claude-3-5-sonnet","content":"Hi","usage":{"input_tokens":100,"output_tokens":50}}}`,
+		`{"uuid":"batch-3","sessionId":"batch-b","userType":"external","cwd":"/other","timestamp":"2024-01-01T10:02:00Z","message":{"role":"assistant","model":"claude-3-5-sonnet","content":"Yo","usage":{"input_tokens":10,"output_tokens":5}}}`,
+		// Logged again with updated usage; the last occurrence wins
+		`{"uuid":"batch-2","sessionId":"batch-a","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:01:00Z","message":{"role":"assistant","model":"claude-3-5-sonnet","content":"Hi","usage":{"input_tokens":200,"output_tokens":70}}}`,
+		// Falls into a second window
+		`{"uuid":"batch-4","sessionId":"batch-a","userType":"external","cwd":"/test","timestamp":"2024-01-01T18:00:00Z","message":{"role":"assistant","model":"claude-3-5-sonnet","content":"Later","usage":{"input_tokens":30,"output_tokens":20}}}`,
+	}
+	content := ""
+	for _, line := range lines {
+		content += line + "\n"
+	}
+	if err := os.WriteFile(logFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+
+	type result struct {
+		processed, windows, relations, sessionTokens, batch2Input int
+	}
+	run := func(batchSize int) result {
+		db, diffSyncService := setupTestDBForBatchSync(t)
+		defer db.Close()
+		diffSyncService.SetBatchSize(batchSize)
+
+		processed, _, err := diffSyncService.processFileFromLine(logFile, 0)
+		if err != nil {
+			t.Fatalf("Failed to process file with batch size %d: %v", batchSize, err)
+		}
+
+		r := result{processed: processed}
+		queries := []struct {
+			query string
+			dest  *int
+		}{
+			{"SELECT COUNT(*) FROM session_windows", &r.windows},
+			{"SELECT COUNT(*) FROM session_window_messages", &r.relations},
+			{"SELECT total_tokens FROM sessions WHERE id = 'batch-a'", &r.sessionTokens},
+			{"SELECT input_tokens FROM messages WHERE id = 'batch-2'", &r.batch2Input},
+		}
+		for _, q := range queries {
+			if err := db.QueryRow(q.query).Scan(q.dest); err != nil {
+				t.Fatalf("Failed to query %q: %v", q.query, err)
+			}
+		}
+		return r
+	}
+
+	perLine := run(1)
+
+	if perLine.batch2Input != 200 {
+		t.Errorf("Expected the last occurrence of a message to be stored, got %d input tokens", perLine.batch2Input)
+	}
+	if perLine.windows != 2 || perLine.relations != 4 {
+		t.Errorf("Unexpected per-line result: %+v", perLine)
+	}
+	// A batch size of 2 splits the file across flushes; 10 puts the duplicate in the same insert
+	for _, batchSize := range []int{2, 10} {
+		if batched := run(batchSize); batched != perLine {
+			t.Errorf("Expected import with batch size %d %+v to match per-line import %+v", batchSize, batched, perLine)
+		}
+	}
+}
+
+func TestProcessFileLines_BatchInsertFailureFallsBackToPerMessage(t *testing.T) {
+	db, diffSyncService := setupTestDBForBatchSync(t)
+	defer db.Close()
+	diffSyncService.SetBatchSize(10)
+
+	// A message the database rejects makes the multi-row insert fail
+	_, err := db.Exec(`
+		DROP TABLE messages;
+		CREATE TABLE messages (
+			id TEXT PRIMARY KEY,
+			session_id TEXT,
+			parent_uuid TEXT,
+			is_sidechain BOOLEAN DEFAULT FALSE,
+			user_type TEXT,
+			message_type TEXT,
+			message_role TEXT,
+			model TEXT,
+			content TEXT CHECK (content NOT LIKE '%rejected%'),
+			input_tokens INTEGER DEFAULT 0,
+			cache_creation_input_tokens INTEGER DEFAULT 0,
+			cache_read_input_tokens INTEGER DEFAULT 0,
+			output_tokens INTEGER DEFAULT 0,
+			service_tier TEXT,
+			request_id TEXT,
+			cost DOUBLE DEFAULT 0.0,
+			timestamp TIMESTAMP,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to recreate messages table: %v", err)
+	}
+
+	logFile := filepath.Join(t.TempDir(), "fallback.jsonl")
+	content := `{"uuid":"fallback-1","sessionId":"fallback-a","userType":"human","cwd":"/test","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Hello"}}
+{"uuid":"fallback-2","sessionId":"fallback-a","userType":"human","cwd":"/test","timestamp":"2024-01-01T10:01:00Z","message":{"role":"user","content":"rejected"}}
+{"uuid":"fallback-3","sessionId":"fallback-a","userType":"human","cwd":"/test","timestamp":"2024-01-01T10:02:00Z","message":{"role":"user","content":"Bye"}}
+`
+	if err := os.WriteFile(logFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+
+	processed, _, err := diffSyncService.processFileFromLine(logFile, 0)
+	if err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+	if processed != 2 {
+		t.Errorf("Expected the 2 valid messages to be imported, got %d", processed)
+	}
+
+	var messages, relations int
+	db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&messages)
+	db.QueryRow("SELECT COUNT(*) FROM session_window_messages").Scan(&relations)
+	if messages != 2 || relations != 2 {
+		t.Errorf("Expected 2 stored and linked messages, got %d messages and %d links", messages, relations)
+	}
+}
@@ -2,19 +2,64 @@ package services
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"ccdash-backend/internal/config"
+	"ccdash-backend/internal/middleware"
 	"ccdash-backend/internal/models"
 )
 
+// errFileRewritten means the lines before the stored offset are not the ones already synced
+var errFileRewritten = errors.New("file was rewritten since the last sync")
+
+// DEFAULT_SYNC_BUSY_GRACE is how recently a file must have been modified to be treated as still being written
+const DEFAULT_SYNC_BUSY_GRACE = 2 * time.Second
+
+// activeSyncs counts log syncs in progress across all DiffSyncService instances
+var activeSyncs int32
+
+// syncLock is held for reading by every log sync and for writing by rebuilds of
+// synced data, so the two never interleave
+var syncLock sync.RWMutex
+
+// ErrSyncInProgress is returned by RunExclusiveOfSyncs when a log sync is running
+var ErrSyncInProgress = errors.New("log sync in progress")
+
+// RunExclusiveOfSyncs runs fn with log syncs blocked until it returns. Rather than
+// waiting for a running sync to finish, it fails with ErrSyncInProgress.
+func RunExclusiveOfSyncs(fn func() error) error {
+	if !syncLock.TryLock() {
+		return ErrSyncInProgress
+	}
+	defer syncLock.Unlock()
+	return fn()
+}
+
+// WaitForSyncs waits up to timeout for in-progress log syncs to finish.
+// It returns false if a sync is still running at the deadline.
+func WaitForSyncs(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for atomic.LoadInt32(&activeSyncs) > 0 {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return true
+}
+
 type DiffSyncService struct {
 	db              *sql.DB
 	tokenService    *TokenService
@@ -23,6 +68,10 @@ type DiffSyncService struct {
 	stateManager    *FileSyncStateManager
 	relationService *SessionWindowMessageService
 	projectService  *ProjectService // Phase 2: Add ProjectService for integration
+	busyGrace       time.Duration
+	batchSize       int
+	onProgress      func(doneFiles, totalFiles, newLines int)
+	logger          *slog.Logger
 }
 
 func NewDiffSyncService(db *sql.DB, tokenService *TokenService, sessionService *SessionService) *DiffSyncService {
@@ -30,6 +79,10 @@ func NewDiffSyncService(db *sql.DB, tokenService *TokenService, sessionService *
 	windowService := NewSessionWindowService(db)
 	relationService := NewSessionWindowMessageService(db)
 	projectService := NewProjectService(db) // Phase 2: Initialize ProjectService
+	busyGrace := DEFAULT_SYNC_BUSY_GRACE
+	if cfg, err := config.GetConfig(); err == nil {
+		busyGrace = cfg.SyncBusyGrace
+	}
 	return &DiffSyncService{
 		db:              db,
 		tokenService:    tokenService,
@@ -38,9 +91,35 @@ func NewDiffSyncService(db *sql.DB, tokenService *TokenService, sessionService *
 		stateManager:    stateManager,
 		relationService: relationService,
 		projectService:  projectService, // Phase 2: Add to struct
+		busyGrace:       busyGrace,
+		batchSize:       DEFAULT_SYNC_BATCH_SIZE,
+		logger:          slog.Default(),
 	}
 }
 
+// SetBusyGrace sets how recently a file must have been modified for its unterminated
+// last line to be deferred to a later sync. Zero disables the check.
+func (d *DiffSyncService) SetBusyGrace(grace time.Duration) {
+	d.busyGrace = grace
+}
+
+// SetBatchSize sets how many messages are written per insert when a file is imported
+// from its first line. A size of 1 or less processes every line individually.
+func (d *DiffSyncService) SetBatchSize(size int) {
+	d.batchSize = size
+}
+
+// SetProgressCallback registers fn to be called after each discovered file is handled
+// (synced, skipped or failed) with the running totals of the sync
+func (d *DiffSyncService) SetProgressCallback(fn func(doneFiles, totalFiles, newLines int)) {
+	d.onProgress = fn
+}
+
+// SetRequestContext tags the sync's log lines with the request ID carried by ctx
+func (d *DiffSyncService) SetRequestContext(ctx context.Context) {
+	d.logger = middleware.ContextLogger(ctx)
+}
+
 // InitializeSchema initializes the database schema for differential sync
 func (d *DiffSyncService) InitializeSchema() error {
 	return d.stateManager.InitializeSchema()
@@ -48,6 +127,18 @@ func (d *DiffSyncService) InitializeSchema() error {
 
 // SyncAllLogs performs differential synchronization of all logs
 func (d *DiffSyncService) SyncAllLogs() (*models.SyncStats, error) {
+	return d.SyncLogsSince(time.Time{})
+}
+
+// SyncLogsSince performs differential synchronization of files modified after since.
+// Older files are skipped without checking their sync state. A zero since syncs all files.
+func (d *DiffSyncService) SyncLogsSince(since time.Time) (*models.SyncStats, error) {
+	syncLock.RLock()
+	defer syncLock.RUnlock()
+
+	atomic.AddInt32(&activeSyncs, 1)
+	defer atomic.AddInt32(&activeSyncs, -1)
+
 	stats := &models.SyncStats{
 		StartTime: time.Now(),
 	}
@@ -59,7 +150,7 @@ func (d *DiffSyncService) SyncAllLogs() (*models.SyncStats, error) {
 
 	// Clean up old states for deleted files
 	if err := d.stateManager.CleanupOldStates(); err != nil {
-		log.Printf("Warning: failed to cleanup old states: %v", err)
+		d.logger.Warn("Failed to cleanup old sync states", "error", err)
 	}
 
 	// Discover all JSONL files
@@ -69,65 +160,137 @@ func (d *DiffSyncService) SyncAllLogs() (*models.SyncStats, error) {
 	}
 
 	stats.TotalFiles = len(files)
-	log.Printf("Found %d JSONL files to process", len(files))
+	d.logger.Info("Found JSONL files to process", "files", len(files))
 
 	// Process each file
-	for _, file := range files {
-		needsSync, lastState, err := d.stateManager.NeedsProcessing(file.Path)
-		if err != nil {
-			log.Printf("Error checking file %s: %v", file.Path, err)
-			continue
-		}
+	d.reportProgress(0, len(files), 0)
+	for i, file := range files {
+		d.processDiscoveredFile(file, since, stats)
+		d.reportProgress(i+1, len(files), stats.NewLines)
+	}
 
-		if needsSync {
-			newLines, err := d.syncFile(file, lastState)
-			if err != nil {
-				log.Printf("Error syncing file %s: %v", file.Path, err)
-				// Update state with error
-				errorMsg := err.Error()
-				errorState := &models.FileProcessingState{
-					FilePath:     file.Path,
-					LastModified: file.ModTime,
-					FileSize:     file.Size,
-					SyncStatus:   "error",
-					ErrorMessage: &errorMsg,
-				}
-				d.stateManager.UpdateFileState(errorState)
-				continue
-			}
-			stats.ProcessedFiles++
-			stats.NewLines += newLines
-		} else {
-			stats.SkippedFiles++
-		}
+	stats.EndTime = time.Now()
+	stats.ProcessingTime = stats.EndTime.Sub(stats.StartTime)
+
+	d.logger.Info("Sync completed", "processed_files", stats.ProcessedFiles, "skipped_files", stats.SkippedFiles,
+		"new_lines", stats.NewLines, "duration", stats.ProcessingTime.String())
+
+	return stats, nil
+}
+// SyncProjectLogs performs differential synchronization of the JSONL files of a single
+// project directory, e.g. one whose logs were just written
+func (d *DiffSyncService) SyncProjectLogs(projectPath string) (*models.SyncStats, error) {
+	syncLock.RLock()
+	defer syncLock.RUnlock()
+
+	atomic.AddInt32(&activeSyncs, 1)
+	defer atomic.AddInt32(&activeSyncs, -1)
+
+	stats := &models.SyncStats{
+		StartTime: time.Now(),
+	}
+
+	if err := d.InitializeSchema(); err != nil {
+		return stats, fmt.Errorf("failed to initialize schema: %w", err)
+	}
+
+	if _, err := os.Stat(projectPath); err != nil {
+		return stats, fmt.Errorf("failed to access project directory: %w", err)
+	}
+
+	files := d.discoverProjectJSONLFiles(projectPath)
+	stats.TotalFiles = len(files)
+	for _, file := range files {
+		d.processDiscoveredFile(file, time.Time{}, stats)
 	}
 
 	stats.EndTime = time.Now()
 	stats.ProcessingTime = stats.EndTime.Sub(stats.StartTime)
 
-	log.Printf("Sync completed: %d files processed, %d skipped, %d new lines, took %v",
-		stats.ProcessedFiles, stats.SkippedFiles, stats.NewLines, stats.ProcessingTime)
+	d.logger.Info("Project sync completed", "project", projectPath, "processed_files", stats.ProcessedFiles,
+		"skipped_files", stats.SkippedFiles, "new_lines", stats.NewLines, "duration", stats.ProcessingTime.String())
 
 	return stats, nil
 }
 
-// discoverJSONLFiles discovers all JSONL files in Claude projects directory
+// reportProgress passes the sync progress to the registered callback, if any
+func (d *DiffSyncService) reportProgress(doneFiles, totalFiles, newLines int) {
+	if d.onProgress != nil {
+		d.onProgress(doneFiles, totalFiles, newLines)
+	}
+}
+
+// processDiscoveredFile syncs one discovered file if it changed after since and
+// since its last sync, counting it in stats
+func (d *DiffSyncService) processDiscoveredFile(file models.FileInfo, since time.Time, stats *models.SyncStats) {
+	if !since.IsZero() && !file.ModTime.After(since) {
+		stats.SkippedFiles++
+		return
+	}
+
+	needsSync, lastState, err := d.stateManager.NeedsProcessing(file.Path)
+	if err != nil {
+		d.logger.Error("Error checking file", "file", file.Path, "error", err)
+		return
+	}
+
+	if needsSync {
+		newLines, err := d.syncFile(file, lastState)
+		if err != nil {
+			d.logger.Error("Error syncing file", "file", file.Path, "error", err)
+			// Update state with error
+			errorMsg := err.Error()
+			errorState := &models.FileProcessingState{
+				FilePath:     file.Path,
+				LastModified: file.ModTime,
+				FileSize:     file.Size,
+				SyncStatus:   "error",
+				ErrorMessage: &errorMsg,
+			}
+			d.stateManager.UpdateFileState(errorState)
+			return
+		}
+		stats.ProcessedFiles++
+		stats.NewLines += newLines
+	} else {
+		stats.SkippedFiles++
+	}
+}
+
+
+// discoverJSONLFiles discovers all JSONL files in the configured Claude projects directories.
+// Directories that don't exist are skipped with a warning.
 func (d *DiffSyncService) discoverJSONLFiles() ([]models.FileInfo, error) {
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get config: %w", err)
 	}
 
-	claudeDir := cfg.ClaudeProjectsDir
-	if _, err := os.Stat(claudeDir); os.IsNotExist(err) {
-		return nil, fmt.Errorf("claude projects directory not found: %s", claudeDir)
+	var files []models.FileInfo
+	for _, claudeDir := range cfg.ClaudeProjectsDirs {
+		if _, err := os.Stat(claudeDir); os.IsNotExist(err) {
+			d.logger.Warn("Claude projects directory not found, skipping", "dir", claudeDir)
+			continue
+		}
+
+		dirFiles, err := d.discoverJSONLFilesIn(claudeDir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, dirFiles...)
 	}
 
+	return files, nil
+}
+
+// discoverJSONLFilesIn discovers the JSONL files of every project in one Claude projects directory.
+// Each file stays under its project directory, so the project name is taken from there.
+func (d *DiffSyncService) discoverJSONLFilesIn(claudeDir string) ([]models.FileInfo, error) {
 	var files []models.FileInfo
 
 	entries, err := os.ReadDir(claudeDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read claude projects directory: %w", err)
+		return nil, fmt.Errorf("failed to read claude projects directory %s: %w", claudeDir, err)
 	}
 
 	for _, entry := range entries {
@@ -135,28 +298,36 @@ func (d *DiffSyncService) discoverJSONLFiles() ([]models.FileInfo, error) {
 			continue
 		}
 
-		projectPath := filepath.Join(claudeDir, entry.Name())
-		jsonlFiles, err := filepath.Glob(filepath.Join(projectPath, "*.jsonl"))
+		files = append(files, d.discoverProjectJSONLFiles(filepath.Join(claudeDir, entry.Name()))...)
+	}
+
+	return files, nil
+}
+
+// discoverProjectJSONLFiles discovers the JSONL files of one project directory
+func (d *DiffSyncService) discoverProjectJSONLFiles(projectPath string) []models.FileInfo {
+	var files []models.FileInfo
+
+	jsonlFiles, err := filepath.Glob(filepath.Join(projectPath, "*.jsonl"))
+	if err != nil {
+		d.logger.Warn("Failed to glob files", "dir", projectPath, "error", err)
+		return nil
+	}
+
+	for _, jsonlFile := range jsonlFiles {
+		fileInfo, err := os.Stat(jsonlFile)
 		if err != nil {
-			log.Printf("Warning: failed to glob files in %s: %v", projectPath, err)
+			d.logger.Warn("Failed to stat file", "file", jsonlFile, "error", err)
 			continue
 		}
-
-		for _, jsonlFile := range jsonlFiles {
-			fileInfo, err := os.Stat(jsonlFile)
-			if err != nil {
-				log.Printf("Warning: failed to stat file %s: %v", jsonlFile, err)
-				continue
-			}
-			files = append(files, models.FileInfo{
-				Path:    jsonlFile,
-				ModTime: fileInfo.ModTime(),
-				Size:    fileInfo.Size(),
-			})
-		}
+		files = append(files, models.FileInfo{
+			Path:    jsonlFile,
+			ModTime: fileInfo.ModTime(),
+			Size:    fileInfo.Size(),
+		})
 	}
 
-	return files, nil
+	return files
 }
 
 // syncFile syncs a single file, processing only new lines
@@ -185,17 +356,30 @@ func (d *DiffSyncService) syncFile(file models.FileInfo, lastState *models.FileP
 		startLine = lastState.LastProcessedLine
 	}
 
-	newLines, totalLines, err := d.processFileFromLine(file.Path, startLine)
+	// A file modified within the grace period may still be mid-write, so its
+	// unterminated last line is left for the next sync instead of being committed
+	deferPartial := d.busyGrace > 0 && time.Since(file.ModTime) < d.busyGrace
+
+	newLines, totalLines, deferredBytes, err := d.processFileLines(file.Path, startLine, deferPartial)
+	if errors.Is(err, errFileRewritten) {
+		d.logger.Warn("File was rewritten since the last sync, re-reading it from the start",
+			"file", file.Path, "last_processed_line", startLine)
+		newLines, totalLines, deferredBytes, err = d.processFileLines(file.Path, 0, deferPartial)
+	}
 	if err != nil {
 		return 0, fmt.Errorf("failed to process file: %w", err)
 	}
+	if deferredBytes > 0 {
+		d.logger.Info("Deferring unterminated last line", "file", file.Path, "bytes", deferredBytes)
+	}
 
-	// Update state to completed
+	// Update state to completed. The recorded size excludes a deferred line so the
+	// file is picked up again even if the writer never touches it afterwards.
 	now := time.Now()
 	completedState := &models.FileProcessingState{
 		FilePath:          file.Path,
 		LastModified:      file.ModTime,
-		FileSize:          file.Size,
+		FileSize:          file.Size - deferredBytes,
 		LastProcessedLine: totalLines,
 		ProcessedUntil:    &now,
 		SyncStatus:        "completed",
@@ -211,9 +395,20 @@ func (d *DiffSyncService) syncFile(file models.FileInfo, lastState *models.FileP
 
 // processFileFromLine processes a file starting from a specific line
 func (d *DiffSyncService) processFileFromLine(filePath string, startLine int) (int, int, error) {
+	processedCount, lineCount, _, err := d.processFileLines(filePath, startLine, false)
+	return processedCount, lineCount, err
+}
+
+// processFileLines processes a file starting from a specific line. With deferPartial,
+// a last line without a terminating newline is neither processed nor counted, and its
+// length is returned as the deferred byte count. A file read from its first line is
+// imported in batches; lines appended since the last sync are processed one by one.
+// If the file has fewer lines than startLine, or the last already processed line is not
+// a JSON line, the file was rewritten and errFileRewritten is returned.
+func (d *DiffSyncService) processFileLines(filePath string, startLine int, deferPartial bool) (int, int, int64, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to open file: %w", err)
+		return 0, 0, 0, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
@@ -223,13 +418,40 @@ func (d *DiffSyncService) processFileFromLine(filePath string, startLine int) (i
 	const maxCapacity = 10 * 1024 * 1024 // 10MB
 	buf := make([]byte, maxCapacity)
 	scanner.Buffer(buf, maxCapacity)
+
+	var deferredBytes int64
+	if deferPartial {
+		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+			if atEOF && len(data) > 0 && bytes.IndexByte(data, '\n') < 0 {
+				// Consume the unterminated tail without emitting it as a line
+				deferredBytes = int64(len(data))
+				return len(data), nil, nil
+			}
+			return bufio.ScanLines(data, atEOF)
+		})
+	}
 	
 	lineCount := 0
 	processedCount := 0
 
+	var batch *messageBatch
+	if startLine == 0 && d.batchSize > 1 {
+		batch = d.newMessageBatch()
+	}
+
 	// Skip already processed lines
+	var lastSkipped string
 	for lineCount < startLine && scanner.Scan() {
 		lineCount++
+		lastSkipped = scanner.Text()
+	}
+	if startLine > 0 && scanner.Err() == nil {
+		if lineCount < startLine {
+			return 0, lineCount, deferredBytes, errFileRewritten
+		}
+		if trimmed := strings.TrimSpace(lastSkipped); trimmed != "" && !json.Valid([]byte(trimmed)) {
+			return 0, lineCount, deferredBytes, errFileRewritten
+		}
 	}
 
 	// Process new lines
@@ -240,39 +462,50 @@ func (d *DiffSyncService) processFileFromLine(filePath string, startLine int) (i
 			continue
 		}
 
-		// First, try to parse as a basic JSON to check if it has required fields
-		var basicCheck map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &basicCheck); err != nil {
+		var entry models.LogEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
 			continue
 		}
 
-		// Check if this looks like a LogEntry (has sessionId and timestamp)
-		sessionId, hasSessionId := basicCheck["sessionId"]
-		timestamp, hasTimestamp := basicCheck["timestamp"]
-		if !hasSessionId || !hasTimestamp || sessionId == nil || timestamp == nil {
+		// Check if this looks like a LogEntry (has session ID and timestamp)
+		if entry.SessionID == "" || entry.Timestamp.IsZero() {
 			// Skip non-LogEntry entries (like summary entries)
 			continue
 		}
 
-		var entry models.LogEntry
-		if err := json.Unmarshal([]byte(line), &entry); err != nil {
-			continue
-		}
-
 		// Extract project name from file path
 		projectName := d.extractProjectNameFromPath(filePath)
+		if batch != nil {
+			written, err := batch.add(&entry, projectName)
+			if err != nil {
+				d.logger.Error("Error processing log entry batch", "file", filePath, "line", lineCount, "error", err)
+			}
+			processedCount += written
+			continue
+		}
 		if err := d.processLogEntry(&entry, projectName); err != nil {
-			log.Printf("Error processing log entry at line %d: %v", lineCount, err)
+			d.logger.Error("Error processing log entry", "file", filePath, "line", lineCount, "error", err)
 			continue
 		}
 		processedCount++
 	}
 
+	if batch != nil {
+		written, err := batch.flush()
+		if err != nil {
+			d.logger.Error("Error processing log entry batch", "file", filePath, "line", lineCount, "error", err)
+		}
+		processedCount += written
+		if err := batch.finish(); err != nil {
+			d.logger.Error("Error updating statistics after batch import", "file", filePath, "error", err)
+		}
+	}
+
 	if err := scanner.Err(); err != nil {
-		return processedCount, lineCount, fmt.Errorf("scanner error: %w", err)
+		return processedCount, lineCount, deferredBytes, fmt.Errorf("scanner error: %w", err)
 	}
 
-	return processedCount, lineCount, nil
+	return processedCount, lineCount, deferredBytes, nil
 }
 
 // extractProjectNameFromPath extracts project name from file path
@@ -283,6 +516,42 @@ func (d *DiffSyncService) extractProjectNameFromPath(filePath string) string {
 
 // processLogEntry processes a single log entry (similar to existing logic)
 func (d *DiffSyncService) processLogEntry(entry *models.LogEntry, projectName string) error {
+	if err := d.ensureSession(entry, projectName); err != nil {
+		return err
+	}
+
+	message := d.buildMessage(entry)
+
+	// Insert message first
+	if err := d.insertMessage(message); err != nil {
+		return fmt.Errorf("failed to insert message: %w", err)
+	}
+
+	// Get or create appropriate session window for this message
+	window, err := d.windowService.GetOrCreateWindowForMessage(entry.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to get/create session window: %w", err)
+	}
+
+	// Add message to window via relation table
+	if err := d.relationService.AddMessageToWindow(window.ID, message.ID); err != nil {
+		return fmt.Errorf("failed to add message to window: %w", err)
+	}
+
+	// Update window statistics after message insertion
+	if err := d.windowService.UpdateWindowStats(window.ID); err != nil {
+		return fmt.Errorf("failed to update window stats: %w", err)
+	}
+
+	if err := d.tokenService.UpdateSessionTokens(entry.SessionID); err != nil {
+		return fmt.Errorf("failed to update session tokens: %w", err)
+	}
+
+	return nil
+}
+
+// ensureSession creates the session of a log entry, or links an existing one to its project
+func (d *DiffSyncService) ensureSession(entry *models.LogEntry, projectName string) error {
 	// Use cwd from log entry if available, otherwise fall back to project name conversion
 	var actualProjectPath, actualProjectName string
 	if entry.Cwd != "" {
@@ -300,7 +569,11 @@ func (d *DiffSyncService) processLogEntry(entry *models.LogEntry, projectName st
 			return fmt.Errorf("failed to create/update session: %w", err)
 		}
 	}
+	return nil
+}
 
+// buildMessage converts a log entry into the message row stored for it, including its cost
+func (d *DiffSyncService) buildMessage(entry *models.LogEntry) *models.Message {
 	message := &models.Message{
 		ID:          entry.UUID,
 		SessionID:   entry.SessionID,
@@ -326,33 +599,20 @@ func (d *DiffSyncService) processLogEntry(entry *models.LogEntry, projectName st
 		message.OutputTokens = entry.Message.Usage.OutputTokens
 		message.ServiceTier = &entry.Message.Usage.ServiceTier
 	}
-
-	// Insert message first
-	if err := d.insertMessage(message); err != nil {
-		return fmt.Errorf("failed to insert message: %w", err)
-	}
-
-	// Get or create appropriate session window for this message
-	window, err := d.windowService.GetOrCreateWindowForMessage(entry.Timestamp)
-	if err != nil {
-		return fmt.Errorf("failed to get/create session window: %w", err)
-	}
-
-	// Add message to window via relation table
-	if err := d.relationService.AddMessageToWindow(window.ID, message.ID); err != nil {
-		return fmt.Errorf("failed to add message to window: %w", err)
-	}
-
-	// Update window statistics after message insertion
-	if err := d.windowService.UpdateWindowStats(window.ID); err != nil {
-		return fmt.Errorf("failed to update window stats: %w", err)
-	}
-
-	if err := d.tokenService.UpdateSessionTokens(entry.SessionID); err != nil {
-		return fmt.Errorf("failed to update session tokens: %w", err)
+	
+	// Store the per-message cost so aggregates can simply SUM it
+	if entry.Message.Role == "assistant" {
+		message.Cost = d.tokenService.CalculateMessageCost(
+			message.SessionID,
+			message.Model,
+			message.InputTokens,
+			message.OutputTokens,
+			message.CacheCreationInputTokens,
+			message.CacheReadInputTokens,
+		)
 	}
 
-	return nil
+	return message
 }
 
 // Helper methods (copied from existing JSONLParser)
@@ -412,9 +672,9 @@ func (d *DiffSyncService) insertMessage(message *models.Message) error {
 			id, session_id, parent_uuid, is_sidechain, user_type, message_type,
 			message_role, model, content, input_tokens, cache_creation_input_tokens,
 			cache_read_input_tokens, output_tokens, service_tier, request_id,
-			timestamp, created_at
+			cost, timestamp, created_at
 		) VALUES (
-			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
 			COALESCE((SELECT created_at FROM messages WHERE id = ?), ?)
 		)
 	`
@@ -436,6 +696,7 @@ func (d *DiffSyncService) insertMessage(message *models.Message) error {
 		message.OutputTokens,
 		message.ServiceTier,
 		message.RequestID,
+		message.Cost,
 		message.Timestamp,
 		message.ID, // for COALESCE subquery
 		now,        // created_at for new records
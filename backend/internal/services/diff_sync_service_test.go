@@ -2,7 +2,12 @@ package services
 
 import (
 	"database/sql"
+	"errors"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -48,6 +53,7 @@ func setupTestDBForDiffSync(t *testing.T) (*sql.DB, *DiffSyncService) {
 			output_tokens INTEGER DEFAULT 0,
 			service_tier TEXT,
 			request_id TEXT,
+			cost DOUBLE DEFAULT 0.0,
 			timestamp TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
@@ -304,4 +310,460 @@ func TestGetSyncStats(t *testing.T) {
 	if stats.SkippedFiles != 0 {
 		t.Errorf("Expected 0 skipped files, got %d", stats.SkippedFiles)
 	}
-}
\ No newline at end of file
+}
+func TestSyncLogsSince_SkipsOlderFiles(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	claudeDir := t.TempDir()
+	t.Setenv("CLAUDE_PROJECTS_DIR", claudeDir)
+
+	projectDir := filepath.Join(claudeDir, "-test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	// Neither file has a sync state, so both would be processed without since
+	oldFile := filepath.Join(projectDir, "old.jsonl")
+	newFile := filepath.Join(projectDir, "new.jsonl")
+	oldData := `{"uuid":"old-1","sessionId":"old-session","userType":"human","cwd":"/test-project","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Old"}}`
+	newData := `{"uuid":"new-1","sessionId":"new-session","userType":"human","cwd":"/test-project","timestamp":"2024-01-02T10:00:00Z","message":{"role":"user","content":"New"}}`
+	if err := os.WriteFile(oldFile, []byte(oldData+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write old file: %v", err)
+	}
+	if err := os.WriteFile(newFile, []byte(newData+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write new file: %v", err)
+	}
+
+	since := time.Now().Add(-1 * time.Hour)
+	oldTime := since.Add(-24 * time.Hour)
+	if err := os.Chtimes(oldFile, oldTime, oldTime); err != nil {
+		t.Fatalf("Failed to set old file mtime: %v", err)
+	}
+
+	stats, err := diffSyncService.SyncLogsSince(since)
+	if err != nil {
+		t.Fatalf("Failed to sync logs: %v", err)
+	}
+
+	if stats.TotalFiles != 2 {
+		t.Errorf("Expected 2 total files, got %d", stats.TotalFiles)
+	}
+	if stats.ProcessedFiles != 1 {
+		t.Errorf("Expected 1 processed file, got %d", stats.ProcessedFiles)
+	}
+	if stats.SkippedFiles != 1 {
+		t.Errorf("Expected 1 skipped file, got %d", stats.SkippedFiles)
+	}
+
+	// The skipped file must not have been touched at all
+	state, err := diffSyncService.stateManager.GetFileState(oldFile)
+	if err != nil {
+		t.Fatalf("Failed to get file state: %v", err)
+	}
+	if state != nil {
+		t.Errorf("Expected no sync state for file older than since, got %+v", state)
+	}
+}
+
+func TestSyncLogsSince_ReportsProgress(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	claudeDir := t.TempDir()
+	t.Setenv("CLAUDE_PROJECTS_DIR", claudeDir)
+
+	projectDir := filepath.Join(claudeDir, "-test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		data := `{"uuid":"` + name + `-1","sessionId":"` + name + `-session","userType":"human","cwd":"/test-project","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Hi"}}`
+		if err := os.WriteFile(filepath.Join(projectDir, name+".jsonl"), []byte(data+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write log file: %v", err)
+		}
+	}
+
+	var reports [][2]int
+	diffSyncService.SetProgressCallback(func(doneFiles, totalFiles, newLines int) {
+		reports = append(reports, [2]int{doneFiles, totalFiles})
+	})
+
+	if _, err := diffSyncService.SyncAllLogs(); err != nil {
+		t.Fatalf("Failed to sync logs: %v", err)
+	}
+
+	expected := [][2]int{{0, 2}, {1, 2}, {2, 2}}
+	if len(reports) != len(expected) {
+		t.Fatalf("Expected %d progress reports, got %v", len(expected), reports)
+	}
+	for i := range expected {
+		if reports[i] != expected[i] {
+			t.Errorf("Report %d: expected %v, got %v", i, expected[i], reports[i])
+		}
+	}
+}
+
+func TestSyncAllLogs_ScansMultipleProjectsDirs(t *testing.T) {
+	db, diffSyncService := setupTestDBForBatchSync(t)
+	defer db.Close()
+
+	personalDir := t.TempDir()
+	workDir := t.TempDir()
+	missingDir := filepath.Join(t.TempDir(), "missing")
+	t.Setenv("CLAUDE_PROJECTS_DIRS", personalDir+", "+missingDir+","+workDir)
+
+	projects := map[string]string{
+		filepath.Join(personalDir, "-personal-app"): "personal",
+		filepath.Join(workDir, "-work-app"):         "work",
+	}
+	for projectDir, name := range projects {
+		if err := os.MkdirAll(projectDir, 0755); err != nil {
+			t.Fatalf("Failed to create project dir: %v", err)
+		}
+		data := `{"uuid":"` + name + `-1","sessionId":"` + name + `-session","userType":"human","cwd":"/` + name + `-app","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Hi"}}`
+		if err := os.WriteFile(filepath.Join(projectDir, name+".jsonl"), []byte(data+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write log file: %v", err)
+		}
+	}
+
+	// The missing directory is skipped instead of failing the sync
+	stats, err := diffSyncService.SyncAllLogs()
+	if err != nil {
+		t.Fatalf("Failed to sync logs: %v", err)
+	}
+	if stats.TotalFiles != 2 {
+		t.Errorf("Expected 2 total files, got %d", stats.TotalFiles)
+	}
+	if stats.ProcessedFiles != 2 {
+		t.Errorf("Expected 2 processed files, got %d", stats.ProcessedFiles)
+	}
+
+	for _, name := range projects {
+		var projectName string
+		err := db.QueryRow("SELECT project_name FROM sessions WHERE id = ?", name+"-session").Scan(&projectName)
+		if err != nil {
+			t.Fatalf("Failed to get session %s-session: %v", name, err)
+		}
+		if projectName != name+"-app" {
+			t.Errorf("Expected session %s-session in project %s-app, got %s", name, name, projectName)
+		}
+	}
+}
+
+func TestSyncProjectLogs_SyncsOnlyThatProject(t *testing.T) {
+	db, diffSyncService := setupTestDBForBatchSync(t)
+	defer db.Close()
+
+	claudeDir := t.TempDir()
+	for _, name := range []string{"synced", "other"} {
+		projectDir := filepath.Join(claudeDir, "-"+name+"-project")
+		if err := os.MkdirAll(projectDir, 0755); err != nil {
+			t.Fatalf("Failed to create project dir: %v", err)
+		}
+		data := `{"uuid":"` + name + `-1","sessionId":"` + name + `-session","userType":"human","cwd":"/` + name + `-project","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Hi"}}`
+		if err := os.WriteFile(filepath.Join(projectDir, name+".jsonl"), []byte(data+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write log file: %v", err)
+		}
+	}
+
+	stats, err := diffSyncService.SyncProjectLogs(filepath.Join(claudeDir, "-synced-project"))
+	if err != nil {
+		t.Fatalf("Failed to sync project logs: %v", err)
+	}
+	if stats.TotalFiles != 1 || stats.ProcessedFiles != 1 {
+		t.Errorf("Expected 1 file found and processed, got %d and %d", stats.TotalFiles, stats.ProcessedFiles)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions WHERE id = 'other-session'").Scan(&count); err != nil {
+		t.Fatalf("Failed to count sessions: %v", err)
+	}
+	if count != 0 {
+		t.Error("Expected the other project not to be synced")
+	}
+
+	if _, err := diffSyncService.SyncProjectLogs(filepath.Join(claudeDir, "-missing-project")); err == nil {
+		t.Error("Expected an error for a missing project directory")
+	}
+}
+
+func TestSyncFile_DefersUnterminatedLineOfBusyFile(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	claudeDir := t.TempDir()
+	t.Setenv("CLAUDE_PROJECTS_DIR", claudeDir)
+	diffSyncService.SetBusyGrace(time.Hour)
+
+	projectDir := filepath.Join(claudeDir, "-test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	// The last line is still being written
+	logFile := filepath.Join(projectDir, "busy.jsonl")
+	complete := `{"uuid":"busy-1","sessionId":"busy-session","userType":"human","cwd":"/test-project","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Done"}}`
+	partial := `{"uuid":"busy-2","sessionId":"busy-session","userType":"human","cwd":"/test-project","timestamp":"2024-01-01T10:01:00Z","message":{"role":"user","content":"Half`
+	if err := os.WriteFile(logFile, []byte(complete+"\n"+partial), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+
+	if _, err := diffSyncService.SyncAllLogs(); err != nil {
+		t.Fatalf("Failed to sync logs: %v", err)
+	}
+
+	state, err := diffSyncService.stateManager.GetFileState(logFile)
+	if err != nil {
+		t.Fatalf("Failed to get file state: %v", err)
+	}
+	if state == nil || state.LastProcessedLine != 1 {
+		t.Fatalf("Expected last processed line 1 for a busy file, got %+v", state)
+	}
+	if state.FileSize != int64(len(complete)+1) {
+		t.Errorf("Expected recorded size to stop at the last newline (%d), got %d", len(complete)+1, state.FileSize)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM messages WHERE session_id = 'busy-session'").Scan(&count); err != nil {
+		t.Fatalf("Failed to count messages: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected only the complete line to be stored, got %d messages", count)
+	}
+
+	// Once the writer finishes the line, it is processed exactly once
+	finished := partial + `"}}`
+	if err := os.WriteFile(logFile, []byte(complete+"\n"+finished+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to finish log file: %v", err)
+	}
+	if _, err := diffSyncService.SyncAllLogs(); err != nil {
+		t.Fatalf("Failed to sync logs: %v", err)
+	}
+
+	state, err = diffSyncService.stateManager.GetFileState(logFile)
+	if err != nil {
+		t.Fatalf("Failed to get file state: %v", err)
+	}
+	if state.LastProcessedLine != 2 {
+		t.Errorf("Expected last processed line 2, got %d", state.LastProcessedLine)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM messages WHERE session_id = 'busy-session'").Scan(&count); err != nil {
+		t.Fatalf("Failed to count messages: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 messages after the line was finished, got %d", count)
+	}
+}
+
+func TestSyncAllLogs_RereadsTruncatedFile(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	claudeDir := t.TempDir()
+	t.Setenv("CLAUDE_PROJECTS_DIR", claudeDir)
+	diffSyncService.SetBusyGrace(0)
+
+	projectDir := filepath.Join(claudeDir, "-test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	line := func(uuid, content string) string {
+		return `{"uuid":"` + uuid + `","sessionId":"trunc-session","userType":"human","cwd":"/test-project","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"` + content + `"}}` + "\n"
+	}
+	logFile := filepath.Join(projectDir, "trunc.jsonl")
+	if err := os.WriteFile(logFile, []byte(line("trunc-1", "One")+line("trunc-2", "Two")+line("trunc-3", "Three")), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+	if _, err := diffSyncService.SyncAllLogs(); err != nil {
+		t.Fatalf("Failed to sync logs: %v", err)
+	}
+
+	// Rewritten with a single new line: resuming after line 3 would miss it
+	if err := os.WriteFile(logFile, []byte(line("trunc-new", "New")), 0644); err != nil {
+		t.Fatalf("Failed to rewrite log file: %v", err)
+	}
+	if _, err := diffSyncService.SyncAllLogs(); err != nil {
+		t.Fatalf("Failed to sync logs: %v", err)
+	}
+
+	state, err := diffSyncService.stateManager.GetFileState(logFile)
+	if err != nil {
+		t.Fatalf("Failed to get file state: %v", err)
+	}
+	if state == nil || state.LastProcessedLine != 1 {
+		t.Errorf("Expected last processed line 1 after the reparse, got %+v", state)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM messages WHERE id = 'trunc-new'").Scan(&count); err != nil {
+		t.Fatalf("Failed to count messages: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected the new line to be stored, got %d", count)
+	}
+}
+
+func TestSyncAllLogs_RereadsFileWithFewerLines(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	claudeDir := t.TempDir()
+	t.Setenv("CLAUDE_PROJECTS_DIR", claudeDir)
+	diffSyncService.SetBusyGrace(0)
+
+	projectDir := filepath.Join(claudeDir, "-test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	logFile := filepath.Join(projectDir, "rewritten.jsonl")
+	short := `{"uuid":"rw-%d","sessionId":"rw-session","userType":"human","cwd":"/test-project","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"x"}}`
+	content := ""
+	for i := 1; i <= 3; i++ {
+		content += strings.Replace(short, "%d", strconv.Itoa(i), 1) + "\n"
+	}
+	if err := os.WriteFile(logFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+	if _, err := diffSyncService.SyncAllLogs(); err != nil {
+		t.Fatalf("Failed to sync logs: %v", err)
+	}
+
+	// Larger than before, but with fewer lines than the stored offset
+	long := `{"uuid":"rw-long","sessionId":"rw-session","userType":"human","cwd":"/test-project","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"` + strings.Repeat("y", len(content)) + `"}}`
+	if err := os.WriteFile(logFile, []byte(long+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite log file: %v", err)
+	}
+	if _, err := diffSyncService.SyncAllLogs(); err != nil {
+		t.Fatalf("Failed to sync logs: %v", err)
+	}
+
+	state, err := diffSyncService.stateManager.GetFileState(logFile)
+	if err != nil {
+		t.Fatalf("Failed to get file state: %v", err)
+	}
+	if state == nil || state.LastProcessedLine != 1 {
+		t.Errorf("Expected last processed line 1 after the reparse, got %+v", state)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM messages WHERE id = 'rw-long'").Scan(&count); err != nil {
+		t.Fatalf("Failed to count messages: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected the rewritten line to be stored, got %d", count)
+	}
+}
+
+func TestProcessLogEntry_StoresMessageCost(t *testing.T) {
+	db, diffSyncService := setupTestDBForDiffSync(t)
+	defer db.Close()
+
+	tmpFile, err := os.CreateTemp("", "test-cost-*.jsonl")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	testData := []string{
+		`{"uuid":"cost-1","sessionId":"cost-session","userType":"human","cwd":"/test","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Hello"}}`,
+		`{"uuid":"cost-2","sessionId":"cost-session","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:01:00Z","message":{"role":"assistant","model":"claude-3-5-sonnet","content":"Hi","usage":{"input_tokens":1000,"cache_creation_input_tokens":200,"cache_read_input_tokens":3000,"output_tokens":500}}}`,
+		`{"uuid":"cost-3","sessionId":"cost-session","userType":"external","cwd":"/test","timestamp":"2024-01-01T10:02:00Z","message":{"role":"assistant","model":"claude-opus-4-20250514","content":"Done","usage":{"input_tokens":2000,"output_tokens":800}}}`,
+	}
+	for _, data := range testData {
+		tmpFile.WriteString(data + "\n")
+	}
+	tmpFile.Close()
+
+	if _, _, err := diffSyncService.processFileFromLine(tmpFile.Name(), 0); err != nil {
+		t.Fatalf("Failed to process file: %v", err)
+	}
+
+	calculator := NewPricingCalculator()
+	expected := map[string]float64{
+		"cost-1": 0,
+		"cost-2": calculator.CalculateCost("claude-3-5-sonnet", 1000, 500, 200, 3000),
+		"cost-3": calculator.CalculateCost("claude-opus-4-20250514", 2000, 800, 0, 0),
+	}
+
+	for id, want := range expected {
+		var got float64
+		if err := db.QueryRow("SELECT cost FROM messages WHERE id = ?", id).Scan(&got); err != nil {
+			t.Fatalf("Failed to query cost for %s: %v", id, err)
+		}
+		if got != want {
+			t.Errorf("Expected stored cost %f for %s, got %f", want, id, got)
+		}
+	}
+
+	sessionCost, err := diffSyncService.tokenService.CalculateSessionCost("cost-session")
+	if err != nil {
+		t.Fatalf("CalculateSessionCost failed: %v", err)
+	}
+	if want := expected["cost-2"] + expected["cost-3"]; sessionCost != want {
+		t.Errorf("Expected session cost %f, got %f", want, sessionCost)
+	}
+}
+
+func TestWaitForSyncs(t *testing.T) {
+	if !WaitForSyncs(time.Second) {
+		t.Fatal("Expected no syncs to be in progress")
+	}
+
+	atomic.AddInt32(&activeSyncs, 1)
+	if WaitForSyncs(150 * time.Millisecond) {
+		t.Error("Expected WaitForSyncs to time out while a sync is running")
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		atomic.AddInt32(&activeSyncs, -1)
+	}()
+	if !WaitForSyncs(5 * time.Second) {
+		t.Error("Expected WaitForSyncs to return once the sync finished")
+	}
+}
+
+func TestRunExclusiveOfSyncs(t *testing.T) {
+	ran := false
+	if err := RunExclusiveOfSyncs(func() error { ran = true; return nil }); err != nil || !ran {
+		t.Fatalf("Expected fn to run with no sync in progress, got %v", err)
+	}
+
+	// A running sync holds the lock for reading
+	syncLock.RLock()
+	err := RunExclusiveOfSyncs(func() error {
+		t.Error("Expected fn not to run during a sync")
+		return nil
+	})
+	syncLock.RUnlock()
+	if !errors.Is(err, ErrSyncInProgress) {
+		t.Errorf("Expected ErrSyncInProgress, got %v", err)
+	}
+
+	// A sync started during the rebuild waits for it to finish
+	synced := make(chan struct{})
+	err = RunExclusiveOfSyncs(func() error {
+		go func() {
+			syncLock.RLock()
+			syncLock.RUnlock()
+			close(synced)
+		}()
+		select {
+		case <-synced:
+			t.Error("Expected the sync to wait for the rebuild")
+		case <-time.After(100 * time.Millisecond):
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunExclusiveOfSyncs failed: %v", err)
+	}
+	select {
+	case <-synced:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the sync to proceed after the rebuild")
+	}
+}
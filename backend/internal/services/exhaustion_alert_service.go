@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"ccdash-backend/internal/models"
+)
+
+// ExhaustionAlertPayload is posted to the configured webhook when the limit is projected to be hit soon
+type ExhaustionAlertPayload struct {
+	Event      string                       `json:"event"`
+	Threshold  string                       `json:"threshold"`
+	Projection *models.ExhaustionProjection `json:"projection"`
+}
+
+// ExhaustionAlertService periodically checks the projected token exhaustion and notifies a webhook
+type ExhaustionAlertService struct {
+	tokenService *TokenService
+	webhookURL   string
+	threshold    time.Duration
+	cooldown     time.Duration
+	httpClient   *http.Client
+
+	lastAlertAt *time.Time
+	alertMutex  sync.Mutex
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewExhaustionAlertService creates a new alert service.
+// An alert fires when exhaustion is projected within threshold, at most once per cooldown.
+func NewExhaustionAlertService(tokenService *TokenService, webhookURL string, threshold, cooldown time.Duration) *ExhaustionAlertService {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &ExhaustionAlertService{
+		tokenService: tokenService,
+		webhookURL:   webhookURL,
+		threshold:    threshold,
+		cooldown:     cooldown,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+}
+
+// Start begins checking the projection at the given interval
+func (s *ExhaustionAlertService) Start(interval time.Duration) {
+	log.Printf("Starting exhaustion alerts (threshold: %v, cooldown: %v)", s.threshold, s.cooldown)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Check(); err != nil {
+					log.Printf("Error checking exhaustion projection: %v", err)
+				}
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic check
+func (s *ExhaustionAlertService) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Check evaluates the current projection and sends an alert if needed.
+// It returns whether an alert was sent.
+func (s *ExhaustionAlertService) Check() (bool, error) {
+	projection, err := s.tokenService.GetProjectedExhaustion()
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	previous, ok := s.shouldAlert(projection, now)
+	if !ok {
+		return false, nil
+	}
+
+	payload := ExhaustionAlertPayload{
+		Event:      "usage.exhaustion_projected",
+		Threshold:  s.threshold.String(),
+		Projection: projection,
+	}
+	if err := postJSONWebhook(s.httpClient, s.webhookURL, payload); err != nil {
+		// Let the next check retry instead of waiting out the cooldown
+		s.releaseAlert(now, previous)
+		return false, err
+	}
+
+	log.Printf("Sent exhaustion alert: limit projected at %v", projection.ProjectedExhaustion.Format(time.RFC3339))
+	return true, nil
+}
+
+// shouldAlert reports whether the projection is within the threshold and the cooldown has passed.
+// The cooldown is claimed before sending so concurrent checks do not alert twice; the
+// previous alert time is returned so a failed delivery can release the claim.
+func (s *ExhaustionAlertService) shouldAlert(projection *models.ExhaustionProjection, now time.Time) (*time.Time, bool) {
+	if s.webhookURL == "" || projection.ProjectedExhaustion == nil || !projection.ExhaustsBeforeReset {
+		return nil, false
+	}
+	if projection.ProjectedExhaustion.Sub(now) > s.threshold {
+		return nil, false
+	}
+
+	s.alertMutex.Lock()
+	defer s.alertMutex.Unlock()
+
+	if s.lastAlertAt != nil && now.Sub(*s.lastAlertAt) < s.cooldown {
+		return nil, false
+	}
+	previous := s.lastAlertAt
+	s.lastAlertAt = &now
+	return previous, true
+}
+
+// releaseAlert restores the alert time from before the claim made at claimedAt
+func (s *ExhaustionAlertService) releaseAlert(claimedAt time.Time, previous *time.Time) {
+	s.alertMutex.Lock()
+	defer s.alertMutex.Unlock()
+
+	if s.lastAlertAt != nil && s.lastAlertAt.Equal(claimedAt) {
+		s.lastAlertAt = previous
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// insertBurningWindow creates an active window 30 minutes in with 5000 tokens used
+// and 2000 tokens spent in the last 30 minutes (4000 tokens/hour)
+func insertBurningWindow(t *testing.T, service *TokenService) {
+	now := time.Now().UTC()
+	windowStart := now.Add(-30 * time.Minute)
+
+	_, err := service.db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, total_input_tokens, total_output_tokens, total_tokens, is_active)
+		VALUES (?, ?, ?, ?, ?, ?, ?, true)
+	`, "burning-window", windowStart, windowStart.Add(WINDOW_DURATION), windowStart.Add(WINDOW_DURATION), 2500, 2500, 5000)
+	if err != nil {
+		t.Fatalf("Failed to insert window: %v", err)
+	}
+
+	_, err = service.db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES (?, ?, ?, ?)`,
+		"burning-session", "test-project", "/test/path", windowStart)
+	if err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := service.db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, fmt.Sprintf("burn-msg-%d", i), "burning-session", "assistant", "test", now.Add(-time.Duration(i+1)*time.Minute), 100, 100)
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+}
+
+func TestGetProjectedExhaustion(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewTokenService(db)
+	insertBurningWindow(t, service)
+
+	projection, err := service.GetProjectedExhaustion()
+	if err != nil {
+		t.Fatalf("GetProjectedExhaustion failed: %v", err)
+	}
+
+	if projection.RemainingTokens != CLAUDE_PRO_LIMIT-5000 {
+		t.Errorf("Expected %d remaining tokens, got %d", CLAUDE_PRO_LIMIT-5000, projection.RemainingTokens)
+	}
+	if projection.BurnRatePerHour < 3900 || projection.BurnRatePerHour > 4100 {
+		t.Errorf("Expected burn rate around 4000/h, got %f", projection.BurnRatePerHour)
+	}
+	if projection.MinutesToExhaustion == nil || *projection.MinutesToExhaustion < 28 || *projection.MinutesToExhaustion > 32 {
+		t.Errorf("Expected exhaustion in about 30 minutes, got %v", projection.MinutesToExhaustion)
+	}
+	if !projection.ExhaustsBeforeReset {
+		t.Error("Expected exhaustion before window reset")
+	}
+	// Half of the sample period with enough messages
+	if projection.Confidence != 0.5 {
+		t.Errorf("Expected confidence 0.5, got %f", projection.Confidence)
+	}
+}
+
+func TestExhaustionAlertService_Debounce(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	var hits int32
+	var received ExhaustionAlertPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tokenService := NewTokenService(db)
+	insertBurningWindow(t, tokenService)
+
+	// Projected exhaustion (~30m) is outside a 10m threshold
+	quiet := NewExhaustionAlertService(tokenService, server.URL, 10*time.Minute, time.Hour)
+	sent, err := quiet.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if sent || atomic.LoadInt32(&hits) != 0 {
+		t.Error("Expected no alert outside the threshold")
+	}
+
+	alerts := NewExhaustionAlertService(tokenService, server.URL, 45*time.Minute, time.Hour)
+	sent, err = alerts.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !sent || atomic.LoadInt32(&hits) != 1 {
+		t.Fatalf("Expected one alert, got %d", atomic.LoadInt32(&hits))
+	}
+	if received.Event != "usage.exhaustion_projected" || received.Projection == nil {
+		t.Errorf("Unexpected alert payload: %+v", received)
+	}
+
+	// Subsequent polls within the cooldown are debounced
+	sent, err = alerts.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if sent || atomic.LoadInt32(&hits) != 1 {
+		t.Errorf("Expected alert to be debounced, got %d webhook calls", atomic.LoadInt32(&hits))
+	}
+}
+
+func TestExhaustionAlertService_RetriesFailedDelivery(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first delivery fails
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tokenService := NewTokenService(db)
+	insertBurningWindow(t, tokenService)
+
+	alerts := NewExhaustionAlertService(tokenService, server.URL, 45*time.Minute, time.Hour)
+	if sent, err := alerts.Check(); err == nil || sent {
+		t.Fatalf("Expected failed delivery, got sent=%v err=%v", sent, err)
+	}
+
+	// A failed delivery does not start the cooldown
+	sent, err := alerts.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !sent || atomic.LoadInt32(&hits) != 2 {
+		t.Errorf("Expected the alert to be retried, got %d webhook calls", atomic.LoadInt32(&hits))
+	}
+}
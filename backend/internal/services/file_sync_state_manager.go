@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"time"
 
@@ -159,6 +160,14 @@ func (f *FileSyncStateManager) NeedsProcessing(filePath string) (bool, *models.F
 		return true, nil, nil
 	}
 	
+	// A file smaller than what was already read has been truncated or rewritten,
+	// so the stored line offset no longer applies and the file is read from the start
+	if fileInfo.Size() < lastState.FileSize {
+		slog.Warn("File shrank since the last sync, re-reading it from the start",
+			"file", filePath, "size", fileInfo.Size(), "recorded_size", lastState.FileSize)
+		return true, nil, nil
+	}
+
 	// Check if file has been modified
 	if fileInfo.ModTime().After(lastState.LastModified) {
 		return true, lastState, nil
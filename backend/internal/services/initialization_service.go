@@ -1,6 +1,7 @@
 package services
 
 import (
+	"math"
 	"sync"
 	"time"
 )
@@ -23,14 +24,20 @@ type InitializationState struct {
 }
 
 type ProgressInfo struct {
-	ProcessedFiles int `json:"processed_files"`
-	TotalFiles     int `json:"total_files"`
-	NewLines       int `json:"new_lines"`
+	// ProcessedFiles counts the discovered files handled so far, whether synced, skipped or failed
+	ProcessedFiles int     `json:"processed_files"`
+	TotalFiles     int     `json:"total_files"`
+	NewLines       int     `json:"new_lines"`
+	Percent        float64 `json:"percent"`
+	// ETASeconds is the estimated time left at the rate so far; nil until the first file is done
+	ETASeconds *int `json:"eta_seconds"`
 }
 
 type InitializationService struct {
 	mu    sync.RWMutex
 	state InitializationState
+	// progressStart is when files started being reported, so discovery time does not skew the ETA
+	progressStart time.Time
 }
 
 var globalInitService *InitializationService
@@ -58,6 +65,7 @@ func (s *InitializationService) StartInitialization() {
 		Message:   "Initializing database and syncing logs...",
 		StartTime: time.Now(),
 	}
+	s.progressStart = time.Time{}
 }
 
 func (s *InitializationService) UpdateProgress(processedFiles, totalFiles, newLines int) {
@@ -65,19 +73,48 @@ func (s *InitializationService) UpdateProgress(processedFiles, totalFiles, newLi
 	defer s.mu.Unlock()
 
 	if s.state.Status == StatusInitializing {
-		s.state.Progress = &ProgressInfo{
-			ProcessedFiles: processedFiles,
-			TotalFiles:     totalFiles,
-			NewLines:       newLines,
+		if s.progressStart.IsZero() {
+			s.progressStart = time.Now()
 		}
+		s.state.Progress = newProgressInfo(processedFiles, totalFiles, newLines, time.Since(s.progressStart))
 		s.state.Message = "Syncing logs..."
 	}
 }
 
-func (s *InitializationService) CompleteInitialization(processedFiles, newLines int) {
+// newProgressInfo computes the completion percentage and, once some files are done,
+// an ETA assuming the remaining files take as long on average as the ones so far
+func newProgressInfo(processedFiles, totalFiles, newLines int, elapsed time.Duration) *ProgressInfo {
+	progress := &ProgressInfo{
+		ProcessedFiles: processedFiles,
+		TotalFiles:     totalFiles,
+		NewLines:       newLines,
+	}
+	if totalFiles <= 0 {
+		return progress
+	}
+
+	done := processedFiles
+	if done > totalFiles {
+		done = totalFiles
+	}
+	progress.Percent = math.Round(float64(done)/float64(totalFiles)*1000) / 10
+
+	if done > 0 {
+		remaining := elapsed.Seconds() / float64(done) * float64(totalFiles-done)
+		eta := int(math.Ceil(remaining))
+		progress.ETASeconds = &eta
+	}
+	return progress
+}
+
+// CompleteInitialization marks the initial sync of totalFiles discovered files as done.
+// Every discovered file has been handled by then, so processed_files equals total_files.
+func (s *InitializationService) CompleteInitialization(totalFiles, newLines int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	eta := 0
+
 	now := time.Now()
 	s.state = InitializationState{
 		Status:    StatusCompleted,
@@ -85,8 +122,11 @@ func (s *InitializationService) CompleteInitialization(processedFiles, newLines
 		StartTime: s.state.StartTime,
 		EndTime:   &now,
 		Progress: &ProgressInfo{
-			ProcessedFiles: processedFiles,
+			ProcessedFiles: totalFiles,
+			TotalFiles:     totalFiles,
 			NewLines:       newLines,
+			Percent:        100,
+			ETASeconds:     &eta,
 		},
 	}
 }
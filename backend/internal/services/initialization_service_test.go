@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewProgressInfo(t *testing.T) {
+	progress := newProgressInfo(0, 200, 0, time.Second)
+	if progress.Percent != 0 || progress.ETASeconds != nil {
+		t.Errorf("Expected 0%% and no ETA before any file is done, got %+v", progress)
+	}
+
+	// 50 files in 10s leaves 150 files, about 30s
+	progress = newProgressInfo(50, 200, 1000, 10*time.Second)
+	if progress.Percent != 25 {
+		t.Errorf("Expected 25%%, got %v", progress.Percent)
+	}
+	if progress.ETASeconds == nil || *progress.ETASeconds != 30 {
+		t.Errorf("Expected ETA of 30s, got %v", progress.ETASeconds)
+	}
+
+	progress = newProgressInfo(1, 3, 0, time.Second)
+	if progress.Percent != 33.3 {
+		t.Errorf("Expected percent rounded to 33.3, got %v", progress.Percent)
+	}
+
+	progress = newProgressInfo(0, 0, 0, time.Second)
+	if progress.Percent != 0 || progress.ETASeconds != nil {
+		t.Errorf("Expected no percent or ETA without discovered files, got %+v", progress)
+	}
+}
+
+func TestInitializationService_Progress(t *testing.T) {
+	service := &InitializationService{}
+
+	// Progress is ignored outside of an initialization
+	service.UpdateProgress(1, 2, 3)
+	if service.GetState().Progress != nil {
+		t.Errorf("Expected no progress before initialization started")
+	}
+
+	service.StartInitialization()
+	service.UpdateProgress(0, 4, 0)
+	service.UpdateProgress(2, 4, 10)
+
+	progress := service.GetState().Progress
+	if progress == nil {
+		t.Fatal("Expected progress while initializing")
+	}
+	if progress.ProcessedFiles != 2 || progress.TotalFiles != 4 || progress.Percent != 50 {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+	if progress.ETASeconds == nil {
+		t.Error("Expected an ETA once files are done")
+	}
+
+	service.CompleteInitialization(4, 10)
+	progress = service.GetState().Progress
+	if progress.Percent != 100 || progress.ProcessedFiles != 4 || progress.TotalFiles != 4 || *progress.ETASeconds != 0 {
+		t.Errorf("Expected completed progress at 100%% with every discovered file processed, got %+v", progress)
+	}
+
+	service.StartInitialization()
+	service.FailInitialization(errors.New("boom"))
+	if service.GetState().Progress != nil {
+		t.Errorf("Expected no progress after a failure")
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// IntegrityService checks the database for broken references that foreign keys do not prevent
+type IntegrityService struct {
+	db *sql.DB
+}
+
+// IntegrityReport counts the integrity problems found in the database
+type IntegrityReport struct {
+	Healthy                bool      `json:"healthy"`
+	SessionsNullProject    int       `json:"sessions_null_project"`    // Legacy sessions without a project
+	OrphanedSessions       int       `json:"orphaned_sessions"`        // project_id references a missing project
+	MessagesWithoutSession int       `json:"messages_without_session"` // session_id is NULL or references a missing session
+	MultiWindowMessages    int       `json:"multi_window_messages"`    // Messages assigned to more than one window
+	EmptyWindows           int       `json:"empty_windows"`            // Windows with no messages assigned
+	CheckedAt              time.Time `json:"checked_at"`
+}
+
+func NewIntegrityService(db *sql.DB) *IntegrityService {
+	return &IntegrityService{db: db}
+}
+
+// Check runs every integrity check, each as a single aggregate query. It changes nothing.
+// Sessions with a NULL project_id are reported but do not make the database unhealthy.
+func (s *IntegrityService) Check() (*IntegrityReport, error) {
+	report := &IntegrityReport{CheckedAt: time.Now()}
+
+	checks := []struct {
+		name  string
+		query string
+		count *int
+	}{
+		{"sessions with NULL project_id", `
+			SELECT COUNT(*) FROM sessions WHERE project_id IS NULL
+		`, &report.SessionsNullProject},
+		{"orphaned sessions", `
+			SELECT COUNT(*)
+			FROM sessions s
+			LEFT JOIN projects p ON p.id = s.project_id
+			WHERE s.project_id IS NOT NULL AND p.id IS NULL
+		`, &report.OrphanedSessions},
+		{"messages without session", `
+			SELECT COUNT(*)
+			FROM messages m
+			LEFT JOIN sessions s ON s.id = m.session_id
+			WHERE s.id IS NULL
+		`, &report.MessagesWithoutSession},
+		{"messages in multiple windows", `
+			SELECT COUNT(*) FROM (
+				SELECT message_id
+				FROM session_window_messages
+				GROUP BY message_id
+				HAVING COUNT(DISTINCT session_window_id) > 1
+			)
+		`, &report.MultiWindowMessages},
+		{"empty windows", `
+			SELECT COUNT(*)
+			FROM session_windows w
+			WHERE NOT EXISTS (
+				SELECT 1 FROM session_window_messages swm WHERE swm.session_window_id = w.id
+			)
+		`, &report.EmptyWindows},
+	}
+
+	for _, check := range checks {
+		if err := s.db.QueryRow(check.query).Scan(check.count); err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", check.name, err)
+		}
+	}
+
+	report.Healthy = report.OrphanedSessions == 0 && report.MessagesWithoutSession == 0 &&
+		report.MultiWindowMessages == 0 && report.EmptyWindows == 0
+	return report, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/marcboeker/go-duckdb"
+)
+
+func setupIntegrityTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+
+	for _, query := range []string{
+		`CREATE TABLE projects (id VARCHAR PRIMARY KEY, name VARCHAR, path VARCHAR)`,
+		`CREATE TABLE sessions (id VARCHAR PRIMARY KEY, project_id VARCHAR)`,
+		`CREATE TABLE messages (id VARCHAR PRIMARY KEY, session_id VARCHAR)`,
+		`CREATE TABLE session_windows (id VARCHAR PRIMARY KEY)`,
+		`CREATE TABLE session_window_messages (
+			id VARCHAR PRIMARY KEY,
+			session_window_id VARCHAR NOT NULL,
+			message_id VARCHAR NOT NULL,
+			UNIQUE(session_window_id, message_id)
+		)`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to create test tables: %v", err)
+		}
+	}
+
+	return db
+}
+
+func TestIntegrityService_Check(t *testing.T) {
+	db := setupIntegrityTestDB(t)
+	defer db.Close()
+
+	service := NewIntegrityService(db)
+
+	// Consistent data: one project, session, message and window
+	for _, query := range []string{
+		`INSERT INTO projects VALUES ('p1', 'ccdash', '/src/ccdash')`,
+		`INSERT INTO sessions VALUES ('s1', 'p1')`,
+		`INSERT INTO messages VALUES ('m1', 's1')`,
+		`INSERT INTO session_windows VALUES ('w1')`,
+		`INSERT INTO session_window_messages VALUES ('r1', 'w1', 'm1')`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+
+	report, err := service.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !report.Healthy || report.OrphanedSessions != 0 || report.MessagesWithoutSession != 0 ||
+		report.MultiWindowMessages != 0 || report.EmptyWindows != 0 || report.SessionsNullProject != 0 {
+		t.Errorf("Expected a healthy report, got %+v", report)
+	}
+
+	// A legacy session without a project is reported but still healthy
+	if _, err := db.Exec(`INSERT INTO sessions VALUES ('s-legacy', NULL)`); err != nil {
+		t.Fatalf("Failed to insert test data: %v", err)
+	}
+	report, err = service.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if !report.Healthy || report.SessionsNullProject != 1 {
+		t.Errorf("Expected 1 session without project and a healthy report, got %+v", report)
+	}
+
+	for _, query := range []string{
+		`INSERT INTO sessions VALUES ('s-orphan', 'deleted-project')`,
+		`INSERT INTO messages VALUES ('m-no-session', 'missing-session')`,
+		`INSERT INTO messages VALUES ('m-null-session', NULL)`,
+		`INSERT INTO session_windows VALUES ('w2')`,
+		`INSERT INTO session_windows VALUES ('w-empty')`,
+		`INSERT INTO session_window_messages VALUES ('r2', 'w2', 'm1')`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to insert test data: %v", err)
+		}
+	}
+
+	report, err = service.Check()
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if report.Healthy {
+		t.Error("Expected an unhealthy report")
+	}
+	if report.SessionsNullProject != 1 || report.OrphanedSessions != 1 || report.MessagesWithoutSession != 2 ||
+		report.MultiWindowMessages != 1 || report.EmptyWindows != 1 {
+		t.Errorf("Unexpected integrity counts: %+v", report)
+	}
+}
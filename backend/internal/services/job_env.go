@@ -0,0 +1,80 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ccdash-backend/internal/models"
+)
+
+// defaultJobEnvPassthrough is the backend environment passed to jobs unless configured otherwise:
+// the variables every platform uses, then the ones of this platform (platformJobEnvPassthrough).
+// Anything else, such as API keys and JWT secrets of the backend itself, is stripped.
+var defaultJobEnvPassthrough = append([]string{
+	"PATH", "TZ", "LANG",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+	"ANTHROPIC_API_KEY", "CLAUDE_CONFIG_DIR",
+}, platformJobEnvPassthrough...)
+
+// requiredJobEnv is always set for claude to run in non-interactive mode
+var requiredJobEnv = []string{"CLAUDECODE=1", "CLAUDE_CODE_ENTRYPOINT=cli"}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// validateJobEnv checks the extra environment variables requested for a job
+func validateJobEnv(env map[string]string) error {
+	for name, value := range env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("%q is not a valid variable name", name)
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("value of %s contains a NUL byte", name)
+		}
+	}
+	return nil
+}
+
+// buildJobEnv returns the environment of a job's process: the allowlisted backend variables,
+// then the job's own variables, then the variables claude requires
+func (je *JobExecutor) buildJobEnv(job *models.Job) []string {
+	allowed := make(map[string]bool, len(je.envPassthrough))
+	for _, name := range je.envPassthrough {
+		allowed[jobEnvKey(name)] = true
+	}
+
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		name, value, ok := strings.Cut(entry, "=")
+		if ok && allowed[jobEnvKey(name)] {
+			env[name] = value
+		}
+	}
+
+	if job.Env != nil && *job.Env != "" {
+		var jobEnv map[string]string
+		if err := json.Unmarshal([]byte(*job.Env), &jobEnv); err != nil {
+			slog.Warn("Ignoring invalid job env", "job_id", job.ID, "error", err)
+		} else {
+			for name, value := range jobEnv {
+				env[name] = value
+			}
+		}
+	}
+
+	for _, entry := range requiredJobEnv {
+		name, value, _ := strings.Cut(entry, "=")
+		env[name] = value
+	}
+
+	result := make([]string, 0, len(env))
+	for name, value := range env {
+		result = append(result, name+"="+value)
+	}
+	sort.Strings(result)
+	return result
+}
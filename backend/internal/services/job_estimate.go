@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"ccdash-backend/internal/models"
+)
+
+// JOB_ESTIMATE_SAMPLE_JOBS is how many recently completed jobs the per-job average is taken from
+const JOB_ESTIMATE_SAMPLE_JOBS = 20
+
+// JOB_ESTIMATE_CHARS_PER_TOKEN approximates how many characters of command text make one token
+const JOB_ESTIMATE_CHARS_PER_TOKEN = 4
+
+// jobUsageAverage is the average token use of completed jobs
+type jobUsageAverage struct {
+	jobs   int
+	tokens float64
+	cost   float64
+}
+
+// EstimateJobTokens returns a ballpark token and cost estimate for running command in the project.
+// A job's usage is the tokens of the project's messages logged while it ran; the estimate is the
+// average over its recently completed jobs plus the command text itself. Projects without history
+// fall back to the average of all projects, then to the command text alone.
+func (js *JobService) EstimateJobTokens(projectID, command string) (*models.JobEstimate, error) {
+	commandTokens := int(math.Ceil(float64(len(command)) / JOB_ESTIMATE_CHARS_PER_TOKEN))
+
+	average, err := js.averageJobUsage(projectID)
+	if err != nil {
+		return nil, err
+	}
+	basis := models.JobEstimateBasisProjectHistory
+	if average.jobs == 0 {
+		if average, err = js.averageJobUsage(""); err != nil {
+			return nil, err
+		}
+		basis = models.JobEstimateBasisGlobalHistory
+	}
+
+	estimate := &models.JobEstimate{
+		IsEstimate:      true,
+		SampleJobs:      average.jobs,
+		EstimatedTokens: commandTokens,
+	}
+	if average.jobs == 0 {
+		estimate.Basis = models.JobEstimateBasisCommandOnly
+		estimate.Note = "No completed jobs with recorded usage yet: only the command text is counted and the cost is unknown"
+		return estimate, nil
+	}
+
+	estimate.Basis = basis
+	estimate.EstimatedTokens += int(math.Round(average.tokens))
+	cost := 0.0
+	if average.tokens > 0 {
+		// Price the command text at the average cost per token of past jobs
+		cost = roundToDecimals(average.cost*float64(estimate.EstimatedTokens)/average.tokens, 6)
+	}
+	estimate.EstimatedCost = &cost
+	if basis == models.JobEstimateBasisProjectHistory {
+		estimate.Note = fmt.Sprintf("Rough estimate from the average of %d completed jobs in this project; actual usage can differ widely", average.jobs)
+	} else {
+		estimate.Note = fmt.Sprintf("No completed jobs in this project yet: rough estimate from the average of %d completed jobs across all projects", average.jobs)
+	}
+	return estimate, nil
+}
+
+// averageJobUsage averages the token use of the most recently completed jobs of a project,
+// or of all projects if projectID is empty. Jobs without any messages logged while they ran
+// are left out, as their usage was not recorded.
+func (js *JobService) averageJobUsage(projectID string) (*jobUsageAverage, error) {
+	projectCondition := ""
+	args := []interface{}{models.JobStatusCompleted}
+	if projectID != "" {
+		projectCondition = "AND project_id = ?"
+		args = append(args, projectID)
+	}
+	args = append(args, JOB_ESTIMATE_SAMPLE_JOBS)
+
+	query := `
+		SELECT COUNT(*), COALESCE(AVG(job_tokens), 0), COALESCE(AVG(job_cost), 0)
+		FROM (
+			SELECT
+				j.id,
+				SUM(m.input_tokens + m.output_tokens +
+					COALESCE(m.cache_creation_input_tokens, 0) + COALESCE(m.cache_read_input_tokens, 0)) AS job_tokens,
+				SUM(COALESCE(m.cost, 0)) AS job_cost
+			FROM (
+				SELECT id, project_id, started_at, completed_at
+				FROM jobs
+				WHERE status = ? AND started_at IS NOT NULL AND completed_at IS NOT NULL ` + projectCondition + `
+				ORDER BY CAST(completed_at AS TIMESTAMP) DESC
+				LIMIT ?
+			) j
+			JOIN sessions s ON s.project_id = j.project_id
+			JOIN messages m ON m.session_id = s.id
+				AND m.timestamp >= CAST(j.started_at AS TIMESTAMP)
+				AND m.timestamp <= CAST(j.completed_at AS TIMESTAMP)
+			GROUP BY j.id
+		)
+	`
+
+	average := &jobUsageAverage{}
+	if err := js.db.QueryRow(query, args...).Scan(&average.jobs, &average.tokens, &average.cost); err != nil {
+		return nil, fmt.Errorf("failed to average job token usage: %w", err)
+	}
+	return average, nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"ccdash-backend/internal/models"
+)
+
+func TestJobService_EstimateJobTokens(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE sessions (
+			id VARCHAR PRIMARY KEY,
+			project_id VARCHAR
+		);
+		CREATE TABLE messages (
+			id VARCHAR PRIMARY KEY,
+			session_id VARCHAR NOT NULL,
+			input_tokens INTEGER DEFAULT 0,
+			output_tokens INTEGER DEFAULT 0,
+			cache_creation_input_tokens INTEGER DEFAULT 0,
+			cache_read_input_tokens INTEGER DEFAULT 0,
+			cost DOUBLE DEFAULT 0.0,
+			timestamp TIMESTAMP NOT NULL
+		);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to extend test schema: %v", err)
+	}
+
+	for _, id := range []string{"busy-project", "new-project"} {
+		if _, err := db.Exec(`INSERT INTO projects (id, name, path) VALUES (?, ?, ?)`, id, id, "/test/"+id); err != nil {
+			t.Fatalf("Failed to insert project: %v", err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_id) VALUES ('busy-session', 'busy-project')`); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+
+	jobService := NewJobService(db)
+	command := "12345678" // 2 tokens of command text
+
+	// No history anywhere: only the command is counted
+	estimate, err := jobService.EstimateJobTokens("new-project", command)
+	if err != nil {
+		t.Fatalf("EstimateJobTokens failed: %v", err)
+	}
+	if !estimate.IsEstimate || estimate.Basis != models.JobEstimateBasisCommandOnly {
+		t.Errorf("Expected a command_only estimate, got %+v", estimate)
+	}
+	if estimate.EstimatedTokens != 2 || estimate.EstimatedCost != nil {
+		t.Errorf("Expected 2 tokens and no cost, got %d and %v", estimate.EstimatedTokens, estimate.EstimatedCost)
+	}
+
+	// Two completed jobs using 1000 and 3000 tokens; the message outside any job is ignored
+	base := time.Date(2025, 7, 15, 10, 0, 0, 0, time.UTC)
+	jobs := []struct {
+		id     string
+		start  time.Time
+		tokens int
+		cost   float64
+	}{
+		{"job-1", base, 1000, 0.01},
+		{"job-2", base.Add(2 * time.Hour), 3000, 0.03},
+	}
+	for _, job := range jobs {
+		_, err := db.Exec(`
+			INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, started_at, completed_at)
+			VALUES (?, 'busy-project', 'claude task', '/test/busy-project', ?, ?, ?, ?)`,
+			job.id, models.JobStatusCompleted, job.start.Format(time.RFC3339),
+			job.start.Format(time.RFC3339), job.start.Add(30*time.Minute).Format(time.RFC3339))
+		if err != nil {
+			t.Fatalf("Failed to insert job: %v", err)
+		}
+		_, err = db.Exec(`
+			INSERT INTO messages (id, session_id, input_tokens, output_tokens, cache_read_input_tokens, cost, timestamp)
+			VALUES (?, 'busy-session', ?, ?, ?, ?, ?)`,
+			job.id+"-msg", job.tokens/4, job.tokens/4, job.tokens/2, job.cost, job.start.Add(10*time.Minute))
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+	_, err = db.Exec(`
+		INSERT INTO messages (id, session_id, input_tokens, output_tokens, cost, timestamp)
+		VALUES ('manual-msg', 'busy-session', 50000, 50000, 1.0, ?)`, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to insert message: %v", err)
+	}
+
+	estimate, err = jobService.EstimateJobTokens("busy-project", command)
+	if err != nil {
+		t.Fatalf("EstimateJobTokens failed: %v", err)
+	}
+	if estimate.Basis != models.JobEstimateBasisProjectHistory || estimate.SampleJobs != 2 {
+		t.Errorf("Expected a project_history estimate from 2 jobs, got %+v", estimate)
+	}
+	if estimate.EstimatedTokens != 2002 {
+		t.Errorf("Expected 2002 estimated tokens, got %d", estimate.EstimatedTokens)
+	}
+	if estimate.EstimatedCost == nil || *estimate.EstimatedCost != 0.02002 {
+		t.Errorf("Expected estimated cost 0.02002, got %v", estimate.EstimatedCost)
+	}
+
+	// A project without jobs falls back to the average of all projects
+	estimate, err = jobService.EstimateJobTokens("new-project", command)
+	if err != nil {
+		t.Fatalf("EstimateJobTokens failed: %v", err)
+	}
+	if estimate.Basis != models.JobEstimateBasisGlobalHistory || estimate.EstimatedTokens != 2002 {
+		t.Errorf("Expected a global_history estimate of 2002 tokens, got %+v", estimate)
+	}
+}
@@ -3,16 +3,21 @@ package services
 import (
 	"bufio"
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"ccdash-backend/internal/middleware"
 	"ccdash-backend/internal/models"
 )
 
@@ -26,6 +31,23 @@ type JobExecutor struct {
 	ctx             context.Context
 	cancel          context.CancelFunc
 	wg              sync.WaitGroup
+	outputRules     *OutputRuleDispatcher
+	notifier        *JobCompletionNotifier
+	paused          bool
+	draining        bool            // Set by Drain; no new jobs are started
+	pauseMutex      sync.RWMutex
+	interrupted     map[string]bool // Jobs force-cancelled by Drain, guarded by cancelMutex
+	resourceLimits  models.ResourceLimits // Defaults for jobs without their own limits
+	staleThreshold  time.Duration         // Minimum age before an untracked running job is stale
+	monitorInterval time.Duration         // How often the queue monitor polls for pending jobs
+	whitelist       *CommandWhitelist     // Commands that skip the safety check, shared by all jobs
+	maxOutputBytes  int                   // Bytes of each output stream kept per job; older output is dropped
+	envPassthrough  []string              // Backend environment variables passed to jobs
+	allowedRoots    []string              // Directories jobs may run under; empty allows any directory
+	maxPerProject   int                   // Jobs of one project run at once; 0 is unlimited
+	projectRunning  map[string]int        // Running jobs per project, guarded by cancelMutex
+	heldJobs        map[string][]string   // Jobs waiting for a project slot, guarded by cancelMutex
+	jobRequests     map[string]string     // IDs of the requests that queued jobs, guarded by cancelMutex
 }
 
 // NewJobExecutor creates a new job executor
@@ -37,11 +59,99 @@ func NewJobExecutor(jobService *JobService, workerCount int) *JobExecutor {
 		workerCount:     workerCount,
 		jobQueue:        make(chan string, 100), // Buffer for pending jobs
 		cancelMap:       make(map[string]context.CancelFunc),
+		interrupted:     make(map[string]bool),
+		projectRunning:  make(map[string]int),
+		heldJobs:        make(map[string][]string),
+		jobRequests:     make(map[string]string),
+		staleThreshold:  DEFAULT_STALE_JOB_THRESHOLD,
+		monitorInterval: DEFAULT_QUEUE_MONITOR_INTERVAL,
+		maxOutputBytes:  DEFAULT_MAX_JOB_OUTPUT_BYTES,
+		envPassthrough:  defaultJobEnvPassthrough,
 		ctx:             ctx,
 		cancel:          cancel,
+		outputRules:     NewOutputRuleDispatcher(),
+		notifier:        NewJobCompletionNotifier(),
+		whitelist:       newDefaultCommandWhitelist(),
 	}
 }
 
+// SetCommandWhitelist replaces the default command whitelist. Call before Start.
+func (je *JobExecutor) SetCommandWhitelist(whitelist *CommandWhitelist) {
+	je.whitelist = whitelist
+}
+
+// CommandWhitelist returns the command whitelist used by the safety check
+func (je *JobExecutor) CommandWhitelist() *CommandWhitelist {
+	return je.whitelist
+}
+
+// SetMonitoring configures the stale running job threshold and the queue monitor polling interval.
+// Zero values keep the current setting. Call before Start.
+func (je *JobExecutor) SetMonitoring(staleThreshold, monitorInterval time.Duration) {
+	if staleThreshold > 0 {
+		je.staleThreshold = staleThreshold
+	}
+	if monitorInterval > 0 {
+		je.monitorInterval = monitorInterval
+	}
+}
+
+// SetMaxOutputBytes caps how many bytes of each job output stream are kept.
+// Only the most recent output is kept; 0 or less keeps everything.
+func (je *JobExecutor) SetMaxOutputBytes(maxBytes int) {
+	je.maxOutputBytes = maxBytes
+}
+
+// SetEnvPassthrough replaces the names of the backend environment variables passed to jobs.
+// CLAUDECODE and CLAUDE_CODE_ENTRYPOINT are always set. Call before Start.
+func (je *JobExecutor) SetEnvPassthrough(names []string) {
+	je.envPassthrough = names
+}
+
+// SetAllowedRoots restricts jobs to execution directories under one of the given roots.
+// No roots allows any existing directory. Call before Start.
+func (je *JobExecutor) SetAllowedRoots(roots []string) {
+	je.allowedRoots = roots
+}
+
+// SetMaxConcurrentPerProject caps how many jobs of the same project run at once.
+// Further jobs of that project wait until one finishes; 0 or less is unlimited. Call before Start.
+func (je *JobExecutor) SetMaxConcurrentPerProject(max int) {
+	je.maxPerProject = max
+}
+
+// SetResourceLimits sets the default memory and CPU time limits applied to every job.
+// Limits set on a job override the matching default.
+func (je *JobExecutor) SetResourceLimits(defaults models.ResourceLimits) {
+	je.resourceLimits = defaults
+}
+
+// effectiveResourceLimits merges a job's own limits over the executor defaults
+func (je *JobExecutor) effectiveResourceLimits(job *models.Job) models.ResourceLimits {
+	limits := je.resourceLimits
+	if job.ResourceLimits == nil || *job.ResourceLimits == "" {
+		return limits
+	}
+	
+	var jobLimits models.ResourceLimits
+	if err := json.Unmarshal([]byte(*job.ResourceLimits), &jobLimits); err != nil {
+		slog.Warn("Ignoring invalid resource limits", "job_id", job.ID, "error", err)
+		return limits
+	}
+	if jobLimits.MemoryMB > 0 {
+		limits.MemoryMB = jobLimits.MemoryMB
+	}
+	if jobLimits.CPUSeconds > 0 {
+		limits.CPUSeconds = jobLimits.CPUSeconds
+	}
+	return limits
+}
+
+// OutputRules returns the dispatcher used to evaluate per-job output rules
+func (je *JobExecutor) OutputRules() *OutputRuleDispatcher {
+	return je.outputRules
+}
+
 // Start starts the job executor workers
 func (je *JobExecutor) Start() {
 	log.Printf("Starting job executor with %d workers", je.workerCount)
@@ -77,22 +187,151 @@ func (je *JobExecutor) Stop() {
 	// Wait for all workers to finish
 	je.wg.Wait()
 	
+	// Let output rule actions and completion webhooks of the last jobs go out
+	je.outputRules.Wait()
+	je.notifier.Wait()
+	
 	log.Println("Job executor stopped")
 }
 
+// JOB_DRAIN_POLL_INTERVAL is how often Drain checks whether running jobs have finished
+const JOB_DRAIN_POLL_INTERVAL = 100 * time.Millisecond
+
+// JOB_DRAIN_KILL_GRACE bounds how long Drain waits for force-cancelled jobs to record their status
+const JOB_DRAIN_KILL_GRACE = 10 * time.Second
+
+// JobInterruptedByShutdownMessage is logged on jobs force-cancelled by Drain
+const JobInterruptedByShutdownMessage = "Job interrupted by shutdown"
+
+// Drain stops accepting new jobs and waits up to timeout for running jobs to finish.
+// Jobs still running at the deadline are cancelled and marked failed. Call Stop afterwards.
+func (je *JobExecutor) Drain(timeout time.Duration) {
+	je.pauseMutex.Lock()
+	je.draining = true
+	je.pauseMutex.Unlock()
+	
+	log.Printf("Draining job executor (timeout %v)...", timeout)
+	
+	if je.waitForRunningJobs(timeout) {
+		log.Println("Job executor drained")
+		return
+	}
+	
+	// Force-cancel stragglers
+	je.cancelMutex.Lock()
+	for jobID, cancelFunc := range je.cancelMap {
+		slog.Warn("Interrupting job: drain timeout exceeded", "job_id", jobID)
+		je.interrupted[jobID] = true
+		cancelFunc()
+	}
+	je.cancelMutex.Unlock()
+	
+	if !je.waitForRunningJobs(JOB_DRAIN_KILL_GRACE) {
+		log.Println("Some interrupted jobs did not finish recording their status")
+	}
+}
+
+// waitForRunningJobs polls until no jobs are running, returning false if timeout elapses first
+func (je *JobExecutor) waitForRunningJobs(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		je.cancelMutex.RLock()
+		running := len(je.cancelMap)
+		je.cancelMutex.RUnlock()
+		
+		if running == 0 {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		time.Sleep(JOB_DRAIN_POLL_INTERVAL)
+	}
+}
+
+// IsDraining reports whether Drain has been called
+func (je *JobExecutor) IsDraining() bool {
+	je.pauseMutex.RLock()
+	defer je.pauseMutex.RUnlock()
+	return je.draining
+}
+
 // QueueJob adds a job to the execution queue
 func (je *JobExecutor) QueueJob(jobID string) error {
+	return je.QueueJobContext(context.Background(), jobID)
+}
+
+// QueueJobContext adds a job to the execution queue. The request ID carried by ctx,
+// if any, is logged with the job's execution.
+func (je *JobExecutor) QueueJobContext(ctx context.Context, jobID string) error {
+	if je.IsDraining() {
+		return fmt.Errorf("job executor is shutting down")
+	}
+	
+	if je.IsPaused() {
+		return fmt.Errorf("job executor is paused")
+	}
+	
+	if requestID := middleware.RequestIDFromContext(ctx); requestID != "" {
+		je.cancelMutex.Lock()
+		je.jobRequests[jobID] = requestID
+		je.cancelMutex.Unlock()
+	}
+	
 	select {
 	case je.jobQueue <- jobID:
-		log.Printf("Job %s queued for execution", jobID)
+		middleware.ContextLogger(ctx).Info("Job queued for execution", "job_id", jobID)
 		return nil
 	case <-je.ctx.Done():
+		je.forgetJobRequest(jobID)
 		return fmt.Errorf("job executor is shutting down")
 	default:
+		je.forgetJobRequest(jobID)
 		return fmt.Errorf("job queue is full")
 	}
 }
 
+// jobRequestContext returns a context carrying the ID of the request that queued the job, if any
+func (je *JobExecutor) jobRequestContext(jobID string) context.Context {
+	je.cancelMutex.RLock()
+	requestID, ok := je.jobRequests[jobID]
+	je.cancelMutex.RUnlock()
+	if !ok {
+		return context.Background()
+	}
+	return middleware.WithRequestID(context.Background(), requestID)
+}
+
+// forgetJobRequest drops the request ID recorded for the job by QueueJobContext
+func (je *JobExecutor) forgetJobRequest(jobID string) {
+	je.cancelMutex.Lock()
+	delete(je.jobRequests, jobID)
+	je.cancelMutex.Unlock()
+}
+
+// Pause stops new jobs from being queued; running jobs continue and pending jobs stay pending
+func (je *JobExecutor) Pause() {
+	je.pauseMutex.Lock()
+	je.paused = true
+	je.pauseMutex.Unlock()
+	log.Println("Job executor paused")
+}
+
+// Resume allows jobs to be queued again
+func (je *JobExecutor) Resume() {
+	je.pauseMutex.Lock()
+	je.paused = false
+	je.pauseMutex.Unlock()
+	log.Println("Job executor resumed")
+}
+
+// IsPaused reports whether the executor is paused
+func (je *JobExecutor) IsPaused() bool {
+	je.pauseMutex.RLock()
+	defer je.pauseMutex.RUnlock()
+	return je.paused
+}
+
 // CancelJob cancels a running job
 func (je *JobExecutor) CancelJob(jobID string) error {
 	je.cancelMutex.Lock()
@@ -110,6 +349,27 @@ func (je *JobExecutor) CancelJob(jobID string) error {
 	return fmt.Errorf("job %s is not running", jobID)
 }
 
+// CancelProjectJobs cancels all running jobs of a project and returns how many were cancelled
+func (je *JobExecutor) CancelProjectJobs(projectID string) (int, error) {
+	status := models.JobStatusRunning
+	jobs, err := je.jobService.GetJobs(models.JobFilters{ProjectID: &projectID, Status: &status})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get running jobs: %w", err)
+	}
+	
+	cancelled := 0
+	for _, job := range jobs {
+		if err := je.CancelJob(job.ID); err != nil {
+			// The job may have finished since it was listed
+			slog.Error("Failed to cancel job", "job_id", job.ID, "error", err)
+			continue
+		}
+		cancelled++
+	}
+	
+	return cancelled, nil
+}
+
 // worker is the main worker goroutine
 func (je *JobExecutor) worker(workerID int) {
 	defer je.wg.Done()
@@ -124,7 +384,13 @@ func (je *JobExecutor) worker(workerID int) {
 				return
 			}
 			
-			log.Printf("Worker %d processing job %s", workerID, jobID)
+			// Queued jobs stay pending while draining and are picked up after restart
+			if je.IsDraining() {
+				slog.Info("Worker skipping job: executor is draining", "worker_id", workerID, "job_id", jobID)
+				continue
+			}
+			
+			slog.Info("Worker processing job", "worker_id", workerID, "job_id", jobID)
 			je.executeJob(jobID)
 			
 		case <-je.ctx.Done():
@@ -138,7 +404,7 @@ func (je *JobExecutor) worker(workerID int) {
 func (je *JobExecutor) queueMonitor() {
 	defer je.wg.Done()
 	
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(je.monitorInterval)
 	defer ticker.Stop()
 	
 	for {
@@ -156,10 +422,15 @@ func (je *JobExecutor) checkPendingJobs() {
 	// First, check for stale running jobs
 	je.checkStaleRunningJobs()
 	
+	// Pending jobs are picked up again after Resume
+	if je.IsPaused() || je.IsDraining() {
+		return
+	}
+	
 	// Then check for pending immediate jobs only
 	pendingJobs, err := je.jobService.GetPendingImmediateJobs(10)
 	if err != nil {
-		log.Printf("Error getting pending immediate jobs: %v", err)
+		slog.Error("Error getting pending immediate jobs", "error", err)
 		return
 	}
 	
@@ -176,15 +447,44 @@ func (je *JobExecutor) checkPendingJobs() {
 		// Queue the job
 		select {
 		case je.jobQueue <- job.ID:
-			log.Printf("Queued pending immediate job %s", job.ID)
+			slog.Info("Queued pending immediate job", "job_id", job.ID)
 		default:
-			log.Printf("Job queue full, skipping job %s", job.ID)
+			slog.Warn("Job queue full, skipping job", "job_id", job.ID)
 		}
 	}
 }
 
+const (
+	// JOB_EXECUTION_TIMEOUT is how long a job may run before it is killed
+	JOB_EXECUTION_TIMEOUT = 30 * time.Minute
+	
+	// DEFAULT_STALE_JOB_THRESHOLD is how long an untracked running job may run before it is treated as stale
+	DEFAULT_STALE_JOB_THRESHOLD = 30 * time.Minute
+	
+	// DEFAULT_QUEUE_MONITOR_INTERVAL is how often pending and stale jobs are checked
+	DEFAULT_QUEUE_MONITOR_INTERVAL = 10 * time.Second
+)
+
+// staleTimeout returns how long an untracked job may run before it is stale: the larger
+// of the execution timeout and the configured threshold, so jobs are never reaped while
+// they could still legitimately be running
+func (je *JobExecutor) staleTimeout() time.Duration {
+	if JOB_EXECUTION_TIMEOUT > je.staleThreshold {
+		return JOB_EXECUTION_TIMEOUT
+	}
+	return je.staleThreshold
+}
+
 // checkStaleRunningJobs checks for jobs marked as running but not tracked by executor
 func (je *JobExecutor) checkStaleRunningJobs() {
+	if _, err := je.CleanupStaleRunningJobs(); err != nil {
+		slog.Error("Error getting running jobs for stale check", "error", err)
+	}
+}
+
+// FindStaleRunningJobs returns jobs marked as running that are not tracked by the executor
+// and whose process is gone or which have exceeded their stale timeout
+func (je *JobExecutor) FindStaleRunningJobs() ([]models.StaleJob, error) {
 	// Get running jobs from database
 	status := models.JobStatusRunning
 	filters := models.JobFilters{
@@ -194,50 +494,80 @@ func (je *JobExecutor) checkStaleRunningJobs() {
 	
 	runningJobs, err := je.jobService.GetJobs(filters)
 	if err != nil {
-		log.Printf("Error getting running jobs for stale check: %v", err)
-		return
+		return nil, fmt.Errorf("failed to get running jobs: %w", err)
 	}
 	
+	staleJobs := []models.StaleJob{}
 	for _, job := range runningJobs {
 		// Check if job is tracked by executor
 		je.cancelMutex.RLock()
 		_, isTracked := je.cancelMap[job.ID]
 		je.cancelMutex.RUnlock()
 		
-		if !isTracked {
-			// Job is marked as running but not tracked by executor
-			log.Printf("Found stale running job %s, checking process status", job.ID)
+		if isTracked {
+			continue
+		}
+		
+		var runningSeconds int64
+		if job.StartedAt != nil {
+			runningSeconds = int64(time.Since(*job.StartedAt).Seconds())
+		}
+		
+		// Check if process actually exists
+		if job.PID != nil && !je.isProcessRunning(*job.PID) {
+			staleJobs = append(staleJobs, models.StaleJob{
+				Job:            job,
+				Reason:         models.StaleJobReasonProcessGone,
+				RunningSeconds: runningSeconds,
+			})
+			continue
+		}
+		
+		// Check if job has been running too long
+		if job.StartedAt != nil && time.Since(*job.StartedAt) > je.staleTimeout() {
+			staleJobs = append(staleJobs, models.StaleJob{
+				Job:            job,
+				Reason:         models.StaleJobReasonTimeout,
+				RunningSeconds: runningSeconds,
+			})
+		}
+	}
+	
+	return staleJobs, nil
+}
+
+// CleanupStaleRunningJobs marks every stale running job as failed and returns the jobs it handled
+func (je *JobExecutor) CleanupStaleRunningJobs() ([]models.StaleJob, error) {
+	staleJobs, err := je.FindStaleRunningJobs()
+	if err != nil {
+		return nil, err
+	}
+	
+	for _, stale := range staleJobs {
+		job := stale.Job
+		switch stale.Reason {
+		case models.StaleJobReasonProcessGone:
+			slog.Warn("Job process is not running, marking as failed", "job_id", job.ID, "pid", *job.PID)
+			je.jobService.UpdateJobStatus(job.ID, models.JobStatusFailed, nil)
+			errorMsg := "Process not found (likely crashed or killed)"
+			je.jobService.UpdateJobLogs(job.ID, nil, &errorMsg, nil)
+		case models.StaleJobReasonTimeout:
+			runningTime := time.Duration(stale.RunningSeconds) * time.Second
+			slog.Warn("Job running too long, marking as failed", "job_id", job.ID, "running_time", runningTime.String())
 			
+			// Try to kill the process if PID exists
 			if job.PID != nil {
-				// Check if process actually exists
-				if !je.isProcessRunning(*job.PID) {
-					log.Printf("Process %d for job %s is not running, marking as failed", *job.PID, job.ID)
-					je.jobService.UpdateJobStatus(job.ID, models.JobStatusFailed, nil)
-					errorMsg := "Process not found (likely crashed or killed)"
-					je.jobService.UpdateJobLogs(job.ID, nil, &errorMsg, nil)
-					continue
-				}
+				je.killProcess(*job.PID)
 			}
 			
-			// Check if job has been running too long (30 minutes timeout)
-			if job.StartedAt != nil {
-				runningTime := time.Since(*job.StartedAt)
-				if runningTime > 30*time.Minute {
-					log.Printf("Job %s running too long (%v), marking as failed", job.ID, runningTime)
-					
-					// Try to kill the process if PID exists
-					if job.PID != nil {
-						je.killProcess(*job.PID)
-					}
-					
-					je.jobService.UpdateJobStatus(job.ID, models.JobStatusFailed, nil)
-					errorMsg := fmt.Sprintf("Job timeout after %v", runningTime)
-					exitCode := -1
-					je.jobService.UpdateJobLogs(job.ID, nil, &errorMsg, &exitCode)
-				}
-			}
+			je.jobService.UpdateJobStatus(job.ID, models.JobStatusFailed, nil)
+			errorMsg := fmt.Sprintf("Job timeout after %v", runningTime)
+			exitCode := -1
+			je.jobService.UpdateJobLogs(job.ID, nil, &errorMsg, &exitCode)
 		}
 	}
+	
+	return staleJobs, nil
 }
 
 // isProcessRunning checks if a process with given PID is still running
@@ -252,62 +582,86 @@ func (je *JobExecutor) isProcessRunning(pid int) bool {
 	return err == nil
 }
 
-// killProcess attempts to gracefully kill a process, then force kill if necessary
+// killProcess attempts to gracefully kill a job's process group, then force kill if necessary.
+// The whole group is signalled so subprocesses started by claude don't outlive the job.
 func (je *JobExecutor) killProcess(pid int) {
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		log.Printf("Process %d not found", pid)
-		return
-	}
-	
 	// Try graceful shutdown first
-	log.Printf("Sending SIGTERM to process %d", pid)
-	err = process.Signal(syscall.SIGTERM)
+	log.Printf("Sending SIGTERM to process group %d", pid)
+	err := signalProcessGroup(pid, syscall.SIGTERM)
 	if err != nil {
-		log.Printf("Failed to send SIGTERM to process %d: %v", pid, err)
+		log.Printf("Failed to send SIGTERM to process group %d: %v", pid, err)
 		return
 	}
 	
 	// Wait for graceful shutdown
 	time.Sleep(5 * time.Second)
 	
-	// Check if still running
-	if je.isProcessRunning(pid) {
-		log.Printf("Process %d still running, sending SIGKILL", pid)
-		process.Signal(syscall.SIGKILL)
+	// Check if any process of the group is still running
+	if signalProcessGroup(pid, syscall.Signal(0)) == nil {
+		log.Printf("Process group %d still running, sending SIGKILL", pid)
+		signalProcessGroup(pid, syscall.SIGKILL)
 	}
 }
 
 // executeJob executes a single job
 func (je *JobExecutor) executeJob(jobID string) {
+	// Log lines and webhooks carry the ID of the request that queued the job, if any
+	ctx := je.jobRequestContext(jobID)
+	logger := middleware.ContextLogger(ctx).With("job_id", jobID)
+	
 	// Get job details
 	job, err := je.jobService.GetJobByID(jobID)
 	if err != nil {
-		log.Printf("Error getting job %s: %v", jobID, err)
+		logger.Error("Error getting job", "error", err)
+		je.forgetJobRequest(jobID)
 		return
 	}
 	
 	if job == nil {
-		log.Printf("Job %s not found", jobID)
+		logger.Warn("Job not found")
+		je.forgetJobRequest(jobID)
 		return
 	}
 	
 	if job.Status != models.JobStatusPending {
-		log.Printf("Job %s is not pending (status: %s)", jobID, job.Status)
+		logger.Info("Job is not pending", "status", job.Status)
+		je.forgetJobRequest(jobID)
+		return
+	}
+	
+	// Jobs of a project at its concurrency cap wait for one of its jobs to finish.
+	// A held job keeps its request ID for when it is queued again.
+	if !je.acquireProjectSlot(job.ProjectID, jobID) {
+		logger.Info("Holding job: project is at its concurrency limit", "project_id", job.ProjectID, "limit", je.maxPerProject)
+		return
+	}
+	defer je.releaseProjectSlot(job.ProjectID)
+	defer je.forgetJobRequest(jobID)
+	
+	startTime := time.Now()
+	
+	// Make sure the job runs in an existing directory it is allowed to use
+	if err := je.validateExecutionDirectory(job.ExecutionDirectory); err != nil {
+		logger.Warn("Invalid execution directory for job", "dir", job.ExecutionDirectory, "error", err)
+		je.jobService.UpdateJobStatus(jobID, models.JobStatusFailed, nil)
+		errMsg := err.Error()
+		je.jobService.UpdateJobLogs(jobID, nil, &errMsg, nil)
+		je.notifier.Notify(ctx, job, models.JobStatusFailed, nil, time.Since(startTime))
 		return
 	}
 	
 	// Validate command with job's execution directory
-	if err := je.validateCommand(job.Command, job.ExecutionDirectory); err != nil {
-		log.Printf("Invalid command for job %s: %v", jobID, err)
+	if err := je.validateCommand(job.Command, job.ExecutionDirectory, job.ProjectID); err != nil {
+		logger.Warn("Invalid command for job", "error", err)
 		je.jobService.UpdateJobStatus(jobID, models.JobStatusFailed, nil)
 		errMsg := err.Error()
 		je.jobService.UpdateJobLogs(jobID, nil, &errMsg, nil)
+		je.notifier.Notify(ctx, job, models.JobStatusFailed, nil, time.Since(startTime))
 		return
 	}
 	
 	// Create job context with timeout
-	jobCtx, cancel := context.WithTimeout(je.ctx, 30*time.Minute)
+	jobCtx, cancel := context.WithTimeout(je.ctx, JOB_EXECUTION_TIMEOUT)
 	defer cancel()
 	
 	// Store cancel function
@@ -319,51 +673,39 @@ func (je *JobExecutor) executeJob(jobID string) {
 	defer func() {
 		je.cancelMutex.Lock()
 		delete(je.cancelMap, jobID)
+		delete(je.interrupted, jobID)
 		je.cancelMutex.Unlock()
 	}()
 	
-	// Build Claude Code command
-	cmdArgs := je.buildCommand(job.Command, job.YoloMode)
+	// Build Claude Code command, applying resource limits where the platform supports them
+	limits := je.effectiveResourceLimits(job)
+	cmdArgs := applyResourceLimits(je.buildCommand(job.Command, job.YoloMode), limits)
 	
-	log.Printf("Executing job %s: %v in directory %s", jobID, cmdArgs, job.ExecutionDirectory)
+	logger.Info("Executing job", "args", cmdArgs, "dir", job.ExecutionDirectory)
 	
 	// Prepare command
 	cmd := exec.CommandContext(jobCtx, cmdArgs[0], cmdArgs[1:]...)
 	cmd.Dir = job.ExecutionDirectory
 	
-	// Inherit environment variables from backend process
-	cmd.Env = os.Environ()
-	
-	// Ensure Claude Code environment variables are set
-	// These are required for claude command to work properly
-	claudeCodeSet := false
-	entrypointSet := false
-	for i, env := range cmd.Env {
-		if strings.HasPrefix(env, "CLAUDECODE=") {
-			cmd.Env[i] = "CLAUDECODE=1"
-			claudeCodeSet = true
-		} else if strings.HasPrefix(env, "CLAUDE_CODE_ENTRYPOINT=") {
-			cmd.Env[i] = "CLAUDE_CODE_ENTRYPOINT=cli"
-			entrypointSet = true
-		}
-	}
-	if !claudeCodeSet {
-		cmd.Env = append(cmd.Env, "CLAUDECODE=1")
-	}
-	if !entrypointSet {
-		cmd.Env = append(cmd.Env, "CLAUDE_CODE_ENTRYPOINT=cli")
-	}
+	// Only allowlisted backend variables are inherited so its secrets don't leak into jobs
+	cmd.Env = je.buildJobEnv(job)
 	
 	// Set process attributes to prevent TTY conflicts
 	configurePlatformSpecificAttrs(cmd)
 	
+	// On timeout or cancellation kill the job's whole process group, not just claude
+	cmd.Cancel = func() error {
+		return signalProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
+	}
+	
 	// Set stdin to /dev/null to prevent hanging on input
 	devNull, err := os.OpenFile(os.DevNull, os.O_RDONLY, 0)
 	if err != nil {
-		log.Printf("Error opening /dev/null for job %s: %v", jobID, err)
+		logger.Error("Error opening /dev/null for job", "error", err)
 		je.jobService.UpdateJobStatus(jobID, models.JobStatusFailed, nil)
 		errorMsg := fmt.Sprintf("Failed to open /dev/null: %v", err)
 		je.jobService.UpdateJobLogs(jobID, nil, &errorMsg, nil)
+		je.notifier.Notify(ctx, job, models.JobStatusFailed, nil, time.Since(startTime))
 		return
 	}
 	defer devNull.Close()
@@ -372,35 +714,38 @@ func (je *JobExecutor) executeJob(jobID string) {
 	// Capture output pipes BEFORE starting command
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		log.Printf("Error creating stdout pipe for job %s: %v", jobID, err)
+		logger.Error("Error creating stdout pipe for job", "error", err)
 		je.jobService.UpdateJobStatus(jobID, models.JobStatusFailed, nil)
 		errorMsg := fmt.Sprintf("Failed to create stdout pipe: %v", err)
 		je.jobService.UpdateJobLogs(jobID, nil, &errorMsg, nil)
+		je.notifier.Notify(ctx, job, models.JobStatusFailed, nil, time.Since(startTime))
 		return
 	}
 	
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		log.Printf("Error creating stderr pipe for job %s: %v", jobID, err)
+		logger.Error("Error creating stderr pipe for job", "error", err)
 		je.jobService.UpdateJobStatus(jobID, models.JobStatusFailed, nil)
 		errorMsg := fmt.Sprintf("Failed to create stderr pipe: %v", err)
 		je.jobService.UpdateJobLogs(jobID, nil, &errorMsg, nil)
+		je.notifier.Notify(ctx, job, models.JobStatusFailed, nil, time.Since(startTime))
 		return
 	}
 	
 	// Update job status to running
 	err = je.jobService.UpdateJobStatus(jobID, models.JobStatusRunning, nil)
 	if err != nil {
-		log.Printf("Error updating job %s status to running: %v", jobID, err)
+		logger.Error("Error updating job status to running", "error", err)
 		return
 	}
 	
 	// Start the command
 	if err := cmd.Start(); err != nil {
-		log.Printf("Error starting command for job %s: %v", jobID, err)
+		logger.Error("Error starting command for job", "error", err)
 		errorMsg := fmt.Sprintf("Failed to start command: %v", err)
 		je.jobService.UpdateJobStatus(jobID, models.JobStatusFailed, nil)
 		je.jobService.UpdateJobLogs(jobID, nil, &errorMsg, nil)
+		je.notifier.Notify(ctx, job, models.JobStatusFailed, nil, time.Since(startTime))
 		return
 	}
 	
@@ -408,11 +753,12 @@ func (je *JobExecutor) executeJob(jobID string) {
 	pid := cmd.Process.Pid
 	err = je.jobService.UpdateJobStatus(jobID, models.JobStatusRunning, &pid)
 	if err != nil {
-		log.Printf("Error updating job %s PID: %v", jobID, err)
+		logger.Error("Error updating job PID", "error", err)
 	}
 	
-	// Stream output
-	var outputBuffer, errorBuffer strings.Builder
+	// Stream output, keeping only the tail of each stream
+	outputBuffer := newTailBuffer(je.maxOutputBytes)
+	errorBuffer := newTailBuffer(je.maxOutputBytes)
 	
 	// Start output goroutines
 	var outputWg sync.WaitGroup
@@ -424,7 +770,7 @@ func (je *JobExecutor) executeJob(jobID string) {
 		for scanner.Scan() {
 			line := scanner.Text()
 			outputBuffer.WriteString(line + "\n")
-			log.Printf("Job %s stdout: %s", jobID, line)
+			logger.Info("Job output", "stream", "stdout", "line", line)
 		}
 	}()
 	
@@ -434,13 +780,24 @@ func (je *JobExecutor) executeJob(jobID string) {
 		for scanner.Scan() {
 			line := scanner.Text()
 			errorBuffer.WriteString(line + "\n")
-			log.Printf("Job %s stderr: %s", jobID, line)
+			logger.Info("Job output", "stream", "stderr", "line", line)
 		}
 	}()
 	
-	// Wait for command to complete with timeout handling
+	// Wait for command to complete with timeout handling.
+	// Wait closes the pipes, so it is held back until all output has been read; once the job
+	// is cancelled it is not, as children of the killed process may keep the pipes open.
 	done := make(chan error, 1)
 	go func() {
+		outputRead := make(chan struct{})
+		go func() {
+			outputWg.Wait()
+			close(outputRead)
+		}()
+		select {
+		case <-outputRead:
+		case <-jobCtx.Done():
+		}
 		done <- cmd.Wait()
 	}()
 	
@@ -449,9 +806,9 @@ func (je *JobExecutor) executeJob(jobID string) {
 		// Command completed normally
 	case <-jobCtx.Done():
 		// Context cancelled (timeout or manual cancellation)
-		log.Printf("Job %s timed out or was cancelled, killing process", jobID)
+		logger.Warn("Job timed out or was cancelled, killing process")
 		if cmd.Process != nil {
-			cmd.Process.Kill()
+			signalProcessGroup(cmd.Process.Pid, syscall.SIGKILL)
 		}
 		err = jobCtx.Err()
 	}
@@ -460,6 +817,12 @@ func (je *JobExecutor) executeJob(jobID string) {
 	outputWg.Wait()
 	
 	// Get output and error logs
+	for stream, buffer := range map[string]*tailBuffer{"stdout": outputBuffer, "stderr": errorBuffer} {
+		if buffer.Truncated() {
+			logger.Warn("Job output truncated", "stream", stream,
+				"dropped_bytes", buffer.DroppedBytes(), "max_bytes", je.maxOutputBytes)
+		}
+	}
 	outputLog := outputBuffer.String()
 	errorLog := errorBuffer.String()
 	
@@ -471,6 +834,10 @@ func (je *JobExecutor) executeJob(jobID string) {
 		if exitError, ok := err.(*exec.ExitError); ok {
 			exitCode = exitError.ExitCode()
 			status = models.JobStatusFailed
+			if reason := resourceLimitExceeded(exitError.ProcessState, errorLog, limits); reason != "" {
+				logger.Warn("Job exceeded resource limit", "reason", reason)
+				errorLog += reason
+			}
 		} else {
 			exitCode = -1
 			status = models.JobStatusFailed
@@ -485,28 +852,97 @@ func (je *JobExecutor) executeJob(jobID string) {
 	
 	// Check if job was cancelled
 	if jobCtx.Err() == context.Canceled {
-		status = models.JobStatusCancelled
-		if errorLog == "" {
-			errorLog = "Job was cancelled"
+		je.cancelMutex.RLock()
+		interrupted := je.interrupted[jobID]
+		je.cancelMutex.RUnlock()
+		
+		if interrupted {
+			// Killed by the drain deadline rather than by the user
+			status = models.JobStatusFailed
+			if errorLog != "" && !strings.HasSuffix(errorLog, "\n") {
+				errorLog += "\n"
+			}
+			errorLog += JobInterruptedByShutdownMessage
+		} else {
+			status = models.JobStatusCancelled
+			if errorLog == "" {
+				errorLog = "Job was cancelled"
+			}
 		}
 	}
 	
-	log.Printf("Job %s completed with status %s, exit code %d", jobID, status, exitCode)
+	logger.Info("Job completed", "status", status, "exit_code", exitCode)
 	
 	// Update job status and logs
 	err = je.jobService.UpdateJobStatus(jobID, status, nil)
 	if err != nil {
-		log.Printf("Error updating job %s final status: %v", jobID, err)
+		logger.Error("Error updating job final status", "error", err)
 	}
 	
 	err = je.jobService.UpdateJobLogs(jobID, &outputLog, &errorLog, &exitCode)
 	if err != nil {
-		log.Printf("Error updating job %s logs: %v", jobID, err)
+		logger.Error("Error updating job logs", "error", err)
+	}
+	
+	// Evaluate output rules against captured output
+	je.outputRules.Dispatch(ctx, job, outputLog+errorLog, status, exitCode)
+	
+	// Notify the job's completion webhook
+	je.notifier.Notify(ctx, job, status, &exitCode, time.Since(startTime))
+}
+
+// validateExecutionDirectory checks that a job's directory exists and is under an allowed root.
+// Symlinks are resolved first so a link inside a root cannot point outside it.
+func (je *JobExecutor) validateExecutionDirectory(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("execution directory is not set")
+	}
+	
+	info, err := os.Stat(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("execution directory does not exist: %s", dir)
+		}
+		return fmt.Errorf("failed to access execution directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("execution directory is not a directory: %s", dir)
+	}
+	
+	if len(je.allowedRoots) == 0 {
+		return nil
+	}
+	
+	resolvedDir, err := resolvePath(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve execution directory %s: %w", dir, err)
+	}
+	for _, root := range je.allowedRoots {
+		resolvedRoot, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(resolvedRoot, resolvedDir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("execution directory %s is outside the allowed roots", dir)
+}
+
+// resolvePath returns the absolute path with symlinks resolved
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
 	}
+	return filepath.EvalSymlinks(absPath)
 }
 
-// validateCommand validates that the command is safe to execute
-func (je *JobExecutor) validateCommand(command string, executionDir string) error {
+// validateCommand validates that the command is safe to execute.
+// Commands whitelisted for the job's project skip the safety check like global ones,
+// but the dangerous pattern checks always apply.
+func (je *JobExecutor) validateCommand(command string, executionDir string, projectID string) error {
 	// Basic command validation
 	if command == "" {
 		return fmt.Errorf("command cannot be empty")
@@ -514,6 +950,17 @@ func (je *JobExecutor) validateCommand(command string, executionDir string) erro
 	
 	// Create a safety checker for the specific execution directory
 	jobSafetyChecker := NewCommandSafetyChecker(executionDir)
+	jobSafetyChecker.whitelist = je.whitelist
+	jobSafetyChecker.projectID = projectID
+	
+	// Load the project's overrides so changes apply to the next job
+	if jobSafetyChecker.IsEnabled() && projectID != "" {
+		commands, err := NewProjectService(je.jobService.db).GetAllowedCommands(projectID)
+		if err != nil {
+			return fmt.Errorf("failed to load project command whitelist: %w", err)
+		}
+		je.whitelist.SetProjectCommands(projectID, commands)
+	}
 	
 	// Use Claude Code safety checker with job's directory context
 	if err := jobSafetyChecker.CheckCommandSafety(command); err != nil {
@@ -563,12 +1010,60 @@ func (je *JobExecutor) sanitizeCommand(command string) string {
 	return strings.TrimSpace(command)
 }
 
-// isClaudeCodeAvailable checks if Claude Code CLI is available
-func (je *JobExecutor) isClaudeCodeAvailable() bool {
+// IsClaudeCodeAvailable checks if Claude Code CLI is available
+func IsClaudeCodeAvailable() bool {
 	_, err := exec.LookPath("claude")
 	return err == nil
 }
 
+// acquireProjectSlot counts a job as running for its project. If the project is at its
+// concurrency cap the job is held instead and false is returned.
+func (je *JobExecutor) acquireProjectSlot(projectID, jobID string) bool {
+	je.cancelMutex.Lock()
+	defer je.cancelMutex.Unlock()
+	
+	if je.maxPerProject > 0 && je.projectRunning[projectID] >= je.maxPerProject {
+		for _, held := range je.heldJobs[projectID] {
+			if held == jobID {
+				return false
+			}
+		}
+		je.heldJobs[projectID] = append(je.heldJobs[projectID], jobID)
+		return false
+	}
+	
+	je.projectRunning[projectID]++
+	return true
+}
+
+// releaseProjectSlot frees a job's project slot and queues the project's next held job.
+// A held job that cannot be queued stays pending for the queue monitor or scheduler.
+func (je *JobExecutor) releaseProjectSlot(projectID string) {
+	je.cancelMutex.Lock()
+	je.projectRunning[projectID]--
+	if je.projectRunning[projectID] <= 0 {
+		delete(je.projectRunning, projectID)
+	}
+	
+	var next string
+	if held := je.heldJobs[projectID]; len(held) > 0 {
+		next = held[0]
+		if len(held) == 1 {
+			delete(je.heldJobs, projectID)
+		} else {
+			je.heldJobs[projectID] = held[1:]
+		}
+	}
+	je.cancelMutex.Unlock()
+	
+	if next == "" || je.ctx.Err() != nil {
+		return
+	}
+	if err := je.QueueJob(next); err != nil {
+		slog.Warn("Failed to queue held job", "job_id", next, "project_id", projectID, "error", err)
+	}
+}
+
 // GetRunningJobs returns a list of currently running job IDs
 func (je *JobExecutor) GetRunningJobs() []string {
 	je.cancelMutex.RLock()
@@ -582,8 +1077,13 @@ func (je *JobExecutor) GetRunningJobs() []string {
 	return runningJobs
 }
 
+// QueueCapacity returns how many more jobs can be queued without blocking
+func (je *JobExecutor) QueueCapacity() int {
+	return cap(je.jobQueue) - len(je.jobQueue)
+}
+
 // GetQueueStatus returns the current queue status
-func (je *JobExecutor) GetQueueStatus() map[string]interface{} {
+func (je *JobExecutor) GetQueueStatus() *models.QueueStatus {
 	je.cancelMutex.RLock()
 	runningCount := len(je.cancelMap)
 	je.cancelMutex.RUnlock()
@@ -591,11 +1091,66 @@ func (je *JobExecutor) GetQueueStatus() map[string]interface{} {
 	// Check safety check configuration
 	safetyCheckEnabled := os.Getenv("CCDASH_DISABLE_SAFETY_CHECK") != "true"
 	
-	return map[string]interface{}{
-		"running_jobs":       runningCount,
-		"queued_jobs":        len(je.jobQueue),
-		"worker_count":       je.workerCount,
-		"claude_available":   je.isClaudeCodeAvailable(),
-		"safety_check_enabled": safetyCheckEnabled,
+	status := &models.QueueStatus{
+		RunningJobs:        runningCount,
+		QueuedJobs:         len(je.jobQueue),
+		WorkerCount:        je.workerCount,
+		Paused:             je.IsPaused(),
+		ClaudeAvailable:    IsClaudeCodeAvailable(),
+		SafetyCheckEnabled: safetyCheckEnabled,
+		Projects:           []models.ProjectQueueStatus{},
+	}
+	
+	if err := je.populateProjectQueueStatus(status); err != nil {
+		log.Printf("Error getting per-project queue status: %v", err)
+	}
+	
+	return status
+}
+
+// populateProjectQueueStatus fills in running/pending counts per project from the jobs table
+func (je *JobExecutor) populateProjectQueueStatus(status *models.QueueStatus) error {
+	query := `
+		SELECT 
+			project_id,
+			COUNT(*) FILTER (WHERE status = ?) as running_count,
+			COUNT(*) FILTER (WHERE status = ?) as pending_count,
+			MIN(created_at) FILTER (WHERE status = ?) as oldest_pending
+		FROM jobs
+		WHERE status IN (?, ?)
+		GROUP BY project_id
+		ORDER BY project_id`
+	
+	rows, err := je.jobService.db.Query(query,
+		models.JobStatusRunning, models.JobStatusPending, models.JobStatusPending,
+		models.JobStatusRunning, models.JobStatusPending)
+	if err != nil {
+		return fmt.Errorf("failed to query queue status by project: %w", err)
+	}
+	defer rows.Close()
+	
+	now := time.Now()
+	for rows.Next() {
+		var project models.ProjectQueueStatus
+		var oldestPending sql.NullString
+		if err := rows.Scan(&project.ProjectID, &project.RunningJobs, &project.PendingJobs, &oldestPending); err != nil {
+			return fmt.Errorf("failed to scan queue status: %w", err)
+		}
+		
+		// created_at is stored as UTC RFC3339 so MIN picks the oldest job
+		if oldestPending.Valid {
+			if createdAt, err := time.Parse(time.RFC3339, oldestPending.String); err == nil {
+				age := int64(now.Sub(createdAt).Seconds())
+				project.OldestPendingAgeSec = &age
+				if status.OldestPendingAgeSec == nil || age > *status.OldestPendingAgeSec {
+					status.OldestPendingAgeSec = &age
+				}
+			}
+		}
+		
+		status.PendingJobs += project.PendingJobs
+		status.Projects = append(status.Projects, project)
 	}
+	
+	return rows.Err()
 }
\ No newline at end of file
@@ -1,13 +1,20 @@
 package services
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"ccdash-backend/internal/middleware"
 	"ccdash-backend/internal/models"
 
 	_ "github.com/marcboeker/go-duckdb"
@@ -52,6 +59,10 @@ func setupJobExecutorTestDB(t *testing.T) *sql.DB {
 			scheduled_at TEXT,
 			schedule_type TEXT,
 			schedule_params TEXT,
+			output_rules TEXT,
+			webhook_url TEXT,
+			resource_limits TEXT,
+			env TEXT,
 			FOREIGN KEY (project_id) REFERENCES projects(id)
 		)`,
 	}
@@ -215,7 +226,7 @@ func TestJobExecutor_ValidateCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := executor.validateCommand(tt.command, "/tmp/test")
+			err := executor.validateCommand(tt.command, "/tmp/test", "")
 			if tt.wantErr {
 				if err == nil {
 					t.Errorf("Expected error for command '%s', got nil", tt.command)
@@ -231,6 +242,61 @@ func TestJobExecutor_ValidateCommand(t *testing.T) {
 	}
 }
 
+func TestJobExecutor_ValidateCommand_ProjectWhitelist(t *testing.T) {
+	// Enable the safety check with an unavailable Claude Code, so commands not whitelisted are rejected
+	t.Setenv("COMMAND_WHITELIST_ENABLED", "true")
+	t.Setenv("CCDASH_CLAUDE_CODE_PATH", filepath.Join(t.TempDir(), "missing-claude"))
+
+	db := setupJobTestDB(t)
+	defer db.Close()
+
+	infra := createTestProject(t, db)
+	app := createTestProject(t, db)
+
+	projectService := NewProjectService(db)
+	commands, err := projectService.AddAllowedCommand(infra.ID, "Terraform  Plan")
+	if err != nil {
+		t.Fatalf("Failed to add allowed command: %v", err)
+	}
+	if len(commands) != 1 || commands[0] != "terraform plan" {
+		t.Errorf("Expected [terraform plan], got %v", commands)
+	}
+	if _, err := projectService.AddAllowedCommand(infra.ID, "rm"); err != nil {
+		t.Fatalf("Failed to add allowed command: %v", err)
+	}
+	if _, err := projectService.AddAllowedCommand("missing", "terraform plan"); err == nil || !strings.Contains(err.Error(), "project not found") {
+		t.Errorf("Expected project not found, got %v", err)
+	}
+
+	executor := NewJobExecutor(NewJobService(db), 1)
+
+	if err := executor.validateCommand("terraform plan -out=tfplan", "/tmp/test", infra.ID); err != nil {
+		t.Errorf("Expected terraform plan to be allowed in the infra project, got %v", err)
+	}
+	if err := executor.validateCommand("terraform plan", "/tmp/test", app.ID); err == nil {
+		t.Error("Expected terraform plan to be rejected in another project")
+	}
+	if err := executor.validateCommand("terraform plan", "/tmp/test", ""); err == nil {
+		t.Error("Expected terraform plan to be rejected without a project")
+	}
+
+	// Dangerous patterns are rejected even when whitelisted for the project
+	if err := executor.validateCommand("rm -rf /", "/tmp/test", infra.ID); err == nil || !strings.Contains(err.Error(), "dangerous pattern") {
+		t.Errorf("Expected dangerous pattern error, got %v", err)
+	}
+
+	// Removing the override applies to the next validation
+	if _, err := projectService.RemoveAllowedCommand(infra.ID, "terraform plan"); err != nil {
+		t.Fatalf("Failed to remove allowed command: %v", err)
+	}
+	if err := executor.validateCommand("terraform plan", "/tmp/test", infra.ID); err == nil {
+		t.Error("Expected terraform plan to be rejected after removing the override")
+	}
+	if _, err := projectService.RemoveAllowedCommand(infra.ID, "terraform plan"); err == nil || !strings.Contains(err.Error(), "allowed command not found") {
+		t.Errorf("Expected allowed command not found, got %v", err)
+	}
+}
+
 func TestJobExecutor_BuildCommand(t *testing.T) {
 	db := setupJobExecutorTestDB(t)
 	defer db.Close()
@@ -378,27 +444,88 @@ func TestJobExecutor_GetQueueStatus(t *testing.T) {
 
 	status := executor.GetQueueStatus()
 
-	if status["worker_count"] != 3 {
-		t.Errorf("Expected worker_count 3, got %v", status["worker_count"])
+	if status.WorkerCount != 3 {
+		t.Errorf("Expected worker_count 3, got %v", status.WorkerCount)
 	}
-	if status["running_jobs"] != 1 {
-		t.Errorf("Expected running_jobs 1, got %v", status["running_jobs"])
+	if status.RunningJobs != 1 {
+		t.Errorf("Expected running_jobs 1, got %v", status.RunningJobs)
 	}
-	if status["queued_jobs"] != 2 {
-		t.Errorf("Expected queued_jobs 2, got %v", status["queued_jobs"])
+	if status.QueuedJobs != 2 {
+		t.Errorf("Expected queued_jobs 2, got %v", status.QueuedJobs)
 	}
-
-	// Claude availability check (this will depend on whether claude is installed)
-	claudeAvailable, ok := status["claude_available"].(bool)
-	if !ok {
-		t.Error("Expected claude_available to be a boolean")
+	if status.Paused {
+		t.Error("Expected executor not to be paused")
 	}
-	_ = claudeAvailable // Use the variable to avoid unused warning
 
 	// Clean up
 	cancel()
 }
 
+func TestJobExecutor_GetQueueStatus_ProjectBreakdown(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`INSERT INTO projects (id, name, path) VALUES ('other-project', 'Other Project', '/other/path')`); err != nil {
+		t.Fatalf("Failed to insert project: %v", err)
+	}
+
+	jobService := NewJobService(db)
+	executor := NewJobExecutor(jobService, 2)
+
+	insertJob := func(id, projectID, status string, createdAt time.Time) {
+		_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type) 
+			VALUES (?, ?, 'echo test', '/test/dir', ?, ?, 'immediate')`,
+			id, projectID, status, createdAt.UTC().Format(time.RFC3339))
+		if err != nil {
+			t.Fatalf("Failed to create job %s: %v", id, err)
+		}
+	}
+
+	now := time.Now()
+	insertJob("a-running", "test-project", models.JobStatusRunning, now.Add(-time.Minute))
+	insertJob("a-pending-old", "test-project", models.JobStatusPending, now.Add(-10*time.Minute))
+	insertJob("a-pending-new", "test-project", models.JobStatusPending, now.Add(-time.Minute))
+	insertJob("b-pending", "other-project", models.JobStatusPending, now.Add(-30*time.Minute))
+	insertJob("b-completed", "other-project", models.JobStatusCompleted, now.Add(-time.Hour))
+
+	executor.Pause()
+	status := executor.GetQueueStatus()
+
+	if !status.Paused {
+		t.Error("Expected paused flag to be set")
+	}
+	if status.PendingJobs != 3 {
+		t.Errorf("Expected 3 pending jobs, got %d", status.PendingJobs)
+	}
+	if len(status.Projects) != 2 {
+		t.Fatalf("Expected 2 projects in breakdown, got %d", len(status.Projects))
+	}
+
+	// Ordered by project ID
+	other, test := status.Projects[0], status.Projects[1]
+	if other.ProjectID != "other-project" || other.RunningJobs != 0 || other.PendingJobs != 1 {
+		t.Errorf("Unexpected breakdown for other-project: %+v", other)
+	}
+	if test.ProjectID != "test-project" || test.RunningJobs != 1 || test.PendingJobs != 2 {
+		t.Errorf("Unexpected breakdown for test-project: %+v", test)
+	}
+	if test.OldestPendingAgeSec == nil || *test.OldestPendingAgeSec < 590 || *test.OldestPendingAgeSec > 660 {
+		t.Errorf("Expected test-project oldest pending age around 600s, got %v", test.OldestPendingAgeSec)
+	}
+	if status.OldestPendingAgeSec == nil || *status.OldestPendingAgeSec < 1790 {
+		t.Errorf("Expected overall oldest pending age around 1800s, got %v", status.OldestPendingAgeSec)
+	}
+
+	// Paused executor refuses new work until resumed
+	if err := executor.QueueJob("a-pending-new"); err == nil {
+		t.Error("Expected QueueJob to fail while paused")
+	}
+	executor.Resume()
+	if err := executor.QueueJob("a-pending-new"); err != nil {
+		t.Errorf("Expected QueueJob to succeed after resume, got %v", err)
+	}
+}
+
 func TestJobExecutor_CancelJob(t *testing.T) {
 	db := setupJobExecutorTestDB(t)
 	defer db.Close()
@@ -439,6 +566,117 @@ func TestJobExecutor_CancelJob(t *testing.T) {
 	}
 }
 
+func TestJobExecutor_StaleJobsWithDeadPID(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	jobService := NewJobService(db)
+	executor := NewJobExecutor(jobService, 1)
+
+	// Run a short-lived process so its PID is known to be gone
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Unable to run helper process: %v", err)
+	}
+	deadPID := cmd.Process.Pid
+
+	now := time.Now().Format(time.RFC3339)
+	_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, started_at, pid, scheduled_at, schedule_type) 
+		VALUES (?, 'test-project', 'echo stale', '/test/dir', ?, ?, ?, ?, ?, 'immediate')`,
+		"stale-job", models.JobStatusRunning, now, now, deadPID, now)
+	if err != nil {
+		t.Fatalf("Failed to create stale job: %v", err)
+	}
+
+	// A running job tracked by the executor is never stale
+	createTestJob(t, db, "tracked-job", "echo tracked", models.JobStatusRunning)
+	_, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	executor.cancelMutex.Lock()
+	executor.cancelMap["tracked-job"] = cancel
+	executor.cancelMutex.Unlock()
+
+	staleJobs, err := executor.FindStaleRunningJobs()
+	if err != nil {
+		t.Fatalf("FindStaleRunningJobs failed: %v", err)
+	}
+	if len(staleJobs) != 1 || staleJobs[0].Job.ID != "stale-job" {
+		t.Fatalf("Expected only stale-job to be stale, got %+v", staleJobs)
+	}
+	if staleJobs[0].Reason != models.StaleJobReasonProcessGone {
+		t.Errorf("Expected reason %s, got %s", models.StaleJobReasonProcessGone, staleJobs[0].Reason)
+	}
+
+	cleaned, err := executor.CleanupStaleRunningJobs()
+	if err != nil {
+		t.Fatalf("CleanupStaleRunningJobs failed: %v", err)
+	}
+	if len(cleaned) != 1 {
+		t.Errorf("Expected 1 cleaned job, got %d", len(cleaned))
+	}
+
+	job, err := jobService.GetJobByID("stale-job")
+	if err != nil {
+		t.Fatalf("Failed to get stale job: %v", err)
+	}
+	if job.Status != models.JobStatusFailed {
+		t.Errorf("Expected stale job to be failed, got %s", job.Status)
+	}
+
+	staleJobs, err = executor.FindStaleRunningJobs()
+	if err != nil {
+		t.Fatalf("FindStaleRunningJobs failed: %v", err)
+	}
+	if len(staleJobs) != 0 {
+		t.Errorf("Expected no stale jobs after cleanup, got %d", len(staleJobs))
+	}
+}
+
+func TestJobExecutor_StaleJobThreshold(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	executor := NewJobExecutor(NewJobService(db), 1)
+
+	// Untracked running jobs without a PID are only judged by how long they have run
+	for id, age := range map[string]time.Duration{"job-10m": 10 * time.Minute, "job-45m": 45 * time.Minute, "job-3h": 3 * time.Hour} {
+		startedAt := time.Now().Add(-age).Format(time.RFC3339)
+		_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, started_at, schedule_type) 
+			VALUES (?, 'test-project', 'echo stale', '/test/dir', ?, ?, ?, 'immediate')`,
+			id, models.JobStatusRunning, startedAt, startedAt)
+		if err != nil {
+			t.Fatalf("Failed to create running job: %v", err)
+		}
+	}
+
+	staleIDs := func() map[string]bool {
+		staleJobs, err := executor.FindStaleRunningJobs()
+		if err != nil {
+			t.Fatalf("FindStaleRunningJobs failed: %v", err)
+		}
+		ids := map[string]bool{}
+		for _, stale := range staleJobs {
+			ids[stale.Job.ID] = true
+		}
+		return ids
+	}
+
+	if ids := staleIDs(); len(ids) != 2 || !ids["job-45m"] || !ids["job-3h"] {
+		t.Errorf("Expected jobs past the default threshold to be stale, got %v", ids)
+	}
+
+	executor.SetMonitoring(2*time.Hour, 0)
+	if ids := staleIDs(); len(ids) != 1 || !ids["job-3h"] {
+		t.Errorf("Expected only jobs past the 2h threshold to be stale, got %v", ids)
+	}
+
+	// A threshold shorter than the execution timeout never reaps jobs that may still be running
+	executor.SetMonitoring(time.Minute, 0)
+	if ids := staleIDs(); ids["job-10m"] {
+		t.Errorf("Expected job within the execution timeout not to be stale, got %v", ids)
+	}
+}
+
 func TestJobExecutor_StartStop(t *testing.T) {
 	db := setupJobExecutorTestDB(t)
 	defer db.Close()
@@ -535,6 +773,280 @@ func BenchmarkJobExecutor_ValidateCommand(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		command := commands[i%len(commands)]
-		executor.validateCommand(command, "/tmp/test")
+		executor.validateCommand(command, "/tmp/test", "")
 	}
-}
\ No newline at end of file
+}
+// installFakeClaude puts a "claude" script that sleeps for the given seconds first on PATH
+func installFakeClaude(t *testing.T, sleepSeconds string) {
+	installFakeClaudeScript(t, "#!/bin/sh\nsleep "+sleepSeconds+"\necho done\n")
+}
+
+// installFakeClaudeScript puts a claude stub running script first on PATH
+func installFakeClaudeScript(t *testing.T, script string) {
+	binDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(binDir, "claude"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// startDrainTestJob runs a pending job in the background and waits until it is tracked as running
+func startDrainTestJob(t *testing.T, db *sql.DB, executor *JobExecutor, id string) chan struct{} {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+		VALUES (?, 'test-project', 'summarize the repo', ?, 'pending', ?, 'immediate')`, id, t.TempDir(), now)
+	if err != nil {
+		t.Fatalf("Failed to create test job: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		executor.executeJob(id)
+		close(done)
+	}()
+
+	for i := 0; i < 100; i++ {
+		if len(executor.GetRunningJobs()) > 0 {
+			return done
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Job never started running")
+	return done
+}
+
+func TestJobExecutor_Drain(t *testing.T) {
+	t.Run("waits for running jobs to finish", func(t *testing.T) {
+		db := setupJobExecutorTestDB(t)
+		defer db.Close()
+		installFakeClaude(t, "0.3")
+
+		executor := NewJobExecutor(NewJobService(db), 1)
+		done := startDrainTestJob(t, db, executor, "drain-finish")
+
+		executor.Drain(5 * time.Second)
+		<-done
+
+		job, _ := executor.jobService.GetJobByID("drain-finish")
+		if job.Status != models.JobStatusCompleted {
+			t.Errorf("Expected job to complete during drain, got %s", job.Status)
+		}
+		if err := executor.QueueJob("another-job"); err == nil {
+			t.Error("Expected QueueJob to fail after Drain")
+		}
+	})
+
+	t.Run("interrupts jobs still running at the deadline", func(t *testing.T) {
+		db := setupJobExecutorTestDB(t)
+		defer db.Close()
+		// stderr output without a trailing newline must stay separate from the shutdown message
+		installFakeClaudeScript(t, "#!/bin/sh\nprintf working >&2\nsleep 30\n")
+
+		executor := NewJobExecutor(NewJobService(db), 1)
+		done := startDrainTestJob(t, db, executor, "drain-interrupt")
+
+		start := time.Now()
+		executor.Drain(200 * time.Millisecond)
+		<-done
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("Expected drain to stop at the deadline, took %v", elapsed)
+		}
+
+		job, _ := executor.jobService.GetJobByID("drain-interrupt")
+		if job.Status != models.JobStatusFailed {
+			t.Errorf("Expected interrupted job to be %s, got %s", models.JobStatusFailed, job.Status)
+		}
+		if job.ErrorLog == nil || *job.ErrorLog != "working\n"+JobInterruptedByShutdownMessage {
+			t.Errorf("Expected stderr followed by the shutdown message, got %v", job.ErrorLog)
+		}
+	})
+}
+
+func TestJobExecutor_ValidateExecutionDirectory(t *testing.T) {
+	root := t.TempDir()
+	projectDir := filepath.Join(root, "project")
+	if err := os.Mkdir(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	filePath := filepath.Join(root, "notes.txt")
+	if err := os.WriteFile(filePath, []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	outsideDir := t.TempDir()
+
+	tests := []struct {
+		name        string
+		roots       []string
+		dir         string
+		expectError string
+	}{
+		{"any directory without roots", nil, outsideDir, ""},
+		{"directory under root", []string{root}, projectDir, ""},
+		{"root itself", []string{root}, root, ""},
+		{"second root", []string{outsideDir, root}, projectDir, ""},
+		{"nonexistent directory", nil, filepath.Join(root, "missing"), "does not exist"},
+		{"file instead of directory", nil, filePath, "is not a directory"},
+		{"outside the roots", []string{root}, outsideDir, "outside the allowed roots"},
+		{"sibling with root as prefix", []string{projectDir}, projectDir + "-other", "does not exist"},
+		{"empty directory", nil, "", "not set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewJobExecutor(nil, 1)
+			executor.SetAllowedRoots(tt.roots)
+
+			err := executor.validateExecutionDirectory(tt.dir)
+			if tt.expectError == "" {
+				if err != nil {
+					t.Errorf("Expected %s to be allowed, got %v", tt.dir, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectError) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestJobExecutor_ExecuteJobFailsOutsideAllowedRoots(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	installFakeClaude(t, "0")
+	executor := NewJobExecutor(NewJobService(db), 1)
+	executor.SetAllowedRoots([]string{t.TempDir()})
+
+	_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+		VALUES ('outside-job', 'test-project', 'summarize the repo', ?, 'pending', '2025-01-01T00:00:00Z', 'immediate')`, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create test job: %v", err)
+	}
+
+	executor.executeJob("outside-job")
+
+	job, err := executor.jobService.GetJobByID("outside-job")
+	if err != nil || job == nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.Status != models.JobStatusFailed {
+		t.Errorf("Expected job to fail, got %s", job.Status)
+	}
+	if job.ErrorLog == nil || !strings.Contains(*job.ErrorLog, "outside the allowed roots") {
+		t.Errorf("Expected the error log to explain the failure, got %v", job.ErrorLog)
+	}
+	if job.OutputLog != nil && *job.OutputLog != "" {
+		t.Errorf("Expected claude not to run, got output %q", *job.OutputLog)
+	}
+}
+
+func TestJobExecutor_LogsRequestID(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	executor := NewJobExecutor(NewJobService(db), 1)
+	executor.SetAllowedRoots([]string{t.TempDir()})
+
+	_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+		VALUES ('request-job', 'test-project', 'summarize the repo', ?, 'pending', '2025-01-01T00:00:00Z', 'immediate')`, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create test job: %v", err)
+	}
+
+	ctx := middleware.WithRequestID(context.Background(), "trace-job")
+	if err := executor.QueueJobContext(ctx, "request-job"); err != nil {
+		t.Fatalf("Failed to queue job: %v", err)
+	}
+	executor.executeJob(<-executor.jobQueue)
+
+	lines := 0
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Failed to parse log line %q: %v", line, err)
+		}
+		if entry["job_id"] != "request-job" {
+			continue
+		}
+		lines++
+		if entry["request_id"] != "trace-job" {
+			t.Errorf("Expected log line to carry the request ID: %s", line)
+		}
+	}
+	if lines < 2 {
+		t.Errorf("Expected queue and execution log lines for the job, got %d", lines)
+	}
+	if len(executor.jobRequests) != 0 {
+		t.Errorf("Expected the request ID to be dropped once the job ran, got %v", executor.jobRequests)
+	}
+}
+
+func TestJobExecutor_MaxConcurrentPerProject(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+	installFakeClaude(t, "0.5")
+
+	if _, err := db.Exec(`INSERT INTO projects (id, name, path) VALUES ('other-project', 'Other Project', '/other/path')`); err != nil {
+		t.Fatalf("Failed to insert other project: %v", err)
+	}
+
+	executor := NewJobExecutor(NewJobService(db), 3)
+	executor.SetMaxConcurrentPerProject(1)
+	executor.SetMonitoring(0, time.Hour)
+	executor.Start()
+	defer executor.Stop()
+
+	for _, job := range []struct{ id, projectID string }{
+		{"first-job", "test-project"},
+		{"second-job", "test-project"},
+		{"other-job", "other-project"},
+	} {
+		_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+			VALUES (?, ?, 'summarize the repo', ?, 'pending', '2025-01-01T00:00:00Z', 'immediate')`, job.id, job.projectID, t.TempDir())
+		if err != nil {
+			t.Fatalf("Failed to create test job: %v", err)
+		}
+		if err := executor.QueueJob(job.id); err != nil {
+			t.Fatalf("Failed to queue job: %v", err)
+		}
+	}
+
+	status := func(id string) string {
+		job, err := executor.jobService.GetJobByID(id)
+		if err != nil || job == nil {
+			t.Fatalf("Failed to get job %s: %v", id, err)
+		}
+		return job.Status
+	}
+
+	otherRanAlongside := false
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		first, second, other := status("first-job"), status("second-job"), status("other-job")
+		if first == models.JobStatusRunning && second == models.JobStatusRunning {
+			t.Fatal("Expected the project's second job to wait for the first to finish")
+		}
+		if other == models.JobStatusRunning && (first == models.JobStatusRunning || second == models.JobStatusRunning) {
+			otherRanAlongside = true
+		}
+		if first == models.JobStatusCompleted && second == models.JobStatusCompleted && other == models.JobStatusCompleted {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	for _, id := range []string{"first-job", "second-job", "other-job"} {
+		if s := status(id); s != models.JobStatusCompleted {
+			t.Errorf("Expected %s to complete, got %s", id, s)
+		}
+	}
+	if !otherRanAlongside {
+		t.Error("Expected the other project's job to run while the capped project's job was running")
+	}
+}
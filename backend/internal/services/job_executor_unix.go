@@ -3,10 +3,27 @@
 package services
 
 import (
+	"fmt"
+	"os"
 	"os/exec"
+	"runtime"
+	"strings"
 	"syscall"
+
+	"ccdash-backend/internal/models"
 )
 
+// platformJobEnvPassthrough is the Unix part of defaultJobEnvPassthrough
+var platformJobEnvPassthrough = []string{
+	"HOME", "USER", "LOGNAME", "SHELL", "TMPDIR",
+	"LC_ALL", "LC_CTYPE", "TERM",
+}
+
+// jobEnvKey returns the name environment variables are matched by; Unix names are case-sensitive
+func jobEnvKey(name string) string {
+	return name
+}
+
 // configurePlatformSpecificAttrs sets platform-specific process attributes for Unix-like systems
 func configurePlatformSpecificAttrs(cmd *exec.Cmd) {
 	cmd.SysProcAttr = &syscall.SysProcAttr{
@@ -15,4 +32,53 @@ func configurePlatformSpecificAttrs(cmd *exec.Cmd) {
 		// Prevent the process from being stopped by TTY signals
 		Setsid: true, // Create a new session to detach from controlling terminal
 	}
-}
\ No newline at end of file
+}
+
+// signalProcessGroup sends sig to the process group led by pid.
+// Jobs run in their own session (Setsid), so this reaches every process they spawned.
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
+
+// applyResourceLimits wraps the command in a shell that calls setrlimit (via ulimit) before exec'ing it.
+// The memory limit caps virtual memory (RLIMIT_AS) and is only enforced on Linux;
+// the CPU time limit (RLIMIT_CPU) applies on Linux and macOS.
+func applyResourceLimits(args []string, limits models.ResourceLimits) []string {
+	var ulimits []string
+	if limits.MemoryMB > 0 && runtime.GOOS == "linux" {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -v %d", limits.MemoryMB*1024))
+	}
+	if limits.CPUSeconds > 0 {
+		ulimits = append(ulimits, fmt.Sprintf("ulimit -t %d", limits.CPUSeconds))
+	}
+	if len(ulimits) == 0 {
+		return args
+	}
+
+	script := strings.Join(ulimits, " && ") + ` && exec "$@"`
+	return append([]string{"/bin/sh", "-c", script, "sh"}, args...)
+}
+
+// resourceLimitExceeded returns a reason if the process appears to have been stopped by its resource limits
+func resourceLimitExceeded(state *os.ProcessState, errorLog string, limits models.ResourceLimits) string {
+	if state == nil {
+		return ""
+	}
+
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && limits.CPUSeconds > 0 {
+		if status.Signaled() && status.Signal() == syscall.SIGXCPU {
+			return fmt.Sprintf("Job exceeded its CPU time limit of %d seconds", limits.CPUSeconds)
+		}
+	}
+
+	if limits.MemoryMB > 0 && runtime.GOOS == "linux" {
+		lower := strings.ToLower(errorLog)
+		for _, pattern := range []string{"out of memory", "cannot allocate memory", "memory exhausted"} {
+			if strings.Contains(lower, pattern) {
+				return fmt.Sprintf("Job exceeded its memory limit of %d MB", limits.MemoryMB)
+			}
+		}
+	}
+
+	return ""
+}
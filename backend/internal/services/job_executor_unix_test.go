@@ -0,0 +1,219 @@
+//go:build linux
+
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"ccdash-backend/internal/models"
+)
+
+// TestHelperAllocateMemory is run as a job's "claude" process and allocates well past the memory limit
+func TestHelperAllocateMemory(t *testing.T) {
+	if os.Getenv("CCDASH_TEST_ALLOCATE_MEMORY") != "1" {
+		t.Skip("helper process")
+	}
+	buf := make([]byte, 2<<30)
+	for i := range buf {
+		buf[i] = 1
+	}
+	os.Exit(0)
+}
+
+func TestJobExecutor_MemoryLimitTerminatesJob(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	// "claude" re-runs this test binary as a memory hog
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nCCDASH_TEST_ALLOCATE_MEMORY=1 exec " + os.Args[0] + " -test.run=TestHelperAllocateMemory\n"
+	if err := os.WriteFile(filepath.Join(binDir, "claude"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	executor := NewJobExecutor(NewJobService(db), 1)
+	executor.SetResourceLimits(models.ResourceLimits{MemoryMB: 1024})
+
+	_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+		VALUES ('memory-job', 'test-project', 'summarize the repo', ?, 'pending', '2025-01-01T00:00:00Z', 'immediate')`, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create test job: %v", err)
+	}
+
+	executor.executeJob("memory-job")
+
+	job, err := executor.jobService.GetJobByID("memory-job")
+	if err != nil || job == nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.Status != models.JobStatusFailed {
+		t.Errorf("Expected memory-limited job to fail, got %s", job.Status)
+	}
+	if job.ErrorLog == nil || !strings.Contains(*job.ErrorLog, "exceeded its memory limit of 1024 MB") {
+		t.Errorf("Expected error log to name the memory limit, got %q", *job.ErrorLog)
+	}
+}
+
+func TestApplyResourceLimits(t *testing.T) {
+	args := []string{"claude", "-p", "hello"}
+
+	if got := applyResourceLimits(args, models.ResourceLimits{}); len(got) != len(args) {
+		t.Errorf("Expected command to be unchanged without limits, got %v", got)
+	}
+
+	got := applyResourceLimits(args, models.ResourceLimits{MemoryMB: 512, CPUSeconds: 60})
+	if got[0] != "/bin/sh" || !strings.Contains(got[2], "ulimit -v 524288") || !strings.Contains(got[2], "ulimit -t 60") {
+		t.Errorf("Expected ulimit wrapper, got %v", got)
+	}
+	if strings.Join(got[len(got)-3:], " ") != "claude -p hello" {
+		t.Errorf("Expected original command to be exec'd, got %v", got)
+	}
+}
+
+func TestJobExecutor_OutputKeepsTailWithinCap(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	// "claude" prints far more than the cap
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nseq 1 20000\n"
+	if err := os.WriteFile(filepath.Join(binDir, "claude"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	executor := NewJobExecutor(NewJobService(db), 1)
+	executor.SetMaxOutputBytes(1000)
+
+	_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+		VALUES ('chatty-job', 'test-project', 'summarize the repo', ?, 'pending', '2025-01-01T00:00:00Z', 'immediate')`, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create test job: %v", err)
+	}
+
+	executor.executeJob("chatty-job")
+
+	job, err := executor.jobService.GetJobByID("chatty-job")
+	if err != nil || job == nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.Status != models.JobStatusCompleted {
+		t.Errorf("Expected job to complete, got %s", job.Status)
+	}
+	if job.OutputLog == nil {
+		t.Fatal("Expected an output log")
+	}
+	output := *job.OutputLog
+	if !strings.HasPrefix(output, JobOutputTruncatedMarker) {
+		t.Errorf("Expected output to start with the truncation marker, got %q", output[:40])
+	}
+	if len(output) > 1000+len(JobOutputTruncatedMarker) {
+		t.Errorf("Expected at most %d bytes of output, got %d", 1000+len(JobOutputTruncatedMarker), len(output))
+	}
+	if !strings.HasSuffix(output, "19999\n20000\n") {
+		t.Errorf("Expected the most recent output to be kept, got %q", output[len(output)-40:])
+	}
+}
+
+func TestJobExecutor_EnvStripsBackendSecrets(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	// "claude" prints its environment
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nenv\n"
+	if err := os.WriteFile(filepath.Join(binDir, "claude"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("CCDASH_API_KEY", "backend-secret")
+
+	executor := NewJobExecutor(NewJobService(db), 1)
+
+	_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type, env)
+		VALUES ('env-job', 'test-project', 'summarize the repo', ?, 'pending', '2025-01-01T00:00:00Z', 'immediate', '{"NODE_ENV":"test"}')`, t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create test job: %v", err)
+	}
+
+	executor.executeJob("env-job")
+
+	job, err := executor.jobService.GetJobByID("env-job")
+	if err != nil || job == nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if job.OutputLog == nil {
+		t.Fatal("Expected an output log")
+	}
+	output := *job.OutputLog
+	if strings.Contains(output, "CCDASH_API_KEY") {
+		t.Error("Expected CCDASH_API_KEY to be stripped from the job environment")
+	}
+	for _, expected := range []string{"PATH=", "NODE_ENV=test", "CLAUDECODE=1", "CLAUDE_CODE_ENTRYPOINT=cli"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in the job environment, got %q", expected, output)
+		}
+	}
+}
+
+func TestJobExecutor_CancelKillsProcessTree(t *testing.T) {
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	// "claude" starts a grandchild, records its PID and waits
+	binDir := t.TempDir()
+	pidFile := filepath.Join(t.TempDir(), "grandchild.pid")
+	script := "#!/bin/sh\nsleep 60 &\necho $! > " + pidFile + "\nsleep 60\n"
+	if err := os.WriteFile(filepath.Join(binDir, "claude"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake claude: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	executor := NewJobExecutor(NewJobService(db), 1)
+	done := startDrainTestJob(t, db, executor, "tree-job")
+
+	var grandchild int
+	for i := 0; i < 100 && grandchild == 0; i++ {
+		if data, err := os.ReadFile(pidFile); err == nil {
+			grandchild, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+		}
+		if grandchild == 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	if grandchild == 0 {
+		t.Fatal("Grandchild process did not start")
+	}
+
+	if err := executor.CancelJob("tree-job"); err != nil {
+		t.Fatalf("Failed to cancel job: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Cancelled job did not finish")
+	}
+
+	for i := 0; i < 100 && processAlive(grandchild); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if processAlive(grandchild) {
+		t.Errorf("Expected grandchild process %d to be killed with the job", grandchild)
+	}
+}
+
+// processAlive reports whether pid is running; zombies waiting to be reaped count as dead
+func processAlive(pid int) bool {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(data))
+	return len(fields) > 2 && fields[2] != "Z"
+}
@@ -3,10 +3,27 @@
 package services
 
 import (
+	"log"
+	"os"
 	"os/exec"
+	"strings"
 	"syscall"
+
+	"ccdash-backend/internal/models"
 )
 
+// platformJobEnvPassthrough is the Windows part of defaultJobEnvPassthrough
+var platformJobEnvPassthrough = []string{
+	"SystemRoot", "USERPROFILE", "APPDATA", "LOCALAPPDATA",
+	"COMSPEC", "PATHEXT", "TEMP",
+}
+
+// jobEnvKey returns the name environment variables are matched by. Windows names are
+// case-insensitive, e.g. PATH is usually set as Path.
+func jobEnvKey(name string) string {
+	return strings.ToUpper(name)
+}
+
 // configurePlatformSpecificAttrs sets platform-specific process attributes for Windows
 func configurePlatformSpecificAttrs(cmd *exec.Cmd) {
 	// Windows doesn't support Credential or Setsid fields
@@ -14,4 +31,26 @@ func configurePlatformSpecificAttrs(cmd *exec.Cmd) {
 		// Windows-specific configuration can be added here if needed
 		// For now, we use an empty struct which is valid on Windows
 	}
-}
\ No newline at end of file
+}
+
+// signalProcessGroup signals only the process itself, as Windows has no process groups to signal
+func signalProcessGroup(pid int, sig syscall.Signal) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(sig)
+}
+
+// applyResourceLimits is a no-op on Windows, which has no setrlimit
+func applyResourceLimits(args []string, limits models.ResourceLimits) []string {
+	if limits.MemoryMB > 0 || limits.CPUSeconds > 0 {
+		log.Println("Job resource limits are not supported on Windows; running without them")
+	}
+	return args
+}
+
+// resourceLimitExceeded never reports a limit on Windows
+func resourceLimitExceeded(state *os.ProcessState, errorLog string, limits models.ResourceLimits) string {
+	return ""
+}
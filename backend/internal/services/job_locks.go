@@ -0,0 +1,61 @@
+package services
+
+import (
+	"database/sql"
+	"sync"
+)
+
+// jobLocks serializes writes to the same job. Rows that DuckDB cannot update in place are
+// rewritten with DELETE+INSERT, and a concurrent update of the same job would otherwise be lost.
+type jobLocks struct {
+	mu    sync.Mutex
+	locks map[string]*jobLock // job ID -> lock, removed once no writer holds or waits for it
+}
+
+type jobLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var (
+	jobLocksMu  sync.Mutex
+	jobLockSets = map[*sql.DB]*jobLocks{}
+)
+
+// jobLocksFor returns the job locks shared by every JobService using db, since the
+// executor, scheduler and handlers each create their own service
+func jobLocksFor(db *sql.DB) *jobLocks {
+	jobLocksMu.Lock()
+	defer jobLocksMu.Unlock()
+
+	locks, exists := jobLockSets[db]
+	if !exists {
+		locks = &jobLocks{locks: make(map[string]*jobLock)}
+		jobLockSets[db] = locks
+	}
+	return locks
+}
+
+// lock blocks until no other writer holds the job's lock and returns the function releasing it
+func (l *jobLocks) lock(id string) func() {
+	l.mu.Lock()
+	jl, exists := l.locks[id]
+	if !exists {
+		jl = &jobLock{}
+		l.locks[id] = jl
+	}
+	jl.refs++
+	l.mu.Unlock()
+
+	jl.mu.Lock()
+	return func() {
+		jl.mu.Unlock()
+
+		l.mu.Lock()
+		jl.refs--
+		if jl.refs == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,65 @@
+package services
+
+import "unicode/utf8"
+
+// DEFAULT_MAX_JOB_OUTPUT_BYTES caps how much of each of a job's output streams is kept
+const DEFAULT_MAX_JOB_OUTPUT_BYTES = 5 * 1024 * 1024
+
+// JobOutputTruncatedMarker starts an output log whose beginning was dropped to stay within the cap
+const JobOutputTruncatedMarker = "…[output truncated]\n"
+
+// tailBuffer keeps the last max bytes written to it, as the end of a job's output is
+// usually the most relevant. A max of 0 or less keeps everything.
+type tailBuffer struct {
+	max     int
+	buf     []byte
+	dropped int64 // Bytes discarded from the start
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (b *tailBuffer) WriteString(s string) {
+	b.buf = append(b.buf, s...)
+
+	// Compact only once twice the cap is buffered so writes stay amortized O(1)
+	if b.max > 0 && len(b.buf) > 2*b.max {
+		b.discardHead(len(b.buf) - b.max)
+	}
+}
+
+func (b *tailBuffer) discardHead(n int) {
+	b.dropped += int64(n)
+	b.buf = append(b.buf[:0], b.buf[n:]...)
+}
+
+// Truncated reports whether any output was dropped
+func (b *tailBuffer) Truncated() bool {
+	return b.dropped > 0 || (b.max > 0 && len(b.buf) > b.max)
+}
+
+// DroppedBytes returns how many bytes were dropped from the start of the output
+func (b *tailBuffer) DroppedBytes() int64 {
+	if b.max > 0 && len(b.buf) > b.max {
+		return b.dropped + int64(len(b.buf)-b.max)
+	}
+	return b.dropped
+}
+
+// String returns the kept output, starting with JobOutputTruncatedMarker if any was dropped
+func (b *tailBuffer) String() string {
+	if !b.Truncated() {
+		return string(b.buf)
+	}
+
+	tail := b.buf
+	if len(tail) > b.max {
+		tail = tail[len(tail)-b.max:]
+	}
+	// Do not start in the middle of a multi-byte character
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+	return JobOutputTruncatedMarker + string(tail)
+}
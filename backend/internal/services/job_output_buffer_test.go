@@ -0,0 +1,32 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTailBuffer(t *testing.T) {
+	buffer := newTailBuffer(10)
+	buffer.WriteString("0123")
+	if buffer.Truncated() || buffer.String() != "0123" {
+		t.Errorf("Expected untruncated output, got %q", buffer.String())
+	}
+
+	// "é" is two bytes; the kept tail must not start halfway through it
+	buffer.WriteString(strings.Repeat("x", 20) + "é123456789")
+	if !buffer.Truncated() {
+		t.Fatal("Expected output to be truncated")
+	}
+	if got := buffer.String(); got != JobOutputTruncatedMarker+"123456789" {
+		t.Errorf("Expected the last whole characters to be kept, got %q", got)
+	}
+	if got := buffer.DroppedBytes(); got != 25 {
+		t.Errorf("Expected 25 dropped bytes, got %d", got)
+	}
+
+	unlimited := newTailBuffer(0)
+	unlimited.WriteString(strings.Repeat("x", 100))
+	if unlimited.Truncated() || len(unlimited.String()) != 100 {
+		t.Error("Expected no cap when max is 0")
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"ccdash-backend/internal/middleware"
+	"ccdash-backend/internal/models"
+)
+
+// JobOutputEvent is the payload delivered when a job's output matches an OutputRule
+type JobOutputEvent struct {
+	Event     string            `json:"event"`
+	JobID     string            `json:"job_id"`
+	ProjectID string            `json:"project_id"`
+	Status    string            `json:"status"`
+	ExitCode  int               `json:"exit_code"`
+	Rule      models.OutputRule `json:"rule"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// JobOutputEventListener receives events fired by event actions
+type JobOutputEventListener func(event JobOutputEvent)
+
+// OutputRuleDispatcher evaluates output rules and triggers their actions
+type OutputRuleDispatcher struct {
+	httpClient *http.Client
+	listeners  []JobOutputEventListener
+	mu         sync.RWMutex
+	pending    sync.WaitGroup // Actions still in flight
+}
+
+// NewOutputRuleDispatcher creates a new output rule dispatcher
+func NewOutputRuleDispatcher() *OutputRuleDispatcher {
+	return &OutputRuleDispatcher{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// AddListener registers a listener for event actions
+func (d *OutputRuleDispatcher) AddListener(listener JobOutputEventListener) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners = append(d.listeners, listener)
+}
+
+// outputRuleMatcher is an OutputRule with its on_output_matches pattern compiled
+type outputRuleMatcher struct {
+	rule models.OutputRule
+	re   *regexp.Regexp // nil without on_output_matches
+}
+
+// compileOutputRules compiles the on_output_matches pattern of each rule
+func compileOutputRules(rules []models.OutputRule) ([]outputRuleMatcher, error) {
+	matchers := make([]outputRuleMatcher, len(rules))
+	for i, rule := range rules {
+		matchers[i].rule = rule
+		if rule.OnOutputMatches != "" {
+			re, err := regexp.Compile(rule.OnOutputMatches)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid on_output_matches pattern: %w", i, err)
+			}
+			matchers[i].re = re
+		}
+	}
+	return matchers, nil
+}
+
+// ValidateOutputRules checks that every rule has a matcher and a valid action
+func ValidateOutputRules(rules []models.OutputRule) error {
+	for i, rule := range rules {
+		if rule.OnOutputContains == "" && rule.OnOutputMatches == "" {
+			return fmt.Errorf("rule %d: on_output_contains or on_output_matches is required", i)
+		}
+		switch rule.Action.Type {
+		case models.OutputRuleActionWebhook:
+			if !strings.HasPrefix(rule.Action.WebhookURL, "http://") && !strings.HasPrefix(rule.Action.WebhookURL, "https://") {
+				return fmt.Errorf("rule %d: webhook_url must be an http or https URL", i)
+			}
+		case models.OutputRuleActionEvent:
+			if rule.Action.Event == "" {
+				return fmt.Errorf("rule %d: event is required for event actions", i)
+			}
+		default:
+			return fmt.Errorf("rule %d: invalid action type: %s", i, rule.Action.Type)
+		}
+	}
+	_, err := compileOutputRules(rules)
+	return err
+}
+
+// matchOutputRules returns the rules whose matcher matches the given output
+func matchOutputRules(matchers []outputRuleMatcher, output string) []models.OutputRule {
+	var matched []models.OutputRule
+	for _, m := range matchers {
+		if m.rule.OnOutputContains != "" && !strings.Contains(output, m.rule.OnOutputContains) {
+			continue
+		}
+		if m.re != nil && !m.re.MatchString(output) {
+			continue
+		}
+		matched = append(matched, m.rule)
+	}
+	return matched
+}
+
+// Dispatch evaluates the job's output rules against its captured output and triggers matching actions.
+// Actions run in the background so slow webhooks or listeners never hold up the executor worker;
+// failures are logged with the request ID carried by ctx, if any.
+func (d *OutputRuleDispatcher) Dispatch(ctx context.Context, job *models.Job, output string, status string, exitCode int) {
+	if job.OutputRules == nil || *job.OutputRules == "" {
+		return
+	}
+
+	logger := middleware.ContextLogger(ctx).With("job_id", job.ID)
+	var rules []models.OutputRule
+	if err := json.Unmarshal([]byte(*job.OutputRules), &rules); err != nil {
+		logger.Error("Failed to parse output rules", "error", err)
+		return
+	}
+	matchers, err := compileOutputRules(rules)
+	if err != nil {
+		logger.Error("Failed to compile output rules", "error", err)
+		return
+	}
+
+	matched := matchOutputRules(matchers, output)
+	if len(matched) == 0 {
+		return
+	}
+
+	d.pending.Add(1)
+	middleware.SafeGoRoutine(ctx, "job-output-rules", func() {
+		defer d.pending.Done()
+		for _, rule := range matched {
+			d.trigger(logger, job, rule, status, exitCode)
+		}
+	})
+}
+
+// Wait blocks until every pending action has finished
+func (d *OutputRuleDispatcher) Wait() {
+	d.pending.Wait()
+}
+
+// trigger runs the action of a matched rule
+func (d *OutputRuleDispatcher) trigger(logger *slog.Logger, job *models.Job, rule models.OutputRule, status string, exitCode int) {
+	event := JobOutputEvent{
+		Event:     rule.Action.Event,
+		JobID:     job.ID,
+		ProjectID: job.ProjectID,
+		Status:    status,
+		ExitCode:  exitCode,
+		Rule:      rule,
+		Timestamp: time.Now().UTC(),
+	}
+
+	switch rule.Action.Type {
+	case models.OutputRuleActionWebhook:
+		if event.Event == "" {
+			event.Event = "job.output_matched"
+		}
+		if err := d.postWebhook(rule.Action.WebhookURL, event); err != nil {
+			logger.Error("Output rule webhook failed", "error", err)
+		}
+	case models.OutputRuleActionEvent:
+		logger.Info("Output rule matched, firing event", "event", event.Event)
+		d.mu.RLock()
+		listeners := append([]JobOutputEventListener(nil), d.listeners...)
+		d.mu.RUnlock()
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+// postWebhook delivers the event as a JSON POST request
+func (d *OutputRuleDispatcher) postWebhook(url string, event JobOutputEvent) error {
+	return postJSONWebhook(d.httpClient, url, event)
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ccdash-backend/internal/models"
+)
+
+func outputRulesJSON(t *testing.T, rules []models.OutputRule) *string {
+	data, err := json.Marshal(rules)
+	if err != nil {
+		t.Fatalf("Failed to marshal output rules: %v", err)
+	}
+	s := string(data)
+	return &s
+}
+
+func TestOutputRuleDispatcher_EventAction(t *testing.T) {
+	dispatcher := NewOutputRuleDispatcher()
+
+	var fired []JobOutputEvent
+	dispatcher.AddListener(func(event JobOutputEvent) {
+		fired = append(fired, event)
+	})
+
+	job := &models.Job{
+		ID:        "job-1",
+		ProjectID: "project-1",
+		OutputRules: outputRulesJSON(t, []models.OutputRule{
+			{OnOutputContains: "FAILED", Action: models.OutputRuleAction{Type: models.OutputRuleActionEvent, Event: "tests_failed"}},
+			{OnOutputMatches: `coverage: \d+%`, Action: models.OutputRuleAction{Type: models.OutputRuleActionEvent, Event: "coverage"}},
+			{OnOutputContains: "PANIC", Action: models.OutputRuleAction{Type: models.OutputRuleActionEvent, Event: "panic"}},
+		}),
+	}
+
+	dispatcher.Dispatch(context.Background(), job, "running tests\n3 tests FAILED\ncoverage: 82%\n", models.JobStatusCompleted, 0)
+	dispatcher.Wait()
+
+	if len(fired) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(fired))
+	}
+	if fired[0].Event != "tests_failed" || fired[0].JobID != "job-1" {
+		t.Errorf("Unexpected first event: %+v", fired[0])
+	}
+	if fired[1].Event != "coverage" {
+		t.Errorf("Unexpected second event: %+v", fired[1])
+	}
+}
+
+func TestOutputRuleDispatcher_WebhookAction(t *testing.T) {
+	received := make(chan JobOutputEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event JobOutputEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode webhook payload: %v", err)
+		}
+		received <- event
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	dispatcher := NewOutputRuleDispatcher()
+	job := &models.Job{
+		ID: "job-2",
+		OutputRules: outputRulesJSON(t, []models.OutputRule{
+			{OnOutputContains: "FAILED", Action: models.OutputRuleAction{Type: models.OutputRuleActionWebhook, WebhookURL: server.URL}},
+		}),
+	}
+
+	// Non-matching output must not fire
+	dispatcher.Dispatch(context.Background(), job, "all tests passed", models.JobStatusCompleted, 0)
+	dispatcher.Wait()
+	select {
+	case event := <-received:
+		t.Fatalf("Unexpected webhook for non-matching output: %+v", event)
+	default:
+	}
+
+	dispatcher.Dispatch(context.Background(), job, "build FAILED", models.JobStatusFailed, 1)
+	dispatcher.Wait()
+	select {
+	case event := <-received:
+		if event.JobID != "job-2" || event.Status != models.JobStatusFailed || event.ExitCode != 1 {
+			t.Errorf("Unexpected webhook payload: %+v", event)
+		}
+	default:
+		t.Fatal("Expected webhook to be called for matching output")
+	}
+}
+
+func TestOutputRuleDispatcher_SlowActionDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	dispatcher := NewOutputRuleDispatcher()
+	dispatcher.AddListener(func(event JobOutputEvent) {
+		<-release
+	})
+
+	job := &models.Job{
+		ID: "job-3",
+		OutputRules: outputRulesJSON(t, []models.OutputRule{
+			{OnOutputMatches: `FAIL(ED)?`, Action: models.OutputRuleAction{Type: models.OutputRuleActionEvent, Event: "failed"}},
+		}),
+	}
+
+	dispatched := make(chan struct{})
+	go func() {
+		dispatcher.Dispatch(context.Background(), job, "build FAILED", models.JobStatusFailed, 1)
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected Dispatch to return without waiting for the listener")
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		dispatcher.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("Expected Wait to block until the listener returns")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-waited
+}
+
+func TestValidateOutputRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []models.OutputRule
+		wantErr bool
+	}{
+		{"no rules", nil, false},
+		{"valid event rule", []models.OutputRule{{OnOutputContains: "x", Action: models.OutputRuleAction{Type: "event", Event: "e"}}}, false},
+		{"missing matcher", []models.OutputRule{{Action: models.OutputRuleAction{Type: "event", Event: "e"}}}, true},
+		{"invalid regex", []models.OutputRule{{OnOutputMatches: "(", Action: models.OutputRuleAction{Type: "event", Event: "e"}}}, true},
+		{"invalid webhook url", []models.OutputRule{{OnOutputContains: "x", Action: models.OutputRuleAction{Type: "webhook", WebhookURL: "ftp://x"}}}, true},
+		{"unknown action", []models.OutputRule{{OnOutputContains: "x", Action: models.OutputRuleAction{Type: "email"}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateOutputRules(tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateOutputRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
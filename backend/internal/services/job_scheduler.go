@@ -28,8 +28,18 @@ type JobScheduler struct {
 	// Last known reset time to detect window changes
 	lastResetTime *time.Time
 	resetMutex    sync.RWMutex
+	
+	// Run guards for identical jobs (same project and command)
+	skipOverlappingRuns bool
+	minRunInterval      time.Duration
+	
+	// Maximum number of due delayed, scheduled and after_reset jobs queued per tick
+	batchSize int
 }
 
+// DEFAULT_SCHEDULER_BATCH_SIZE is how many due scheduled jobs are queued per tick by default
+const DEFAULT_SCHEDULER_BATCH_SIZE = 10
+
 // NewJobScheduler creates a new job scheduler
 func NewJobScheduler(db *sql.DB, jobService *JobService, jobExecutor *JobExecutor, windowService *SessionWindowService, pollingInterval time.Duration) *JobScheduler {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -40,11 +50,28 @@ func NewJobScheduler(db *sql.DB, jobService *JobService, jobExecutor *JobExecuto
 		windowService:   windowService,
 		db:              db,
 		pollingInterval: pollingInterval,
+		batchSize:       DEFAULT_SCHEDULER_BATCH_SIZE,
 		ctx:             ctx,
 		cancel:          cancel,
 	}
 }
 
+// SetBatchSize sets how many due scheduled jobs are queued per tick at most,
+// so a large backlog does not flood the executor
+func (js *JobScheduler) SetBatchSize(batchSize int) {
+	if batchSize > 0 {
+		js.batchSize = batchSize
+	}
+}
+
+// SetRunGuards configures how identical jobs (same project and command) are throttled.
+// When skipOverlapping is set, a job is not queued while an identical job is running.
+// A non-zero minInterval defers a job until that long after the last identical job started.
+func (js *JobScheduler) SetRunGuards(skipOverlapping bool, minInterval time.Duration) {
+	js.skipOverlappingRuns = skipOverlapping
+	js.minRunInterval = minInterval
+}
+
 // Start starts the scheduler
 func (js *JobScheduler) Start() {
 	log.Printf("Starting job scheduler with polling interval: %v", js.pollingInterval)
@@ -128,7 +155,8 @@ func (js *JobScheduler) checkAfterResetJobsWithRetry() error {
 	return fmt.Errorf("failed after %d retries: %w", maxRetries, lastErr)
 }
 
-// checkAfterResetJobs checks for jobs scheduled to run after reset
+// checkAfterResetJobs schedules after_reset jobs created while there was no upcoming reset
+// at the reset time of the active window. Once due, checkScheduledJobs queues them.
 func (js *JobScheduler) checkAfterResetJobs() error {
 	// Get current active window
 	activeWindow, err := js.windowService.GetActiveWindow()
@@ -136,8 +164,8 @@ func (js *JobScheduler) checkAfterResetJobs() error {
 		return fmt.Errorf("failed to get active window: %w", err)
 	}
 	
-	if activeWindow == nil {
-		return nil // No active window
+	if activeWindow == nil || !activeWindow.ResetTime.After(time.Now()) {
+		return nil // No upcoming reset; check again on the next tick
 	}
 	
 	// Check if reset time has changed
@@ -146,43 +174,20 @@ func (js *JobScheduler) checkAfterResetJobs() error {
 	js.resetMutex.RUnlock()
 	
 	if lastReset == nil || !activeWindow.ResetTime.Equal(*lastReset) {
-		// Reset time has changed, execute after_reset jobs
 		log.Printf("SessionWindow reset detected. New reset time: %v", activeWindow.ResetTime)
 		
 		// Update last reset time
 		js.resetMutex.Lock()
 		js.lastResetTime = &activeWindow.ResetTime
 		js.resetMutex.Unlock()
-		
-		// Get all pending after_reset jobs
-		query := `
-			SELECT id FROM jobs 
-			WHERE status = ? AND schedule_type = ?
-			ORDER BY priority DESC, CAST(created_at AS TIMESTAMP) ASC`
-		
-		rows, err := js.db.Query(query, models.JobStatusPending, models.ScheduleTypeAfterReset)
-		if err != nil {
-			return fmt.Errorf("failed to query after_reset jobs: %w", err)
-		}
-		defer rows.Close()
-		
-		var jobIDs []string
-		for rows.Next() {
-			var jobID string
-			if err := rows.Scan(&jobID); err != nil {
-				return fmt.Errorf("failed to scan job ID: %w", err)
-			}
-			jobIDs = append(jobIDs, jobID)
-		}
-		
-		// Queue jobs for execution
-		for _, jobID := range jobIDs {
-			if err := js.jobExecutor.QueueJob(jobID); err != nil {
-				log.Printf("Failed to queue after_reset job %s: %v", jobID, err)
-			} else {
-				log.Printf("Queued after_reset job %s for execution", jobID)
-			}
-		}
+	}
+	
+	scheduled, err := js.jobService.ScheduleAfterResetJobs(activeWindow.ResetTime)
+	if err != nil {
+		return err
+	}
+	if scheduled > 0 {
+		log.Printf("Scheduled %d after_reset jobs at %v", scheduled, activeWindow.ResetTime)
 	}
 	
 	return nil
@@ -219,56 +224,90 @@ func (js *JobScheduler) checkScheduledJobsWithRetry() error {
 
 // checkScheduledJobs checks for delayed and scheduled jobs
 func (js *JobScheduler) checkScheduledJobs() error {
-	now := time.Now().UTC()
-	
+	// Queue no more than the executor has room for; the rest waits for later ticks
+	limit := js.batchSize
+	if capacity := js.jobExecutor.QueueCapacity(); capacity < limit {
+		limit = capacity
+	}
+	if limit <= 0 {
+		log.Printf("Job queue is full, deferring scheduled jobs to the next check")
+		return nil
+	}
 	
-	// Get all pending jobs with scheduled_at <= now
-	query := `
-		SELECT id, schedule_type, schedule_params, scheduled_at 
-		FROM jobs 
-		WHERE status = ? 
-		AND scheduled_at IS NOT NULL 
-		AND scheduled_at <= ?
-		AND schedule_type IN (?, ?)
-		ORDER BY priority DESC, CAST(scheduled_at AS TIMESTAMP) ASC`
-	
-	rows, err := js.db.Query(query, 
-		models.JobStatusPending, 
-		now.Format(time.RFC3339),
-		models.ScheduleTypeDelayed,
-		models.ScheduleTypeScheduled)
-	if err != nil {
-		return fmt.Errorf("failed to query scheduled jobs: %w", err)
+	// Deferred jobs don't count towards the batch, so keep reading due jobs until it is
+	// filled; otherwise deferred high-priority jobs would hold back every other job
+	queued := 0
+	for offset := 0; queued < limit; offset += limit {
+		jobs, err := js.jobService.GetScheduledJobs(limit, offset)
+		if err != nil {
+			return err
+		}
+		
+		// Queue jobs for execution
+		for _, job := range jobs {
+			if queued >= limit {
+				break
+			}
+			if skip, reason, err := js.shouldSkipRun(job.ID); err != nil {
+				log.Printf("Failed to check run guards for job %s: %v", job.ID, err)
+			} else if skip {
+				// The job stays pending and is checked again on the next tick
+				log.Printf("Deferring %s job %s: %s", *job.ScheduleType, job.ID, reason)
+				continue
+			}
+			
+			// The executor rejects every job once it is full, paused or stopping
+			if err := js.jobExecutor.QueueJob(job.ID); err != nil {
+				log.Printf("Failed to queue scheduled job %s: %v", job.ID, err)
+				return nil
+			}
+			log.Printf("Queued %s job %s for execution", *job.ScheduleType, job.ID)
+			queued++
+		}
+		
+		if len(jobs) < limit {
+			break
+		}
 	}
-	defer rows.Close()
 	
-	type scheduledJob struct {
-		ID             string
-		ScheduleType   string
-		ScheduleParams *string
-		ScheduledAt    string
+	return nil
+}
+
+// shouldSkipRun reports whether a job must not start yet because of an identical job
+func (js *JobScheduler) shouldSkipRun(jobID string) (bool, string, error) {
+	if !js.skipOverlappingRuns && js.minRunInterval <= 0 {
+		return false, "", nil
 	}
 	
-	var jobs []scheduledJob
-	for rows.Next() {
-		var job scheduledJob
-		if err := rows.Scan(&job.ID, &job.ScheduleType, &job.ScheduleParams, &job.ScheduledAt); err != nil {
-			return fmt.Errorf("failed to scan scheduled job: %w", err)
-		}
-		jobs = append(jobs, job)
+	query := `
+		SELECT 
+			COUNT(*) FILTER (WHERE other.status = ?) as running_count,
+			MAX(other.started_at) as last_started_at
+		FROM jobs j
+		JOIN jobs other ON other.project_id = j.project_id 
+			AND other.command = j.command 
+			AND other.id != j.id
+		WHERE j.id = ?`
+	
+	var runningCount int
+	var lastStartedAt sql.NullString
+	if err := js.db.QueryRow(query, models.JobStatusRunning, jobID).Scan(&runningCount, &lastStartedAt); err != nil {
+		return false, "", fmt.Errorf("failed to query identical jobs: %w", err)
 	}
 	
+	if js.skipOverlappingRuns && runningCount > 0 {
+		return true, "previous identical job is still running", nil
+	}
 	
-	// Queue jobs for execution
-	for _, job := range jobs {
-		if err := js.jobExecutor.QueueJob(job.ID); err != nil {
-			log.Printf("Failed to queue scheduled job %s: %v", job.ID, err)
-		} else {
-			log.Printf("Queued %s job %s for execution", job.ScheduleType, job.ID)
+	if js.minRunInterval > 0 && lastStartedAt.Valid {
+		// started_at is stored as UTC RFC3339 so MAX picks the latest start
+		lastStart, err := time.Parse(time.RFC3339, lastStartedAt.String)
+		if err == nil && time.Since(lastStart) < js.minRunInterval {
+			return true, fmt.Sprintf("identical job started %v ago (minimum interval %v)", time.Since(lastStart).Round(time.Second), js.minRunInterval), nil
 		}
 	}
 	
-	return nil
+	return false, "", nil
 }
 
 // GetSchedulerStatus returns the current scheduler status
@@ -286,6 +325,9 @@ func (js *JobScheduler) GetSchedulerStatus() map[string]interface{} {
 		status["last_reset_time"] = lastReset.Format(time.RFC3339)
 	}
 	
+	status["skip_overlapping_runs"] = js.skipOverlappingRuns
+	status["min_run_interval"] = js.minRunInterval.String()
+	
 	return status
 }
 
@@ -2,6 +2,7 @@ package services
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -244,4 +245,217 @@ func TestJobScheduler_GetSchedulerStatus(t *testing.T) {
 	status = scheduler.GetSchedulerStatus()
 	assert.True(t, status["running"].(bool))
 	assert.NotEmpty(t, status["last_check"])
-}
\ No newline at end of file
+}
+func TestJobScheduler_SkipOverlappingRuns(t *testing.T) {
+	// Job columns are TEXT as in the production schema
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	// Executor is not started so queued jobs stay in the queue
+	jobService := NewJobService(db)
+	jobExecutor := NewJobExecutor(jobService, 1)
+
+	windowService := &SessionWindowService{db: db}
+	scheduler := NewJobScheduler(db, jobService, jobExecutor, windowService, 1*time.Minute)
+	scheduler.SetRunGuards(true, 0)
+
+	projectID := "test-project"
+
+	// Previous instance of the same command is still running
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := db.Exec(`
+		INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, started_at, scheduled_at, schedule_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		"overlap-running", projectID, "echo 'nightly'", "/test/overlap", models.JobStatusRunning, now, now, now, models.ScheduleTypeScheduled)
+	require.NoError(t, err)
+
+	pastTime := time.Now().Add(-1 * time.Minute).UTC().Format(time.RFC3339)
+	_, err = db.Exec(`
+		INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, scheduled_at, schedule_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		"overlap-next", projectID, "echo 'nightly'", "/test/overlap", models.JobStatusPending, now, pastTime, models.ScheduleTypeScheduled)
+	require.NoError(t, err)
+
+	err = scheduler.checkScheduledJobs()
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(jobExecutor.jobQueue), "job must not start while the identical job runs")
+
+	// Once the previous instance finishes, the job is queued on the next check
+	_, err = db.Exec(`UPDATE jobs SET status = ? WHERE id = ?`, models.JobStatusCompleted, "overlap-running")
+	require.NoError(t, err)
+
+	err = scheduler.checkScheduledJobs()
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(jobExecutor.jobQueue))
+}
+
+func TestJobScheduler_MinRunInterval(t *testing.T) {
+	// Job columns are TEXT as in the production schema
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	jobService := NewJobService(db)
+	jobExecutor := NewJobExecutor(jobService, 1)
+
+	windowService := &SessionWindowService{db: db}
+	scheduler := NewJobScheduler(db, jobService, jobExecutor, windowService, 1*time.Minute)
+	scheduler.SetRunGuards(false, time.Hour)
+
+	projectID := "test-project"
+
+	// Identical job started 10 minutes ago and already completed
+	startedAt := time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339)
+	_, err := db.Exec(`
+		INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, started_at, scheduled_at, schedule_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		"interval-done", projectID, "echo 'hourly'", "/test/interval", models.JobStatusCompleted, startedAt, startedAt, startedAt, models.ScheduleTypeScheduled)
+	require.NoError(t, err)
+
+	pastTime := time.Now().Add(-1 * time.Minute).UTC().Format(time.RFC3339)
+	_, err = db.Exec(`
+		INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, scheduled_at, schedule_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		"interval-next", projectID, "echo 'hourly'", "/test/interval", models.JobStatusPending, pastTime, pastTime, models.ScheduleTypeScheduled)
+	require.NoError(t, err)
+
+	err = scheduler.checkScheduledJobs()
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(jobExecutor.jobQueue), "job must wait for the minimum interval")
+
+	scheduler.SetRunGuards(false, 5*time.Minute)
+	err = scheduler.checkScheduledJobs()
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(jobExecutor.jobQueue))
+}
+
+func TestJobScheduler_BatchSize(t *testing.T) {
+	// Job columns are TEXT as in the production schema
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	// Executor is not started so queued jobs stay in the queue
+	jobService := NewJobService(db)
+	jobExecutor := NewJobExecutor(jobService, 1)
+
+	windowService := &SessionWindowService{db: db}
+	scheduler := NewJobScheduler(db, jobService, jobExecutor, windowService, 1*time.Minute)
+	scheduler.SetBatchSize(10)
+
+	now := time.Now().UTC()
+	for i := 0; i < 100; i++ {
+		scheduledAt := now.Add(-time.Duration(100-i) * time.Minute).Format(time.RFC3339)
+		_, err := db.Exec(`
+			INSERT INTO jobs (id, project_id, command, execution_directory, status, priority, created_at, scheduled_at, schedule_type)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			fmt.Sprintf("batch-%03d", i), "test-project", "echo 'batch'", "/test/batch", models.JobStatusPending,
+			i%2, now.Format(time.RFC3339), scheduledAt, models.ScheduleTypeScheduled)
+		require.NoError(t, err)
+	}
+
+	err := scheduler.checkScheduledJobs()
+	require.NoError(t, err)
+	require.Equal(t, 10, len(jobExecutor.jobQueue))
+
+	// Higher priority first, then the earliest scheduled
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, fmt.Sprintf("batch-%03d", 2*i+1), <-jobExecutor.jobQueue)
+	}
+
+	// Only as many jobs as the queue has room for are queued
+	for len(jobExecutor.jobQueue) < cap(jobExecutor.jobQueue)-3 {
+		jobExecutor.jobQueue <- "filler"
+	}
+	err = scheduler.checkScheduledJobs()
+	require.NoError(t, err)
+	assert.Equal(t, cap(jobExecutor.jobQueue), len(jobExecutor.jobQueue))
+}
+
+func TestJobScheduler_DeferredJobsDoNotFillBatch(t *testing.T) {
+	// Job columns are TEXT as in the production schema
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	jobService := NewJobService(db)
+	jobExecutor := NewJobExecutor(jobService, 1)
+
+	windowService := &SessionWindowService{db: db}
+	scheduler := NewJobScheduler(db, jobService, jobExecutor, windowService, 1*time.Minute)
+	scheduler.SetBatchSize(2)
+	scheduler.SetRunGuards(true, 0)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	pastTime := time.Now().Add(-1 * time.Minute).UTC().Format(time.RFC3339)
+	_, err := db.Exec(`
+		INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, started_at, scheduled_at, schedule_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		"nightly-running", "test-project", "echo 'nightly'", "/test/nightly", models.JobStatusRunning, now, now, now, models.ScheduleTypeScheduled)
+	require.NoError(t, err)
+
+	// High-priority jobs deferred behind the running one, and low-priority jobs that can run
+	jobs := []struct {
+		id, command string
+		priority    int
+	}{
+		{"nightly-1", "echo 'nightly'", 5},
+		{"nightly-2", "echo 'nightly'", 5},
+		{"nightly-3", "echo 'nightly'", 5},
+		{"other-1", "echo 'other 1'", 0},
+		{"other-2", "echo 'other 2'", 0},
+	}
+	for _, job := range jobs {
+		_, err := db.Exec(`
+			INSERT INTO jobs (id, project_id, command, execution_directory, status, priority, created_at, scheduled_at, schedule_type)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			job.id, "test-project", job.command, "/test/nightly", models.JobStatusPending, job.priority, now, pastTime, models.ScheduleTypeScheduled)
+		require.NoError(t, err)
+	}
+
+	err = scheduler.checkScheduledJobs()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(jobExecutor.jobQueue))
+	assert.Equal(t, "other-1", <-jobExecutor.jobQueue)
+	assert.Equal(t, "other-2", <-jobExecutor.jobQueue)
+}
+
+func TestJobScheduler_AfterResetJobsRunAtResetTime(t *testing.T) {
+	// Job columns are TEXT as in the production schema
+	db := setupJobExecutorTestDB(t)
+	defer db.Close()
+
+	// Executor is not started so queued jobs stay in the queue
+	jobService := NewJobService(db)
+	jobExecutor := NewJobExecutor(jobService, 1)
+
+	windowService := NewSessionWindowService(db)
+	scheduler := NewJobScheduler(db, jobService, jobExecutor, windowService, 1*time.Minute)
+
+	// Created while there was no active window
+	now := time.Now().UTC()
+	_, err := db.Exec(`
+		INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		"after-reset-waiting", "test-project", "echo 'after reset'", "/test/reset", models.JobStatusPending,
+		now.Format(time.RFC3339), models.ScheduleTypeAfterReset)
+	require.NoError(t, err)
+
+	resetTime := now.Add(2 * time.Hour).Truncate(time.Hour)
+	addTestSessionWindow(t, db, "active-window", resetTime.Add(-5*time.Hour), resetTime)
+
+	require.NoError(t, scheduler.checkAfterResetJobs())
+	job, err := jobService.GetJobByID("after-reset-waiting")
+	require.NoError(t, err)
+	require.NotNil(t, job.ScheduledAt)
+	assert.True(t, job.ScheduledAt.Equal(resetTime), "expected %v, got %v", resetTime, job.ScheduledAt)
+
+	// Not due before the reset
+	require.NoError(t, scheduler.checkScheduledJobs())
+	assert.Equal(t, 0, len(jobExecutor.jobQueue))
+
+	// Due once the reset has passed
+	_, err = db.Exec(`UPDATE jobs SET scheduled_at = ? WHERE id = ?`,
+		now.Add(-1*time.Minute).Format(time.RFC3339), "after-reset-waiting")
+	require.NoError(t, err)
+	require.NoError(t, scheduler.checkScheduledJobs())
+	require.Equal(t, 1, len(jobExecutor.jobQueue))
+	assert.Equal(t, "after-reset-waiting", <-jobExecutor.jobQueue)
+}
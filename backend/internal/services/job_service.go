@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 	
 	"ccdash-backend/internal/models"
@@ -11,27 +13,26 @@ import (
 )
 
 type JobService struct {
-	db *sql.DB
+	db             *sql.DB
+	projectService *ProjectService
+	windowService  *SessionWindowService // Resolves the reset time after_reset jobs run at
+	locks          *jobLocks              // Serializes updates of the same job
 }
 
 func NewJobService(db *sql.DB) *JobService {
-	return &JobService{db: db}
+	return &JobService{
+		db:             db,
+		projectService: NewProjectService(db),
+		windowService:  NewSessionWindowService(db),
+		locks:          jobLocksFor(db),
+	}
 }
 
 // CreateJob creates a new job
 func (js *JobService) CreateJob(req *models.CreateJobRequest) (*models.Job, error) {
-	// プロジェクトの存在確認
-	project, err := js.getProjectByID(req.ProjectID)
+	project, err := js.validateCreateJobRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get project: %w", err)
-	}
-	if project == nil {
-		return nil, fmt.Errorf("project not found: %s", req.ProjectID)
-	}
-	
-	// スケジュールパラメータの検証
-	if err := js.validateScheduleParams(req.ScheduleType, req.ScheduleParams); err != nil {
-		return nil, fmt.Errorf("invalid schedule parameters: %w", err)
+		return nil, err
 	}
 	
 	job := &models.Job{
@@ -45,24 +46,46 @@ func (js *JobService) CreateJob(req *models.CreateJobRequest) (*models.Job, erro
 		CreatedAt:         time.Now(),
 		ScheduleType:      &req.ScheduleType,
 	}
+	if req.WebhookURL != "" {
+		job.WebhookURL = &req.WebhookURL
+	}
 	
-	// スケジュールタイプに応じてscheduled_atを設定
-	switch req.ScheduleType {
-	case models.ScheduleTypeImmediate:
-		// immediateタイプはscheduled_atをnullにする（スケジューラーで処理しないため）
-		job.ScheduledAt = nil
-	case models.ScheduleTypeAfterReset:
-		// after_resetタイプもscheduled_atをnullにする（リセット時に処理するため）
-		job.ScheduledAt = nil
-	case models.ScheduleTypeDelayed:
-		if req.ScheduleParams != nil && req.ScheduleParams.DelayHours != nil {
-			scheduledTime := time.Now().Add(time.Duration(*req.ScheduleParams.DelayHours) * time.Hour)
-			job.ScheduledAt = &scheduledTime
+	// リソース制限をJSON文字列に変換
+	if req.ResourceLimits != nil {
+		limitsBytes, err := json.Marshal(req.ResourceLimits)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal resource limits: %w", err)
 		}
-	case models.ScheduleTypeScheduled:
-		if req.ScheduleParams != nil && req.ScheduleParams.ScheduledTime != nil {
-			job.ScheduledAt = req.ScheduleParams.ScheduledTime
+		limitsStr := string(limitsBytes)
+		job.ResourceLimits = &limitsStr
+	}
+	
+	// 追加の環境変数をJSON文字列に変換
+	if len(req.Env) > 0 {
+		envBytes, err := json.Marshal(req.Env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal env: %w", err)
 		}
+		envStr := string(envBytes)
+		job.Env = &envStr
+	}
+	
+	// スケジュールタイプに応じてscheduled_atを設定
+	job.ScheduledAt = computeScheduledAt(req, job.CreatedAt)
+	if req.ScheduleType == models.ScheduleTypeAfterReset {
+		job.ScheduledAt = js.afterResetScheduledAt()
+	}
+	
+	// OutputRulesをJSON文字列に変換
+	var outputRulesJSON *string
+	if len(req.OutputRules) > 0 {
+		rulesBytes, err := json.Marshal(req.OutputRules)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal output rules: %w", err)
+		}
+		rulesStr := string(rulesBytes)
+		outputRulesJSON = &rulesStr
+		job.OutputRules = outputRulesJSON
 	}
 	
 	// ScheduleParamsをJSON文字列に変換
@@ -80,13 +103,13 @@ func (js *JobService) CreateJob(req *models.CreateJobRequest) (*models.Job, erro
 	query := `
 		INSERT INTO jobs (
 			id, project_id, command, execution_directory, yolo_mode, 
-			status, priority, created_at, scheduled_at, schedule_type, schedule_params
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			status, priority, created_at, scheduled_at, schedule_type, schedule_params, output_rules, webhook_url, resource_limits, env
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 	
 	_, err = js.db.Exec(query,
 		job.ID, job.ProjectID, job.Command, job.ExecutionDirectory,
 		job.YoloMode, job.Status, job.Priority, job.CreatedAt.UTC().Format(time.RFC3339),
-		formatTimePtr(job.ScheduledAt), job.ScheduleType, scheduleParamsJSON)
+		formatTimePtr(job.ScheduledAt), job.ScheduleType, scheduleParamsJSON, outputRulesJSON, job.WebhookURL, job.ResourceLimits, job.Env)
 	
 	if err != nil {
 		return nil, fmt.Errorf("failed to create job: %w", err)
@@ -95,13 +118,185 @@ func (js *JobService) CreateJob(req *models.CreateJobRequest) (*models.Job, erro
 	return job, nil
 }
 
+// CloneJob creates a new pending immediate job running the same command as an existing one,
+// with its project, directory, yolo mode, output rules, webhook, resource limits and env.
+// Logs, exit code, PID, timestamps and schedule are not copied; the original job is untouched.
+func (js *JobService) CloneJob(jobID string) (*models.Job, error) {
+	original, err := js.GetJobByID(jobID)
+	if err != nil {
+		return nil, err
+	}
+	if original == nil {
+		return nil, fmt.Errorf("job not found: %s", jobID)
+	}
+	
+	scheduleType := models.ScheduleTypeImmediate
+	job := &models.Job{
+		ID:                 uuid.New().String(),
+		ProjectID:          original.ProjectID,
+		Command:            original.Command,
+		ExecutionDirectory: original.ExecutionDirectory,
+		YoloMode:           original.YoloMode,
+		Status:             models.JobStatusPending,
+		Priority:           original.Priority,
+		CreatedAt:          time.Now(),
+		ScheduleType:       &scheduleType,
+		OutputRules:        original.OutputRules,
+		WebhookURL:         original.WebhookURL,
+		ResourceLimits:     original.ResourceLimits,
+		Env:                original.Env,
+		Project:            original.Project,
+	}
+	
+	query := `
+		INSERT INTO jobs (
+			id, project_id, command, execution_directory, yolo_mode, 
+			status, priority, created_at, schedule_type, output_rules, webhook_url, resource_limits, env
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	
+	_, err = js.db.Exec(query,
+		job.ID, job.ProjectID, job.Command, job.ExecutionDirectory,
+		job.YoloMode, job.Status, job.Priority, job.CreatedAt.UTC().Format(time.RFC3339),
+		job.ScheduleType, job.OutputRules, job.WebhookURL, job.ResourceLimits, job.Env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone job: %w", err)
+	}
+	
+	return job, nil
+}
+
+// PreviewSchedule validates a job request exactly like CreateJob and returns when it
+// would be scheduled, without creating the job
+func (js *JobService) PreviewSchedule(req *models.CreateJobRequest) (*models.SchedulePreview, error) {
+	if _, err := js.validateCreateJobRequest(req); err != nil {
+		return nil, err
+	}
+	
+	preview := &models.SchedulePreview{
+		ScheduleType: req.ScheduleType,
+		ScheduledAt:  computeScheduledAt(req, time.Now()),
+	}
+	if req.ScheduleType == models.ScheduleTypeAfterReset {
+		preview.ScheduledAt = js.afterResetScheduledAt()
+	}
+	if preview.ScheduledAt != nil {
+		// scheduled_atは秒精度のUTCで保存される
+		storedAt := preview.ScheduledAt.UTC().Truncate(time.Second)
+		preview.ScheduledAt = &storedAt
+	}
+	return preview, nil
+}
+
+// validateCreateJobRequest checks a job request and returns the project the job runs in
+func (js *JobService) validateCreateJobRequest(req *models.CreateJobRequest) (*models.Project, error) {
+	// プロジェクトの存在確認
+	project, err := js.getProjectByID(req.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project: %w", err)
+	}
+	if project == nil {
+		return nil, fmt.Errorf("project not found: %s", req.ProjectID)
+	}
+	
+	// スケジュールパラメータの検証
+	if err := js.validateScheduleParams(req.ScheduleType, req.ScheduleParams); err != nil {
+		return nil, fmt.Errorf("invalid schedule parameters: %w", err)
+	}
+	
+	// 出力ルールの検証
+	if err := ValidateOutputRules(req.OutputRules); err != nil {
+		return nil, fmt.Errorf("invalid output rules: %w", err)
+	}
+	
+	// 完了通知Webhookの検証
+	if req.WebhookURL != "" && !strings.HasPrefix(req.WebhookURL, "http://") && !strings.HasPrefix(req.WebhookURL, "https://") {
+		return nil, fmt.Errorf("invalid webhook_url: must be an http or https URL")
+	}
+	
+	// リソース制限の検証
+	if req.ResourceLimits != nil && (req.ResourceLimits.MemoryMB < 0 || req.ResourceLimits.CPUSeconds < 0) {
+		return nil, fmt.Errorf("invalid resource_limits: values must not be negative")
+	}
+	
+	// 追加の環境変数の検証
+	if err := validateJobEnv(req.Env); err != nil {
+		return nil, fmt.Errorf("invalid env: %w", err)
+	}
+	
+	return project, nil
+}
+
+// computeScheduledAt returns the scheduled_at of a job requested at now.
+// immediate jobs have none as the executor picks them up; after_reset jobs are resolved
+// from the active window by afterResetScheduledAt.
+func computeScheduledAt(req *models.CreateJobRequest, now time.Time) *time.Time {
+	switch req.ScheduleType {
+	case models.ScheduleTypeDelayed:
+		if req.ScheduleParams != nil && req.ScheduleParams.DelayHours != nil {
+			scheduledTime := now.Add(time.Duration(*req.ScheduleParams.DelayHours) * time.Hour)
+			return &scheduledTime
+		}
+	case models.ScheduleTypeScheduled:
+		if req.ScheduleParams != nil && req.ScheduleParams.ScheduledTime != nil {
+			return req.ScheduleParams.ScheduledTime
+		}
+	}
+	return nil
+}
+
+// afterResetScheduledAt returns when an after_reset job runs: the reset time of the active window.
+// Without an upcoming reset it returns nil and the scheduler sets the time once a window exists.
+func (js *JobService) afterResetScheduledAt() *time.Time {
+	resetTime, err := js.windowService.GetUpcomingResetTime()
+	if err != nil {
+		slog.Warn("Failed to resolve reset time for after_reset job", "error", err)
+		return nil
+	}
+	return resetTime
+}
+
+// ScheduleAfterResetJobs sets the scheduled_at of pending after_reset jobs created while there
+// was no active window to resetTime. Each job goes through UpdateJob so the change is serialized
+// with other updates of the same job. Returns how many jobs were scheduled.
+func (js *JobService) ScheduleAfterResetJobs(resetTime time.Time) (int64, error) {
+	rows, err := js.db.Query(`
+		SELECT id FROM jobs
+		WHERE status = ? AND schedule_type = ? AND scheduled_at IS NULL`,
+		models.JobStatusPending, models.ScheduleTypeAfterReset)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get unscheduled after_reset jobs: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan after_reset job: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to get unscheduled after_reset jobs: %w", err)
+	}
+
+	var scheduled int64
+	for _, id := range ids {
+		if err := js.UpdateJob(id, models.JobUpdate{ScheduledAt: &resetTime}); err != nil {
+			return scheduled, fmt.Errorf("failed to schedule after_reset job %s: %w", id, err)
+		}
+		scheduled++
+	}
+	return scheduled, nil
+}
+
 // GetJob retrieves a single job by ID
 func (js *JobService) GetJob(jobID string) (*models.Job, error) {
 	query := `
 		SELECT j.id, j.project_id, j.command, j.execution_directory, j.yolo_mode,
 			   j.status, j.priority, j.created_at, j.started_at, j.completed_at,
 			   j.output_log, j.error_log, j.exit_code, j.pid,
-			   j.scheduled_at, j.schedule_type, j.schedule_params,
+			   j.scheduled_at, j.schedule_type, j.schedule_params, j.output_rules, j.webhook_url, j.resource_limits, j.env,
 			   p.name as project_name, p.path as project_path
 		FROM jobs j
 		LEFT JOIN projects p ON j.project_id = p.id
@@ -126,7 +321,7 @@ func (js *JobService) GetJobs(filters models.JobFilters) ([]*models.Job, error)
 		SELECT j.id, j.project_id, j.command, j.execution_directory, j.yolo_mode,
 			   j.status, j.priority, j.created_at, j.started_at, j.completed_at,
 			   j.output_log, j.error_log, j.exit_code, j.pid,
-			   j.scheduled_at, j.schedule_type, j.schedule_params,
+			   j.scheduled_at, j.schedule_type, j.schedule_params, j.output_rules, j.webhook_url, j.resource_limits, j.env,
 			   p.name as project_name, p.path as project_path
 		FROM jobs j
 		LEFT JOIN projects p ON j.project_id = p.id
@@ -144,6 +339,22 @@ func (js *JobService) GetJobs(filters models.JobFilters) ([]*models.Job, error)
 		args = append(args, *filters.Status)
 	}
 	
+	if filters.CommandContains != nil {
+		query += ` AND j.command ILIKE ? ESCAPE '\'`
+		args = append(args, likeContainsPattern(*filters.CommandContains))
+	}
+	
+	// Job timestamps are stored as RFC3339 text
+	if filters.CreatedAfter != nil {
+		query += " AND CAST(j.created_at AS TIMESTAMP) >= CAST(? AS TIMESTAMP)"
+		args = append(args, filters.CreatedAfter.UTC().Format(time.RFC3339))
+	}
+	
+	if filters.CreatedBefore != nil {
+		query += " AND CAST(j.created_at AS TIMESTAMP) < CAST(? AS TIMESTAMP)"
+		args = append(args, filters.CreatedBefore.UTC().Format(time.RFC3339))
+	}
+	
 	query += " ORDER BY j.priority DESC, j.created_at DESC"
 	
 	if filters.Limit > 0 {
@@ -183,7 +394,7 @@ func (js *JobService) GetJobByID(id string) (*models.Job, error) {
 		SELECT j.id, j.project_id, j.command, j.execution_directory, j.yolo_mode,
 			   j.status, j.priority, j.created_at, j.started_at, j.completed_at,
 			   j.output_log, j.error_log, j.exit_code, j.pid,
-			   j.scheduled_at, j.schedule_type, j.schedule_params,
+			   j.scheduled_at, j.schedule_type, j.schedule_params, j.output_rules, j.webhook_url, j.resource_limits, j.env,
 			   p.name as project_name, p.path as project_path
 		FROM jobs j
 		LEFT JOIN projects p ON j.project_id = p.id
@@ -203,10 +414,12 @@ func (js *JobService) GetJobByID(id string) (*models.Job, error) {
 	return job, nil
 }
 
-// UpdateJobStatus updates job status and related fields
-// Note: Using DELETE+INSERT workaround due to DuckDB UPDATE constraint bug
+// UpdateJobStatus updates job status and related fields.
+// started_at is set when a job first starts running; terminal statuses set completed_at and clear the PID.
 func (js *JobService) UpdateJobStatus(id string, status string, pid *int) error {
-	// First, get the current job data
+	unlock := js.locks.lock(id)
+	defer unlock()
+
 	job, err := js.GetJobByID(id)
 	if err != nil {
 		return fmt.Errorf("failed to get job for update: %w", err)
@@ -216,125 +429,236 @@ func (js *JobService) UpdateJobStatus(id string, status string, pid *int) error
 	}
 
 	now := time.Now().UTC()
+	update := models.JobUpdate{Status: &status, PID: pid, ClearPID: pid == nil}
 
-	// Delete the existing job record
-	_, err = js.db.Exec("DELETE FROM jobs WHERE id = ?", id)
-	if err != nil {
-		return fmt.Errorf("failed to delete job for update: %w", err)
+	if status == models.JobStatusRunning && job.StartedAt == nil {
+		update.StartedAt = &now
+	} else if status == models.JobStatusCompleted || status == models.JobStatusFailed || status == models.JobStatusCancelled {
+		update.CompletedAt = &now
+		update.PID = nil // Clear PID when job completes
+		update.ClearPID = true
 	}
 
-	// Prepare the new values
-	var pidValue interface{}
-	if pid != nil {
-		pidValue = *pid
-	}
+	return js.updateJob(id, update)
+}
 
-	var startedAt interface{}
-	var completedAt interface{}
+// UpdateJob changes only the columns set in update with a targeted UPDATE.
+// DuckDB 1.1 still rejects UPDATEs of indexed columns (jobs.status is indexed) with a
+// spurious primary key violation, so in that case the row is rewritten with DELETE+INSERT instead.
+// Updates of the same job are serialized so a rewrite cannot drop a concurrent update.
+func (js *JobService) UpdateJob(id string, update models.JobUpdate) error {
+	unlock := js.locks.lock(id)
+	defer unlock()
 
-	// Handle timestamps - convert *time.Time to string or nil
-	if job.StartedAt != nil {
-		startedAt = job.StartedAt.UTC().Format(time.RFC3339)
-	} else {
-		startedAt = nil
-	}
+	return js.updateJob(id, update)
+}
 
-	if job.CompletedAt != nil {
-		completedAt = job.CompletedAt.UTC().Format(time.RFC3339)
-	} else {
-		completedAt = nil
-	}
+// updateJob applies update; the caller must hold the job's lock
+func (js *JobService) updateJob(id string, update models.JobUpdate) error {
+	var sets []string
+	var args []interface{}
 
-	// Update timestamps based on status
-	if status == models.JobStatusRunning && job.StartedAt == nil {
-		startedAt = now.Format(time.RFC3339)
-	} else if status == models.JobStatusCompleted || status == models.JobStatusFailed || status == models.JobStatusCancelled {
-		completedAt = now.Format(time.RFC3339)
-		pidValue = nil // Clear PID when job completes
+	if update.Status != nil {
+		sets = append(sets, "status = ?")
+		args = append(args, *update.Status)
+	}
+	if update.PID != nil {
+		sets = append(sets, "pid = ?")
+		args = append(args, *update.PID)
+	} else if update.ClearPID {
+		sets = append(sets, "pid = NULL")
+	}
+	if update.StartedAt != nil {
+		sets = append(sets, "started_at = ?")
+		args = append(args, update.StartedAt.UTC().Format(time.RFC3339))
+	}
+	if update.CompletedAt != nil {
+		sets = append(sets, "completed_at = ?")
+		args = append(args, update.CompletedAt.UTC().Format(time.RFC3339))
+	}
+	if update.ScheduledAt != nil {
+		sets = append(sets, "scheduled_at = ?")
+		args = append(args, update.ScheduledAt.UTC().Format(time.RFC3339))
+	}
+	if update.OutputLog != nil {
+		sets = append(sets, "output_log = ?")
+		args = append(args, *update.OutputLog)
+	}
+	if update.ErrorLog != nil {
+		sets = append(sets, "error_log = ?")
+		args = append(args, *update.ErrorLog)
+	}
+	if update.ExitCode != nil {
+		sets = append(sets, "exit_code = ?")
+		args = append(args, *update.ExitCode)
+	}
+	if len(sets) == 0 {
+		return nil
 	}
 
-	// Insert the updated job record
-	query := `INSERT INTO jobs (
-		id, project_id, command, execution_directory, yolo_mode, 
-		status, priority, created_at, started_at, completed_at, 
-		output_log, error_log, exit_code, pid, scheduled_at, schedule_type, schedule_params
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-	var scheduledAt interface{}
-	if job.ScheduledAt != nil {
-		scheduledAt = job.ScheduledAt.UTC().Format(time.RFC3339)
-	} else {
-		scheduledAt = nil
+	args = append(args, id)
+	result, err := js.db.Exec("UPDATE jobs SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...)
+	if err != nil {
+		if isIndexedUpdateConflict(err) {
+			return js.rewriteJob(id, update)
+		}
+		return fmt.Errorf("failed to update job: %w", err)
 	}
 
-	_, err = js.db.Exec(query,
-		job.ID, job.ProjectID, job.Command, job.ExecutionDirectory, job.YoloMode,
-		status, job.Priority, job.CreatedAt.UTC().Format(time.RFC3339), startedAt, completedAt,
-		job.OutputLog, job.ErrorLog, job.ExitCode, pidValue, scheduledAt, job.ScheduleType, job.ScheduleParams,
-	)
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to insert updated job: %w", err)
+		return fmt.Errorf("failed to check updated job: %w", err)
 	}
-	
+	if rows == 0 {
+		return fmt.Errorf("job not found: %s", id)
+	}
+
 	return nil
 }
 
-// UpdateJobLogs updates job output and error logs
-// Note: Using DELETE+INSERT workaround due to DuckDB UPDATE constraint bug
-func (js *JobService) UpdateJobLogs(id string, outputLog, errorLog *string, exitCode *int) error {
-	// First, get the current job data
+// isIndexedUpdateConflict reports whether err is DuckDB's false primary key violation
+// for an UPDATE that touches an indexed column
+func isIndexedUpdateConflict(err error) bool {
+	return strings.Contains(err.Error(), "violates primary key constraint")
+}
+
+// rewriteJob applies update by deleting the job row and inserting the changed copy.
+// This is the fallback for updates DuckDB cannot perform in place.
+func (js *JobService) rewriteJob(id string, update models.JobUpdate) error {
 	job, err := js.GetJobByID(id)
 	if err != nil {
-		return fmt.Errorf("failed to get job for log update: %w", err)
+		return fmt.Errorf("failed to get job for update: %w", err)
 	}
 	if job == nil {
 		return fmt.Errorf("job not found: %s", id)
 	}
 
+	if update.Status != nil {
+		job.Status = *update.Status
+	}
+	if update.PID != nil {
+		job.PID = update.PID
+	} else if update.ClearPID {
+		job.PID = nil
+	}
+	if update.StartedAt != nil {
+		job.StartedAt = update.StartedAt
+	}
+	if update.CompletedAt != nil {
+		job.CompletedAt = update.CompletedAt
+	}
+	if update.ScheduledAt != nil {
+		job.ScheduledAt = update.ScheduledAt
+	}
+	if update.OutputLog != nil {
+		job.OutputLog = update.OutputLog
+	}
+	if update.ErrorLog != nil {
+		job.ErrorLog = update.ErrorLog
+	}
+	if update.ExitCode != nil {
+		job.ExitCode = update.ExitCode
+	}
+
 	// Delete the existing job record
 	_, err = js.db.Exec("DELETE FROM jobs WHERE id = ?", id)
 	if err != nil {
-		return fmt.Errorf("failed to delete job for log update: %w", err)
+		return fmt.Errorf("failed to delete job for update: %w", err)
 	}
 
-	// Insert the updated job record with new logs
+	// Insert the updated job record
 	query := `INSERT INTO jobs (
 		id, project_id, command, execution_directory, yolo_mode, 
 		status, priority, created_at, started_at, completed_at, 
-		output_log, error_log, exit_code, pid, scheduled_at, schedule_type, schedule_params
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		output_log, error_log, exit_code, pid, scheduled_at, schedule_type, schedule_params, output_rules, webhook_url, resource_limits, env
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	var startedAtStr interface{}
-	if job.StartedAt != nil {
-		startedAtStr = job.StartedAt.UTC().Format(time.RFC3339)
-	} else {
-		startedAtStr = nil
+	_, err = js.db.Exec(query,
+		job.ID, job.ProjectID, job.Command, job.ExecutionDirectory, job.YoloMode,
+		job.Status, job.Priority, job.CreatedAt.UTC().Format(time.RFC3339), formatJobTime(job.StartedAt), formatJobTime(job.CompletedAt),
+		job.OutputLog, job.ErrorLog, job.ExitCode, job.PID, formatJobTime(job.ScheduledAt), job.ScheduleType, job.ScheduleParams, job.OutputRules, job.WebhookURL, job.ResourceLimits, job.Env,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert updated job: %w", err)
 	}
 
-	var completedAtStr interface{}
-	if job.CompletedAt != nil {
-		completedAtStr = job.CompletedAt.UTC().Format(time.RFC3339)
-	} else {
-		completedAtStr = nil
-	}
+	return nil
+}
 
-	var scheduledAtStr interface{}
-	if job.ScheduledAt != nil {
-		scheduledAtStr = job.ScheduledAt.UTC().Format(time.RFC3339)
-	} else {
-		scheduledAtStr = nil
+// formatJobTime converts an optional timestamp to an RFC3339 string, or nil
+func formatJobTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
 	}
+	return t.UTC().Format(time.RFC3339)
+}
 
-	_, err = js.db.Exec(query,
-		job.ID, job.ProjectID, job.Command, job.ExecutionDirectory, job.YoloMode,
-		job.Status, job.Priority, job.CreatedAt.UTC().Format(time.RFC3339), startedAtStr, completedAtStr,
-		outputLog, errorLog, exitCode, job.PID, scheduledAtStr, job.ScheduleType, job.ScheduleParams,
-	)
+// GetJobLogs returns a slice of a job's log lines without loading the rest of the job.
+// tail limits the number of lines (0 returns all) and offset skips lines from the end,
+// so successive pages walk backwards through the log. Returns nil if the job does not exist.
+func (js *JobService) GetJobLogs(id string, stream string, tail, offset int) (*models.JobLogs, error) {
+	if stream == "" {
+		stream = models.JobLogStreamBoth
+	}
+	if stream != models.JobLogStreamStdout && stream != models.JobLogStreamStderr && stream != models.JobLogStreamBoth {
+		return nil, fmt.Errorf("invalid log stream: %s", stream)
+	}
+	if tail < 0 || offset < 0 {
+		return nil, fmt.Errorf("tail and offset must not be negative")
+	}
+	
+	var outputLog, errorLog sql.NullString
+	err := js.db.QueryRow("SELECT output_log, error_log FROM jobs WHERE id = ?", id).Scan(&outputLog, &errorLog)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to insert job with updated logs: %w", err)
+		return nil, fmt.Errorf("failed to get job logs: %w", err)
 	}
 	
-	return nil
+	logs := &models.JobLogs{
+		JobID:  id,
+		Stream: stream,
+		Tail:   tail,
+		Offset: offset,
+	}
+	if stream != models.JobLogStreamStderr {
+		logs.Stdout = sliceLogLines(outputLog.String, tail, offset)
+	}
+	if stream != models.JobLogStreamStdout {
+		logs.Stderr = sliceLogLines(errorLog.String, tail, offset)
+	}
+	
+	return logs, nil
+}
+
+// sliceLogLines returns up to tail lines ending offset lines before the end of the log
+func sliceLogLines(content string, tail, offset int) *models.JobLogChunk {
+	lines := []string{}
+	if trimmed := strings.TrimSuffix(content, "\n"); trimmed != "" {
+		lines = strings.Split(trimmed, "\n")
+	}
+	
+	end := len(lines) - offset
+	if end < 0 {
+		end = 0
+	}
+	start := 0
+	if tail > 0 && end-tail > 0 {
+		start = end - tail
+	}
+	
+	return &models.JobLogChunk{
+		Lines:      lines[start:end],
+		TotalLines: len(lines),
+		StartLine:  start,
+	}
+}
+
+// UpdateJobLogs updates job output and error logs and the exit code.
+// nil values leave the corresponding column unchanged.
+func (js *JobService) UpdateJobLogs(id string, outputLog, errorLog *string, exitCode *int) error {
+	return js.UpdateJob(id, models.JobUpdate{OutputLog: outputLog, ErrorLog: errorLog, ExitCode: exitCode})
 }
 
 // DeleteJob deletes a job (only if not running)
@@ -359,6 +683,219 @@ func (js *JobService) DeleteJob(id string) error {
 	return nil
 }
 
+// CancelPendingJobs cancels every pending job of a project and returns the number cancelled.
+// Jobs in any other status are left untouched: the update is conditional on status 'pending',
+// so a job a worker picked up in the meantime keeps its running status.
+func (js *JobService) CancelPendingJobs(projectID string) (int, error) {
+	tx, err := js.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin cancel transaction: %w", err)
+	}
+	defer tx.Rollback()
+	
+	now := time.Now().UTC()
+	result, err := tx.Exec(`
+		UPDATE jobs SET status = ?, completed_at = ?, pid = NULL
+		WHERE project_id = ? AND status = ?
+	`, models.JobStatusCancelled, now.Format(time.RFC3339), projectID, models.JobStatusPending)
+	if err != nil {
+		if isIndexedUpdateConflict(err) {
+			tx.Rollback()
+			return js.cancelPendingJobsOneByOne(projectID, now)
+		}
+		return 0, fmt.Errorf("failed to cancel pending jobs: %w", err)
+	}
+	cancelled, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count cancelled jobs: %w", err)
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit cancelled jobs: %w", err)
+	}
+	return int(cancelled), nil
+}
+
+// cancelPendingJobsOneByOne is the fallback for CancelPendingJobs when DuckDB cannot update the
+// indexed status column in place. Each job is rewritten under its lock, and only if it is still
+// pending, so the check and the update cannot interleave with a worker starting the job.
+func (js *JobService) cancelPendingJobsOneByOne(projectID string, now time.Time) (int, error) {
+	rows, err := js.db.Query(`SELECT id FROM jobs WHERE project_id = ? AND status = ?`, projectID, models.JobStatusPending)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query pending jobs: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan pending job: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating pending jobs: %w", err)
+	}
+	
+	status := models.JobStatusCancelled
+	update := models.JobUpdate{Status: &status, CompletedAt: &now, ClearPID: true}
+	cancelled := 0
+	for _, id := range ids {
+		ok, err := js.cancelIfPending(id, update)
+		if err != nil {
+			return cancelled, fmt.Errorf("failed to cancel job %s: %w", id, err)
+		}
+		if ok {
+			cancelled++
+		}
+	}
+	return cancelled, nil
+}
+
+// cancelIfPending applies update to the job if it is still pending, reporting whether it did
+func (js *JobService) cancelIfPending(id string, update models.JobUpdate) (bool, error) {
+	unlock := js.locks.lock(id)
+	defer unlock()
+	
+	job, err := js.GetJobByID(id)
+	if err != nil {
+		return false, err
+	}
+	if job == nil || job.Status != models.JobStatusPending {
+		return false, nil
+	}
+	return true, js.updateJob(id, update)
+}
+
+// GetJobStats returns job counts by status and the average and longest run duration.
+// Cancelled and failed jobs usually stopped early, so durations only include
+// completed jobs unless the filters opt the other terminal statuses in.
+func (js *JobService) GetJobStats(filters models.JobStatsFilters) (*models.JobStats, error) {
+	stats := &models.JobStats{
+		ProjectID:        filters.ProjectID,
+		DurationStatuses: []string{models.JobStatusCompleted},
+	}
+	if filters.IncludeFailed {
+		stats.DurationStatuses = append(stats.DurationStatuses, models.JobStatusFailed)
+	}
+	if filters.IncludeCancelled {
+		stats.DurationStatuses = append(stats.DurationStatuses, models.JobStatusCancelled)
+	}
+
+	var args []interface{}
+	placeholders := make([]string, len(stats.DurationStatuses))
+	for i, status := range stats.DurationStatuses {
+		placeholders[i] = "?"
+		args = append(args, status)
+	}
+
+	// Only jobs in the duration statuses that both started and finished get a duration.
+	// Job timestamps are stored as RFC3339 text.
+	query := `
+		WITH durations AS (
+			SELECT status,
+				   CASE WHEN status IN (` + strings.Join(placeholders, ", ") + `)
+						 AND started_at IS NOT NULL AND completed_at IS NOT NULL
+					    THEN date_diff('second', CAST(started_at AS TIMESTAMP), CAST(completed_at AS TIMESTAMP)) END as duration
+			FROM jobs
+			WHERE 1=1`
+	if filters.ProjectID != nil {
+		query += " AND project_id = ?"
+		args = append(args, *filters.ProjectID)
+	}
+	query += `
+		)
+		SELECT 
+			COUNT(*),
+			COUNT(*) FILTER (WHERE status = ?),
+			COUNT(*) FILTER (WHERE status = ?),
+			COUNT(*) FILTER (WHERE status = ?),
+			COUNT(*) FILTER (WHERE status = ?),
+			COUNT(*) FILTER (WHERE status = ?),
+			COUNT(duration),
+			COALESCE(AVG(duration), 0),
+			COALESCE(MAX(duration), 0)
+		FROM durations`
+	args = append(args, models.JobStatusPending, models.JobStatusRunning, models.JobStatusCompleted,
+		models.JobStatusFailed, models.JobStatusCancelled)
+
+	err := js.db.QueryRow(query, args...).Scan(
+		&stats.TotalJobs,
+		&stats.PendingJobs,
+		&stats.RunningJobs,
+		&stats.CompletedJobs,
+		&stats.FailedJobs,
+		&stats.CancelledJobs,
+		&stats.DurationJobCount,
+		&stats.AverageDurationSec,
+		&stats.MaxDurationSec,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetJobSummary counts jobs by status and the jobs created today and this week, optionally
+// for one project. Days and weeks (starting on Monday) are taken in now's time zone.
+func (js *JobService) GetJobSummary(projectID *string, now time.Time) (*models.JobSummary, error) {
+	todayFrom := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekFrom := todayFrom.AddDate(0, 0, -((int(todayFrom.Weekday()) + 6) % 7))
+
+	summary := &models.JobSummary{
+		ProjectID: projectID,
+		ByStatus: map[string]int{
+			models.JobStatusPending:   0,
+			models.JobStatusRunning:   0,
+			models.JobStatusCompleted: 0,
+			models.JobStatusFailed:    0,
+			models.JobStatusCancelled: 0,
+		},
+		TodayFrom: todayFrom,
+		WeekFrom:  weekFrom,
+	}
+
+	// Job timestamps are stored as RFC3339 text
+	query := `
+		SELECT status,
+			   COUNT(*),
+			   COUNT(*) FILTER (WHERE CAST(created_at AS TIMESTAMP) >= CAST(? AS TIMESTAMP)),
+			   COUNT(*) FILTER (WHERE CAST(created_at AS TIMESTAMP) >= CAST(? AS TIMESTAMP))
+		FROM jobs
+		WHERE 1=1`
+	args := []interface{}{todayFrom.UTC().Format(time.RFC3339), weekFrom.UTC().Format(time.RFC3339)}
+	if projectID != nil {
+		query += " AND project_id = ?"
+		args = append(args, *projectID)
+	}
+	query += " GROUP BY status"
+
+	rows, err := js.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job summary: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count, today, thisWeek int
+		if err := rows.Scan(&status, &count, &today, &thisWeek); err != nil {
+			return nil, fmt.Errorf("failed to scan job summary: %w", err)
+		}
+		summary.ByStatus[status] = count
+		summary.Total += count
+		summary.Today += today
+		summary.ThisWeek += thisWeek
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over job summary: %w", err)
+	}
+
+	return summary, nil
+}
+
 // GetPendingJobs retrieves jobs that are ready to be executed
 func (js *JobService) GetPendingJobs(limit int) ([]*models.Job, error) {
 	status := models.JobStatusPending
@@ -375,7 +912,7 @@ func (js *JobService) GetPendingImmediateJobs(limit int) ([]*models.Job, error)
 		SELECT j.id, j.project_id, j.command, j.execution_directory, j.yolo_mode,
 			   j.status, j.priority, j.created_at, j.started_at, j.completed_at,
 			   j.output_log, j.error_log, j.exit_code, j.pid,
-			   j.scheduled_at, j.schedule_type, j.schedule_params,
+			   j.scheduled_at, j.schedule_type, j.schedule_params, j.output_rules, j.webhook_url, j.resource_limits, j.env,
 			   p.name as project_name, p.path as project_path
 		FROM jobs j
 		JOIN projects p ON j.project_id = p.id
@@ -407,25 +944,14 @@ func (js *JobService) GetPendingImmediateJobs(limit int) ([]*models.Job, error)
 // Helper methods
 
 func (js *JobService) getProjectByID(id string) (*models.Project, error) {
-	query := "SELECT id, name, path FROM projects WHERE id = ?"
-	row := js.db.QueryRow(query, id)
-	
-	project := &models.Project{}
-	err := row.Scan(&project.ID, &project.Name, &project.Path)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	if err != nil {
-		return nil, err
-	}
-	
-	return project, nil
+	// Served from the shared project cache when possible
+	return js.projectService.GetProjectByID(id)
 }
 
 func (js *JobService) scanJobRow(row interface{}, job *models.Job) error {
 	var createdAt, startedAt, completedAt, scheduledAt, outputLog, errorLog sql.NullString
 	var exitCode, pid sql.NullInt64
-	var scheduleType, scheduleParams sql.NullString
+	var scheduleType, scheduleParams, outputRules, webhookURL, resourceLimits, env sql.NullString
 	
 	scanner, ok := row.(interface {
 		Scan(dest ...interface{}) error
@@ -438,7 +964,7 @@ func (js *JobService) scanJobRow(row interface{}, job *models.Job) error {
 		&job.ID, &job.ProjectID, &job.Command, &job.ExecutionDirectory,
 		&job.YoloMode, &job.Status, &job.Priority, &createdAt,
 		&startedAt, &completedAt, &outputLog, &errorLog,
-		&exitCode, &pid, &scheduledAt, &scheduleType, &scheduleParams,
+		&exitCode, &pid, &scheduledAt, &scheduleType, &scheduleParams, &outputRules, &webhookURL, &resourceLimits, &env,
 		&job.Project.Name, &job.Project.Path)
 	
 	if err != nil {
@@ -485,6 +1011,18 @@ func (js *JobService) scanJobRow(row interface{}, job *models.Job) error {
 	if scheduleParams.Valid {
 		job.ScheduleParams = &scheduleParams.String
 	}
+	if outputRules.Valid {
+		job.OutputRules = &outputRules.String
+	}
+	if webhookURL.Valid {
+		job.WebhookURL = &webhookURL.String
+	}
+	if resourceLimits.Valid {
+		job.ResourceLimits = &resourceLimits.String
+	}
+	if env.Valid {
+		job.Env = &env.String
+	}
 	
 	return nil
 }
@@ -521,28 +1059,46 @@ func (js *JobService) validateScheduleParams(scheduleType string, params *models
 			return fmt.Errorf("scheduled_time must be in the future")
 		}
 		return nil
+	case models.ScheduleTypeCustom:
+		// customは廃止済み。scheduled_atが設定されず実行されないジョブを作らないよう拒否する
+		return fmt.Errorf("schedule type custom is no longer supported: use scheduled with scheduled_time")
 	default:
 		return fmt.Errorf("invalid schedule type: %s", scheduleType)
 	}
 }
 
-// GetScheduledJobs retrieves jobs that are scheduled for execution
-func (js *JobService) GetScheduledJobs() ([]*models.Job, error) {
-	now := time.Now()
+// GetScheduledJobs retrieves up to limit delayed, scheduled and after_reset jobs that are due
+// for execution, skipping the first offset, highest priority and earliest scheduled first.
+// A limit of 0 or less returns all of them. scheduled_at is stored as RFC3339 text in UTC,
+// so it is compared against the current UTC time.
+func (js *JobService) GetScheduledJobs(limit, offset int) ([]*models.Job, error) {
+	now := time.Now().UTC()
 	query := `
 		SELECT j.id, j.project_id, j.command, j.execution_directory, j.yolo_mode,
 			   j.status, j.priority, j.created_at, j.started_at, j.completed_at,
 			   j.output_log, j.error_log, j.exit_code, j.pid,
-			   j.scheduled_at, j.schedule_type, j.schedule_params,
+			   j.scheduled_at, j.schedule_type, j.schedule_params, j.output_rules, j.webhook_url, j.resource_limits, j.env,
 			   p.name as project_name, p.path as project_path
 		FROM jobs j
 		LEFT JOIN projects p ON j.project_id = p.id
 		WHERE j.status = ? 
 		  AND j.scheduled_at IS NOT NULL
 		  AND j.scheduled_at <= ?
-		ORDER BY j.priority DESC, j.scheduled_at ASC`
+		  AND j.schedule_type IN (?, ?, ?)
+		ORDER BY j.priority DESC, CAST(j.scheduled_at AS TIMESTAMP) ASC, j.id`
+	args := []interface{}{
+		models.JobStatusPending,
+		now.Format(time.RFC3339),
+		models.ScheduleTypeDelayed,
+		models.ScheduleTypeScheduled,
+		models.ScheduleTypeAfterReset,
+	}
+	if limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	}
 	
-	rows, err := js.db.Query(query, models.JobStatusPending, now.Format(time.RFC3339))
+	rows, err := js.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query scheduled jobs: %w", err)
 	}
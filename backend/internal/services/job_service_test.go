@@ -2,7 +2,11 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -56,6 +60,10 @@ func setupJobTestDB(t *testing.T) *sql.DB {
 			scheduled_at VARCHAR,
 			schedule_type VARCHAR,
 			schedule_params TEXT,
+			output_rules TEXT,
+			webhook_url TEXT,
+			resource_limits TEXT,
+			env TEXT,
 			FOREIGN KEY (project_id) REFERENCES projects(id)
 		)`
 
@@ -63,6 +71,18 @@ func setupJobTestDB(t *testing.T) *sql.DB {
 		t.Fatalf("Failed to create jobs table: %v", err)
 	}
 
+	createAllowedCommandsTableQuery := `
+		CREATE TABLE project_allowed_commands (
+			project_id VARCHAR NOT NULL,
+			command VARCHAR NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (project_id, command)
+		)`
+
+	if _, err := db.Exec(createAllowedCommandsTableQuery); err != nil {
+		t.Fatalf("Failed to create project_allowed_commands table: %v", err)
+	}
+
 	return db
 }
 
@@ -203,6 +223,69 @@ func TestJobService_GetJobByID(t *testing.T) {
 	}
 }
 
+func TestJobService_GetJobLogs(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+
+	project := createTestProject(t, db)
+	jobService := NewJobService(db)
+
+	createdJob, err := jobService.CreateJob(&models.CreateJobRequest{
+		ProjectID:    project.ID,
+		Command:      "test command",
+		ScheduleType: models.ScheduleTypeImmediate,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create job for test: %v", err)
+	}
+
+	outputLog := "line 1\nline 2\nline 3\nline 4\nline 5\n"
+	errorLog := "warning\n"
+	exitCode := 0
+	if err := jobService.UpdateJobLogs(createdJob.ID, &outputLog, &errorLog, &exitCode); err != nil {
+		t.Fatalf("Failed to update job logs: %v", err)
+	}
+
+	// Last two stdout lines, skipping the final line
+	logs, err := jobService.GetJobLogs(createdJob.ID, models.JobLogStreamStdout, 2, 1)
+	if err != nil {
+		t.Fatalf("GetJobLogs failed: %v", err)
+	}
+	if logs.Stderr != nil {
+		t.Error("Expected no stderr chunk for stdout stream")
+	}
+	if logs.Stdout.TotalLines != 5 || logs.Stdout.StartLine != 2 {
+		t.Errorf("Expected 5 total lines starting at 2, got %d starting at %d", logs.Stdout.TotalLines, logs.Stdout.StartLine)
+	}
+	if len(logs.Stdout.Lines) != 2 || logs.Stdout.Lines[0] != "line 3" || logs.Stdout.Lines[1] != "line 4" {
+		t.Errorf("Unexpected stdout lines: %v", logs.Stdout.Lines)
+	}
+
+	// Offset past the start returns an empty page
+	logs, err = jobService.GetJobLogs(createdJob.ID, models.JobLogStreamBoth, 10, 10)
+	if err != nil {
+		t.Fatalf("GetJobLogs failed: %v", err)
+	}
+	if len(logs.Stdout.Lines) != 0 || logs.Stdout.TotalLines != 5 {
+		t.Errorf("Expected empty stdout page with 5 total lines, got %v (%d)", logs.Stdout.Lines, logs.Stdout.TotalLines)
+	}
+	if logs.Stderr == nil || logs.Stderr.TotalLines != 1 {
+		t.Errorf("Expected stderr chunk with 1 total line, got %+v", logs.Stderr)
+	}
+
+	if _, err := jobService.GetJobLogs(createdJob.ID, "invalid", 10, 0); err == nil {
+		t.Error("Expected error for invalid stream")
+	}
+
+	logs, err = jobService.GetJobLogs("non-existent", models.JobLogStreamBoth, 10, 0)
+	if err != nil {
+		t.Fatalf("GetJobLogs failed for missing job: %v", err)
+	}
+	if logs != nil {
+		t.Error("Expected nil logs for missing job")
+	}
+}
+
 func TestJobService_GetJobs(t *testing.T) {
 	db := setupJobTestDB(t)
 	defer db.Close()
@@ -332,6 +415,189 @@ func TestJobService_UpdateJobStatus(t *testing.T) {
 	}
 }
 
+func TestJobService_UpdateJob(t *testing.T) {
+	// setupJob creates a job with every updatable column populated
+	setupJob := func(t *testing.T, withStatusIndex bool) (*JobService, *models.Job) {
+		db := setupJobTestDB(t)
+		t.Cleanup(func() { db.Close() })
+		if withStatusIndex {
+			if _, err := db.Exec(`CREATE INDEX idx_jobs_status ON jobs(status)`); err != nil {
+				t.Fatalf("Failed to create status index: %v", err)
+			}
+		}
+
+		project := createTestProject(t, db)
+		jobService := NewJobService(db)
+		job, err := jobService.CreateJob(&models.CreateJobRequest{
+			ProjectID:    project.ID,
+			Command:      "test command",
+			ScheduleType: models.ScheduleTypeImmediate,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create job: %v", err)
+		}
+		if err := jobService.UpdateJobStatus(job.ID, models.JobStatusRunning, intPtr(4321)); err != nil {
+			t.Fatalf("Failed to start job: %v", err)
+		}
+		if err := jobService.UpdateJobLogs(job.ID, stringPtr("out"), stringPtr("err"), intPtr(3)); err != nil {
+			t.Fatalf("Failed to set logs: %v", err)
+		}
+
+		job, err = jobService.GetJobByID(job.ID)
+		if err != nil {
+			t.Fatalf("Failed to get job: %v", err)
+		}
+		return jobService, job
+	}
+
+	tests := []struct {
+		name            string
+		withStatusIndex bool
+		update          models.JobUpdate
+		apply           func(job *models.Job)
+	}{
+		{
+			name:            "logs only",
+			withStatusIndex: true,
+			update:          models.JobUpdate{OutputLog: stringPtr("new out")},
+			apply:           func(job *models.Job) { job.OutputLog = stringPtr("new out") },
+		},
+		{
+			name:            "pid and exit code",
+			withStatusIndex: true,
+			update:          models.JobUpdate{PID: intPtr(99), ExitCode: intPtr(0)},
+			apply: func(job *models.Job) {
+				job.PID = intPtr(99)
+				job.ExitCode = intPtr(0)
+			},
+		},
+		{
+			name:            "status in place",
+			withStatusIndex: false,
+			update:          models.JobUpdate{Status: stringPtr(models.JobStatusFailed), ClearPID: true},
+			apply: func(job *models.Job) {
+				job.Status = models.JobStatusFailed
+				job.PID = nil
+			},
+		},
+		{
+			name:            "status with fallback rewrite",
+			withStatusIndex: true,
+			update:          models.JobUpdate{Status: stringPtr(models.JobStatusFailed), ErrorLog: stringPtr("boom")},
+			apply: func(job *models.Job) {
+				job.Status = models.JobStatusFailed
+				job.ErrorLog = stringPtr("boom")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobService, before := setupJob(t, tt.withStatusIndex)
+
+			if err := jobService.UpdateJob(before.ID, tt.update); err != nil {
+				t.Fatalf("UpdateJob failed: %v", err)
+			}
+
+			after, err := jobService.GetJobByID(before.ID)
+			if err != nil {
+				t.Fatalf("Failed to get updated job: %v", err)
+			}
+
+			expected := *before
+			tt.apply(&expected)
+			if !reflect.DeepEqual(&expected, after) {
+				t.Errorf("Unexpected job after update:\nexpected %+v\ngot      %+v", expected, *after)
+			}
+		})
+	}
+
+	t.Run("missing job", func(t *testing.T) {
+		jobService, _ := setupJob(t, false)
+		if err := jobService.UpdateJob("missing", models.JobUpdate{OutputLog: stringPtr("x")}); err == nil {
+			t.Error("Expected error for a missing job")
+		}
+	})
+}
+
+func TestJobService_ConcurrentStatusAndLogUpdates(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+	// With jobs.status indexed, status updates go through the DELETE+INSERT rewrite
+	if _, err := db.Exec(`CREATE INDEX idx_jobs_status ON jobs(status)`); err != nil {
+		t.Fatalf("Failed to create status index: %v", err)
+	}
+
+	project := createTestProject(t, db)
+	// The executor and the handlers each have their own service on the same database
+	statusService := NewJobService(db)
+	logService := NewJobService(db)
+
+	job, err := statusService.CreateJob(&models.CreateJobRequest{
+		ProjectID:    project.ID,
+		Command:      "test command",
+		ScheduleType: models.ScheduleTypeImmediate,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create job for test: %v", err)
+	}
+
+	const updates = 20
+	statuses := []string{models.JobStatusRunning, models.JobStatusPending}
+	errs := make(chan error, 2*updates)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < updates; i++ {
+			if err := statusService.UpdateJobStatus(job.ID, statuses[i%2], nil); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		output := ""
+		for i := 0; i < updates; i++ {
+			output += fmt.Sprintf("line %d\n", i)
+			exitCode := i
+			if err := logService.UpdateJobLogs(job.ID, &output, nil, &exitCode); err != nil {
+				errs <- err
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Concurrent update failed: %v", err)
+	}
+
+	var rows int
+	if err := db.QueryRow("SELECT COUNT(*) FROM jobs WHERE id = ?", job.ID).Scan(&rows); err != nil {
+		t.Fatalf("Failed to count job rows: %v", err)
+	}
+	if rows != 1 {
+		t.Fatalf("Expected 1 job row, got %d", rows)
+	}
+
+	final, err := statusService.GetJobByID(job.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if want := statuses[(updates-1)%2]; final.Status != want {
+		t.Errorf("Expected status %s, got %s", want, final.Status)
+	}
+	if final.StartedAt == nil {
+		t.Error("StartedAt should be kept once the job has run")
+	}
+	if final.OutputLog == nil || !strings.HasSuffix(*final.OutputLog, fmt.Sprintf("line %d\n", updates-1)) {
+		t.Errorf("Expected output log ending with the last line, got %v", final.OutputLog)
+	}
+	if final.ExitCode == nil || *final.ExitCode != updates-1 {
+		t.Errorf("Expected exit code %d, got %v", updates-1, final.ExitCode)
+	}
+}
+
 func TestJobService_DeleteJob(t *testing.T) {
 	db := setupJobTestDB(t)
 	defer db.Close()
@@ -402,7 +668,171 @@ func TestJobService_DeleteJob_RunningJob(t *testing.T) {
 	}
 }
 
+func TestJobService_CancelPendingJobs(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("indexed=%v", indexed), func(t *testing.T) {
+			db := setupJobTestDB(t)
+			defer db.Close()
+
+			// With the production index DuckDB cannot UPDATE the status column in place
+			if indexed {
+				if _, err := db.Exec(`CREATE INDEX idx_jobs_status ON jobs(status)`); err != nil {
+					t.Fatalf("Failed to create index: %v", err)
+				}
+			}
+
+			project := createTestProject(t, db)
+			other := createTestProject(t, db)
+			jobService := NewJobService(db)
+
+			jobs := []struct {
+				id        string
+				projectID string
+				status    string
+			}{
+				{"pending-1", project.ID, models.JobStatusPending},
+				{"pending-2", project.ID, models.JobStatusPending},
+				{"completed-1", project.ID, models.JobStatusCompleted},
+				{"cancelled-1", project.ID, models.JobStatusCancelled},
+				{"running-1", project.ID, models.JobStatusRunning},
+				{"other-pending", other.ID, models.JobStatusPending},
+			}
+			now := time.Now().UTC().Format(time.RFC3339)
+			for _, j := range jobs {
+				_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+					VALUES (?, ?, 'test command', '/test/path', ?, ?, 'immediate')`, j.id, j.projectID, j.status, now)
+				if err != nil {
+					t.Fatalf("Failed to create test job: %v", err)
+				}
+			}
+
+			cancelled, err := jobService.CancelPendingJobs(project.ID)
+			if err != nil {
+				t.Fatalf("CancelPendingJobs failed: %v", err)
+			}
+			if cancelled != 2 {
+				t.Errorf("Expected 2 cancelled jobs, got %d", cancelled)
+			}
+
+			expected := map[string]string{
+				"pending-1":     models.JobStatusCancelled,
+				"pending-2":     models.JobStatusCancelled,
+				"completed-1":   models.JobStatusCompleted,
+				"cancelled-1":   models.JobStatusCancelled,
+				"running-1":     models.JobStatusRunning,
+				"other-pending": models.JobStatusPending,
+			}
+			for id, status := range expected {
+				job, err := jobService.GetJobByID(id)
+				if err != nil || job == nil {
+					t.Fatalf("Failed to get job %s: %v", id, err)
+				}
+				if job.Status != status {
+					t.Errorf("Expected job %s to be %s, got %s", id, status, job.Status)
+				}
+			}
+
+			// Only previously pending jobs get a completion time
+			job, _ := jobService.GetJobByID("pending-1")
+			if job.CompletedAt == nil {
+				t.Error("Expected cancelled job to have completed_at set")
+			}
+			job, _ = jobService.GetJobByID("cancelled-1")
+			if job.CompletedAt != nil {
+				t.Error("Expected already-cancelled job to be untouched")
+			}
+
+			// A job a worker started after it was listed is not cancelled by the fallback
+			cancelledStatus := models.JobStatusCancelled
+			if ok, err := jobService.cancelIfPending("running-1", models.JobUpdate{Status: &cancelledStatus}); ok || err != nil {
+				t.Errorf("Expected running job to be skipped, got %v (err: %v)", ok, err)
+			}
+			if job, _ := jobService.GetJobByID("running-1"); job.Status != models.JobStatusRunning {
+				t.Errorf("Expected running job to keep its status, got %s", job.Status)
+			}
+
+			// Nothing left to cancel
+			cancelled, err = jobService.CancelPendingJobs(project.ID)
+			if err != nil || cancelled != 0 {
+				t.Errorf("Expected no jobs to cancel on second call, got %d (err: %v)", cancelled, err)
+			}
+		})
+	}
+}
+
 // Test schedule parameter validation
+func TestJobService_GetJobStats(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+
+	project := createTestProject(t, db)
+	otherProject := createTestProject(t, db)
+	jobService := NewJobService(db)
+
+	start := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	insertJob := func(id, projectID, status string, duration time.Duration) {
+		var startedAt, completedAt interface{}
+		if duration > 0 {
+			startedAt = start.Format(time.RFC3339)
+			completedAt = start.Add(duration).Format(time.RFC3339)
+		}
+		_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, started_at, completed_at, schedule_type)
+			VALUES (?, ?, 'test command', '/test/path', ?, ?, ?, ?, 'immediate')`,
+			id, projectID, status, start.Format(time.RFC3339), startedAt, completedAt)
+		if err != nil {
+			t.Fatalf("Failed to insert job %s: %v", id, err)
+		}
+	}
+
+	insertJob("completed-1", project.ID, models.JobStatusCompleted, 60*time.Second)
+	insertJob("completed-2", project.ID, models.JobStatusCompleted, 120*time.Second)
+	insertJob("failed-1", project.ID, models.JobStatusFailed, 30*time.Second)
+	insertJob("cancelled-1", project.ID, models.JobStatusCancelled, 6*time.Second)
+	insertJob("pending-1", project.ID, models.JobStatusPending, 0)
+	insertJob("other-1", otherProject.ID, models.JobStatusCompleted, 600*time.Second)
+
+	tests := []struct {
+		name            string
+		filters         models.JobStatsFilters
+		expectedCount   int
+		expectedAverage float64
+		expectedMax     float64
+	}{
+		{"completed only by default", models.JobStatsFilters{ProjectID: &project.ID}, 2, 90, 120},
+		{"include failed", models.JobStatsFilters{ProjectID: &project.ID, IncludeFailed: true}, 3, 70, 120},
+		{"include cancelled", models.JobStatsFilters{ProjectID: &project.ID, IncludeCancelled: true}, 3, 62, 120},
+		{"all projects", models.JobStatsFilters{}, 3, 260, 600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := jobService.GetJobStats(tt.filters)
+			if err != nil {
+				t.Fatalf("GetJobStats failed: %v", err)
+			}
+
+			if stats.DurationJobCount != tt.expectedCount {
+				t.Errorf("Expected %d jobs in duration stats, got %d", tt.expectedCount, stats.DurationJobCount)
+			}
+			if stats.AverageDurationSec != tt.expectedAverage {
+				t.Errorf("Expected average duration %.0fs, got %f", tt.expectedAverage, stats.AverageDurationSec)
+			}
+			if stats.MaxDurationSec != tt.expectedMax {
+				t.Errorf("Expected max duration %.0fs, got %f", tt.expectedMax, stats.MaxDurationSec)
+			}
+		})
+	}
+
+	// Status counts are unaffected by the duration filters
+	stats, err := jobService.GetJobStats(models.JobStatsFilters{ProjectID: &project.ID})
+	if err != nil {
+		t.Fatalf("GetJobStats failed: %v", err)
+	}
+	if stats.TotalJobs != 5 || stats.CompletedJobs != 2 || stats.FailedJobs != 1 || stats.CancelledJobs != 1 || stats.PendingJobs != 1 || stats.RunningJobs != 0 {
+		t.Errorf("Unexpected status counts: %+v", stats)
+	}
+}
+
 func TestJobService_ValidateScheduleParams(t *testing.T) {
 	db := setupJobTestDB(t)
 	defer db.Close()
@@ -468,6 +898,13 @@ func TestJobService_ValidateScheduleParams(t *testing.T) {
 			wantErr:      true,
 			errMsg:       "must be in the future",
 		},
+		{
+			name:         "custom - removed schedule type",
+			scheduleType: models.ScheduleTypeCustom,
+			params:       &models.ScheduleParams{ScheduledTime: timePtr(time.Now().Add(time.Hour))},
+			wantErr:      true,
+			errMsg:       "use scheduled with scheduled_time",
+		},
 		{
 			name:         "invalid schedule type",
 			scheduleType: "invalid",
@@ -495,6 +932,372 @@ func TestJobService_ValidateScheduleParams(t *testing.T) {
 	}
 }
 
+func TestJobService_CreateJob_CustomScheduleTypeRejected(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+	
+	project := createTestProject(t, db)
+	jobService := NewJobService(db)
+	
+	// custom used to create a job without scheduled_at that never ran
+	job, err := jobService.CreateJob(&models.CreateJobRequest{
+		ProjectID:      project.ID,
+		Command:        "custom command",
+		ScheduleType:   models.ScheduleTypeCustom,
+		ScheduleParams: &models.ScheduleParams{ScheduledTime: timePtr(time.Now().Add(time.Hour))},
+	})
+	if err == nil {
+		t.Fatalf("Expected custom schedule type to be rejected, got job %+v", job)
+	}
+	if !strings.Contains(err.Error(), "invalid schedule parameters") || !strings.Contains(err.Error(), "no longer supported") {
+		t.Errorf("Expected a clear validation error, got %v", err)
+	}
+	
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM jobs").Scan(&count); err != nil {
+		t.Fatalf("Failed to count jobs: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no job to be stored, got %d", count)
+	}
+}
+
+func TestJobService_GetJobs_CommandAndCreatedFilters(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+	
+	project := createTestProject(t, db)
+	otherProject := createTestProject(t, db)
+	jobService := NewJobService(db)
+	
+	insertJob := func(id, projectID, command, status string, createdAt time.Time) {
+		_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+			VALUES (?, ?, ?, '/test/path', ?, ?, 'immediate')`,
+			id, projectID, command, status, createdAt.UTC().Format(time.RFC3339))
+		if err != nil {
+			t.Fatalf("Failed to insert job %s: %v", id, err)
+		}
+	}
+	
+	lastWeek := time.Date(2025, 8, 4, 12, 0, 0, 0, time.UTC)
+	thisWeek := time.Date(2025, 8, 12, 12, 0, 0, 0, time.UTC)
+	insertJob("go-test-last-week", project.ID, "run go test ./...", models.JobStatusCompleted, lastWeek)
+	insertJob("go-test-this-week", project.ID, "Run GO TEST for services", models.JobStatusFailed, thisWeek)
+	insertJob("lint-last-week", project.ID, "run the linter", models.JobStatusCompleted, lastWeek.Add(time.Hour))
+	insertJob("coverage-last-week", project.ID, "report 100% coverage", models.JobStatusCompleted, lastWeek.Add(2*time.Hour))
+	insertJob("other-go-test", otherProject.ID, "go test in other project", models.JobStatusCompleted, lastWeek)
+	
+	strPtr := func(s string) *string { return &s }
+	weekStart := time.Date(2025, 8, 4, 0, 0, 0, 0, time.UTC)
+	weekEnd := weekStart.AddDate(0, 0, 7)
+	completed := models.JobStatusCompleted
+	
+	tests := []struct {
+		name     string
+		filters  models.JobFilters
+		expected []string
+	}{
+		{"command is case-insensitive", models.JobFilters{ProjectID: &project.ID, CommandContains: strPtr("go test")},
+			[]string{"go-test-last-week", "go-test-this-week"}},
+		{"wildcards match literally", models.JobFilters{CommandContains: strPtr("100%")},
+			[]string{"coverage-last-week"}},
+		{"created after", models.JobFilters{ProjectID: &project.ID, CreatedAfter: &weekEnd},
+			[]string{"go-test-this-week"}},
+		{"created before", models.JobFilters{ProjectID: &project.ID, CreatedBefore: &weekEnd},
+			[]string{"go-test-last-week", "lint-last-week", "coverage-last-week"}},
+		{"created range", models.JobFilters{CreatedAfter: &weekStart, CreatedBefore: &weekEnd},
+			[]string{"go-test-last-week", "lint-last-week", "coverage-last-week", "other-go-test"}},
+		{"command last week", models.JobFilters{CommandContains: strPtr("go test"), CreatedAfter: &weekStart, CreatedBefore: &weekEnd},
+			[]string{"go-test-last-week", "other-go-test"}},
+		{"command, range, project and status", models.JobFilters{ProjectID: &project.ID, Status: &completed,
+			CommandContains: strPtr("go test"), CreatedAfter: &weekStart, CreatedBefore: &weekEnd},
+			[]string{"go-test-last-week"}},
+		{"no match", models.JobFilters{CommandContains: strPtr("deploy")}, nil},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs, err := jobService.GetJobs(tt.filters)
+			if err != nil {
+				t.Fatalf("GetJobs failed: %v", err)
+			}
+			
+			found := make(map[string]bool)
+			for _, job := range jobs {
+				found[job.ID] = true
+			}
+			if len(jobs) != len(tt.expected) {
+				t.Errorf("Expected %d jobs, got %d", len(tt.expected), len(jobs))
+			}
+			for _, id := range tt.expected {
+				if !found[id] {
+					t.Errorf("Expected job %s in results", id)
+				}
+			}
+		})
+	}
+}
+
+func TestJobService_CloneJob(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+	
+	project := createTestProject(t, db)
+	jobService := NewJobService(db)
+	
+	_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, yolo_mode, status, created_at,
+			started_at, completed_at, output_log, error_log, exit_code, pid, scheduled_at, schedule_type, webhook_url, env)
+		VALUES ('finished-job', ?, 'run go test ./...', '/test/path', true, 'failed', '2025-08-01T10:00:00Z',
+			'2025-08-01T10:00:05Z', '2025-08-01T10:01:00Z', 'some output', 'some error', 1, 4242, '2025-08-01T10:00:00Z', 'delayed',
+			'https://example.com/hook', '{"NODE_ENV":"test"}')`, project.ID)
+	if err != nil {
+		t.Fatalf("Failed to insert job: %v", err)
+	}
+	
+	clone, err := jobService.CloneJob("finished-job")
+	if err != nil {
+		t.Fatalf("CloneJob failed: %v", err)
+	}
+	if clone.ID == "finished-job" {
+		t.Fatal("Expected the clone to get a new ID")
+	}
+	
+	stored, err := jobService.GetJobByID(clone.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("Failed to get cloned job: %v", err)
+	}
+	if stored.Command != "run go test ./..." || stored.ProjectID != project.ID || stored.ExecutionDirectory != "/test/path" || !stored.YoloMode {
+		t.Errorf("Expected command, project, directory and yolo mode to be copied, got %+v", stored)
+	}
+	if stored.Status != models.JobStatusPending || stored.ScheduleType == nil || *stored.ScheduleType != models.ScheduleTypeImmediate {
+		t.Errorf("Expected a pending immediate job, got %s", stored.Status)
+	}
+	if stored.WebhookURL == nil || *stored.WebhookURL != "https://example.com/hook" || stored.Env == nil || *stored.Env != `{"NODE_ENV":"test"}` {
+		t.Errorf("Expected webhook and env to be copied, got %v and %v", stored.WebhookURL, stored.Env)
+	}
+	if stored.OutputLog != nil || stored.ErrorLog != nil || stored.ExitCode != nil || stored.PID != nil {
+		t.Errorf("Expected no logs, exit code or PID, got %+v", stored)
+	}
+	if stored.StartedAt != nil || stored.CompletedAt != nil || stored.ScheduledAt != nil {
+		t.Errorf("Expected no run timestamps, got %+v", stored)
+	}
+	
+	// The original job is untouched
+	original, err := jobService.GetJobByID("finished-job")
+	if err != nil || original == nil {
+		t.Fatalf("Failed to get original job: %v", err)
+	}
+	if original.Status != models.JobStatusFailed || original.OutputLog == nil || *original.OutputLog != "some output" {
+		t.Errorf("Expected the original job to be unchanged, got %+v", original)
+	}
+	
+	if _, err := jobService.CloneJob("missing-job"); err == nil {
+		t.Error("Expected an error cloning a missing job")
+	}
+}
+
+func TestJobService_GetJobSummary(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+	
+	project := createTestProject(t, db)
+	otherProject := createTestProject(t, db)
+	jobService := NewJobService(db)
+	
+	insertJob := func(id, projectID, status string, createdAt time.Time) {
+		_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type)
+			VALUES (?, ?, 'test command', '/test/path', ?, ?, 'immediate')`,
+			id, projectID, status, createdAt.UTC().Format(time.RFC3339))
+		if err != nil {
+			t.Fatalf("Failed to insert job %s: %v", id, err)
+		}
+	}
+	
+	// Wednesday; the week started on Monday 2025-08-11
+	now := time.Date(2025, 8, 13, 15, 0, 0, 0, time.UTC)
+	insertJob("today", project.ID, models.JobStatusPending, now.Add(-2*time.Hour))
+	insertJob("yesterday", project.ID, models.JobStatusCompleted, now.AddDate(0, 0, -1))
+	insertJob("monday", project.ID, models.JobStatusFailed, time.Date(2025, 8, 11, 0, 30, 0, 0, time.UTC))
+	insertJob("last-week", project.ID, models.JobStatusCompleted, time.Date(2025, 8, 10, 23, 0, 0, 0, time.UTC))
+	insertJob("other-today", otherProject.ID, models.JobStatusRunning, now.Add(-time.Hour))
+	
+	summary, err := jobService.GetJobSummary(&project.ID, now)
+	if err != nil {
+		t.Fatalf("GetJobSummary failed: %v", err)
+	}
+	if summary.Total != 4 || summary.Today != 1 || summary.ThisWeek != 3 {
+		t.Errorf("Expected total 4, today 1, this week 3, got %d, %d, %d", summary.Total, summary.Today, summary.ThisWeek)
+	}
+	expected := map[string]int{
+		models.JobStatusPending:   1,
+		models.JobStatusRunning:   0,
+		models.JobStatusCompleted: 2,
+		models.JobStatusFailed:    1,
+		models.JobStatusCancelled: 0,
+	}
+	for status, count := range expected {
+		if summary.ByStatus[status] != count {
+			t.Errorf("Expected %d %s jobs, got %d", count, status, summary.ByStatus[status])
+		}
+	}
+	if !summary.WeekFrom.Equal(time.Date(2025, 8, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the week to start on Monday, got %v", summary.WeekFrom)
+	}
+	
+	// Without a project every job is counted
+	summary, err = jobService.GetJobSummary(nil, now)
+	if err != nil {
+		t.Fatalf("GetJobSummary failed: %v", err)
+	}
+	if summary.Total != 5 || summary.Today != 2 || summary.ByStatus[models.JobStatusRunning] != 1 {
+		t.Errorf("Expected 5 jobs, 2 today and 1 running, got %+v", summary)
+	}
+}
+
+func TestJobService_CreateJob_Env(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+	
+	project := createTestProject(t, db)
+	jobService := NewJobService(db)
+	
+	job, err := jobService.CreateJob(&models.CreateJobRequest{
+		ProjectID:    project.ID,
+		Command:      "run the tests",
+		ScheduleType: models.ScheduleTypeImmediate,
+		Env:          map[string]string{"NODE_ENV": "test"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	
+	stored, err := jobService.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if stored.Env == nil || *stored.Env != `{"NODE_ENV":"test"}` {
+		t.Errorf("Expected env to be stored, got %v", stored.Env)
+	}
+	
+	// Values are redacted in API responses
+	encoded, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatalf("Failed to encode job: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"env":"{\"NODE_ENV\":\"[redacted]\"}"`) || strings.Contains(string(encoded), `\"test\"`) {
+		t.Errorf("Expected env values to be redacted, got %s", encoded)
+	}
+	if *stored.Env != `{"NODE_ENV":"test"}` {
+		t.Errorf("Expected encoding not to change the job, got %s", *stored.Env)
+	}
+	
+	_, err = jobService.CreateJob(&models.CreateJobRequest{
+		ProjectID:    project.ID,
+		Command:      "run the tests",
+		ScheduleType: models.ScheduleTypeImmediate,
+		Env:          map[string]string{"NODE ENV": "test"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid env") {
+		t.Errorf("Expected an invalid variable name to be rejected, got %v", err)
+	}
+}
+
+func TestJobService_PreviewSchedule(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+	
+	project := createTestProject(t, db)
+	jobService := NewJobService(db)
+	
+	// A timezone-sensitive scheduled time: 09:30 in UTC+9
+	tokyo := time.FixedZone("JST", 9*60*60)
+	scheduledTime := time.Now().In(tokyo).Add(48 * time.Hour).Truncate(time.Minute)
+	
+	tests := []struct {
+		name   string
+		params *models.ScheduleParams
+		typ    string
+	}{
+		{"immediate", nil, models.ScheduleTypeImmediate},
+		{"after_reset", nil, models.ScheduleTypeAfterReset},
+		{"delayed", &models.ScheduleParams{DelayHours: intPtr(3)}, models.ScheduleTypeDelayed},
+		{"scheduled", &models.ScheduleParams{ScheduledTime: &scheduledTime}, models.ScheduleTypeScheduled},
+	}
+	
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &models.CreateJobRequest{
+				ProjectID:      project.ID,
+				Command:        "echo " + tt.name,
+				ScheduleType:   tt.typ,
+				ScheduleParams: tt.params,
+			}
+			
+			preview, err := jobService.PreviewSchedule(req)
+			if err != nil {
+				t.Fatalf("PreviewSchedule failed: %v", err)
+			}
+			created, err := jobService.CreateJob(req)
+			if err != nil {
+				t.Fatalf("CreateJob failed: %v", err)
+			}
+			stored, err := jobService.GetJobByID(created.ID)
+			if err != nil {
+				t.Fatalf("GetJobByID failed: %v", err)
+			}
+			
+			if preview.ScheduleType != tt.typ {
+				t.Errorf("Expected schedule type %s, got %s", tt.typ, preview.ScheduleType)
+			}
+			if (preview.ScheduledAt == nil) != (stored.ScheduledAt == nil) {
+				t.Fatalf("Expected scheduled_at %v, got %v", stored.ScheduledAt, preview.ScheduledAt)
+			}
+			if preview.ScheduledAt == nil {
+				return
+			}
+			// delayed is relative to the request time, which differs by the time between the two calls
+			diff := stored.ScheduledAt.Sub(*preview.ScheduledAt)
+			if diff < 0 || diff > 2*time.Second {
+				t.Errorf("Expected scheduled_at %v, got %v", stored.ScheduledAt, preview.ScheduledAt)
+			}
+			if preview.ScheduledAt.Location() != time.UTC {
+				t.Errorf("Expected scheduled_at in UTC, got %v", preview.ScheduledAt)
+			}
+			if tt.typ == models.ScheduleTypeScheduled && !preview.ScheduledAt.Equal(scheduledTime) {
+				t.Errorf("Expected scheduled_at to be the same instant as %v, got %v", scheduledTime, preview.ScheduledAt)
+			}
+		})
+	}
+	
+	// Nothing is created by a preview, and invalid requests fail as they would on create
+	var before int
+	if err := db.QueryRow("SELECT COUNT(*) FROM jobs").Scan(&before); err != nil {
+		t.Fatalf("Failed to count jobs: %v", err)
+	}
+	_, err := jobService.PreviewSchedule(&models.CreateJobRequest{
+		ProjectID:      project.ID,
+		Command:        "echo past",
+		ScheduleType:   models.ScheduleTypeScheduled,
+		ScheduleParams: &models.ScheduleParams{ScheduledTime: timePtr(time.Now().Add(-time.Hour))},
+	})
+	if err == nil || !strings.Contains(err.Error(), "must be in the future") {
+		t.Errorf("Expected past scheduled_time to be rejected, got %v", err)
+	}
+	if _, err := jobService.PreviewSchedule(&models.CreateJobRequest{ProjectID: "missing", Command: "echo", ScheduleType: models.ScheduleTypeImmediate}); err == nil {
+		t.Error("Expected unknown project to be rejected")
+	}
+	if _, err := jobService.PreviewSchedule(&models.CreateJobRequest{ProjectID: project.ID, Command: "echo", ScheduleType: models.ScheduleTypeImmediate}); err != nil {
+		t.Fatalf("PreviewSchedule failed: %v", err)
+	}
+	var after int
+	if err := db.QueryRow("SELECT COUNT(*) FROM jobs").Scan(&after); err != nil {
+		t.Fatalf("Failed to count jobs: %v", err)
+	}
+	if after != before {
+		t.Errorf("Expected preview not to create jobs, count went from %d to %d", before, after)
+	}
+}
+
 // Test creating jobs with schedule parameters
 func TestJobService_CreateJob_WithScheduleParams(t *testing.T) {
 	db := setupJobTestDB(t)
@@ -591,7 +1394,7 @@ func TestJobService_GetScheduledJobs(t *testing.T) {
 	// but in real scenarios, jobs might become "past" if not executed on time
 	
 	// Get scheduled jobs
-	scheduledJobs, err := jobService.GetScheduledJobs()
+	scheduledJobs, err := jobService.GetScheduledJobs(0, 0)
 	if err != nil {
 		t.Fatalf("GetScheduledJobs failed: %v", err)
 	}
@@ -606,11 +1409,165 @@ func TestJobService_GetScheduledJobs(t *testing.T) {
 	}
 }
 
+func TestJobService_GetScheduledJobs_Paging(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+
+	// scheduled_at is stored in UTC; a local time west of UTC sorts before it as text
+	local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { time.Local = local }()
+
+	project := createTestProject(t, db)
+	jobService := NewJobService(db)
+
+	now := time.Now().UTC()
+	jobs := []struct {
+		id           string
+		priority     int
+		scheduleType string
+		scheduledAt  interface{}
+	}{
+		{"due-high", 5, models.ScheduleTypeScheduled, now.Add(-time.Minute).Format(time.RFC3339)},
+		{"due-early", 0, models.ScheduleTypeDelayed, now.Add(-2 * time.Hour).Format(time.RFC3339)},
+		{"due-late", 0, models.ScheduleTypeAfterReset, now.Add(-time.Hour).Format(time.RFC3339)},
+		{"future", 9, models.ScheduleTypeScheduled, now.Add(time.Hour).Format(time.RFC3339)},
+		{"immediate", 9, models.ScheduleTypeImmediate, nil},
+	}
+	for _, j := range jobs {
+		_, err := db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, priority, created_at, scheduled_at, schedule_type)
+			VALUES (?, ?, 'test command', '/test/path', 'pending', ?, ?, ?, ?)`, j.id, project.ID, j.priority, now.Format(time.RFC3339), j.scheduledAt, j.scheduleType)
+		if err != nil {
+			t.Fatalf("Failed to create test job: %v", err)
+		}
+	}
+
+	var ids []string
+	for offset := 0; ; offset += 2 {
+		page, err := jobService.GetScheduledJobs(2, offset)
+		if err != nil {
+			t.Fatalf("GetScheduledJobs failed: %v", err)
+		}
+		for _, job := range page {
+			ids = append(ids, job.ID)
+		}
+		if len(page) < 2 {
+			break
+		}
+	}
+
+	expected := []string{"due-high", "due-early", "due-late"}
+	if strings.Join(ids, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected due jobs %v, got %v", expected, ids)
+	}
+}
+
+// addTestSessionWindow creates the session_windows table if needed and inserts an active window
+func addTestSessionWindow(t *testing.T, db *sql.DB, id string, windowStart, resetTime time.Time) {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS session_windows (
+			id TEXT PRIMARY KEY,
+			window_start TIMESTAMP NOT NULL,
+			window_end TIMESTAMP NOT NULL,
+			reset_time TIMESTAMP NOT NULL,
+			total_input_tokens INTEGER DEFAULT 0,
+			total_output_tokens INTEGER DEFAULT 0,
+			total_tokens INTEGER DEFAULT 0,
+			total_cache_creation_tokens INTEGER DEFAULT 0,
+			total_cache_read_tokens INTEGER DEFAULT 0,
+			message_count INTEGER DEFAULT 0,
+			session_count INTEGER DEFAULT 0,
+			total_cost DOUBLE DEFAULT 0.0,
+			is_active BOOLEAN DEFAULT true,
+			plan TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		t.Fatalf("Failed to create session_windows table: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, is_active)
+		VALUES (?, ?, ?, ?, true)`, id, windowStart, resetTime, resetTime)
+	if err != nil {
+		t.Fatalf("Failed to insert session window: %v", err)
+	}
+}
+
+func TestJobService_CreateJob_AfterResetScheduledAtResetTime(t *testing.T) {
+	db := setupJobTestDB(t)
+	defer db.Close()
+	
+	project := createTestProject(t, db)
+	jobService := NewJobService(db)
+	req := &models.CreateJobRequest{
+		ProjectID:    project.ID,
+		Command:      "echo after reset",
+		ScheduleType: models.ScheduleTypeAfterReset,
+	}
+	
+	// Without an active window the job waits for the scheduler to resolve it
+	job, err := jobService.CreateJob(req)
+	if err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	if job.ScheduledAt != nil {
+		t.Errorf("Expected no scheduled_at without an active window, got %v", job.ScheduledAt)
+	}
+	waitingID := job.ID
+	
+	resetTime := time.Now().UTC().Add(3 * time.Hour).Truncate(time.Hour)
+	addTestSessionWindow(t, db, "active-window", resetTime.Add(-5*time.Hour), resetTime)
+	
+	job, err = jobService.CreateJob(req)
+	if err != nil {
+		t.Fatalf("CreateJob failed: %v", err)
+	}
+	stored, err := jobService.GetJobByID(job.ID)
+	if err != nil {
+		t.Fatalf("GetJobByID failed: %v", err)
+	}
+	if stored.ScheduledAt == nil || !stored.ScheduledAt.Equal(resetTime) {
+		t.Errorf("Expected scheduled_at %v, got %v", resetTime, stored.ScheduledAt)
+	}
+	
+	preview, err := jobService.PreviewSchedule(req)
+	if err != nil {
+		t.Fatalf("PreviewSchedule failed: %v", err)
+	}
+	if preview.ScheduledAt == nil || !preview.ScheduledAt.Equal(resetTime) {
+		t.Errorf("Expected preview scheduled_at %v, got %v", resetTime, preview.ScheduledAt)
+	}
+	
+	// The job created before the window existed is scheduled at the same reset
+	scheduled, err := jobService.ScheduleAfterResetJobs(resetTime)
+	if err != nil {
+		t.Fatalf("ScheduleAfterResetJobs failed: %v", err)
+	}
+	if scheduled != 1 {
+		t.Errorf("Expected 1 job to be scheduled, got %d", scheduled)
+	}
+	waiting, err := jobService.GetJobByID(waitingID)
+	if err != nil {
+		t.Fatalf("GetJobByID failed: %v", err)
+	}
+	if waiting.ScheduledAt == nil || !waiting.ScheduledAt.Equal(resetTime) {
+		t.Errorf("Expected waiting job scheduled_at %v, got %v", resetTime, waiting.ScheduledAt)
+	}
+	if waiting.Status != models.JobStatusPending || waiting.Command != req.Command {
+		t.Errorf("Expected the rest of the waiting job to be unchanged, got %+v", waiting)
+	}
+}
+
 // Helper functions
 func intPtr(i int) *int {
 	return &i
 }
 
+func stringPtr(s string) *string {
+	return &s
+}
+
 func timePtr(t time.Time) *time.Time {
 	return &t
 }
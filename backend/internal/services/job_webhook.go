@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"ccdash-backend/internal/middleware"
+	"ccdash-backend/internal/models"
+)
+
+const (
+	JOB_WEBHOOK_TIMEOUT     = 5 * time.Second
+	JOB_WEBHOOK_RETRY_DELAY = 1 * time.Second
+)
+
+// JobCompletionEvent is the payload delivered to a job's webhook_url when it reaches a terminal status
+type JobCompletionEvent struct {
+	JobID    string  `json:"job_id"`
+	Status   string  `json:"status"`
+	ExitCode *int    `json:"exit_code"`
+	Duration float64 `json:"duration"` // seconds
+	Command  string  `json:"command"`
+}
+
+// JobCompletionNotifier posts completion events to per-job webhooks
+type JobCompletionNotifier struct {
+	httpClient *http.Client
+	retryDelay time.Duration
+	pending    sync.WaitGroup // Deliveries still in flight
+}
+
+// NewJobCompletionNotifier creates a notifier with a short timeout and a single retry
+func NewJobCompletionNotifier() *JobCompletionNotifier {
+	return &JobCompletionNotifier{
+		httpClient: &http.Client{Timeout: JOB_WEBHOOK_TIMEOUT},
+		retryDelay: JOB_WEBHOOK_RETRY_DELAY,
+	}
+}
+
+// Notify sends the completion event if the job has a webhook configured.
+// Delivery runs in the background so a slow webhook never holds up the executor worker;
+// failures are only logged and never affect the job itself.
+func (n *JobCompletionNotifier) Notify(ctx context.Context, job *models.Job, status string, exitCode *int, duration time.Duration) {
+	if job.WebhookURL == nil || *job.WebhookURL == "" {
+		return
+	}
+
+	url := *job.WebhookURL
+	event := JobCompletionEvent{
+		JobID:    job.ID,
+		Status:   status,
+		ExitCode: exitCode,
+		Duration: duration.Seconds(),
+		Command:  job.Command,
+	}
+
+	n.pending.Add(1)
+	middleware.SafeGoRoutine(ctx, "job-webhook", func() {
+		defer n.pending.Done()
+		n.deliver(ctx, url, event)
+	})
+}
+
+// Wait blocks until every pending delivery has finished
+func (n *JobCompletionNotifier) Wait() {
+	n.pending.Wait()
+}
+
+// deliver posts the event, retrying once after retryDelay. Failures are logged with the
+// request ID carried by ctx, if any.
+func (n *JobCompletionNotifier) deliver(ctx context.Context, url string, event JobCompletionEvent) {
+	logger := middleware.ContextLogger(ctx)
+	err := postJSONWebhook(n.httpClient, url, event)
+	if err != nil {
+		logger.Warn("Completion webhook failed, retrying", "job_id", event.JobID, "error", err)
+		time.Sleep(n.retryDelay)
+		err = postJSONWebhook(n.httpClient, url, event)
+	}
+	if err != nil {
+		logger.Error("Completion webhook failed", "job_id", event.JobID, "error", err)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ccdash-backend/internal/models"
+)
+
+func createTestJobWithWebhook(t *testing.T, executor *JobExecutor, id, command, webhookURL string) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := executor.jobService.db.Exec(`INSERT INTO jobs (id, project_id, command, execution_directory, status, created_at, schedule_type, webhook_url)
+		VALUES (?, 'test-project', ?, '/test/dir', 'pending', ?, 'immediate', ?)`, id, command, now, webhookURL)
+	if err != nil {
+		t.Fatalf("Failed to create test job: %v", err)
+	}
+}
+
+func TestJobExecutor_CompletionWebhook(t *testing.T) {
+	t.Run("delivers payload on terminal status", func(t *testing.T) {
+		db := setupJobExecutorTestDB(t)
+		defer db.Close()
+
+		received := make(chan JobCompletionEvent, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var event JobCompletionEvent
+			if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+				t.Errorf("Failed to decode webhook payload: %v", err)
+			}
+			received <- event
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		executor := NewJobExecutor(NewJobService(db), 1)
+		// A command rejected by validation reaches the failed status without running claude
+		createTestJobWithWebhook(t, executor, "webhook-job-1", "echo hello; rm -rf /", server.URL)
+
+		executor.executeJob("webhook-job-1")
+		executor.notifier.Wait()
+
+		select {
+		case event := <-received:
+			if event.JobID != "webhook-job-1" || event.Status != models.JobStatusFailed {
+				t.Errorf("Unexpected webhook payload: %+v", event)
+			}
+			if event.Command != "echo hello; rm -rf /" {
+				t.Errorf("Expected command in payload, got %q", event.Command)
+			}
+			if event.ExitCode != nil {
+				t.Errorf("Expected null exit code for a job that never started, got %d", *event.ExitCode)
+			}
+			if event.Duration < 0 {
+				t.Errorf("Expected non-negative duration, got %f", event.Duration)
+			}
+		default:
+			t.Fatal("Expected completion webhook to be called")
+		}
+	})
+
+	t.Run("delivery failure does not change job status", func(t *testing.T) {
+		db := setupJobExecutorTestDB(t)
+		defer db.Close()
+
+		var attempts int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&attempts, 1)
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		executor := NewJobExecutor(NewJobService(db), 1)
+		executor.notifier.retryDelay = 10 * time.Millisecond
+		createTestJobWithWebhook(t, executor, "webhook-job-2", "echo hello; rm -rf /", server.URL)
+
+		executor.executeJob("webhook-job-2")
+		executor.notifier.Wait()
+
+		if got := atomic.LoadInt32(&attempts); got != 2 {
+			t.Errorf("Expected 2 delivery attempts (one retry), got %d", got)
+		}
+
+		job, err := executor.jobService.GetJobByID("webhook-job-2")
+		if err != nil {
+			t.Fatalf("Failed to get job: %v", err)
+		}
+		if job.Status != models.JobStatusFailed {
+			t.Errorf("Expected job status to remain %s, got %s", models.JobStatusFailed, job.Status)
+		}
+	})
+
+	t.Run("slow webhook does not hold the worker", func(t *testing.T) {
+		db := setupJobExecutorTestDB(t)
+		defer db.Close()
+
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		executor := NewJobExecutor(NewJobService(db), 1)
+		createTestJobWithWebhook(t, executor, "webhook-job-3", "echo hello; rm -rf /", server.URL)
+
+		done := make(chan struct{})
+		go func() {
+			executor.executeJob("webhook-job-3")
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(JOB_WEBHOOK_TIMEOUT / 2):
+			t.Error("Expected the job to finish without waiting for webhook delivery")
+		}
+		close(release)
+		executor.notifier.Wait()
+	})
+}
@@ -163,6 +163,18 @@ func (p *JSONLParser) processLogEntry(entry *models.LogEntry, projectName string
 		message.ServiceTier = &entry.Message.Usage.ServiceTier
 	}
 	
+	// Store the per-message cost so aggregates can simply SUM it
+	if entry.Message.Role == "assistant" {
+		message.Cost = p.tokenService.CalculateMessageCost(
+			message.SessionID,
+			message.Model,
+			message.InputTokens,
+			message.OutputTokens,
+			message.CacheCreationInputTokens,
+			message.CacheReadInputTokens,
+		)
+	}
+	
 	// Insert message first
 	if err := p.insertMessage(message); err != nil {
 		return fmt.Errorf("failed to insert message: %w", err)
@@ -198,8 +210,8 @@ func (p *JSONLParser) insertMessage(message *models.Message) error {
 			id, session_id, parent_uuid, is_sidechain, user_type, message_type,
 			message_role, model, content, input_tokens, cache_creation_input_tokens,
 			cache_read_input_tokens, output_tokens, service_tier, request_id,
-			timestamp, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			cost, timestamp, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	
 	_, err := p.db.Exec(upsertQuery,
@@ -218,6 +230,7 @@ func (p *JSONLParser) insertMessage(message *models.Message) error {
 		message.OutputTokens,
 		message.ServiceTier,
 		message.RequestID,
+		message.Cost,
 		message.Timestamp,
 		time.Now(),
 	)
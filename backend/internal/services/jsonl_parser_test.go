@@ -54,10 +54,18 @@ func setupTestDBForJSONL(t *testing.T) (*sql.DB, *TokenService, *SessionService)
 			output_tokens INTEGER DEFAULT 0,
 			service_tier TEXT,
 			request_id TEXT,
+			cost DOUBLE DEFAULT 0.0,
 			timestamp TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (session_id) REFERENCES sessions(id)
 		);
+
+		CREATE TABLE IF NOT EXISTS session_tags (
+			session_id TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (session_id, tag)
+		);
 	`
 
 	_, err = db.Exec(createTables)
@@ -515,6 +523,100 @@ func TestParseJSONLFile(t *testing.T) {
 	}
 }
 
+func TestExportSession_JSONLRoundTrip(t *testing.T) {
+	db, tokenService, sessionService := setupTestDBForJSONL(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0`); err != nil {
+		t.Fatalf("Failed to extend sessions table: %v", err)
+	}
+
+	parser := NewJSONLParser(db, tokenService, sessionService)
+	dir := t.TempDir()
+
+	// A small session: plain text, structured content blocks, usage and a sidechain reply
+	original := strings.Join([]string{
+		`{"parentUuid":null,"isSidechain":false,"userType":"external","cwd":"/Users/test/roundtrip","sessionId":"rt-session","version":"1.0.0","type":"user","message":{"role":"user","content":"Fix the <b>bug</b> & test"},"uuid":"rt-1","timestamp":"2025-08-01T10:00:00.123Z"}`,
+		`{"parentUuid":"rt-1","isSidechain":false,"userType":"external","cwd":"/Users/test/roundtrip","sessionId":"rt-session","version":"1.0.0","type":"assistant","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":[{"type":"text","text":"Done:\n` + "```go\\nx := 1\\n```" + `"}],"usage":{"input_tokens":1200,"cache_creation_input_tokens":300,"cache_read_input_tokens":4000,"output_tokens":250,"service_tier":"standard"}},"requestId":"req_1","uuid":"rt-2","timestamp":"2025-08-01T10:00:05Z"}`,
+		`{"parentUuid":"rt-2","isSidechain":true,"userType":"external","cwd":"/Users/test/roundtrip","sessionId":"rt-session","version":"1.0.0","type":"assistant","message":{"type":"message","role":"assistant","model":"claude-sonnet-4-20250514","content":"Sidechain note","usage":{"input_tokens":10,"cache_creation_input_tokens":0,"cache_read_input_tokens":0,"output_tokens":5,"service_tier":""}},"uuid":"rt-3","timestamp":"2025-08-01T10:00:09Z"}`,
+	}, "\n") + "\n"
+
+	originalPath := filepath.Join(dir, "original.jsonl")
+	if err := os.WriteFile(originalPath, []byte(original), 0644); err != nil {
+		t.Fatalf("Failed to write original log: %v", err)
+	}
+	if err := parser.parseJSONLFile(originalPath, "roundtrip"); err != nil {
+		t.Fatalf("Failed to parse original log: %v", err)
+	}
+
+	snapshot := func() []string {
+		rows, err := db.Query(`
+			SELECT id, session_id, parent_uuid, is_sidechain, user_type, message_type, message_role,
+				   model, content, input_tokens, cache_creation_input_tokens, cache_read_input_tokens,
+				   output_tokens, service_tier, request_id, cost, timestamp
+			FROM messages ORDER BY id`)
+		if err != nil {
+			t.Fatalf("Failed to query messages: %v", err)
+		}
+		defer rows.Close()
+
+		var snapshot []string
+		for rows.Next() {
+			var m models.Message
+			if err := rows.Scan(&m.ID, &m.SessionID, &m.ParentUUID, &m.IsSidechain, &m.UserType, &m.MessageType,
+				&m.MessageRole, &m.Model, &m.Content, &m.InputTokens, &m.CacheCreationInputTokens,
+				&m.CacheReadInputTokens, &m.OutputTokens, &m.ServiceTier, &m.RequestID, &m.Cost, &m.Timestamp); err != nil {
+				t.Fatalf("Failed to scan message: %v", err)
+			}
+			row, _ := json.Marshal(m)
+			snapshot = append(snapshot, string(row))
+		}
+		return snapshot
+	}
+
+	before := snapshot()
+	if len(before) != 3 {
+		t.Fatalf("Expected 3 imported messages, got %d", len(before))
+	}
+
+	export, err := sessionService.ExportSession("rt-session", SessionExportFormatJSONL)
+	if err != nil {
+		t.Fatalf("ExportSession failed: %v", err)
+	}
+	if export.Filename != "session-rt-session.jsonl" {
+		t.Errorf("Expected filename session-rt-session.jsonl, got %s", export.Filename)
+	}
+	if lines := strings.Split(strings.TrimSuffix(string(export.Content), "\n"), "\n"); len(lines) != 3 {
+		t.Fatalf("Expected one line per message, got %d:\n%s", len(lines), export.Content)
+	}
+	// Content blocks are exported as JSON rather than as an encoded string
+	if !strings.Contains(string(export.Content), `"content":[{"text":`) {
+		t.Errorf("Expected structured content in export, got:\n%s", export.Content)
+	}
+
+	// Re-importing the export from scratch reproduces the same rows
+	if _, err := db.Exec("DELETE FROM messages"); err != nil {
+		t.Fatalf("Failed to clear messages: %v", err)
+	}
+	exportPath := filepath.Join(dir, export.Filename)
+	if err := os.WriteFile(exportPath, export.Content, 0644); err != nil {
+		t.Fatalf("Failed to write export: %v", err)
+	}
+	if err := parser.parseJSONLFile(exportPath, "roundtrip"); err != nil {
+		t.Fatalf("Failed to parse exported log: %v", err)
+	}
+
+	after := snapshot()
+	if len(after) != len(before) {
+		t.Fatalf("Expected %d messages after re-import, got %d", len(before), len(after))
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			t.Errorf("Message changed in round trip:\nbefore %s\nafter  %s", before[i], after[i])
+		}
+	}
+}
+
 func TestSyncProjectLogs(t *testing.T) {
 	db, tokenService, sessionService := setupTestDBForJSONL(t)
 	defer db.Close()
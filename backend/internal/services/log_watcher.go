@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// LOG_WATCH_DEBOUNCE is how long a project's logs must be quiet before the watcher syncs it
+const LOG_WATCH_DEBOUNCE = 2 * time.Second
+
+// LogWatcher watches the Claude projects directories and syncs a project shortly after
+// one of its JSONL files is written
+type LogWatcher struct {
+	diffSyncService *DiffSyncService
+	claudeDirs      []string
+	debounce        time.Duration
+
+	watcher *fsnotify.Watcher
+
+	// Debounce timers per project directory; a fired timer sends the project to pending
+	timers  map[string]*time.Timer
+	timerMu sync.Mutex
+	pending chan string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewLogWatcher creates a watcher for the given Claude projects directories
+func NewLogWatcher(diffSyncService *DiffSyncService, claudeDirs []string, debounce time.Duration) *LogWatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &LogWatcher{
+		diffSyncService: diffSyncService,
+		claudeDirs:      claudeDirs,
+		debounce:        debounce,
+		timers:          make(map[string]*time.Timer),
+		pending:         make(chan string),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+}
+
+// Start watches every existing projects directory and the project directories in it.
+// Directories that don't exist are skipped with a warning.
+func (lw *LogWatcher) Start() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	lw.watcher = watcher
+
+	for _, claudeDir := range lw.claudeDirs {
+		if err := lw.watchProjectsDir(claudeDir); err != nil {
+			log.Printf("Not watching Claude projects directory %s: %v", claudeDir, err)
+		}
+	}
+
+	log.Printf("Watching Claude logs for changes (debounce: %v)", lw.debounce)
+
+	lw.wg.Add(1)
+	go lw.watchLoop()
+	return nil
+}
+
+// Stop stops watching, waiting for a running project sync to finish
+func (lw *LogWatcher) Stop() {
+	log.Println("Stopping log watcher")
+
+	lw.cancel()
+	lw.wg.Wait()
+
+	lw.timerMu.Lock()
+	for projectPath, timer := range lw.timers {
+		timer.Stop()
+		delete(lw.timers, projectPath)
+	}
+	lw.timerMu.Unlock()
+
+	if lw.watcher != nil {
+		lw.watcher.Close()
+	}
+
+	log.Println("Log watcher stopped")
+}
+
+// watchProjectsDir watches a projects directory for new projects and each project in it for log writes.
+// fsnotify is not recursive, so every project directory is watched on its own.
+func (lw *LogWatcher) watchProjectsDir(claudeDir string) error {
+	entries, err := os.ReadDir(claudeDir)
+	if err != nil {
+		return err
+	}
+	if err := lw.watcher.Add(claudeDir); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		projectPath := filepath.Join(claudeDir, entry.Name())
+		if err := lw.watcher.Add(projectPath); err != nil {
+			log.Printf("Failed to watch project directory %s: %v", projectPath, err)
+		}
+	}
+	return nil
+}
+
+// watchLoop handles file events and runs the debounced project syncs one at a time
+func (lw *LogWatcher) watchLoop() {
+	defer lw.wg.Done()
+
+	for {
+		select {
+		case <-lw.ctx.Done():
+			return
+		case event, ok := <-lw.watcher.Events:
+			if !ok {
+				return
+			}
+			lw.handleEvent(event)
+		case err, ok := <-lw.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Log watcher error: %v", err)
+		case projectPath := <-lw.pending:
+			lw.syncProject(projectPath)
+		}
+	}
+}
+
+// handleEvent watches newly created project directories and schedules a sync of
+// the project whose JSONL file was created or written
+func (lw *LogWatcher) handleEvent(event fsnotify.Event) {
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+
+	if lw.isProjectsDir(filepath.Dir(event.Name)) {
+		if event.Has(fsnotify.Create) {
+			if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+				if err := lw.watcher.Add(event.Name); err != nil {
+					log.Printf("Failed to watch project directory %s: %v", event.Name, err)
+				}
+				// Logs written before the watch was added are picked up by syncing the new project
+				lw.scheduleSync(event.Name)
+			}
+		}
+		return
+	}
+
+	if strings.HasSuffix(event.Name, ".jsonl") {
+		lw.scheduleSync(filepath.Dir(event.Name))
+	}
+}
+
+// isProjectsDir reports whether dir is one of the watched Claude projects directories
+func (lw *LogWatcher) isProjectsDir(dir string) bool {
+	for _, claudeDir := range lw.claudeDirs {
+		if filepath.Clean(claudeDir) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleSync syncs the project once its logs have been quiet for the debounce period
+func (lw *LogWatcher) scheduleSync(projectPath string) {
+	lw.timerMu.Lock()
+	defer lw.timerMu.Unlock()
+
+	if timer, ok := lw.timers[projectPath]; ok {
+		timer.Reset(lw.debounce)
+		return
+	}
+
+	lw.timers[projectPath] = time.AfterFunc(lw.debounce, func() {
+		lw.timerMu.Lock()
+		delete(lw.timers, projectPath)
+		lw.timerMu.Unlock()
+
+		select {
+		case lw.pending <- projectPath:
+		case <-lw.ctx.Done():
+		}
+	})
+}
+
+// syncProject syncs the changed project unless initialization is in progress
+func (lw *LogWatcher) syncProject(projectPath string) {
+	if GetGlobalInitializationService().IsInitializing() {
+		log.Printf("Skipping watched sync of %s: initialization in progress", projectPath)
+		return
+	}
+
+	stats, err := lw.diffSyncService.SyncProjectLogs(projectPath)
+	if err != nil {
+		log.Printf("Watched sync of %s failed: %v", projectPath, err)
+		return
+	}
+
+	log.Printf("Watched sync of %s: %d files processed, %d new lines", filepath.Base(projectPath), stats.ProcessedFiles, stats.NewLines)
+}
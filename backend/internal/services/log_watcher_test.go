@@ -0,0 +1,60 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogWatcher_SyncsWrittenProject(t *testing.T) {
+	db, diffSyncService := setupTestDBForBatchSync(t)
+	defer db.Close()
+
+	claudeDir := t.TempDir()
+	existingProject := filepath.Join(claudeDir, "-existing-project")
+	if err := os.MkdirAll(existingProject, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+
+	watcher := NewLogWatcher(diffSyncService, []string{claudeDir, filepath.Join(claudeDir, "missing")}, 50*time.Millisecond)
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	countSessionMessages := func(sessionID string) int {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM messages WHERE session_id = ?", sessionID).Scan(&count); err != nil {
+			t.Fatalf("Failed to count messages: %v", err)
+		}
+		return count
+	}
+	waitForMessages := func(sessionID string, expected int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for countSessionMessages(sessionID) != expected {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d messages in %s, got %d", expected, sessionID, countSessionMessages(sessionID))
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	// A write to a project that existed when the watcher started
+	data := `{"uuid":"existing-1","sessionId":"existing-session","userType":"human","cwd":"/existing-project","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Hi"}}`
+	if err := os.WriteFile(filepath.Join(existingProject, "log.jsonl"), []byte(data+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+	waitForMessages("existing-session", 1)
+
+	// A project created after the watcher started
+	newProject := filepath.Join(claudeDir, "-new-project")
+	if err := os.MkdirAll(newProject, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	data = `{"uuid":"new-1","sessionId":"new-session","userType":"human","cwd":"/new-project","timestamp":"2024-01-01T11:00:00Z","message":{"role":"user","content":"Hi"}}`
+	if err := os.WriteFile(filepath.Join(newProject, "log.jsonl"), []byte(data+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+	waitForMessages("new-session", 1)
+}
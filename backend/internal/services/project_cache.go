@@ -0,0 +1,155 @@
+package services
+
+import (
+	"container/list"
+	"database/sql"
+	"sync"
+	"time"
+
+	"ccdash-backend/internal/models"
+)
+
+const (
+	PROJECT_CACHE_SIZE = 256
+	PROJECT_CACHE_TTL  = 5 * time.Minute
+)
+
+// ProjectCache is a small LRU cache of projects keyed by ID with a TTL per entry.
+// Projects are also indexed by name and path for the session sync hot path.
+type ProjectCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element // project ID -> element in order
+	byPath   map[string]string        // name/path key -> project ID
+	order    *list.List               // most recently used at the front
+	now      func() time.Time
+}
+
+type projectCacheEntry struct {
+	project   models.Project
+	expiresAt time.Time
+}
+
+var (
+	projectCachesMu sync.Mutex
+	projectCaches   = map[*sql.DB]*ProjectCache{}
+)
+
+// NewProjectCache creates an empty cache holding up to capacity projects for ttl each
+func NewProjectCache(capacity int, ttl time.Duration) *ProjectCache {
+	return &ProjectCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		byPath:   make(map[string]string),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// projectCacheFor returns the cache shared by all services using the same database,
+// so an update through one ProjectService is seen by every other lookup
+func projectCacheFor(db *sql.DB) *ProjectCache {
+	projectCachesMu.Lock()
+	defer projectCachesMu.Unlock()
+
+	cache, exists := projectCaches[db]
+	if !exists {
+		cache = NewProjectCache(PROJECT_CACHE_SIZE, PROJECT_CACHE_TTL)
+		projectCaches[db] = cache
+	}
+	return cache
+}
+
+func projectPathKey(name, path string) string {
+	return name + "\x00" + path
+}
+
+// Get returns a copy of the cached project, or nil if it is missing or expired
+func (c *ProjectCache) Get(id string) *models.Project {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[id]
+	if !exists {
+		return nil
+	}
+	return c.touch(elem)
+}
+
+// GetByNameAndPath returns a copy of the cached project with the given name and path
+func (c *ProjectCache) GetByNameAndPath(name, path string) *models.Project {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id, exists := c.byPath[projectPathKey(name, path)]
+	if !exists {
+		return nil
+	}
+	return c.touch(c.entries[id])
+}
+
+// touch returns a copy of the entry's project and marks it as recently used, evicting it if expired
+func (c *ProjectCache) touch(elem *list.Element) *models.Project {
+	entry := elem.Value.(*projectCacheEntry)
+	if c.now().After(entry.expiresAt) {
+		c.remove(elem)
+		return nil
+	}
+
+	c.order.MoveToFront(elem)
+	project := entry.project
+	return &project
+}
+
+// Put stores a copy of the project, evicting the least recently used entry when full
+func (c *ProjectCache) Put(project *models.Project) {
+	if project == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exists := c.entries[project.ID]; exists {
+		c.remove(elem)
+	}
+
+	entry := &projectCacheEntry{project: *project, expiresAt: c.now().Add(c.ttl)}
+	c.entries[project.ID] = c.order.PushFront(entry)
+	c.byPath[projectPathKey(project.Name, project.Path)] = project.ID
+
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate evicts the project with the given ID
+func (c *ProjectCache) Invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, exists := c.entries[id]; exists {
+		c.remove(elem)
+	}
+}
+
+// Clear evicts every project
+func (c *ProjectCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.byPath = make(map[string]string)
+	c.order.Init()
+}
+
+func (c *ProjectCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*projectCacheEntry)
+	delete(c.entries, entry.project.ID)
+	key := projectPathKey(entry.project.Name, entry.project.Path)
+	if c.byPath[key] == entry.project.ID {
+		delete(c.byPath, key)
+	}
+}
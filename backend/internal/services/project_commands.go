@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+)
+
+// AddAllowedCommand whitelists a command for jobs of one project.
+// Returns the project's whitelisted commands after the change.
+func (p *ProjectService) AddAllowedCommand(projectID, command string) ([]string, error) {
+	command = NormalizeWhitelistCommand(command)
+	if command == "" {
+		return nil, fmt.Errorf("invalid command: must not be empty")
+	}
+
+	project, err := p.GetProjectByID(projectID)
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, fmt.Errorf("project not found: %s", projectID)
+	}
+
+	_, err = p.db.Exec(`INSERT OR IGNORE INTO project_allowed_commands (project_id, command) VALUES (?, ?)`, projectID, command)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add allowed command: %w", err)
+	}
+
+	return p.GetAllowedCommands(projectID)
+}
+
+// RemoveAllowedCommand removes a command from a project's whitelist.
+// Returns the project's whitelisted commands after the change.
+func (p *ProjectService) RemoveAllowedCommand(projectID, command string) ([]string, error) {
+	result, err := p.db.Exec(`DELETE FROM project_allowed_commands WHERE project_id = ? AND command = ?`,
+		projectID, NormalizeWhitelistCommand(command))
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove allowed command: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check removed allowed command: %w", err)
+	}
+	if removed == 0 {
+		return nil, fmt.Errorf("allowed command not found in project %s: %s", projectID, command)
+	}
+
+	return p.GetAllowedCommands(projectID)
+}
+
+// GetAllowedCommands returns the commands whitelisted for a project in alphabetical order
+func (p *ProjectService) GetAllowedCommands(projectID string) ([]string, error) {
+	rows, err := p.db.Query(`SELECT command FROM project_allowed_commands WHERE project_id = ? ORDER BY command`, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get allowed commands: %w", err)
+	}
+	defer rows.Close()
+
+	commands := []string{}
+	for rows.Next() {
+		var command string
+		if err := rows.Scan(&command); err != nil {
+			return nil, fmt.Errorf("failed to scan allowed command: %w", err)
+		}
+		commands = append(commands, command)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over allowed commands: %w", err)
+	}
+
+	return commands, nil
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+
+	"ccdash-backend/internal/models"
+)
+
+// projectMarker maps a file found at the project root to what it indicates
+type projectMarker struct {
+	file  string
+	value string
+}
+
+// Language markers, checked in order. The first match wins, so more specific
+// markers (tsconfig.json) come before the generic ones (package.json).
+var languageMarkers = []projectMarker{
+	{"go.mod", "Go"},
+	{"Cargo.toml", "Rust"},
+	{"tsconfig.json", "TypeScript"},
+	{"package.json", "JavaScript"},
+	{"pyproject.toml", "Python"},
+	{"requirements.txt", "Python"},
+	{"setup.py", "Python"},
+	{"Gemfile", "Ruby"},
+	{"pom.xml", "Java"},
+	{"build.gradle", "Java"},
+	{"build.gradle.kts", "Kotlin"},
+	{"composer.json", "PHP"},
+	{"Package.swift", "Swift"},
+	{"mix.exs", "Elixir"},
+}
+
+// Framework markers, checked in order
+var frameworkMarkers = []projectMarker{
+	{"next.config.js", "Next.js"},
+	{"next.config.mjs", "Next.js"},
+	{"next.config.ts", "Next.js"},
+	{"nuxt.config.js", "Nuxt"},
+	{"nuxt.config.ts", "Nuxt"},
+	{"svelte.config.js", "SvelteKit"},
+	{"angular.json", "Angular"},
+	{"astro.config.mjs", "Astro"},
+	{"remix.config.js", "Remix"},
+	{"manage.py", "Django"},
+	{"config/routes.rb", "Rails"},
+	{"artisan", "Laravel"},
+}
+
+// DetectProjectMetadata inspects the project directory for well-known files and
+// returns the language and framework they indicate. Either is empty when nothing
+// matches, and both are empty when the path does not exist or is not a directory.
+func (p *ProjectService) DetectProjectMetadata(projectPath string) (language, framework string) {
+	if projectPath == "" {
+		return "", ""
+	}
+	info, err := os.Stat(projectPath)
+	if err != nil || !info.IsDir() {
+		return "", ""
+	}
+
+	return firstMarker(projectPath, languageMarkers), firstMarker(projectPath, frameworkMarkers)
+}
+
+// firstMarker returns the value of the first marker present in dir
+func firstMarker(dir string, markers []projectMarker) string {
+	for _, marker := range markers {
+		if _, err := os.Stat(filepath.Join(dir, marker.file)); err == nil {
+			return marker.value
+		}
+	}
+	return ""
+}
+
+// applyDetectedMetadata fills in the language and framework of a project that
+// has none set, from what is found in its directory. Set values are kept.
+func (p *ProjectService) applyDetectedMetadata(project *models.Project) (*models.Project, error) {
+	if project.Language != nil && project.Framework != nil {
+		return project, nil
+	}
+
+	language, framework := p.DetectProjectMetadata(project.Path)
+
+	updated := *project
+	changed := false
+	if updated.Language == nil && language != "" {
+		updated.Language = &language
+		changed = true
+	}
+	if updated.Framework == nil && framework != "" {
+		updated.Framework = &framework
+		changed = true
+	}
+	if !changed {
+		return project, nil
+	}
+
+	if err := p.UpdateProject(&updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
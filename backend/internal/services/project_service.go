@@ -3,6 +3,7 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"ccdash-backend/internal/models"
@@ -10,14 +11,21 @@ import (
 )
 
 type ProjectService struct {
-	db *sql.DB
+	db                *sql.DB
+	pricingCalculator *PricingCalculator
+	cache             *ProjectCache // Shared by every ProjectService on the same database
 }
 
 func NewProjectService(db *sql.DB) *ProjectService {
-	return &ProjectService{db: db}
+	return &ProjectService{
+		db:                db,
+		pricingCalculator: NewPricingCalculator(),
+		cache:             projectCacheFor(db),
+	}
 }
 
-// GetOrCreateProject gets an existing project or creates a new one
+// GetOrCreateProject gets an existing project or creates a new one.
+// Language and framework are detected from the project directory when not already set.
 func (p *ProjectService) GetOrCreateProject(name, path string) (*models.Project, error) {
 	// Try to find existing project first
 	project, err := p.FindProjectByNameAndPath(name, path)
@@ -29,12 +37,21 @@ func (p *ProjectService) GetOrCreateProject(name, path string) (*models.Project,
 		return project, nil
 	}
 	
-	// Create new project if not found
-	return p.CreateProject(name, path)
+	// Create new project if not found. Metadata is only detected here since sync
+	// calls this for every log entry and existing projects would be re-stat'ed each time.
+	project, err = p.CreateProject(name, path)
+	if err != nil {
+		return nil, err
+	}
+	return p.applyDetectedMetadata(project)
 }
 
 // FindProjectByNameAndPath finds a project by name and path
 func (p *ProjectService) FindProjectByNameAndPath(name, path string) (*models.Project, error) {
+	if project := p.cache.GetByNameAndPath(name, path); project != nil {
+		return project, nil
+	}
+	
 	query := `
 		SELECT id, name, path, description, repository_url, language, framework,
 			   is_active, created_at, updated_at
@@ -63,6 +80,7 @@ func (p *ProjectService) FindProjectByNameAndPath(name, path string) (*models.Pr
 		return nil, fmt.Errorf("failed to query project: %w", err)
 	}
 	
+	p.cache.Put(&project)
 	return &project, nil
 }
 
@@ -104,6 +122,10 @@ func (p *ProjectService) CreateProject(name, path string) (*models.Project, erro
 
 // GetProjectByID gets a project by ID
 func (p *ProjectService) GetProjectByID(id string) (*models.Project, error) {
+	if project := p.cache.Get(id); project != nil {
+		return project, nil
+	}
+	
 	query := `
 		SELECT id, name, path, description, repository_url, language, framework,
 			   is_active, created_at, updated_at
@@ -132,18 +154,224 @@ func (p *ProjectService) GetProjectByID(id string) (*models.Project, error) {
 		return nil, fmt.Errorf("failed to query project by ID: %w", err)
 	}
 	
+	p.cache.Put(&project)
 	return &project, nil
 }
 
-// GetAllProjects gets all projects that have sessions
+// GetProjectStats returns aggregate statistics for a project.
+// Projects without sessions return zero values rather than an error.
+func (p *ProjectService) GetProjectStats(projectID string) (*models.ProjectStats, error) {
+	stats := &models.ProjectStats{ProjectID: projectID}
+
+	query := `
+		SELECT 
+			COUNT(*) as session_count,
+			COALESCE(SUM(message_count), 0) as message_count,
+			COALESCE(SUM(total_tokens), 0) as total_tokens,
+			MIN(start_time) as first_activity,
+			MAX(COALESCE(end_time, start_time)) as last_activity,
+			COALESCE(AVG(date_diff('second', start_time, end_time)), 0) as avg_duration
+		FROM sessions
+		WHERE project_id = ?
+	`
+
+	var firstActivity, lastActivity sql.NullTime
+	err := p.db.QueryRow(query, projectID).Scan(
+		&stats.SessionCount,
+		&stats.MessageCount,
+		&stats.TotalTokens,
+		&firstActivity,
+		&lastActivity,
+		&stats.AverageSessionDurationSec,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query project stats: %w", err)
+	}
+
+	if firstActivity.Valid {
+		stats.FirstActivity = &firstActivity.Time
+	}
+	if lastActivity.Valid {
+		stats.LastActivity = &lastActivity.Time
+	}
+
+	if stats.SessionCount == 0 {
+		return stats, nil
+	}
+
+	// Cost depends on per-model pricing, so aggregate tokens per model
+	costQuery := `
+		SELECT 
+			m.model,
+			COALESCE(SUM(m.input_tokens), 0),
+			COALESCE(SUM(m.output_tokens), 0),
+			COALESCE(SUM(m.cache_creation_input_tokens), 0),
+			COALESCE(SUM(m.cache_read_input_tokens), 0)
+		FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		WHERE s.project_id = ?
+		AND m.message_role = 'assistant'
+		AND m.model IS NOT NULL
+		GROUP BY m.model
+	`
+
+	rows, err := p.db.Query(costQuery, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query project cost: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var model string
+		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int
+		if err := rows.Scan(&model, &inputTokens, &outputTokens, &cacheCreationTokens, &cacheReadTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan project cost: %w", err)
+		}
+		stats.TotalCost += p.pricingCalculator.CalculateCost(model, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over project cost: %w", err)
+	}
+
+	stats.TotalCost = roundToDecimals(stats.TotalCost, 6)
+	return stats, nil
+}
+
+// GetProjectCostStats returns the mean, median, 90th percentile and total of the
+// project's session costs. Percentiles are interpolated between sessions.
+// Projects without sessions return zero values rather than an error.
+func (p *ProjectService) GetProjectCostStats(projectID string) (*models.ProjectCostStats, error) {
+	stats := &models.ProjectCostStats{ProjectID: projectID}
+
+	query := `
+		SELECT 
+			COUNT(*) as session_count,
+			COALESCE(SUM(cost), 0) as total_cost,
+			COALESCE(AVG(cost), 0) as mean_cost,
+			COALESCE(QUANTILE_CONT(cost, 0.5), 0) as median_cost,
+			COALESCE(QUANTILE_CONT(cost, 0.9), 0) as p90_cost
+		FROM (
+			SELECT COALESCE(total_cost, 0) as cost
+			FROM sessions
+			WHERE project_id = ?
+		)
+	`
+
+	err := p.db.QueryRow(query, projectID).Scan(
+		&stats.SessionCount,
+		&stats.TotalCost,
+		&stats.MeanCost,
+		&stats.MedianCost,
+		&stats.P90Cost,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query project cost stats: %w", err)
+	}
+
+	stats.TotalCost = roundToDecimals(stats.TotalCost, 6)
+	stats.MeanCost = roundToDecimals(stats.MeanCost, 6)
+	stats.MedianCost = roundToDecimals(stats.MedianCost, 6)
+	stats.P90Cost = roundToDecimals(stats.P90Cost, 6)
+	return stats, nil
+}
+
+// Project leaderboard metrics
+const (
+	LeaderboardMetricTokens = "tokens"
+	LeaderboardMetricCost   = "cost"
+
+	DEFAULT_LEADERBOARD_LIMIT = 10
+	MAX_LEADERBOARD_LIMIT     = 100
+)
+
+// IsValidLeaderboardMetric reports whether metric is a supported leaderboard metric
+func IsValidLeaderboardMetric(metric string) bool {
+	return metric == LeaderboardMetricTokens || metric == LeaderboardMetricCost
+}
+
+// GetLeaderboard ranks active projects by the total tokens or cost of their sessions,
+// counting sessions started in [from, to) when either bound is given. Projects without
+// sessions in the range are left out rather than listed with zeros.
+func (p *ProjectService) GetLeaderboard(metric string, limit int, from, to *time.Time) ([]models.ProjectLeaderboardEntry, error) {
+	if !IsValidLeaderboardMetric(metric) {
+		return nil, fmt.Errorf("invalid leaderboard metric: %s", metric)
+	}
+
+	orderBy := "total_tokens DESC, total_cost DESC"
+	if metric == LeaderboardMetricCost {
+		orderBy = "total_cost DESC, total_tokens DESC"
+	}
+
+	conditions := []string{"p.is_active = true"}
+	args := []interface{}{}
+	if from != nil {
+		conditions = append(conditions, "s.start_time >= ?")
+		args = append(args, *from)
+	}
+	if to != nil {
+		conditions = append(conditions, "s.start_time < ?")
+		args = append(args, *to)
+	}
+	args = append(args, limit)
+
+	query := `
+		SELECT 
+			p.id, p.name, p.path,
+			COUNT(s.id) as session_count,
+			COALESCE(SUM(s.total_tokens), 0) as total_tokens,
+			COALESCE(SUM(s.total_cost), 0) as total_cost
+		FROM projects p
+		INNER JOIN sessions s ON s.project_id = p.id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY p.id, p.name, p.path
+		ORDER BY ` + orderBy + `, p.name ASC
+		LIMIT ?
+	`
+
+	rows, err := p.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query project leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	leaderboard := []models.ProjectLeaderboardEntry{}
+	for rows.Next() {
+		var entry models.ProjectLeaderboardEntry
+		if err := rows.Scan(&entry.ProjectID, &entry.ProjectName, &entry.ProjectPath,
+			&entry.SessionCount, &entry.TotalTokens, &entry.TotalCost); err != nil {
+			return nil, fmt.Errorf("failed to scan project leaderboard entry: %w", err)
+		}
+		entry.Rank = len(leaderboard) + 1
+		entry.TotalCost = roundToDecimals(entry.TotalCost, 6)
+		leaderboard = append(leaderboard, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over project leaderboard: %w", err)
+	}
+
+	return leaderboard, nil
+}
+
+// GetAllProjects gets all active projects that have sessions
 func (p *ProjectService) GetAllProjects() ([]models.Project, error) {
+	return p.GetProjects(false)
+}
+
+// GetProjects gets all projects that have sessions, including soft deleted ones if includeInactive is set
+func (p *ProjectService) GetProjects(includeInactive bool) ([]models.Project, error) {
+	activeFilter := "WHERE p.is_active = true"
+	if includeInactive {
+		activeFilter = ""
+	}
+	
 	// Only return projects that have sessions associated with them
 	query := `
 		SELECT DISTINCT p.id, p.name, p.path, p.description, p.repository_url, 
 		       p.language, p.framework, p.is_active, p.created_at, p.updated_at
 		FROM projects p
 		INNER JOIN sessions s ON p.id = s.project_id
-		WHERE p.is_active = true
+		` + activeFilter + `
 		ORDER BY p.name ASC
 	`
 	
@@ -201,6 +429,7 @@ func (p *ProjectService) UpdateProject(project *models.Project) error {
 		return fmt.Errorf("failed to update project: %w", err)
 	}
 	
+	p.cache.Invalidate(project.ID)
 	return nil
 }
 
@@ -217,6 +446,198 @@ func (p *ProjectService) DeleteProject(id string) error {
 		return fmt.Errorf("failed to delete project: %w", err)
 	}
 	
+	p.cache.Invalidate(id)
+	return nil
+}
+
+// RestoreProject restores a soft deleted project (sets is_active back to true).
+// Sessions keep their project_id when a project is deleted, so they are linked again as they were.
+func (p *ProjectService) RestoreProject(id string) error {
+	query := `
+		UPDATE projects
+		SET is_active = true, updated_at = ?
+		WHERE id = ?
+	`
+	
+	result, err := p.db.Exec(query, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to restore project: %w", err)
+	}
+	
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check restored project: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("project not found: %s", id)
+	}
+	
+	p.cache.Invalidate(id)
+	return nil
+}
+
+// MergeProjects moves every session of the source project to the target project and
+// soft deletes the source, in one transaction. Returns the number of sessions moved.
+func (p *ProjectService) MergeProjects(sourceID, targetID string) (int, error) {
+	if sourceID == targetID {
+		return 0, fmt.Errorf("source and target projects must be different")
+	}
+	for _, id := range []string{sourceID, targetID} {
+		project, err := p.GetProjectByID(id)
+		if err != nil {
+			return 0, err
+		}
+		if project == nil {
+			return 0, fmt.Errorf("project not found: %s", id)
+		}
+	}
+	
+	moved, err := p.mergeProjects(sourceID, targetID)
+	if err != nil {
+		return 0, err
+	}
+	
+	p.cache.Invalidate(sourceID)
+	p.cache.Invalidate(targetID)
+	return moved, nil
+}
+
+// mergeProjects reassigns the sessions and soft deletes the source project
+func (p *ProjectService) mergeProjects(sourceID, targetID string) (int, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin merge transaction: %w", err)
+	}
+	defer tx.Rollback()
+	
+	result, err := tx.Exec(`UPDATE sessions SET project_id = ? WHERE project_id = ?`, targetID, sourceID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reassign sessions: %w", err)
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count reassigned sessions: %w", err)
+	}
+	
+	now := time.Now()
+	if _, err := tx.Exec(`UPDATE projects SET is_active = false, updated_at = ? WHERE id = ?`, now, sourceID); err != nil {
+		return 0, fmt.Errorf("failed to delete source project: %w", err)
+	}
+	if _, err := tx.Exec(`UPDATE projects SET updated_at = ? WHERE id = ?`, now, targetID); err != nil {
+		return 0, fmt.Errorf("failed to update target project: %w", err)
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit project merge: %w", err)
+	}
+	return int(moved), nil
+}
+
+// RepairOrphanedSessions fixes sessions whose project_id is NULL or references a missing project.
+// They are reassigned to the project derived from their name and path, which is created if needed.
+// Sessions without a name or path cannot be resolved; a dangling project_id on them is set to NULL.
+// With dryRun the changes are reported without modifying data.
+func (p *ProjectService) RepairOrphanedSessions(dryRun bool) (*models.SessionRepairResult, error) {
+	rows, err := p.db.Query(`
+		SELECT s.id, s.project_id, COALESCE(s.project_name, ''), COALESCE(s.project_path, '')
+		FROM sessions s
+		LEFT JOIN projects pr ON pr.id = s.project_id
+		WHERE s.project_id IS NULL OR pr.id IS NULL
+		ORDER BY s.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orphaned sessions: %w", err)
+	}
+	
+	var orphans []models.SessionRepair
+	for rows.Next() {
+		var orphan models.SessionRepair
+		if err := rows.Scan(&orphan.SessionID, &orphan.OldProjectID, &orphan.ProjectName, &orphan.ProjectPath); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan orphaned session: %w", err)
+		}
+		orphans = append(orphans, orphan)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating orphaned sessions: %w", err)
+	}
+	
+	result := &models.SessionRepairResult{
+		DryRun:   dryRun,
+		Orphaned: len(orphans),
+		Changes:  []models.SessionRepair{},
+	}
+	
+	// Project IDs by name and path; nil when a dry run would create the project
+	resolved := make(map[[2]string]*string)
+	for _, orphan := range orphans {
+		if orphan.ProjectName == "" || orphan.ProjectPath == "" {
+			if orphan.OldProjectID == nil {
+				result.Unresolved++
+				continue
+			}
+			orphan.Action = models.SessionRepairClear
+			result.Cleared++
+			result.Changes = append(result.Changes, orphan)
+			continue
+		}
+		
+		key := [2]string{orphan.ProjectName, orphan.ProjectPath}
+		projectID, ok := resolved[key]
+		if !ok {
+			project, err := p.FindProjectByNameAndPath(orphan.ProjectName, orphan.ProjectPath)
+			if err != nil {
+				return nil, err
+			}
+			if project == nil {
+				result.ProjectsCreated++
+				if !dryRun {
+					if project, err = p.GetOrCreateProject(orphan.ProjectName, orphan.ProjectPath); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if project != nil {
+				projectID = &project.ID
+			}
+			resolved[key] = projectID
+		}
+		
+		orphan.Action = models.SessionRepairReassign
+		orphan.NewProjectID = projectID
+		result.Reassigned++
+		result.Changes = append(result.Changes, orphan)
+	}
+	
+	if dryRun || len(result.Changes) == 0 {
+		return result, nil
+	}
+	
+	if err := p.repairSessions(result.Changes); err != nil {
+		return nil, err
+	}
+	
+	return result, nil
+}
+
+// repairSessions applies the session repair changes in one transaction
+func (p *ProjectService) repairSessions(changes []models.SessionRepair) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin session repair transaction: %w", err)
+	}
+	defer tx.Rollback()
+	
+	for _, change := range changes {
+		if _, err := tx.Exec(`UPDATE sessions SET project_id = ? WHERE id = ?`, change.NewProjectID, change.SessionID); err != nil {
+			return fmt.Errorf("failed to repair session %s: %w", change.SessionID, err)
+		}
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit session repair: %w", err)
+	}
 	return nil
 }
 
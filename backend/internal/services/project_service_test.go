@@ -2,6 +2,10 @@ package services
 
 import (
 	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -57,6 +61,14 @@ func setupProjectTestDB(t *testing.T) *sql.DB {
 	return db
 }
 
+// stringValue dereferences an optional string, treating nil as empty
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
 // isValidUUID checks if a string is a valid UUID
 func isValidUUID(u string) bool {
 	_, err := uuid.Parse(u)
@@ -155,6 +167,110 @@ func TestGetOrCreateProject(t *testing.T) {
 	}
 }
 
+func TestGetOrCreateProject_DetectsMetadata(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	projectService := NewProjectService(db)
+
+	writeMarkers := func(dir string, files ...string) {
+		for _, file := range files {
+			path := filepath.Join(dir, file)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create marker directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte{}, 0644); err != nil {
+				t.Fatalf("Failed to write marker file: %v", err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name              string
+		files             []string
+		expectedLanguage  string
+		expectedFramework string
+	}{
+		{"go", []string{"go.mod"}, "Go", ""},
+		{"rust", []string{"Cargo.toml"}, "Rust", ""},
+		{"python django", []string{"requirements.txt", "manage.py"}, "Python", "Django"},
+		{"next.js", []string{"package.json", "next.config.js"}, "JavaScript", "Next.js"},
+		{"typescript", []string{"package.json", "tsconfig.json"}, "TypeScript", ""},
+		{"rails", []string{"Gemfile", "config/routes.rb"}, "Ruby", "Rails"},
+		{"no markers", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeMarkers(dir, tt.files...)
+
+			language, framework := projectService.DetectProjectMetadata(dir)
+			if language != tt.expectedLanguage || framework != tt.expectedFramework {
+				t.Errorf("Expected %q/%q, got %q/%q", tt.expectedLanguage, tt.expectedFramework, language, framework)
+			}
+
+			project, err := projectService.GetOrCreateProject(tt.name, dir)
+			if err != nil {
+				t.Fatalf("Failed to create project: %v", err)
+			}
+			if got := stringValue(project.Language); got != tt.expectedLanguage {
+				t.Errorf("Expected language %q, got %q", tt.expectedLanguage, got)
+			}
+			if got := stringValue(project.Framework); got != tt.expectedFramework {
+				t.Errorf("Expected framework %q, got %q", tt.expectedFramework, got)
+			}
+
+			// Persisted, not just set on the returned project
+			stored, err := NewProjectService(db).GetProjectByID(project.ID)
+			if err != nil {
+				t.Fatalf("Failed to get project: %v", err)
+			}
+			if stringValue(stored.Language) != tt.expectedLanguage || stringValue(stored.Framework) != tt.expectedFramework {
+				t.Errorf("Expected stored %q/%q, got %q/%q", tt.expectedLanguage, tt.expectedFramework,
+					stringValue(stored.Language), stringValue(stored.Framework))
+			}
+		})
+	}
+
+	t.Run("missing path", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "does-not-exist")
+		language, framework := projectService.DetectProjectMetadata(missing)
+		if language != "" || framework != "" {
+			t.Errorf("Expected no metadata for missing path, got %q/%q", language, framework)
+		}
+
+		project, err := projectService.GetOrCreateProject("missing", missing)
+		if err != nil {
+			t.Fatalf("Failed to create project for missing path: %v", err)
+		}
+		if project.Language != nil || project.Framework != nil {
+			t.Errorf("Expected no metadata, got %v/%v", project.Language, project.Framework)
+		}
+	})
+
+	t.Run("existing projects are not re-detected", func(t *testing.T) {
+		dir := t.TempDir()
+		project, err := projectService.GetOrCreateProject("existing", dir)
+		if err != nil {
+			t.Fatalf("Failed to create project: %v", err)
+		}
+		if project.Language != nil || project.Framework != nil {
+			t.Fatalf("Expected no metadata for an empty directory, got %v/%v", project.Language, project.Framework)
+		}
+
+		writeMarkers(dir, "go.mod", "next.config.js")
+		project, err = projectService.GetOrCreateProject("existing", dir)
+		if err != nil {
+			t.Fatalf("Failed to get project: %v", err)
+		}
+		if project.Language != nil || project.Framework != nil {
+			t.Errorf("Expected metadata to be detected only on creation, got %q/%q",
+				stringValue(project.Language), stringValue(project.Framework))
+		}
+	})
+}
+
 func TestFindProjectByNameAndPath(t *testing.T) {
 	db := setupProjectTestDB(t)
 	defer db.Close()
@@ -353,6 +469,262 @@ func TestDeleteProject(t *testing.T) {
 	}
 }
 
+func TestRestoreProject(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`ALTER TABLE sessions ADD COLUMN project_id VARCHAR`); err != nil {
+		t.Fatalf("Failed to add project_id column: %v", err)
+	}
+
+	projectService := NewProjectService(db)
+
+	project, err := projectService.GetOrCreateProject("test-project", "/test/path")
+	if err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, project_id, start_time)
+		VALUES ('session-1', 'test-project', '/test/path', ?, CURRENT_TIMESTAMP)
+	`, project.ID)
+	if err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+
+	containsProject := func(projects []models.Project) *models.Project {
+		for i := range projects {
+			if projects[i].ID == project.ID {
+				return &projects[i]
+			}
+		}
+		return nil
+	}
+
+	if err := projectService.DeleteProject(project.ID); err != nil {
+		t.Fatalf("Failed to delete project: %v", err)
+	}
+
+	active, err := projectService.GetAllProjects()
+	if err != nil {
+		t.Fatalf("Failed to get projects: %v", err)
+	}
+	if containsProject(active) != nil {
+		t.Error("Deleted project should not appear in GetAllProjects")
+	}
+
+	all, err := projectService.GetProjects(true)
+	if err != nil {
+		t.Fatalf("Failed to get projects including inactive: %v", err)
+	}
+	hidden := containsProject(all)
+	if hidden == nil {
+		t.Fatal("Expected deleted project to be listed when including inactive projects")
+	}
+	if hidden.IsActive {
+		t.Error("Expected deleted project to be listed as inactive")
+	}
+
+	if err := projectService.RestoreProject(project.ID); err != nil {
+		t.Fatalf("Failed to restore project: %v", err)
+	}
+
+	active, err = projectService.GetAllProjects()
+	if err != nil {
+		t.Fatalf("Failed to get projects: %v", err)
+	}
+	restored := containsProject(active)
+	if restored == nil || !restored.IsActive {
+		t.Fatalf("Expected restored project to be listed as active, got %+v", restored)
+	}
+
+	// The restored project is the one new sessions for the same path link to, and its sessions are kept
+	again, err := projectService.GetOrCreateProject("test-project", "/test/path")
+	if err != nil {
+		t.Fatalf("Failed to get project: %v", err)
+	}
+	if again.ID != project.ID || !again.IsActive {
+		t.Errorf("Expected restored project %s to be active, got %s (active %v)", project.ID, again.ID, again.IsActive)
+	}
+	var linked int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sessions WHERE project_id = ?", project.ID).Scan(&linked); err != nil {
+		t.Fatalf("Failed to count sessions: %v", err)
+	}
+	if linked != 1 {
+		t.Errorf("Expected 1 session linked to the restored project, got %d", linked)
+	}
+
+	if err := projectService.RestoreProject("missing"); err == nil || !strings.Contains(err.Error(), "project not found") {
+		t.Errorf("Expected project not found error, got %v", err)
+	}
+}
+
+func TestMergeProjects(t *testing.T) {
+	for _, withMessages := range []bool{false, true} {
+		t.Run(fmt.Sprintf("withMessages=%v", withMessages), func(t *testing.T) {
+			db := setupProjectTestDB(t)
+			defer db.Close()
+
+			if _, err := db.Exec(`ALTER TABLE sessions ADD COLUMN project_id VARCHAR`); err != nil {
+				t.Fatalf("Failed to add project_id column: %v", err)
+			}
+			if withMessages {
+				// As in the real schema: messages reference sessions
+				for _, query := range []string{
+					`CREATE TABLE messages (id VARCHAR PRIMARY KEY, session_id VARCHAR, FOREIGN KEY (session_id) REFERENCES sessions (id))`,
+					`CREATE TABLE session_window_messages (id VARCHAR PRIMARY KEY, message_id VARCHAR, FOREIGN KEY (message_id) REFERENCES messages (id))`,
+				} {
+					if _, err := db.Exec(query); err != nil {
+						t.Fatalf("Failed to set up schema: %v", err)
+					}
+				}
+			}
+
+			projectService := NewProjectService(db)
+			source, err := projectService.CreateProject("-Users-me-git-manavi", "/Users/me/git/manavi")
+			if err != nil {
+				t.Fatalf("Failed to create source project: %v", err)
+			}
+			target, err := projectService.CreateProject("manavi", "/Users/me/git/manavi")
+			if err != nil {
+				t.Fatalf("Failed to create target project: %v", err)
+			}
+
+			sessions := map[string]string{"s1": source.ID, "s2": source.ID, "s3": target.ID}
+			for id, projectID := range sessions {
+				_, err := db.Exec(`
+					INSERT INTO sessions (id, project_name, project_path, project_id, start_time)
+					VALUES (?, 'manavi', '/Users/me/git/manavi', ?, CURRENT_TIMESTAMP)
+				`, id, projectID)
+				if err != nil {
+					t.Fatalf("Failed to insert session: %v", err)
+				}
+				if withMessages {
+					if _, err := db.Exec(`INSERT INTO messages VALUES (?, ?)`, "m-"+id, id); err != nil {
+						t.Fatalf("Failed to insert message: %v", err)
+					}
+					if _, err := db.Exec(`INSERT INTO session_window_messages VALUES (?, ?)`, "w-"+id, "m-"+id); err != nil {
+						t.Fatalf("Failed to insert window message: %v", err)
+					}
+				}
+			}
+
+			// Cached before the merge, so a stale active source would show up below
+			if _, err := projectService.GetProjectByID(source.ID); err != nil {
+				t.Fatalf("Failed to get source project: %v", err)
+			}
+
+			moved, err := projectService.MergeProjects(source.ID, target.ID)
+			if err != nil {
+				t.Fatalf("MergeProjects failed: %v", err)
+			}
+			if moved != 2 {
+				t.Errorf("Expected 2 reassigned sessions, got %d", moved)
+			}
+
+			var targetSessions, sourceSessions int
+			db.QueryRow("SELECT COUNT(*) FROM sessions WHERE project_id = ?", target.ID).Scan(&targetSessions)
+			db.QueryRow("SELECT COUNT(*) FROM sessions WHERE project_id = ?", source.ID).Scan(&sourceSessions)
+			if targetSessions != 3 || sourceSessions != 0 {
+				t.Errorf("Expected 3 sessions on target and 0 on source, got %d and %d", targetSessions, sourceSessions)
+			}
+
+			merged, err := projectService.GetProjectByID(source.ID)
+			if err != nil || merged == nil {
+				t.Fatalf("Failed to get source project: %v", err)
+			}
+			if merged.IsActive {
+				t.Error("Expected source project to be soft deleted")
+			}
+
+			if withMessages {
+				var messages, windowMessages int
+				db.QueryRow(`SELECT COUNT(*) FROM messages m JOIN sessions s ON s.id = m.session_id WHERE s.project_id = ?`, target.ID).Scan(&messages)
+				db.QueryRow(`SELECT COUNT(*) FROM session_window_messages`).Scan(&windowMessages)
+				if messages != 3 || windowMessages != 3 {
+					t.Errorf("Expected the merged sessions to keep their messages, got %d messages and %d window messages", messages, windowMessages)
+				}
+			}
+
+			// Validation
+			if _, err := projectService.MergeProjects(target.ID, target.ID); err == nil || !strings.Contains(err.Error(), "must be different") {
+				t.Errorf("Expected merging a project into itself to fail, got %v", err)
+			}
+			if _, err := projectService.MergeProjects("missing", target.ID); err == nil || !strings.Contains(err.Error(), "project not found") {
+				t.Errorf("Expected missing source to fail, got %v", err)
+			}
+			if _, err := projectService.MergeProjects(source.ID, "missing"); err == nil || !strings.Contains(err.Error(), "project not found") {
+				t.Errorf("Expected missing target to fail, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMergeProjects_FailureRollsBack(t *testing.T) {
+	db, err := sql.Open("duckdb", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer db.Close()
+
+	// The CHECK rejects soft deleting the source, the last step of the merge
+	for _, query := range []string{
+		`CREATE TABLE projects (
+			id VARCHAR PRIMARY KEY,
+			name VARCHAR NOT NULL,
+			path VARCHAR NOT NULL,
+			description TEXT,
+			repository_url VARCHAR,
+			language VARCHAR,
+			framework VARCHAR,
+			is_active BOOLEAN DEFAULT true,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(name, path),
+			CHECK (is_active OR name <> 'undeletable')
+		)`,
+		`CREATE TABLE sessions (id VARCHAR PRIMARY KEY, project_name VARCHAR, project_path VARCHAR, project_id VARCHAR, start_time TIMESTAMP)`,
+		`CREATE TABLE messages (id VARCHAR PRIMARY KEY, session_id VARCHAR, FOREIGN KEY (session_id) REFERENCES sessions (id))`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to set up schema: %v", err)
+		}
+	}
+
+	projectService := NewProjectService(db)
+	source, err := projectService.CreateProject("undeletable", "/Users/me/git/manavi")
+	if err != nil {
+		t.Fatalf("Failed to create source project: %v", err)
+	}
+	target, err := projectService.CreateProject("manavi", "/Users/me/git/manavi")
+	if err != nil {
+		t.Fatalf("Failed to create target project: %v", err)
+	}
+	for _, id := range []string{"s1", "s2"} {
+		if _, err := db.Exec(`INSERT INTO sessions VALUES (?, 'manavi', '/Users/me/git/manavi', ?, CURRENT_TIMESTAMP)`, id, source.ID); err != nil {
+			t.Fatalf("Failed to insert session: %v", err)
+		}
+		if _, err := db.Exec(`INSERT INTO messages VALUES (?, ?)`, "m-"+id, id); err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	if _, err := projectService.MergeProjects(source.ID, target.ID); err == nil || !strings.Contains(err.Error(), "failed to delete source project") {
+		t.Fatalf("Expected the merge to fail soft deleting the source, got %v", err)
+	}
+
+	// The sessions were reassigned before the failing step; the rollback must undo it
+	var sourceSessions, messages int
+	db.QueryRow("SELECT COUNT(*) FROM sessions WHERE project_id = ?", source.ID).Scan(&sourceSessions)
+	db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&messages)
+	if sourceSessions != 2 || messages != 2 {
+		t.Errorf("Expected 2 sessions left on the source with 2 messages, got %d and %d", sourceSessions, messages)
+	}
+	merged, err := projectService.GetProjectByID(source.ID)
+	if err != nil || merged == nil || !merged.IsActive {
+		t.Errorf("Expected source project to stay active, got %+v (%v)", merged, err)
+	}
+}
+
 func TestMigrateExistingSessionsToProjects(t *testing.T) {
 	db := setupProjectTestDB(t)
 	defer db.Close()
@@ -425,4 +797,446 @@ func TestMigrateExistingSessionsToProjects(t *testing.T) {
 	} else {
 		t.Error("project-b not found after migration")
 	}
-}
\ No newline at end of file
+}
+func TestGetProjectStats(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	queries := []string{
+		`ALTER TABLE sessions ADD COLUMN project_id VARCHAR`,
+		`ALTER TABLE sessions ADD COLUMN end_time TIMESTAMP`,
+		`ALTER TABLE sessions ADD COLUMN total_tokens INTEGER DEFAULT 0`,
+		`ALTER TABLE sessions ADD COLUMN message_count INTEGER DEFAULT 0`,
+		`CREATE TABLE messages (
+			id VARCHAR PRIMARY KEY,
+			session_id VARCHAR,
+			message_role VARCHAR,
+			model VARCHAR,
+			input_tokens INTEGER DEFAULT 0,
+			cache_creation_input_tokens INTEGER DEFAULT 0,
+			cache_read_input_tokens INTEGER DEFAULT 0,
+			output_tokens INTEGER DEFAULT 0
+		)`,
+	}
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to prepare stats schema: %v", err)
+		}
+	}
+
+	projectService := NewProjectService(db)
+
+	// Project without sessions returns zeros
+	stats, err := projectService.GetProjectStats("empty-project")
+	if err != nil {
+		t.Fatalf("GetProjectStats failed for empty project: %v", err)
+	}
+	if stats.SessionCount != 0 || stats.TotalTokens != 0 || stats.TotalCost != 0 || stats.FirstActivity != nil || stats.LastActivity != nil {
+		t.Errorf("Expected zero stats for empty project, got %+v", stats)
+	}
+
+	start := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, end_time, project_id, total_tokens, message_count)
+		VALUES (?, 'p', '/p', ?, ?, 'stats-project', 1000, 4), (?, 'p', '/p', ?, ?, 'stats-project', 3000, 6), (?, 'o', '/o', ?, ?, 'other-project', 500, 1)
+	`, "s1", start, start.Add(10*time.Minute),
+		"s2", start.Add(time.Hour), start.Add(90*time.Minute),
+		"s3", start, start.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to insert sessions: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, model, input_tokens, output_tokens)
+		VALUES ('m1', 's1', 'assistant', 'claude-sonnet-4-20250514', 1000000, 0),
+		       ('m2', 's2', 'assistant', 'claude-opus-4-20250514', 0, 1000000),
+		       ('m3', 's3', 'assistant', 'claude-opus-4-20250514', 1000000, 0)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to insert messages: %v", err)
+	}
+
+	stats, err = projectService.GetProjectStats("stats-project")
+	if err != nil {
+		t.Fatalf("GetProjectStats failed: %v", err)
+	}
+
+	if stats.SessionCount != 2 {
+		t.Errorf("Expected 2 sessions, got %d", stats.SessionCount)
+	}
+	if stats.MessageCount != 10 {
+		t.Errorf("Expected 10 messages, got %d", stats.MessageCount)
+	}
+	if stats.TotalTokens != 4000 {
+		t.Errorf("Expected 4000 tokens, got %d", stats.TotalTokens)
+	}
+	if stats.TotalCost != 78.0 {
+		t.Errorf("Expected cost 78.0, got %f", stats.TotalCost)
+	}
+	if stats.FirstActivity == nil || !stats.FirstActivity.Equal(start) {
+		t.Errorf("Expected first activity %v, got %v", start, stats.FirstActivity)
+	}
+	if stats.LastActivity == nil || !stats.LastActivity.Equal(start.Add(90*time.Minute)) {
+		t.Errorf("Expected last activity %v, got %v", start.Add(90*time.Minute), stats.LastActivity)
+	}
+	// (600s + 1800s) / 2
+	if stats.AverageSessionDurationSec != 1200 {
+		t.Errorf("Expected average duration 1200s, got %f", stats.AverageSessionDurationSec)
+	}
+}
+
+func TestGetProjectCostStats(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	queries := []string{
+		`ALTER TABLE sessions ADD COLUMN project_id VARCHAR`,
+		`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0.0`,
+	}
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to prepare cost stats schema: %v", err)
+		}
+	}
+
+	projectService := NewProjectService(db)
+
+	// Project without sessions returns zeros
+	stats, err := projectService.GetProjectCostStats("empty-project")
+	if err != nil {
+		t.Fatalf("GetProjectCostStats failed for empty project: %v", err)
+	}
+	if stats.SessionCount != 0 || stats.TotalCost != 0 || stats.MeanCost != 0 || stats.MedianCost != 0 || stats.P90Cost != 0 {
+		t.Errorf("Expected zero cost stats for empty project, got %+v", stats)
+	}
+
+	start := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, project_id, total_cost)
+		VALUES ('s1', 'p', '/p', ?, 'cost-project', 1.0), ('s2', 'p', '/p', ?, 'cost-project', 2.0),
+		       ('s3', 'p', '/p', ?, 'cost-project', 3.0), ('s4', 'p', '/p', ?, 'cost-project', 10.0),
+		       ('s5', 'o', '/o', ?, 'other-project', 100.0)
+	`, start, start, start, start, start)
+	if err != nil {
+		t.Fatalf("Failed to insert sessions: %v", err)
+	}
+
+	stats, err = projectService.GetProjectCostStats("cost-project")
+	if err != nil {
+		t.Fatalf("GetProjectCostStats failed: %v", err)
+	}
+
+	if stats.SessionCount != 4 {
+		t.Errorf("Expected 4 sessions, got %d", stats.SessionCount)
+	}
+	if stats.TotalCost != 16.0 {
+		t.Errorf("Expected total cost 16.0, got %f", stats.TotalCost)
+	}
+	if stats.MeanCost != 4.0 {
+		t.Errorf("Expected mean cost 4.0, got %f", stats.MeanCost)
+	}
+	// Interpolated between 2.0 and 3.0
+	if stats.MedianCost != 2.5 {
+		t.Errorf("Expected median cost 2.5, got %f", stats.MedianCost)
+	}
+	// 70% of the way from 3.0 to 10.0
+	if stats.P90Cost != 7.9 {
+		t.Errorf("Expected p90 cost 7.9, got %f", stats.P90Cost)
+	}
+}
+
+func TestGetProjectByID_Cache(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	projectService := NewProjectService(db)
+
+	project, err := projectService.CreateProject("cached-project", "/cached/path")
+	if err != nil {
+		t.Fatalf("Failed to create project: %v", err)
+	}
+
+	// First lookup populates the cache
+	if _, err := projectService.GetProjectByID(project.ID); err != nil {
+		t.Fatalf("Failed to get project: %v", err)
+	}
+
+	// A change made behind the service's back is not seen while the entry is cached
+	if _, err := db.Exec("UPDATE projects SET language = 'Rust' WHERE id = ?", project.ID); err != nil {
+		t.Fatalf("Failed to modify project: %v", err)
+	}
+	cached, err := NewJobService(db).getProjectByID(project.ID)
+	if err != nil {
+		t.Fatalf("Failed to get cached project: %v", err)
+	}
+	if cached.Language != nil {
+		t.Errorf("Expected cached project without language, got %s", *cached.Language)
+	}
+
+	// Updating through the service evicts the entry
+	language := "Go"
+	cached.Language = &language
+	if err := projectService.UpdateProject(cached); err != nil {
+		t.Fatalf("Failed to update project: %v", err)
+	}
+	if _, err := db.Exec("UPDATE projects SET framework = 'gin' WHERE id = ?", project.ID); err != nil {
+		t.Fatalf("Failed to modify project: %v", err)
+	}
+
+	updated, err := projectService.GetProjectByID(project.ID)
+	if err != nil {
+		t.Fatalf("Failed to get updated project: %v", err)
+	}
+	if updated.Language == nil || *updated.Language != language {
+		t.Errorf("Expected language %s after update, got %v", language, updated.Language)
+	}
+	if updated.Framework == nil || *updated.Framework != "gin" {
+		t.Errorf("Expected project to be reloaded from the database, got framework %v", updated.Framework)
+	}
+}
+
+func TestProjectCache_EvictsLeastRecentlyUsedAndExpired(t *testing.T) {
+	cache := NewProjectCache(2, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Put(&models.Project{ID: "a", Name: "a", Path: "/a"})
+	cache.Put(&models.Project{ID: "b", Name: "b", Path: "/b"})
+	cache.Get("a") // "b" becomes least recently used
+	cache.Put(&models.Project{ID: "c", Name: "c", Path: "/c"})
+
+	if cache.Get("b") != nil {
+		t.Error("Expected least recently used project to be evicted")
+	}
+	if cache.Get("a") == nil || cache.GetByNameAndPath("c", "/c") == nil {
+		t.Error("Expected recently used projects to stay cached")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if cache.Get("a") != nil {
+		t.Error("Expected expired project to be evicted")
+	}
+}
+
+func TestGetLeaderboard(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	queries := []string{
+		`ALTER TABLE sessions ADD COLUMN project_id VARCHAR`,
+		`ALTER TABLE sessions ADD COLUMN total_tokens INTEGER DEFAULT 0`,
+		`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0.0`,
+	}
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to prepare leaderboard schema: %v", err)
+		}
+	}
+
+	projectService := NewProjectService(db)
+	var ids []string
+	for _, name := range []string{"alpha", "beta", "gamma", "idle"} {
+		project, err := projectService.CreateProject(name, "/"+name)
+		if err != nil {
+			t.Fatalf("Failed to create project: %v", err)
+		}
+		ids = append(ids, project.ID)
+	}
+	alpha, beta, gamma := ids[0], ids[1], ids[2]
+
+	june := time.Date(2025, 6, 15, 10, 0, 0, 0, time.UTC)
+	july := time.Date(2025, 7, 15, 10, 0, 0, 0, time.UTC)
+	// alpha uses the most tokens, beta costs the most; idle has no sessions
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, project_id, total_tokens, total_cost)
+		VALUES ('a1', 'alpha', '/alpha', ?, ?, 5000, 1.0), ('a2', 'alpha', '/alpha', ?, ?, 4000, 1.5),
+		       ('b1', 'beta', '/beta', ?, ?, 3000, 9.0),
+		       ('g1', 'gamma', '/gamma', ?, ?, 100, 0.1)
+	`, june, alpha, july, alpha, july, beta, june, gamma)
+	if err != nil {
+		t.Fatalf("Failed to insert sessions: %v", err)
+	}
+
+	byTokens, err := projectService.GetLeaderboard(LeaderboardMetricTokens, 10, nil, nil)
+	if err != nil {
+		t.Fatalf("GetLeaderboard failed: %v", err)
+	}
+	if len(byTokens) != 3 {
+		t.Fatalf("Expected 3 projects with sessions, got %+v", byTokens)
+	}
+	if byTokens[0].ProjectName != "alpha" || byTokens[0].TotalTokens != 9000 || byTokens[0].SessionCount != 2 || byTokens[0].Rank != 1 {
+		t.Errorf("Expected alpha first with 9000 tokens over 2 sessions, got %+v", byTokens[0])
+	}
+	if byTokens[1].ProjectName != "beta" || byTokens[2].ProjectName != "gamma" || byTokens[2].Rank != 3 {
+		t.Errorf("Expected beta then gamma, got %+v", byTokens)
+	}
+
+	byCost, err := projectService.GetLeaderboard(LeaderboardMetricCost, 1, nil, nil)
+	if err != nil {
+		t.Fatalf("GetLeaderboard failed: %v", err)
+	}
+	if len(byCost) != 1 || byCost[0].ProjectName != "beta" || byCost[0].TotalCost != 9.0 {
+		t.Errorf("Expected only beta ranked by cost, got %+v", byCost)
+	}
+
+	// Only July sessions: alpha keeps one session, gamma drops out
+	from := time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC)
+	inJuly, err := projectService.GetLeaderboard(LeaderboardMetricTokens, 10, &from, &to)
+	if err != nil {
+		t.Fatalf("GetLeaderboard failed: %v", err)
+	}
+	if len(inJuly) != 2 || inJuly[0].ProjectName != "alpha" || inJuly[0].TotalTokens != 4000 || inJuly[1].ProjectName != "beta" {
+		t.Errorf("Expected alpha (4000) then beta for July, got %+v", inJuly)
+	}
+
+	// Soft deleted projects are not ranked
+	if err := projectService.DeleteProject(beta); err != nil {
+		t.Fatalf("Failed to delete project: %v", err)
+	}
+	byCost, err = projectService.GetLeaderboard(LeaderboardMetricCost, 10, nil, nil)
+	if err != nil {
+		t.Fatalf("GetLeaderboard failed: %v", err)
+	}
+	for _, entry := range byCost {
+		if entry.ProjectID == beta {
+			t.Errorf("Expected deleted project to be excluded, got %+v", byCost)
+		}
+	}
+
+	if _, err := projectService.GetLeaderboard("messages", 10, nil, nil); err == nil {
+		t.Error("Expected an error for an unknown metric")
+	}
+}
+
+func TestRepairOrphanedSessions(t *testing.T) {
+	for _, withMessages := range []bool{false, true} {
+		t.Run(fmt.Sprintf("withMessages=%v", withMessages), func(t *testing.T) {
+			db := setupProjectTestDB(t)
+			defer db.Close()
+
+			if _, err := db.Exec(`ALTER TABLE sessions ADD COLUMN project_id VARCHAR`); err != nil {
+				t.Fatalf("Failed to add project_id column: %v", err)
+			}
+			if withMessages {
+				for _, query := range []string{
+					`CREATE TABLE messages (id VARCHAR PRIMARY KEY, session_id VARCHAR, FOREIGN KEY (session_id) REFERENCES sessions (id))`,
+				} {
+					if _, err := db.Exec(query); err != nil {
+						t.Fatalf("Failed to set up schema: %v", err)
+					}
+				}
+			}
+
+			projectService := NewProjectService(db)
+			existing, err := projectService.CreateProject("manavi", "/Users/me/git/manavi")
+			if err != nil {
+				t.Fatalf("Failed to create project: %v", err)
+			}
+
+			sessions := []struct {
+				id, name, path string
+				projectID      *string
+			}{
+				{"s-ok", "manavi", "/Users/me/git/manavi", &existing.ID},
+				{"s-null", "manavi", "/Users/me/git/manavi", nil},
+				{"s-dangling-1", "ccdash", "/Users/me/git/ccdash", stringPtr("deleted-project")},
+				{"s-dangling-2", "ccdash", "/Users/me/git/ccdash", stringPtr("deleted-project")},
+				{"s-unnamed-dangling", "", "", stringPtr("deleted-project")},
+				{"s-unnamed-null", "", "", nil},
+			}
+			for _, s := range sessions {
+				_, err := db.Exec(`
+					INSERT INTO sessions (id, project_name, project_path, project_id, start_time)
+					VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+				`, s.id, s.name, s.path, s.projectID)
+				if err != nil {
+					t.Fatalf("Failed to insert session: %v", err)
+				}
+				if withMessages {
+					if _, err := db.Exec(`INSERT INTO messages VALUES (?, ?)`, "m-"+s.id, s.id); err != nil {
+						t.Fatalf("Failed to insert message: %v", err)
+					}
+				}
+			}
+
+			projectIDs := func() map[string]string {
+				ids := make(map[string]string)
+				rows, err := db.Query(`SELECT id, COALESCE(project_id, '') FROM sessions`)
+				if err != nil {
+					t.Fatalf("Failed to query sessions: %v", err)
+				}
+				defer rows.Close()
+				for rows.Next() {
+					var id, projectID string
+					rows.Scan(&id, &projectID)
+					ids[id] = projectID
+				}
+				return ids
+			}
+			before := projectIDs()
+
+			checkCounts := func(result *models.SessionRepairResult) {
+				t.Helper()
+				if result.Orphaned != 5 || result.Reassigned != 3 || result.ProjectsCreated != 1 ||
+					result.Cleared != 1 || result.Unresolved != 1 || len(result.Changes) != 4 {
+					t.Errorf("Unexpected repair counts: %+v", result)
+				}
+			}
+
+			// A dry run reports the changes without making them
+			result, err := projectService.RepairOrphanedSessions(true)
+			if err != nil {
+				t.Fatalf("RepairOrphanedSessions dry run failed: %v", err)
+			}
+			if !result.DryRun {
+				t.Error("Expected the result to be marked as a dry run")
+			}
+			checkCounts(result)
+			for _, change := range result.Changes {
+				if change.SessionID == "s-null" && stringValue(change.NewProjectID) != existing.ID {
+					t.Errorf("Expected s-null to be reassigned to %s, got %+v", existing.ID, change)
+				}
+			}
+			if after := projectIDs(); fmt.Sprint(after) != fmt.Sprint(before) {
+				t.Errorf("Dry run modified sessions: %v -> %v", before, after)
+			}
+			var projectCount int
+			db.QueryRow(`SELECT COUNT(*) FROM projects`).Scan(&projectCount)
+			if projectCount != 1 {
+				t.Errorf("Dry run created projects: %d", projectCount)
+			}
+
+			result, err = projectService.RepairOrphanedSessions(false)
+			if err != nil {
+				t.Fatalf("RepairOrphanedSessions failed: %v", err)
+			}
+			checkCounts(result)
+
+			created, err := projectService.FindProjectByNameAndPath("ccdash", "/Users/me/git/ccdash")
+			if err != nil || created == nil {
+				t.Fatalf("Expected the ccdash project to be created: %v", err)
+			}
+			expected := map[string]string{
+				"s-ok":               existing.ID,
+				"s-null":             existing.ID,
+				"s-dangling-1":       created.ID,
+				"s-dangling-2":       created.ID,
+				"s-unnamed-dangling": "",
+				"s-unnamed-null":     "",
+			}
+			if after := projectIDs(); fmt.Sprint(after) != fmt.Sprint(expected) {
+				t.Errorf("Expected sessions %v, got %v", expected, after)
+			}
+
+			// Only the unresolvable session is left
+			result, err = projectService.RepairOrphanedSessions(false)
+			if err != nil {
+				t.Fatalf("RepairOrphanedSessions failed: %v", err)
+			}
+			if result.Orphaned != 2 || result.Unresolved != 2 || len(result.Changes) != 0 {
+				t.Errorf("Expected nothing left to repair, got %+v", result)
+			}
+		})
+	}
+}
@@ -12,9 +12,14 @@ import (
 	"ccdash-backend/internal/models"
 )
 
+// DEFAULT_SESSION_ACTIVE_TIMEOUT is how long a session may go without messages and still
+// count as active
+const DEFAULT_SESSION_ACTIVE_TIMEOUT = 30 * time.Minute
+
 // SessionActivityDetector provides advanced session state detection
 type SessionActivityDetector struct {
-	db *sql.DB
+	db            *sql.DB
+	activeTimeout time.Duration // Inactivity after which a session is no longer active
 }
 
 // SessionPattern represents the pattern of messages in a session
@@ -42,7 +47,18 @@ type SessionActivityScore struct {
 
 // NewSessionActivityDetector creates a new session activity detector
 func NewSessionActivityDetector(db *sql.DB) *SessionActivityDetector {
-	return &SessionActivityDetector{db: db}
+	return &SessionActivityDetector{
+		db:            db,
+		activeTimeout: DEFAULT_SESSION_ACTIVE_TIMEOUT,
+	}
+}
+
+// SetActiveTimeout sets how long a session may go without messages and still count as active.
+// Non-positive values keep the current timeout.
+func (s *SessionActivityDetector) SetActiveTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		s.activeTimeout = timeout
+	}
 }
 
 // IsSessionActive determines if a session is currently active using multiple criteria
@@ -56,6 +72,58 @@ func (s *SessionActivityDetector) IsSessionActive(sessionID string, session mode
 	return score.IsActive
 }
 
+// BatchIsActive computes a cheap, message-recency based active flag for many sessions in one query.
+// Unlike IsSessionActive it skips process, file and pattern checks, so it is suitable for session lists.
+// Unknown session IDs are reported as inactive.
+func (s *SessionActivityDetector) BatchIsActive(sessionIDs []string) (map[string]bool, error) {
+	active := make(map[string]bool, len(sessionIDs))
+	if len(sessionIDs) == 0 {
+		return active, nil
+	}
+	
+	placeholders := make([]string, len(sessionIDs))
+	args := make([]interface{}, len(sessionIDs))
+	for i, sessionID := range sessionIDs {
+		placeholders[i] = "?"
+		args[i] = sessionID
+	}
+	
+	// Sessions without messages fall back to their start time
+	query := `
+		SELECT s.id, COALESCE(s.status, ''), s.end_time, COALESCE(MAX(m.timestamp), s.start_time, s.created_at)
+		FROM sessions s
+		LEFT JOIN messages m ON m.session_id = s.id
+		WHERE s.id IN (` + strings.Join(placeholders, ", ") + `)
+		GROUP BY s.id, s.status, s.end_time, s.start_time, s.created_at
+	`
+	
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session activity: %w", err)
+	}
+	defer rows.Close()
+	
+	now := time.Now()
+	for rows.Next() {
+		var session models.Session
+		var lastActivity sql.NullTime
+		if err := rows.Scan(&session.ID, &session.Status, &session.EndTime, &lastActivity); err != nil {
+			return nil, fmt.Errorf("failed to scan session activity: %w", err)
+		}
+		active[session.ID] = lastActivity.Valid && isRecentlyActive(session, lastActivity.Time, now, s.activeTimeout)
+	}
+	
+	return active, rows.Err()
+}
+
+// isRecentlyActive reports whether a session that has not ended had a message within timeout of now
+func isRecentlyActive(session models.Session, lastActivity, now time.Time, timeout time.Duration) bool {
+	if session.Status == "completed" || session.Status == "failed" || session.EndTime != nil {
+		return false
+	}
+	return now.Sub(lastActivity) < timeout
+}
+
 // CalculateActivityScore calculates a comprehensive activity score for a session
 func (s *SessionActivityDetector) CalculateActivityScore(sessionID string, session models.Session, lastActivity time.Time) SessionActivityScore {
 	score := SessionActivityScore{}
@@ -75,9 +143,9 @@ func (s *SessionActivityDetector) CalculateActivityScore(sessionID string, sessi
 	// Calculate total score
 	score.TotalScore = score.ProcessScore*0.4 + score.FileScore*0.3 + score.MessageScore*0.2 + score.PatternScore*0.1
 
-	// Determine if session is active based on message score alone for now
+	// Determine if session is active based on message recency alone for now
 	// This is a simplified approach for compatibility with existing tests
-	score.IsActive = score.MessageScore >= 0.5
+	score.IsActive = !lastActivity.IsZero() && time.Since(lastActivity) < s.activeTimeout
 
 	// Determine inactive reason
 	if !score.IsActive {
@@ -415,6 +483,7 @@ func (s *SessionActivityDetector) GetSessionActivityReport(sessionID string, ses
 		},
 		"inactive_reason":        score.InactiveReason,
 		"recommended_timeout":    score.RecommendedTimeout.String(),
+		"active_timeout":         s.activeTimeout.String(),
 		"last_activity":         lastActivity.Format(time.RFC3339),
 		"time_since_activity":   time.Since(lastActivity).String(),
 	}
@@ -48,6 +48,7 @@ func setupTestDBForActivity(t *testing.T) (*sql.DB, *SessionActivityDetector) {
 			output_tokens INTEGER DEFAULT 0,
 			service_tier TEXT,
 			request_id TEXT,
+			cost DOUBLE DEFAULT 0.0,
 			timestamp TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
@@ -492,4 +493,113 @@ func TestDetermineInactiveReason(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestBatchIsActive(t *testing.T) {
+	db, detector := setupTestDBForActivity(t)
+	defer db.Close()
+
+	now := time.Now()
+	testSessions := []struct {
+		id        string
+		startTime time.Time
+		endTime   *time.Time
+		status    string
+		lastMsg   *time.Time
+		expected  bool
+	}{
+		{"batch-recent", now.Add(-time.Hour), nil, "active", timePtr(now.Add(-5 * time.Minute)), true},
+		{"batch-idle", now.Add(-2 * time.Hour), nil, "active", timePtr(now.Add(-45 * time.Minute)), false},
+		{"batch-completed", now.Add(-time.Hour), nil, "completed", timePtr(now.Add(-time.Minute)), false},
+		{"batch-ended", now.Add(-time.Hour), &now, "active", timePtr(now.Add(-time.Minute)), false},
+		{"batch-new", now.Add(-2 * time.Minute), nil, "active", nil, true},
+	}
+	for _, ts := range testSessions {
+		_, err := db.Exec(`
+			INSERT INTO sessions (id, project_name, project_path, start_time, end_time, status)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, ts.id, "test-project", "/test/path", ts.startTime, ts.endTime, ts.status)
+		if err != nil {
+			t.Fatalf("Failed to insert test session: %v", err)
+		}
+		if ts.lastMsg != nil {
+			_, err = db.Exec(`
+				INSERT INTO messages (id, session_id, message_role, content, timestamp)
+				VALUES (?, ?, ?, ?, ?)
+			`, ts.id+"-msg", ts.id, "user", "hello", *ts.lastMsg)
+			if err != nil {
+				t.Fatalf("Failed to insert test message: %v", err)
+			}
+		}
+	}
+
+	ids := []string{"unknown-session"}
+	for _, ts := range testSessions {
+		ids = append(ids, ts.id)
+	}
+
+	active, err := detector.BatchIsActive(ids)
+	if err != nil {
+		t.Fatalf("BatchIsActive failed: %v", err)
+	}
+	for _, ts := range testSessions {
+		if active[ts.id] != ts.expected {
+			t.Errorf("Session %s: expected active %v, got %v", ts.id, ts.expected, active[ts.id])
+		}
+	}
+	if active["unknown-session"] {
+		t.Error("Expected unknown session to be inactive")
+	}
+
+	empty, err := detector.BatchIsActive(nil)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("Expected empty result for no sessions, got %v (err %v)", empty, err)
+	}
+}
+
+func TestSessionActiveTimeout(t *testing.T) {
+	db, detector := setupTestDBForActivity(t)
+	defer db.Close()
+
+	lastActivity := time.Now().Add(-40 * time.Minute)
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, status)
+		VALUES (?, ?, ?, ?, ?)
+	`, "idle-session", "test-project", "/test/path", lastActivity.Add(-time.Hour), "active")
+	if err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, content, timestamp)
+		VALUES (?, ?, ?, ?, ?)
+	`, "idle-session-msg", "idle-session", "user", "hello", lastActivity)
+	if err != nil {
+		t.Fatalf("Failed to insert test message: %v", err)
+	}
+
+	session := models.Session{ID: "idle-session", Status: "active"}
+	testCases := []struct {
+		timeout  time.Duration
+		expected bool
+	}{
+		{45 * time.Minute, true},
+		{20 * time.Minute, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.timeout.String(), func(t *testing.T) {
+			detector.SetActiveTimeout(tc.timeout)
+
+			if active := detector.IsSessionActive(session.ID, session, lastActivity); active != tc.expected {
+				t.Errorf("IsSessionActive: expected %v, got %v", tc.expected, active)
+			}
+
+			active, err := detector.BatchIsActive([]string{session.ID})
+			if err != nil {
+				t.Fatalf("BatchIsActive failed: %v", err)
+			}
+			if active[session.ID] != tc.expected {
+				t.Errorf("BatchIsActive: expected %v, got %v", tc.expected, active[session.ID])
+			}
+		})
+	}
+}
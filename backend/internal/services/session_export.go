@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"ccdash-backend/internal/models"
+)
+
+// Session export formats
+const (
+	SessionExportFormatMarkdown = "markdown"
+	SessionExportFormatJSON     = "json"
+	SessionExportFormatJSONL    = "jsonl"
+)
+
+// SessionExport is a rendered session transcript ready to be downloaded
+type SessionExport struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// IsValidSessionExportFormat reports whether format is a supported export format
+func IsValidSessionExportFormat(format string) bool {
+	return format == SessionExportFormatMarkdown || format == SessionExportFormatJSON || format == SessionExportFormatJSONL
+}
+
+// ExportSession renders a session's messages in the given format.
+// Markdown includes a frontmatter header with the project, token totals and cost;
+// JSON is the raw message list and JSONL is one Claude log entry per message, which
+// imports back into the same message rows. Returns nil if the session does not exist.
+func (s *SessionService) ExportSession(sessionID, format string) (*SessionExport, error) {
+	if !IsValidSessionExportFormat(format) {
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+
+	session, err := s.GetSessionByID(sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := s.GetSessionMessages(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = []models.Message{}
+	}
+
+	filename := "session-" + unsafeFilenameChars.ReplaceAllString(sessionID, "_")
+
+	if format == SessionExportFormatJSON {
+		content, err := json.MarshalIndent(messages, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode session messages: %w", err)
+		}
+		return &SessionExport{
+			Filename:    filename + ".json",
+			ContentType: "application/json; charset=utf-8",
+			Content:     content,
+		}, nil
+	}
+
+	if format == SessionExportFormatJSONL {
+		content, err := renderSessionJSONL(session, messages)
+		if err != nil {
+			return nil, err
+		}
+		return &SessionExport{
+			Filename:    filename + ".jsonl",
+			ContentType: "application/x-ndjson; charset=utf-8",
+			Content:     content,
+		}, nil
+	}
+
+	return &SessionExport{
+		Filename:    filename + ".md",
+		ContentType: "text/markdown; charset=utf-8",
+		Content:     []byte(renderSessionMarkdown(session, messages)),
+	}, nil
+}
+
+// renderSessionMarkdown renders messages in order under a header per message
+func renderSessionMarkdown(session *models.SessionSummary, messages []models.Message) string {
+	var b strings.Builder
+
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "session_id: %q\n", session.ID)
+	fmt.Fprintf(&b, "project: %q\n", session.ProjectName)
+	fmt.Fprintf(&b, "project_path: %q\n", session.ProjectPath)
+	fmt.Fprintf(&b, "start_time: %s\n", session.StartTime.UTC().Format(time.RFC3339))
+	if session.EndTime != nil {
+		fmt.Fprintf(&b, "end_time: %s\n", session.EndTime.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "message_count: %d\n", len(messages))
+	fmt.Fprintf(&b, "total_input_tokens: %d\n", session.TotalInputTokens)
+	fmt.Fprintf(&b, "total_output_tokens: %d\n", session.TotalOutputTokens)
+	fmt.Fprintf(&b, "total_tokens: %d\n", session.TotalTokens)
+	fmt.Fprintf(&b, "total_cost: %.6f\n", session.TotalCost)
+	b.WriteString("---\n\n")
+
+	fmt.Fprintf(&b, "# Session %s\n", session.ID)
+
+	for _, message := range messages {
+		if message.Content == nil || strings.TrimSpace(*message.Content) == "" {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n## %s", messageHeading(message))
+		if message.Model != nil && *message.Model != "" {
+			fmt.Fprintf(&b, " (%s)", *message.Model)
+		}
+		fmt.Fprintf(&b, " · %s\n\n", message.Timestamp.UTC().Format(time.RFC3339))
+
+		content := strings.TrimRight(*message.Content, "\n")
+		b.WriteString(content)
+		b.WriteString("\n")
+
+		// Close a code block left open so it does not swallow the following messages
+		if strings.Count("\n"+content, "\n```")%2 == 1 {
+			b.WriteString("```\n")
+		}
+	}
+
+	return b.String()
+}
+
+// renderSessionJSONL rebuilds the log entries the messages were imported from.
+// The session's project path is used as the cwd, so re-importing resolves the same session.
+func renderSessionJSONL(session *models.SessionSummary, messages []models.Message) ([]byte, error) {
+	var b bytes.Buffer
+	for _, message := range messages {
+		entry := models.LogEntry{
+			ParentUUID:  message.ParentUUID,
+			IsSidechain: message.IsSidechain,
+			Cwd:         session.ProjectPath,
+			SessionID:   message.SessionID,
+			RequestID:   message.RequestID,
+			UUID:        message.ID,
+			Timestamp:   message.Timestamp,
+			Message: models.LogMessage{
+				Type:  message.MessageType,
+				Model: message.Model,
+			},
+		}
+		if message.UserType != nil {
+			entry.UserType = *message.UserType
+		}
+		if message.MessageRole != nil {
+			entry.Message.Role = *message.MessageRole
+			entry.Type = *message.MessageRole
+		}
+		if message.Content != nil {
+			entry.Message.Content = exportedContent(*message.Content)
+		}
+		// Messages imported with usage always have a service tier, even an empty one
+		if message.ServiceTier != nil {
+			entry.Message.Usage = &models.Usage{
+				InputTokens:              message.InputTokens,
+				CacheCreationInputTokens: message.CacheCreationInputTokens,
+				CacheReadInputTokens:     message.CacheReadInputTokens,
+				OutputTokens:             message.OutputTokens,
+				ServiceTier:              *message.ServiceTier,
+			}
+		}
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode log entry for message %s: %w", message.ID, err)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+// exportedContent returns structured content (content blocks stored as JSON) as JSON
+// so it is exported as it was logged. Anything that would not be stored back as the
+// same string, such as plain text, stays a string.
+func exportedContent(content string) interface{} {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" || (trimmed[0] != '[' && trimmed[0] != '{') {
+		return content
+	}
+
+	var structured interface{}
+	if err := json.Unmarshal([]byte(content), &structured); err != nil {
+		return content
+	}
+	if reencoded, err := json.Marshal(structured); err != nil || string(reencoded) != content {
+		return content
+	}
+	return json.RawMessage(content)
+}
+
+// messageHeading returns the capitalized role of a message, marking sidechain messages
+func messageHeading(message models.Message) string {
+	role := "message"
+	if message.MessageRole != nil && *message.MessageRole != "" {
+		role = *message.MessageRole
+	} else if message.MessageType != nil && *message.MessageType != "" {
+		role = *message.MessageType
+	}
+
+	heading := strings.ToUpper(role[:1]) + role[1:]
+	if message.IsSidechain {
+		heading += " (sidechain)"
+	}
+	return heading
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"database/sql"
 	"testing"
+	"time"
 
 	_ "github.com/marcboeker/go-duckdb"
 )
@@ -64,9 +65,17 @@ func setupIntegrationTestDB(t *testing.T) *sql.DB {
 			output_tokens INTEGER DEFAULT 0,
 			service_tier VARCHAR,
 			request_id VARCHAR,
+			cost DOUBLE DEFAULT 0.0,
 			timestamp TIMESTAMP NOT NULL,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		)`,
+		
+		`CREATE TABLE session_tags (
+			session_id VARCHAR NOT NULL,
+			tag VARCHAR NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (session_id, tag)
+		)`,
 	}
 	
 	for _, query := range queries {
@@ -361,4 +370,84 @@ func TestExistingSessionUpdateWithProject(t *testing.T) {
 	if sessionCount != 1 {
 		t.Errorf("Expected 1 session, got %d", sessionCount)
 	}
-}
\ No newline at end of file
+}
+// TestGetSessionsByProjectPaginated tests paging and sorting a project's sessions
+func TestGetSessionsByProjectPaginated(t *testing.T) {
+	db := setupIntegrationTestDB(t)
+	defer db.Close()
+
+	sessionService := NewSessionService(db)
+	base := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+	sessions := []struct {
+		id     string
+		start  time.Time
+		tokens int
+		cost   float64
+	}{
+		{"oldest", base, 300, 0.5},
+		{"middle", base.Add(time.Hour), 100, 3.0},
+		{"newest", base.Add(2 * time.Hour), 200, 1.0},
+	}
+	for _, s := range sessions {
+		_, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, project_id, start_time, total_tokens, total_cost)
+			VALUES (?, 'proj', '/proj', 'project-1', ?, ?, ?)`, s.id, s.start, s.tokens, s.cost)
+		if err != nil {
+			t.Fatalf("Failed to insert session %s: %v", s.id, err)
+		}
+	}
+	_, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, project_id, start_time)
+		VALUES ('elsewhere', 'other', '/other', 'project-2', ?)`, base)
+	if err != nil {
+		t.Fatalf("Failed to insert other session: %v", err)
+	}
+
+	tests := []struct {
+		sort     string
+		expected []string
+	}{
+		{SessionSortStartTime, []string{"newest", "middle", "oldest"}},
+		{SessionSortTotalTokens, []string{"oldest", "newest", "middle"}},
+		{SessionSortTotalCost, []string{"middle", "newest", "oldest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			result, err := sessionService.GetSessionsByProjectPaginated("project-1", tt.sort, 1, 10)
+			if err != nil {
+				t.Fatalf("GetSessionsByProjectPaginated failed: %v", err)
+			}
+			if len(result.Sessions) != len(tt.expected) {
+				t.Fatalf("Expected %d sessions, got %d", len(tt.expected), len(result.Sessions))
+			}
+			for i, id := range tt.expected {
+				if result.Sessions[i].ID != id {
+					t.Errorf("Expected session %d to be %s, got %s", i, id, result.Sessions[i].ID)
+				}
+			}
+		})
+	}
+
+	// Second page of two
+	result, err := sessionService.GetSessionsByProjectPaginated("project-1", SessionSortStartTime, 2, 2)
+	if err != nil {
+		t.Fatalf("GetSessionsByProjectPaginated failed: %v", err)
+	}
+	if len(result.Sessions) != 1 || result.Sessions[0].ID != "oldest" {
+		t.Errorf("Expected only the oldest session on page 2, got %+v", result.Sessions)
+	}
+	if result.Total != 3 || result.TotalPages != 2 || result.HasNext || !result.HasPrevious {
+		t.Errorf("Unexpected pagination metadata: %+v", result)
+	}
+
+	// A page past the end is empty, not nil
+	result, err = sessionService.GetSessionsByProjectPaginated("project-1", SessionSortStartTime, 5, 2)
+	if err != nil {
+		t.Fatalf("GetSessionsByProjectPaginated failed: %v", err)
+	}
+	if result.Sessions == nil || len(result.Sessions) != 0 {
+		t.Errorf("Expected an empty page, got %+v", result.Sessions)
+	}
+
+	if _, err := sessionService.GetSessionsByProjectPaginated("project-1", "message_count", 1, 10); err == nil {
+		t.Error("Expected an error for an unsupported sort")
+	}
+}
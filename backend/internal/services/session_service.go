@@ -3,29 +3,75 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"html"
 	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 	
 	"ccdash-backend/internal/models"
 )
 
 type SessionService struct {
-	db               *sql.DB
-	activityDetector *SessionActivityDetector
-	projectService   *ProjectService // Phase 2: Add ProjectService dependency
+	db                *sql.DB
+	activityDetector  *SessionActivityDetector
+	projectService    *ProjectService // Phase 2: Add ProjectService dependency
+	pricingCalculator *PricingCalculator
 }
 
 func NewSessionService(db *sql.DB) *SessionService {
 	return &SessionService{
-		db:               db,
-		activityDetector: NewSessionActivityDetector(db),
-		projectService:   NewProjectService(db), // Phase 2: Initialize ProjectService
+		db:                db,
+		activityDetector:  NewSessionActivityDetector(db),
+		projectService:    NewProjectService(db), // Phase 2: Initialize ProjectService
+		pricingCalculator: NewPricingCalculator(),
 	}
 }
 
+// SetActiveTimeout sets how long a session may go without messages and still count as active
+func (s *SessionService) SetActiveTimeout(timeout time.Duration) {
+	s.activityDetector.SetActiveTimeout(timeout)
+}
+
 func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
-	// Simplified query without JOIN for better performance
+	return s.GetSessionsFiltered(models.SessionFilters{})
+}
+
+// GetSessionsFiltered returns sessions matching the filters, newest first.
+// With ActiveOnly the activity detector runs only on the fetched page.
+func (s *SessionService) GetSessionsFiltered(filters models.SessionFilters) ([]models.SessionSummary, error) {
+	var conditions []string
+	var args []interface{}
+	
+	if filters.From != nil {
+		conditions = append(conditions, "COALESCE(s.start_time, s.created_at) >= ?")
+		args = append(args, *filters.From)
+	}
+	if filters.To != nil {
+		conditions = append(conditions, "COALESCE(s.start_time, s.created_at) <= ?")
+		args = append(args, *filters.To)
+	}
+	if filters.Status != nil {
+		conditions = append(conditions, "s.status = ?")
+		args = append(args, *filters.Status)
+	}
+	if filters.Tag != nil {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM session_tags t WHERE t.session_id = s.id AND t.tag = ?)")
+		args = append(args, *filters.Tag)
+	}
+	
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	
+	limitClause := ""
+	if filters.Limit > 0 {
+		limitClause = "LIMIT ? OFFSET ?"
+		args = append(args, filters.Limit, filters.Offset)
+	}
+	
+	// Last activity comes from one grouped subquery rather than a query per session
 	query := `
 		SELECT 
 			s.id,
@@ -40,22 +86,30 @@ func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
 			s.message_count,
 			s.total_cost,
 			s.status,
-			s.created_at
+			s.created_at,
+			la.last_activity
 		FROM sessions s
-		ORDER BY s.start_time DESC
-	`
+		LEFT JOIN (
+			SELECT session_id, MAX(timestamp) as last_activity
+			FROM messages
+			GROUP BY session_id
+		) la ON la.session_id = s.id
+		` + whereClause + `
+		ORDER BY COALESCE(s.start_time, s.created_at) DESC, s.id
+		` + limitClause
 	
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sessions: %w", err)
 	}
 	defer rows.Close()
 	
 	var sessions []models.SessionSummary
+	now := time.Now()
 	
 	for rows.Next() {
 		var session models.SessionSummary
-		var startTime sql.NullTime
+		var startTime, lastActivity sql.NullTime
 		
 		err := rows.Scan(
 			&session.ID,
@@ -71,6 +125,7 @@ func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
 			&session.TotalCost,
 			&session.Status,
 			&session.CreatedAt,
+			&lastActivity,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan session: %w", err)
@@ -83,9 +138,14 @@ func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
 			session.StartTime = session.CreatedAt
 		}
 		
-		// Set default values for performance (avoid additional queries)
-		session.LastActivity = session.StartTime  // Use start_time as fallback
-		session.IsActive = false  // Default to inactive for list view
+		// Sessions without messages fall back to their start time
+		session.LastActivity = session.StartTime
+		if lastActivity.Valid {
+			session.LastActivity = lastActivity.Time
+		}
+		
+		// Cheap recency check; the full activity detector is too slow to run per listed session
+		session.IsActive = isRecentlyActive(session.Session, session.LastActivity, now, s.activityDetector.activeTimeout)
 		
 		if session.EndTime != nil {
 			duration := session.EndTime.Sub(session.StartTime)
@@ -99,9 +159,47 @@ func (s *SessionService) GetAllSessions() ([]models.SessionSummary, error) {
 		sessions = append(sessions, session)
 	}
 	
+	if filters.ActiveOnly {
+		sessions, err = s.filterActiveSessions(sessions)
+		if err != nil {
+			return nil, err
+		}
+	}
+	
+	if err := s.attachTags(sessions); err != nil {
+		return nil, err
+	}
+	
 	return sessions, nil
 }
 
+// filterActiveSessions keeps the sessions the cheap batch activity check considers active
+func (s *SessionService) filterActiveSessions(sessions []models.SessionSummary) ([]models.SessionSummary, error) {
+	if len(sessions) == 0 {
+		return sessions, nil
+	}
+	
+	sessionIDs := make([]string, len(sessions))
+	for i, session := range sessions {
+		sessionIDs[i] = session.ID
+	}
+	
+	active, err := s.activityDetector.BatchIsActive(sessionIDs)
+	if err != nil {
+		return nil, err
+	}
+	
+	activeSessions := []models.SessionSummary{}
+	for _, session := range sessions {
+		session.IsActive = active[session.ID]
+		if session.IsActive {
+			activeSessions = append(activeSessions, session)
+		}
+	}
+	
+	return activeSessions, nil
+}
+
 func (s *SessionService) GetSessionByID(sessionID string) (*models.SessionSummary, error) {
 	query := `
 		SELECT 
@@ -184,6 +282,12 @@ func (s *SessionService) GetSessionByID(sessionID string) (*models.SessionSummar
 	}
 	session.GeneratedCode = generatedCode
 	
+	tags, err := s.GetTags(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	session.Tags = tags
+	
 	return &session, nil
 }
 
@@ -193,7 +297,7 @@ func (s *SessionService) GetSessionMessages(sessionID string) ([]models.Message,
 			id, session_id, parent_uuid, is_sidechain, user_type, message_type,
 			message_role, model, content, input_tokens, cache_creation_input_tokens,
 			cache_read_input_tokens, output_tokens, service_tier, request_id,
-			timestamp, created_at
+			COALESCE(cost, 0), timestamp, created_at
 		FROM messages 
 		WHERE session_id = ?
 		ORDER BY timestamp ASC
@@ -225,6 +329,7 @@ func (s *SessionService) GetSessionMessages(sessionID string) ([]models.Message,
 			&message.OutputTokens,
 			&message.ServiceTier,
 			&message.RequestID,
+			&message.Cost,
 			&message.Timestamp,
 			&message.CreatedAt,
 		)
@@ -238,6 +343,229 @@ func (s *SessionService) GetSessionMessages(sessionID string) ([]models.Message,
 	return messages, nil
 }
 
+// UnknownModelKey groups assistant messages that have no model recorded
+const UnknownModelKey = "unknown"
+
+// GetSessionCostByModel returns the session cost split by model for assistant messages.
+// Messages with a NULL model are grouped under UnknownModelKey and contribute zero cost.
+func (s *SessionService) GetSessionCostByModel(sessionID string) (map[string]float64, error) {
+	query := `
+		SELECT 
+			model,
+			COALESCE(SUM(input_tokens), 0) as total_input_tokens,
+			COALESCE(SUM(output_tokens), 0) as total_output_tokens,
+			COALESCE(SUM(cache_creation_input_tokens), 0) as total_cache_creation_tokens,
+			COALESCE(SUM(cache_read_input_tokens), 0) as total_cache_read_tokens
+		FROM messages 
+		WHERE session_id = ? 
+		AND message_role = 'assistant'
+		GROUP BY model
+	`
+	
+	rows, err := s.db.Query(query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages for session cost by model: %w", err)
+	}
+	defer rows.Close()
+	
+	costByModel := make(map[string]float64)
+	
+	for rows.Next() {
+		var model sql.NullString
+		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int
+		
+		err := rows.Scan(&model, &inputTokens, &outputTokens, &cacheCreationTokens, &cacheReadTokens)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message data for session cost by model: %w", err)
+		}
+		
+		if !model.Valid || model.String == "" {
+			costByModel[UnknownModelKey] = 0.0
+			continue
+		}
+		
+		costByModel[model.String] += s.pricingCalculator.CalculateCost(
+			model.String,
+			inputTokens,
+			outputTokens,
+			cacheCreationTokens,
+			cacheReadTokens,
+		)
+	}
+	
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over messages for session cost by model: %w", err)
+	}
+	
+	return costByModel, nil
+}
+
+const (
+	MAX_SEARCH_QUERY_LENGTH = 200
+	SEARCH_SNIPPET_CONTEXT  = 80 // Characters of context kept on each side of a match
+)
+
+// MessageSearchResult represents paginated message search results
+type MessageSearchResult struct {
+	Query       string                    `json:"query"`
+	Hits        []models.MessageSearchHit `json:"hits"`
+	Total       int                       `json:"total"`
+	Page        int                       `json:"page"`
+	PageSize    int                       `json:"page_size"`
+	TotalPages  int                       `json:"total_pages"`
+	HasNext     bool                      `json:"has_next"`
+	HasPrevious bool                      `json:"has_previous"`
+}
+
+// likeContainsPattern returns an ILIKE pattern matching text anywhere, with LIKE wildcards
+// escaped so the text is matched literally. Use it with ESCAPE '\'.
+func likeContainsPattern(text string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + escaper.Replace(text) + "%"
+}
+
+// SearchMessages returns up to limit messages whose content contains query (case-insensitive)
+func (s *SessionService) SearchMessages(query string, limit int) ([]models.MessageSearchHit, error) {
+	result, err := s.SearchMessagesPaginated(query, "", 1, limit)
+	if err != nil {
+		return nil, err
+	}
+	return result.Hits, nil
+}
+
+// SearchMessagesPaginated searches message content, optionally restricted to a project
+func (s *SessionService) SearchMessagesPaginated(query, projectID string, page, pageSize int) (*MessageSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("search query is required")
+	}
+	if len(query) > MAX_SEARCH_QUERY_LENGTH {
+		return nil, fmt.Errorf("search query exceeds %d characters", MAX_SEARCH_QUERY_LENGTH)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20 // Default page size
+	}
+
+	pattern := likeContainsPattern(query)
+
+	whereClause := `WHERE m.content ILIKE ? ESCAPE '\'`
+	args := []interface{}{pattern}
+	if projectID != "" {
+		whereClause += ` AND s.project_id = ?`
+		args = append(args, projectID)
+	}
+
+	// Get total count
+	countQuery := `
+		SELECT COUNT(*)
+		FROM messages m
+		LEFT JOIN sessions s ON m.session_id = s.id
+		` + whereClause
+	var total int
+	if err := s.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	totalPages := (total + pageSize - 1) / pageSize
+	offset := (page - 1) * pageSize
+
+	searchQuery := `
+		SELECT m.id, m.session_id, s.project_name, m.message_role, m.timestamp, m.content
+		FROM messages m
+		LEFT JOIN sessions s ON m.session_id = s.id
+		` + whereClause + `
+		ORDER BY m.timestamp DESC
+		LIMIT ? OFFSET ?
+	`
+
+	rows, err := s.db.Query(searchQuery, append(args, pageSize, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []models.MessageSearchHit{}
+	for rows.Next() {
+		var hit models.MessageSearchHit
+		var content sql.NullString
+		if err := rows.Scan(&hit.MessageID, &hit.SessionID, &hit.ProjectName, &hit.MessageRole, &hit.Timestamp, &content); err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+		hit.Snippet = buildSearchSnippet(content.String, query)
+		hits = append(hits, hit)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over search results: %w", err)
+	}
+
+	return &MessageSearchResult{
+		Query:       query,
+		Hits:        hits,
+		Total:       total,
+		Page:        page,
+		PageSize:    pageSize,
+		TotalPages:  totalPages,
+		HasNext:     page < totalPages,
+		HasPrevious: page > 1,
+	}, nil
+}
+
+// buildSearchSnippet cuts the content around the first match and wraps the match in <mark> tags.
+// Surrounding text is HTML-escaped so the snippet can be rendered safely.
+func buildSearchSnippet(content, query string) string {
+	runes := []rune(content)
+	lowerContent := strings.ToLower(content)
+	lowerQuery := strings.ToLower(query)
+
+	// ToLower maps rune by rune, so rune offsets in the lowered text are offsets in content
+	// even where lowercasing changes the byte length (e.g. "İ" becomes "i")
+	matchStart := -1
+	if i := strings.Index(lowerContent, lowerQuery); i >= 0 {
+		matchStart = utf8.RuneCountInString(lowerContent[:i])
+	}
+
+	if matchStart < 0 {
+		// No match was found; fall back to the leading text
+		end := len(runes)
+		if end > SEARCH_SNIPPET_CONTEXT*2 {
+			end = SEARCH_SNIPPET_CONTEXT * 2
+		}
+		snippet := html.EscapeString(string(runes[:end]))
+		if end < len(runes) {
+			snippet += "..."
+		}
+		return snippet
+	}
+
+	matchEnd := matchStart + utf8.RuneCountInString(lowerQuery)
+	start := matchStart - SEARCH_SNIPPET_CONTEXT
+	if start < 0 {
+		start = 0
+	}
+	end := matchEnd + SEARCH_SNIPPET_CONTEXT
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	b.WriteString(html.EscapeString(string(runes[start:matchStart])))
+	b.WriteString("<mark>")
+	b.WriteString(html.EscapeString(string(runes[matchStart:matchEnd])))
+	b.WriteString("</mark>")
+	b.WriteString(html.EscapeString(string(runes[matchEnd:end])))
+	if end < len(runes) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
 // PaginatedMessagesResult represents paginated message results
 type PaginatedMessagesResult struct {
 	Messages    []models.Message `json:"messages"`
@@ -275,7 +603,7 @@ func (s *SessionService) GetSessionMessagesPaginated(sessionID string, page, pag
 			id, session_id, parent_uuid, is_sidechain, user_type, message_type,
 			message_role, model, content, input_tokens, cache_creation_input_tokens,
 			cache_read_input_tokens, output_tokens, service_tier, request_id,
-			timestamp, created_at
+			COALESCE(cost, 0), timestamp, created_at
 		FROM messages 
 		WHERE session_id = ?
 		ORDER BY timestamp ASC
@@ -308,6 +636,7 @@ func (s *SessionService) GetSessionMessagesPaginated(sessionID string, page, pag
 			&message.OutputTokens,
 			&message.ServiceTier,
 			&message.RequestID,
+			&message.Cost,
 			&message.Timestamp,
 			&message.CreatedAt,
 		)
@@ -540,6 +869,112 @@ func (s *SessionService) GetSessionsByProject(projectID string) ([]models.Sessio
 	}
 	defer rows.Close()
 	
+	return s.scanProjectSessions(rows)
+}
+
+// Project session sort orders, all descending
+const (
+	SessionSortStartTime   = "start_time"
+	SessionSortTotalTokens = "total_tokens"
+	SessionSortTotalCost   = "total_cost"
+)
+
+// sessionSortOrders maps each session sort to its ORDER BY clause; ties fall back to newest first
+var sessionSortOrders = map[string]string{
+	SessionSortStartTime:   "s.start_time DESC, s.id",
+	SessionSortTotalTokens: "s.total_tokens DESC, s.start_time DESC, s.id",
+	SessionSortTotalCost:   "s.total_cost DESC, s.start_time DESC, s.id",
+}
+
+// IsValidSessionSort reports whether sort is a supported project session sort
+func IsValidSessionSort(sort string) bool {
+	_, ok := sessionSortOrders[sort]
+	return ok
+}
+
+// PaginatedSessionsResult represents paginated project session results
+type PaginatedSessionsResult struct {
+	Sessions    []models.SessionSummary `json:"sessions"`
+	Sort        string                  `json:"sort"`
+	Total       int                     `json:"total"`
+	Page        int                     `json:"page"`
+	PageSize    int                     `json:"page_size"`
+	TotalPages  int                     `json:"total_pages"`
+	HasNext     bool                    `json:"has_next"`
+	HasPrevious bool                    `json:"has_previous"`
+}
+
+// GetSessionsByProjectPaginated returns one page of a project's sessions in the given sort order
+func (s *SessionService) GetSessionsByProjectPaginated(projectID, sort string, page, pageSize int) (*PaginatedSessionsResult, error) {
+	orderBy, ok := sessionSortOrders[sort]
+	if !ok {
+		return nil, fmt.Errorf("invalid session sort: %s", sort)
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20 // Default page size
+	}
+
+	var total int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE project_id = ?`, projectID).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get project session count: %w", err)
+	}
+
+	totalPages := (total + pageSize - 1) / pageSize
+	offset := (page - 1) * pageSize
+
+	query := `
+		SELECT 
+			s.id,
+			s.project_name,
+			s.project_path,
+			s.project_id,
+			s.start_time,
+			s.end_time,
+			s.total_input_tokens,
+			s.total_output_tokens,
+			s.total_tokens,
+			s.message_count,
+			s.total_cost,
+			s.status,
+			s.created_at
+		FROM sessions s
+		WHERE s.project_id = ?
+		ORDER BY ` + orderBy + `
+		LIMIT ? OFFSET ?
+	`
+	
+	rows, err := s.db.Query(query, projectID, pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions by project: %w", err)
+	}
+	defer rows.Close()
+	
+	sessions, err := s.scanProjectSessions(rows)
+	if err != nil {
+		return nil, err
+	}
+	if sessions == nil {
+		sessions = []models.SessionSummary{}
+	}
+
+	return &PaginatedSessionsResult{
+		Sessions:    sessions,
+		Sort:        sort,
+		Total:       total,
+		Page:        page,
+		PageSize:    pageSize,
+		TotalPages:  totalPages,
+		HasNext:     page < totalPages,
+		HasPrevious: page > 1,
+	}, nil
+}
+
+// scanProjectSessions reads session summaries selected by the project session queries
+func (s *SessionService) scanProjectSessions(rows *sql.Rows) ([]models.SessionSummary, error) {
 	var sessions []models.SessionSummary
 	
 	for rows.Next() {
@@ -583,6 +1018,10 @@ func (s *SessionService) GetSessionsByProject(projectID string) ([]models.Sessio
 		sessions = append(sessions, session)
 	}
 	
+	if err := s.attachTags(sessions); err != nil {
+		return nil, err
+	}
+	
 	return sessions, nil
 }
 
@@ -670,4 +1109,64 @@ func (s *SessionService) GetSessionsWithoutProjectID() ([]models.Session, error)
 	}
 	
 	return sessions, nil
-}
\ No newline at end of file
+}
+
+const (
+	DEFAULT_HEATMAP_DAYS = 90
+	MAX_HEATMAP_DAYS     = 365
+)
+
+// GetActivityHeatmap counts messages by local day of week and hour of day over the last
+// days days up to now, in now's timezone. Only per-bucket counts are read from the database.
+func (s *SessionService) GetActivityHeatmap(days int, now time.Time) (*models.ActivityHeatmap, error) {
+	if days < 1 || days > MAX_HEATMAP_DAYS {
+		return nil, fmt.Errorf("invalid days: must be between 1 and %d", MAX_HEATMAP_DAYS)
+	}
+	
+	loc := now.Location()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -(days - 1))
+	
+	heatmap := &models.ActivityHeatmap{
+		Days:     days,
+		From:     from,
+		To:       now,
+		Timezone: loc.String(),
+	}
+	
+	query := `
+		SELECT 
+			` + localBucketExpr + ` as bucket,
+			COUNT(*) as message_count
+		FROM messages
+		WHERE timestamp >= ? AND timestamp <= ?
+		GROUP BY bucket
+	`
+	
+	rows, err := s.db.Query(query, from.UTC(), now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activity heatmap: %w", err)
+	}
+	defer rows.Close()
+	
+	for rows.Next() {
+		var bucket time.Time
+		var count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan activity heatmap: %w", err)
+		}
+		
+		local := localBucketTime(bucket, loc)
+		weekday, hour := int(local.Weekday()), local.Hour()
+		
+		heatmap.Matrix[weekday][hour] += count
+		heatmap.DayTotals[weekday] += count
+		heatmap.HourTotals[hour] += count
+		heatmap.Total += count
+	}
+	
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over activity heatmap: %w", err)
+	}
+	
+	return heatmap, nil
+}
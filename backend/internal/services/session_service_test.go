@@ -2,7 +2,9 @@ package services
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,7 +12,7 @@ import (
 	_ "github.com/marcboeker/go-duckdb"
 )
 
-func setupTestDBForSession(t *testing.T) *sql.DB {
+func setupTestDBForSession(t testing.TB) *sql.DB {
 	db, err := sql.Open("duckdb", ":memory:")
 	if err != nil {
 		t.Fatalf("Failed to create test database: %v", err)
@@ -50,10 +52,18 @@ func setupTestDBForSession(t *testing.T) *sql.DB {
 			output_tokens INTEGER DEFAULT 0,
 			service_tier TEXT,
 			request_id TEXT,
+			cost DOUBLE DEFAULT 0.0,
 			timestamp TIMESTAMP,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (session_id) REFERENCES sessions(id)
 		);
+
+		CREATE TABLE IF NOT EXISTS session_tags (
+			session_id TEXT NOT NULL,
+			tag TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (session_id, tag)
+		);
 	`
 
 	_, err = db.Exec(createTables)
@@ -664,4 +674,705 @@ func TestGetSessionByID_NotFound(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for non-existent session, got nil")
 	}
-}
\ No newline at end of file
+}
+func TestGetSessionCostByModel(t *testing.T) {
+	db := setupTestDBForSession(t)
+	defer db.Close()
+
+	service := NewSessionService(db)
+	sessionID := "cost-by-model-session"
+
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) 
+		VALUES (?, ?, ?, ?)
+	`, sessionID, "test-project", "/test/path", time.Now())
+	if err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+
+	testMessages := []struct {
+		id     string
+		role   string
+		model  interface{}
+		input  int
+		output int
+	}{
+		{"cbm-1", "assistant", "claude-sonnet-4-20250514", 1_000_000, 0},
+		{"cbm-2", "assistant", "claude-opus-4-20250514", 0, 1_000_000},
+		{"cbm-3", "assistant", "claude-opus-4-20250514", 1_000_000, 0},
+		{"cbm-4", "assistant", nil, 500, 500},
+		{"cbm-5", "user", "claude-opus-4-20250514", 1_000_000, 0},
+	}
+
+	for i, msg := range testMessages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, model, content, timestamp, input_tokens, output_tokens) 
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, msg.id, sessionID, msg.role, msg.model, "test", time.Now().Add(time.Duration(i)*time.Minute), msg.input, msg.output)
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	costByModel, err := service.GetSessionCostByModel(sessionID)
+	if err != nil {
+		t.Fatalf("GetSessionCostByModel failed: %v", err)
+	}
+
+	if costByModel["claude-sonnet-4-20250514"] != 3.0 {
+		t.Errorf("Expected sonnet cost 3.0, got %f", costByModel["claude-sonnet-4-20250514"])
+	}
+	// Opus: 75.0 output + 15.0 input; the user message is excluded
+	if costByModel["claude-opus-4-20250514"] != 90.0 {
+		t.Errorf("Expected opus cost 90.0, got %f", costByModel["claude-opus-4-20250514"])
+	}
+	cost, exists := costByModel[UnknownModelKey]
+	if !exists {
+		t.Error("Expected messages without a model to be grouped under unknown")
+	}
+	if cost != 0.0 {
+		t.Errorf("Expected unknown model cost 0.0, got %f", cost)
+	}
+}
+
+func TestSearchMessagesPaginated(t *testing.T) {
+	db := setupTestDBForSession(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`ALTER TABLE sessions ADD COLUMN project_id TEXT`); err != nil {
+		t.Fatalf("Failed to add project_id column: %v", err)
+	}
+
+	service := NewSessionService(db)
+
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, project_id) 
+		VALUES (?, ?, ?, ?, ?), (?, ?, ?, ?, ?)
+	`, "search-a", "project-a", "/a", time.Now(), "proj-a",
+		"search-b", "project-b", "/b", time.Now(), "proj-b")
+	if err != nil {
+		t.Fatalf("Failed to insert test sessions: %v", err)
+	}
+
+	longOutput := strings.Repeat("x", 500) + " <b>Deploy Script</b> " + strings.Repeat("y", 500)
+	testMessages := []struct {
+		id        string
+		sessionID string
+		content   string
+	}{
+		{"search-1", "search-a", "Please run the deploy script"},
+		{"search-2", "search-b", longOutput},
+		{"search-3", "search-b", "unrelated message"},
+		{"search-4", "search-a", "100% done"},
+	}
+
+	for i, msg := range testMessages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp) 
+			VALUES (?, ?, ?, ?, ?)
+		`, msg.id, msg.sessionID, "user", msg.content, time.Now().Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	result, err := service.SearchMessagesPaginated("DEPLOY script", "", 1, 20)
+	if err != nil {
+		t.Fatalf("SearchMessagesPaginated failed: %v", err)
+	}
+	if result.Total != 2 {
+		t.Fatalf("Expected 2 matches, got %d", result.Total)
+	}
+
+	// Newest first: the long tool output is trimmed around the match and escaped
+	snippet := result.Hits[0].Snippet
+	if !strings.Contains(snippet, "&lt;b&gt;<mark>Deploy Script</mark>&lt;/b&gt;") {
+		t.Errorf("Expected highlighted and escaped match, got %s", snippet)
+	}
+	if !strings.HasPrefix(snippet, "...") || !strings.HasSuffix(snippet, "...") {
+		t.Errorf("Expected snippet to be trimmed on both sides, got %s", snippet)
+	}
+	if len([]rune(snippet)) > 2*SEARCH_SNIPPET_CONTEXT+50 {
+		t.Errorf("Expected snippet to be capped, got %d characters", len([]rune(snippet)))
+	}
+	if result.Hits[1].Snippet != "Please run the <mark>deploy script</mark>" {
+		t.Errorf("Unexpected snippet: %s", result.Hits[1].Snippet)
+	}
+
+	// Project filter
+	result, err = service.SearchMessagesPaginated("deploy", "proj-a", 1, 20)
+	if err != nil {
+		t.Fatalf("SearchMessagesPaginated failed: %v", err)
+	}
+	if result.Total != 1 || result.Hits[0].SessionID != "search-a" {
+		t.Errorf("Expected single match in search-a, got %+v", result.Hits)
+	}
+
+	// LIKE wildcards are matched literally
+	hits, err := service.SearchMessages("%", 10)
+	if err != nil {
+		t.Fatalf("SearchMessages failed: %v", err)
+	}
+	if len(hits) != 1 || hits[0].MessageID != "search-4" {
+		t.Errorf("Expected only the message containing a literal %%, got %+v", hits)
+	}
+
+	if _, err := service.SearchMessages("  ", 10); err == nil {
+		t.Error("Expected error for empty query")
+	}
+}
+
+func TestBuildSearchSnippet(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		query   string
+		want    string
+	}{
+		{"case insensitive", "Run the Deploy script", "deploy", "Run the <mark>Deploy</mark> script"},
+		{"multibyte", "デプロイを実行 deploy", "DEPLOY", "デプロイを実行 <mark>deploy</mark>"},
+		// Lowercasing shortens these runes' UTF-8 encoding, so byte offsets differ from content
+		{"shorter when lowered", "İstanbul \u212A deploy", "deploy", "İstanbul \u212A <mark>deploy</mark>"},
+		{"match in shortened rune", "Visit İstanbul", "istanbul", "Visit <mark>İstanbul</mark>"},
+		{"no match", "a <b> c", "zzz", "a &lt;b&gt; c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildSearchSnippet(tt.content, tt.query); got != tt.want {
+				t.Errorf("buildSearchSnippet(%q, %q) = %q, want %q", tt.content, tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetSessionsFiltered(t *testing.T) {
+	db := setupTestDBForSession(t)
+	defer db.Close()
+
+	for _, query := range []string{
+		`ALTER TABLE sessions ADD COLUMN project_id TEXT`,
+		`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to extend sessions table: %v", err)
+		}
+	}
+
+	service := NewSessionService(db)
+	now := time.Now()
+
+	testSessions := []struct {
+		id        string
+		startTime time.Time
+		status    string
+		lastMsg   time.Time
+	}{
+		{"filter-recent", now.Add(-time.Hour), "active", now.Add(-2 * time.Minute)},
+		{"filter-stale", now.Add(-2 * time.Hour), "active", now.Add(-90 * time.Minute)},
+		{"filter-old", now.Add(-72 * time.Hour), "completed", now.Add(-71 * time.Hour)},
+	}
+	for _, ts := range testSessions {
+		_, err := db.Exec(`
+			INSERT INTO sessions (id, project_name, project_path, start_time, status) 
+			VALUES (?, ?, ?, ?, ?)
+		`, ts.id, "test-project", "/test/path", ts.startTime, ts.status)
+		if err != nil {
+			t.Fatalf("Failed to insert test session: %v", err)
+		}
+		_, err = db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp) 
+			VALUES (?, ?, ?, ?, ?)
+		`, ts.id+"-msg", ts.id, "user", "hello", ts.lastMsg)
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	// No filters keeps the full listing
+	all, err := service.GetAllSessions()
+	if err != nil {
+		t.Fatalf("GetAllSessions failed: %v", err)
+	}
+	if len(all) != 3 {
+		t.Errorf("Expected 3 sessions, got %d", len(all))
+	}
+
+	from := now.Add(-24 * time.Hour)
+	sessions, err := service.GetSessionsFiltered(models.SessionFilters{From: &from})
+	if err != nil {
+		t.Fatalf("GetSessionsFiltered failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "filter-recent" {
+		t.Errorf("Expected the 2 sessions from the last day, got %+v", sessions)
+	}
+
+	to := now.Add(-24 * time.Hour)
+	status := "completed"
+	sessions, err = service.GetSessionsFiltered(models.SessionFilters{To: &to, Status: &status})
+	if err != nil {
+		t.Fatalf("GetSessionsFiltered failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "filter-old" {
+		t.Errorf("Expected only filter-old, got %+v", sessions)
+	}
+
+	sessions, err = service.GetSessionsFiltered(models.SessionFilters{Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatalf("GetSessionsFiltered failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "filter-stale" {
+		t.Errorf("Expected second page to contain filter-stale, got %+v", sessions)
+	}
+
+	sessions, err = service.GetSessionsFiltered(models.SessionFilters{ActiveOnly: true})
+	if err != nil {
+		t.Fatalf("GetSessionsFiltered failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "filter-recent" || !sessions[0].IsActive {
+		t.Errorf("Expected only filter-recent to be active, got %+v", sessions)
+	}
+}
+
+
+func TestGetSessionsFiltered_LastActivity(t *testing.T) {
+	db := setupTestDBForSession(t)
+	defer db.Close()
+
+	for _, query := range []string{
+		`ALTER TABLE sessions ADD COLUMN project_id TEXT`,
+		`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to extend sessions table: %v", err)
+		}
+	}
+
+	service := NewSessionService(db)
+	now := time.Now()
+
+	testSessions := []struct {
+		id        string
+		startTime time.Time
+		status    string
+		endTime   *time.Time
+		lastMsgs  []time.Time
+	}{
+		{"recent", now.Add(-time.Hour), "active", nil, []time.Time{now.Add(-50 * time.Minute), now.Add(-5 * time.Minute)}},
+		{"idle", now.Add(-2 * time.Hour), "active", nil, []time.Time{now.Add(-45 * time.Minute)}},
+		{"ended", now.Add(-time.Hour), "active", &now, []time.Time{now.Add(-time.Minute)}},
+		{"completed", now.Add(-time.Hour), "completed", nil, []time.Time{now.Add(-time.Minute)}},
+		{"no-messages", now.Add(-3 * time.Hour), "active", nil, nil},
+	}
+	for _, ts := range testSessions {
+		_, err := db.Exec(`
+			INSERT INTO sessions (id, project_name, project_path, start_time, end_time, status) 
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, ts.id, "test-project", "/test/path", ts.startTime, ts.endTime, ts.status)
+		if err != nil {
+			t.Fatalf("Failed to insert test session: %v", err)
+		}
+		for i, msgTime := range ts.lastMsgs {
+			_, err = db.Exec(`
+				INSERT INTO messages (id, session_id, message_role, content, timestamp) 
+				VALUES (?, ?, ?, ?, ?)
+			`, fmt.Sprintf("%s-msg-%d", ts.id, i), ts.id, "user", "hello", msgTime)
+			if err != nil {
+				t.Fatalf("Failed to insert test message: %v", err)
+			}
+		}
+	}
+
+	sessions, err := service.GetSessionsFiltered(models.SessionFilters{})
+	if err != nil {
+		t.Fatalf("GetSessionsFiltered failed: %v", err)
+	}
+	byID := make(map[string]models.SessionSummary)
+	for _, session := range sessions {
+		byID[session.ID] = session
+	}
+
+	expected := map[string]struct {
+		lastActivity time.Time
+		isActive     bool
+	}{
+		"recent":      {now.Add(-5 * time.Minute), true},
+		"idle":        {now.Add(-45 * time.Minute), false},
+		"ended":       {now.Add(-time.Minute), false},
+		"completed":   {now.Add(-time.Minute), false},
+		"no-messages": {now.Add(-3 * time.Hour), false},
+	}
+	for id, want := range expected {
+		session, ok := byID[id]
+		if !ok {
+			t.Errorf("Session %s missing from listing", id)
+			continue
+		}
+		if diff := session.LastActivity.Sub(want.lastActivity); diff > time.Second || diff < -time.Second {
+			t.Errorf("Session %s: expected last activity %v, got %v", id, want.lastActivity, session.LastActivity)
+		}
+		if session.IsActive != want.isActive {
+			t.Errorf("Session %s: expected is_active %v, got %v", id, want.isActive, session.IsActive)
+		}
+	}
+}
+
+func BenchmarkGetSessionsFiltered(b *testing.B) {
+	db := setupTestDBForSession(b)
+	defer db.Close()
+
+	setup := []string{
+		`ALTER TABLE sessions ADD COLUMN project_id TEXT`,
+		`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0`,
+		// 5,000 sessions with 20 messages each
+		`INSERT INTO sessions (id, project_name, project_path, start_time, status)
+			SELECT 'bench-' || i, 'bench-project', '/bench/path', CAST(CURRENT_TIMESTAMP AS TIMESTAMP) - INTERVAL (i) MINUTE, 'active'
+			FROM range(5000) t(i)`,
+		`INSERT INTO messages (id, session_id, message_role, content, timestamp)
+			SELECT 'bench-' || i || '-' || j, 'bench-' || i, 'user', 'hello', CAST(CURRENT_TIMESTAMP AS TIMESTAMP) - INTERVAL (i) MINUTE + INTERVAL (j) SECOND
+			FROM range(5000) s(i), range(20) m(j)`,
+	}
+	for _, query := range setup {
+		if _, err := db.Exec(query); err != nil {
+			b.Fatalf("Failed to prepare benchmark data: %v", err)
+		}
+	}
+
+	service := NewSessionService(db)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := service.GetSessionsFiltered(models.SessionFilters{}); err != nil {
+			b.Fatalf("GetSessionsFiltered failed: %v", err)
+		}
+	}
+}
+
+func TestSessionTags(t *testing.T) {
+	db := setupTestDBForSession(t)
+	defer db.Close()
+
+	for _, query := range []string{
+		`ALTER TABLE sessions ADD COLUMN project_id TEXT`,
+		`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to extend sessions table: %v", err)
+		}
+	}
+
+	service := NewSessionService(db)
+	now := time.Now()
+	for i, id := range []string{"tagged", "untagged"} {
+		_, err := db.Exec(`
+			INSERT INTO sessions (id, project_name, project_path, start_time) 
+			VALUES (?, ?, ?, ?)
+		`, id, "test-project", "/test/path", now.Add(-time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to insert test session: %v", err)
+		}
+	}
+
+	// Tags are trimmed and deduplicated per session
+	for _, tag := range []string{"bugfix", "experiment", " bugfix "} {
+		if _, err := service.AddTag("tagged", tag); err != nil {
+			t.Fatalf("AddTag(%q) failed: %v", tag, err)
+		}
+	}
+	tags, err := service.GetTags("tagged")
+	if err != nil {
+		t.Fatalf("GetTags failed: %v", err)
+	}
+	if strings.Join(tags, ",") != "bugfix,experiment" {
+		t.Errorf("Expected tags [bugfix experiment], got %v", tags)
+	}
+
+	if _, err := service.AddTag("tagged", "   "); err == nil || !strings.Contains(err.Error(), "invalid tag") {
+		t.Errorf("Expected empty tag to be rejected, got %v", err)
+	}
+	if _, err := service.AddTag("tagged", strings.Repeat("x", MaxSessionTagLength+1)); err == nil || !strings.Contains(err.Error(), "invalid tag") {
+		t.Errorf("Expected long tag to be rejected, got %v", err)
+	}
+	if _, err := service.AddTag("missing", "bugfix"); err == nil || !strings.Contains(err.Error(), "session not found") {
+		t.Errorf("Expected missing session to be rejected, got %v", err)
+	}
+
+	// Tag filter and tags in the summaries
+	tag := "bugfix"
+	sessions, err := service.GetSessionsFiltered(models.SessionFilters{Tag: &tag})
+	if err != nil {
+		t.Fatalf("GetSessionsFiltered failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "tagged" {
+		t.Fatalf("Expected only the tagged session, got %+v", sessions)
+	}
+	if strings.Join(sessions[0].Tags, ",") != "bugfix,experiment" {
+		t.Errorf("Expected tags in the summary, got %v", sessions[0].Tags)
+	}
+
+	all, err := service.GetAllSessions()
+	if err != nil {
+		t.Fatalf("GetAllSessions failed: %v", err)
+	}
+	for _, session := range all {
+		if session.ID == "untagged" && (session.Tags == nil || len(session.Tags) != 0) {
+			t.Errorf("Expected an empty tag list for the untagged session, got %#v", session.Tags)
+		}
+	}
+
+	detail, err := service.GetSessionByID("tagged")
+	if err != nil {
+		t.Fatalf("GetSessionByID failed: %v", err)
+	}
+	if len(detail.Tags) != 2 {
+		t.Errorf("Expected 2 tags on the session details, got %v", detail.Tags)
+	}
+
+	tags, err = service.RemoveTag("tagged", "bugfix")
+	if err != nil {
+		t.Fatalf("RemoveTag failed: %v", err)
+	}
+	if strings.Join(tags, ",") != "experiment" {
+		t.Errorf("Expected [experiment] after removal, got %v", tags)
+	}
+	if _, err := service.RemoveTag("tagged", "bugfix"); err == nil || !strings.Contains(err.Error(), "tag not found") {
+		t.Errorf("Expected removing a missing tag to fail, got %v", err)
+	}
+
+	sessions, err = service.GetSessionsFiltered(models.SessionFilters{Tag: &tag})
+	if err != nil {
+		t.Fatalf("GetSessionsFiltered failed: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("Expected no sessions tagged bugfix after removal, got %d", len(sessions))
+	}
+}
+func TestGetAllSessions_NullStartTimeOrdering(t *testing.T) {
+	db := setupTestDBForSession(t)
+	defer db.Close()
+
+	for _, query := range []string{
+		`ALTER TABLE sessions ADD COLUMN project_id TEXT`,
+		`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0`,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatalf("Failed to extend sessions table: %v", err)
+		}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	testSessions := []struct {
+		id        string
+		startTime interface{}
+		createdAt time.Time
+	}{
+		{"newest", now.Add(-time.Hour), now.Add(-time.Hour)},
+		{"legacy-middle", nil, now.Add(-2 * time.Hour)},
+		{"oldest", now.Add(-3 * time.Hour), now.Add(-3 * time.Hour)},
+		// Same effective time as legacy-middle; ties are broken by ID
+		{"legacy-a", nil, now.Add(-2 * time.Hour)},
+	}
+	for _, ts := range testSessions {
+		_, err := db.Exec(`
+			INSERT INTO sessions (id, project_name, project_path, start_time, created_at) 
+			VALUES (?, ?, ?, ?, ?)
+		`, ts.id, "test-project", "/test/path", ts.startTime, ts.createdAt)
+		if err != nil {
+			t.Fatalf("Failed to insert test session: %v", err)
+		}
+	}
+
+	service := NewSessionService(db)
+	expected := []string{"newest", "legacy-a", "legacy-middle", "oldest"}
+
+	// Repeated queries return the same order
+	for i := 0; i < 3; i++ {
+		sessions, err := service.GetAllSessions()
+		if err != nil {
+			t.Fatalf("GetAllSessions failed: %v", err)
+		}
+		if len(sessions) != len(expected) {
+			t.Fatalf("Expected %d sessions, got %d", len(expected), len(sessions))
+		}
+		for j, id := range expected {
+			if sessions[j].ID != id {
+				t.Fatalf("Expected session %d to be %s, got %s", j, id, sessions[j].ID)
+			}
+		}
+		if !sessions[1].StartTime.Equal(testSessions[3].createdAt) {
+			t.Errorf("Expected null start_time to fall back to created_at, got %v", sessions[1].StartTime)
+		}
+	}
+}
+
+func TestExportSession(t *testing.T) {
+	db := setupTestDBForSession(t)
+	defer db.Close()
+
+	if _, err := db.Exec(`ALTER TABLE sessions ADD COLUMN total_cost DOUBLE DEFAULT 0`); err != nil {
+		t.Fatalf("Failed to extend sessions table: %v", err)
+	}
+
+	service := NewSessionService(db)
+	sessionID := "export-session"
+	startTime := time.Date(2025, 8, 1, 10, 0, 0, 0, time.UTC)
+
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time, total_input_tokens, total_output_tokens, total_tokens, total_cost) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, sessionID, "export-project", "/test/export", startTime, 120, 80, 200, 0.0125)
+	if err != nil {
+		t.Fatalf("Failed to create test session: %v", err)
+	}
+
+	testMessages := []struct {
+		id      string
+		role    string
+		model   interface{}
+		content string
+		offset  time.Duration
+	}{
+		// Inserted out of order; the export follows the timestamps
+		{"msg2", "assistant", "claude-sonnet-4-20250514", "Here you go:\n\n```go\nfmt.Println(\"hi\")\n```", 2 * time.Minute},
+		{"msg1", "user", nil, "Print hi in Go", time.Minute},
+		{"msg3", "assistant", "claude-sonnet-4-20250514", "Unfinished:\n```sh\necho partial", 3 * time.Minute},
+	}
+	for _, m := range testMessages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, model, content, timestamp) 
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, m.id, sessionID, m.role, m.model, m.content, startTime.Add(m.offset))
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	t.Run("markdown", func(t *testing.T) {
+		export, err := service.ExportSession(sessionID, SessionExportFormatMarkdown)
+		if err != nil {
+			t.Fatalf("ExportSession failed: %v", err)
+		}
+		if export.Filename != "session-export-session.md" {
+			t.Errorf("Expected filename session-export-session.md, got %s", export.Filename)
+		}
+
+		content := string(export.Content)
+		for _, expected := range []string{
+			"project: \"export-project\"\n",
+			"total_tokens: 200\n",
+			"total_cost: 0.012500\n",
+			"## User · 2025-08-01T10:01:00Z\n\nPrint hi in Go\n",
+			"## Assistant (claude-sonnet-4-20250514) · 2025-08-01T10:02:00Z\n\nHere you go:\n\n```go\nfmt.Println(\"hi\")\n```\n",
+			"echo partial\n```\n",
+		} {
+			if !strings.Contains(content, expected) {
+				t.Errorf("Expected markdown to contain %q, got:\n%s", expected, content)
+			}
+		}
+		if !strings.HasPrefix(content, "---\n") {
+			t.Errorf("Expected markdown to start with frontmatter, got:\n%s", content)
+		}
+		if strings.Index(content, "## User") > strings.Index(content, "## Assistant") {
+			t.Errorf("Expected messages in timestamp order, got:\n%s", content)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		export, err := service.ExportSession(sessionID, SessionExportFormatJSON)
+		if err != nil {
+			t.Fatalf("ExportSession failed: %v", err)
+		}
+		if export.Filename != "session-export-session.json" {
+			t.Errorf("Expected filename session-export-session.json, got %s", export.Filename)
+		}
+
+		var messages []models.Message
+		if err := json.Unmarshal(export.Content, &messages); err != nil {
+			t.Fatalf("Failed to decode JSON export: %v", err)
+		}
+		if len(messages) != 3 || messages[0].ID != "msg1" || messages[2].ID != "msg3" {
+			t.Errorf("Expected 3 ordered messages, got %+v", messages)
+		}
+	})
+
+	t.Run("missing session", func(t *testing.T) {
+		export, err := service.ExportSession("missing-session", SessionExportFormatMarkdown)
+		if err != nil {
+			t.Fatalf("ExportSession failed: %v", err)
+		}
+		if export != nil {
+			t.Errorf("Expected nil export for a missing session, got %+v", export)
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		if _, err := service.ExportSession(sessionID, "pdf"); err == nil {
+			t.Error("Expected error for an unsupported format")
+		}
+	})
+}
+
+func TestGetActivityHeatmap(t *testing.T) {
+	db := setupTestDBForSession(t)
+	defer db.Close()
+
+	service := NewSessionService(db)
+
+	// A half-hour offset checks that hours are local, not UTC shifted by whole hours
+	loc := time.FixedZone("IST", 5*60*60+30*60)
+	now := time.Date(2025, 8, 10, 12, 0, 0, 0, loc) // Sunday
+
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES (?, ?, ?, ?)`,
+		"heatmap-session", "test-project", "/test/path", now); err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+
+	timestamps := []time.Time{
+		time.Date(2025, 8, 10, 9, 45, 0, 0, loc),  // Sunday 09
+		time.Date(2025, 8, 10, 9, 50, 0, 0, loc),  // Sunday 09
+		time.Date(2025, 8, 9, 23, 40, 0, 0, loc),  // Saturday 23
+		time.Date(2025, 8, 4, 0, 10, 0, 0, loc),   // Monday 00, still Sunday in UTC
+		time.Date(2025, 8, 3, 23, 50, 0, 0, loc),  // Before the 7 day range
+		time.Date(2025, 8, 10, 12, 30, 0, 0, loc), // After now
+	}
+	for i, ts := range timestamps {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp) 
+			VALUES (?, ?, ?, ?, ?)
+		`, fmt.Sprintf("heatmap-msg-%d", i), "heatmap-session", "user", "hello", ts)
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	heatmap, err := service.GetActivityHeatmap(7, now)
+	if err != nil {
+		t.Fatalf("GetActivityHeatmap failed: %v", err)
+	}
+
+	expected := map[[2]int]int{
+		{int(time.Sunday), 9}:    2,
+		{int(time.Saturday), 23}: 1,
+		{int(time.Monday), 0}:    1,
+	}
+	for day := 0; day < 7; day++ {
+		for hour := 0; hour < 24; hour++ {
+			if got := heatmap.Matrix[day][hour]; got != expected[[2]int{day, hour}] {
+				t.Errorf("Matrix[%d][%d]: expected %d, got %d", day, hour, expected[[2]int{day, hour}], got)
+			}
+		}
+	}
+	if heatmap.Total != 4 {
+		t.Errorf("Expected total 4, got %d", heatmap.Total)
+	}
+	if heatmap.DayTotals[time.Sunday] != 2 || heatmap.DayTotals[time.Monday] != 1 || heatmap.DayTotals[time.Saturday] != 1 {
+		t.Errorf("Unexpected day totals %v", heatmap.DayTotals)
+	}
+	if heatmap.HourTotals[9] != 2 || heatmap.HourTotals[0] != 1 || heatmap.HourTotals[23] != 1 {
+		t.Errorf("Unexpected hour totals %v", heatmap.HourTotals)
+	}
+
+	if _, err := service.GetActivityHeatmap(MAX_HEATMAP_DAYS+1, now); err == nil {
+		t.Error("Expected days over the maximum to be rejected")
+	}
+}
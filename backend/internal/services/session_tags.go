@@ -0,0 +1,131 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"ccdash-backend/internal/models"
+)
+
+// MaxSessionTagLength is the longest tag accepted, in characters
+const MaxSessionTagLength = 64
+
+// normalizeTag trims surrounding whitespace and validates a tag
+func normalizeTag(tag string) (string, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return "", fmt.Errorf("invalid tag: must not be empty")
+	}
+	if utf8.RuneCountInString(tag) > MaxSessionTagLength {
+		return "", fmt.Errorf("invalid tag: must be at most %d characters", MaxSessionTagLength)
+	}
+	return tag, nil
+}
+
+// AddTag tags a session. Adding a tag the session already has is a no-op.
+// Returns the session's tags after the change.
+func (s *SessionService) AddTag(sessionID, tag string) ([]string, error) {
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM sessions WHERE id = ?", sessionID).Scan(&count); err != nil {
+		return nil, fmt.Errorf("failed to check session: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	_, err = s.db.Exec(`INSERT OR IGNORE INTO session_tags (session_id, tag) VALUES (?, ?)`, sessionID, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add session tag: %w", err)
+	}
+
+	return s.GetTags(sessionID)
+}
+
+// RemoveTag removes a tag from a session. Returns the session's tags after the change.
+func (s *SessionService) RemoveTag(sessionID, tag string) ([]string, error) {
+	result, err := s.db.Exec(`DELETE FROM session_tags WHERE session_id = ? AND tag = ?`, sessionID, strings.TrimSpace(tag))
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove session tag: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check removed session tag: %w", err)
+	}
+	if removed == 0 {
+		return nil, fmt.Errorf("tag not found on session %s: %s", sessionID, tag)
+	}
+
+	return s.GetTags(sessionID)
+}
+
+// GetTags returns the tags of a session in alphabetical order
+func (s *SessionService) GetTags(sessionID string) ([]string, error) {
+	rows, err := s.db.Query(`SELECT tag FROM session_tags WHERE session_id = ? ORDER BY tag`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan session tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over session tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// attachTags loads the tags of every session in one query
+func (s *SessionService) attachTags(sessions []models.SessionSummary) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(sessions))
+	args := make([]interface{}, len(sessions))
+	index := make(map[string]int, len(sessions))
+	for i := range sessions {
+		placeholders[i] = "?"
+		args[i] = sessions[i].ID
+		index[sessions[i].ID] = i
+		sessions[i].Tags = []string{}
+	}
+
+	rows, err := s.db.Query(`SELECT session_id, tag FROM session_tags WHERE session_id IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get session tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionID, tag string
+		if err := rows.Scan(&sessionID, &tag); err != nil {
+			return fmt.Errorf("failed to scan session tag: %w", err)
+		}
+		if i, ok := index[sessionID]; ok {
+			sessions[i].Tags = append(sessions[i].Tags, tag)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over session tags: %w", err)
+	}
+
+	for i := range sessions {
+		sort.Strings(sessions[i].Tags)
+	}
+	return nil
+}
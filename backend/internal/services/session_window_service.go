@@ -2,38 +2,94 @@ package services
 
 import (
 	"database/sql"
+	"encoding/csv"
 	"fmt"
+	"io"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var (
+	windowIncludeCacheTokensMu sync.RWMutex
+	windowIncludeCacheTokens   bool
+)
+
+// SetWindowIncludeCacheTokens sets whether session window services created afterwards
+// count cache tokens in window total_tokens
+func SetWindowIncludeCacheTokens(include bool) {
+	windowIncludeCacheTokensMu.Lock()
+	defer windowIncludeCacheTokensMu.Unlock()
+	windowIncludeCacheTokens = include
+}
+
+func getWindowIncludeCacheTokens() bool {
+	windowIncludeCacheTokensMu.RLock()
+	defer windowIncludeCacheTokensMu.RUnlock()
+	return windowIncludeCacheTokens
+}
+
 type SessionWindowService struct {
-	db              *sql.DB
-	relationService *SessionWindowMessageService
+	db                 *sql.DB
+	relationService    *SessionWindowMessageService
+	includeCacheTokens bool // Include cache tokens in total_tokens (matches the cost basis)
 }
 
 type SessionWindow struct {
-	ID                string    `json:"id"`
-	WindowStart       time.Time `json:"window_start"`
-	WindowEnd         time.Time `json:"window_end"`
-	ResetTime         time.Time `json:"reset_time"`
-	TotalInputTokens  int       `json:"total_input_tokens"`
-	TotalOutputTokens int       `json:"total_output_tokens"`
-	TotalTokens       int       `json:"total_tokens"`
-	MessageCount      int       `json:"message_count"`
-	SessionCount      int       `json:"session_count"`
-	TotalCost         float64   `json:"total_cost"`
-	IsActive          bool      `json:"is_active"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	ID                       string    `json:"id"`
+	WindowStart              time.Time `json:"window_start"`
+	WindowEnd                time.Time `json:"window_end"`
+	ResetTime                time.Time `json:"reset_time"`
+	TotalInputTokens         int       `json:"total_input_tokens"`
+	TotalOutputTokens        int       `json:"total_output_tokens"`
+	TotalTokens              int       `json:"total_tokens"`
+	TotalCacheCreationTokens int       `json:"total_cache_creation_tokens"`
+	TotalCacheReadTokens     int       `json:"total_cache_read_tokens"`
+	MessageCount             int       `json:"message_count"`
+	SessionCount             int       `json:"session_count"`
+	TotalCost                float64   `json:"total_cost"`
+	IsActive                 bool      `json:"is_active"`
+	Plan                     string    `json:"plan"`        // Plan in effect when the window was created
+	UsageLimit               int       `json:"usage_limit"` // Token limit of Plan
+	UsageRate                float64   `json:"usage_rate"`
+	CreatedAt                time.Time `json:"created_at"`
+	UpdatedAt                time.Time `json:"updated_at"`
+}
+
+// ActiveWindowStatus is the active window with its usage against the plan limit, for the usage meter
+type ActiveWindowStatus struct {
+	Window            *SessionWindow `json:"window"`
+	Plan              string         `json:"plan"`
+	UsageLimit        int            `json:"usage_limit"`
+	UsageRate         float64        `json:"usage_rate"`       // Exceeds 1.0 when usage is over the limit
+	TokensRemaining   int            `json:"tokens_remaining"` // Never negative
+	SecondsUntilReset int64          `json:"seconds_until_reset"`
+	TimeUntilReset    string         `json:"time_until_reset"`
 }
 
 func NewSessionWindowService(db *sql.DB) *SessionWindowService {
 	return &SessionWindowService{
-		db:              db,
-		relationService: NewSessionWindowMessageService(db),
+		db:                 db,
+		relationService:    NewSessionWindowMessageService(db),
+		includeCacheTokens: getWindowIncludeCacheTokens(),
+	}
+}
+
+// applyPlanLimit fills in the usage limit and rate from the plan recorded on the window.
+// Windows created before plans were tracked are rated against the current plan.
+func (w *SessionWindow) applyPlanLimit() {
+	if w.Plan == "" {
+		w.Plan = CurrentPlan()
 	}
+	w.UsageLimit = UsageLimitForPlan(w.Plan)
+	w.UsageRate = float64(w.TotalTokens) / float64(w.UsageLimit)
+}
+
+// SetIncludeCacheTokens controls whether cache tokens are counted in window total_tokens
+func (s *SessionWindowService) SetIncludeCacheTokens(include bool) {
+	s.includeCacheTokens = include
 }
 
 // GetCurrentActiveWindow returns the currently active session window
@@ -42,8 +98,10 @@ func (s *SessionWindowService) GetCurrentActiveWindow() (*SessionWindow, error)
 		SELECT 
 			id, window_start, window_end, reset_time,
 			total_input_tokens, total_output_tokens, total_tokens,
+			COALESCE(total_cache_creation_tokens, 0) as total_cache_creation_tokens,
+			COALESCE(total_cache_read_tokens, 0) as total_cache_read_tokens,
 			message_count, session_count, COALESCE(total_cost, 0) as total_cost, is_active,
-			created_at, updated_at
+			COALESCE(plan, '') as plan, created_at, updated_at
 		FROM session_windows 
 		WHERE is_active = true
 		ORDER BY window_start DESC
@@ -59,10 +117,13 @@ func (s *SessionWindowService) GetCurrentActiveWindow() (*SessionWindow, error)
 		&window.TotalInputTokens,
 		&window.TotalOutputTokens,
 		&window.TotalTokens,
+		&window.TotalCacheCreationTokens,
+		&window.TotalCacheReadTokens,
 		&window.MessageCount,
 		&window.SessionCount,
 		&window.TotalCost,
 		&window.IsActive,
+		&window.Plan,
 		&window.CreatedAt,
 		&window.UpdatedAt,
 	)
@@ -74,17 +135,49 @@ func (s *SessionWindowService) GetCurrentActiveWindow() (*SessionWindow, error)
 		return nil, fmt.Errorf("failed to get current active window: %w", err)
 	}
 
+	window.applyPlanLimit()
 	return &window, nil
 }
 
+// GetActiveWindowStatus returns the active window with its usage and time until reset,
+// or nil if no window is active at now
+func (s *SessionWindowService) GetActiveWindowStatus(now time.Time) (*ActiveWindowStatus, error) {
+	window, err := s.GetCurrentActiveWindow()
+	if err != nil {
+		return nil, err
+	}
+	// A window still flagged active after its reset time has already ended
+	if window == nil || !window.ResetTime.After(now) {
+		return nil, nil
+	}
+	
+	tokensRemaining := window.UsageLimit - window.TotalTokens
+	if tokensRemaining < 0 {
+		tokensRemaining = 0
+	}
+	timeUntilReset := window.ResetTime.Sub(now)
+	
+	return &ActiveWindowStatus{
+		Window:            window,
+		Plan:              window.Plan,
+		UsageLimit:        window.UsageLimit,
+		UsageRate:         window.UsageRate,
+		TokensRemaining:   tokensRemaining,
+		SecondsUntilReset: int64(timeUntilReset.Seconds()),
+		TimeUntilReset:    timeUntilReset.Round(time.Second).String(),
+	}, nil
+}
+
 // findWindowForTime finds an existing window that contains the given time
 func (s *SessionWindowService) findWindowForTime(messageTime time.Time) (*SessionWindow, error) {
 	query := `
 		SELECT 
 			id, window_start, window_end, reset_time,
 			total_input_tokens, total_output_tokens, total_tokens,
+			COALESCE(total_cache_creation_tokens, 0) as total_cache_creation_tokens,
+			COALESCE(total_cache_read_tokens, 0) as total_cache_read_tokens,
 			message_count, session_count, COALESCE(total_cost, 0) as total_cost, is_active,
-			created_at, updated_at
+			COALESCE(plan, '') as plan, created_at, updated_at
 		FROM session_windows 
 		WHERE ? >= window_start AND ? < window_end
 		ORDER BY window_start DESC
@@ -100,10 +193,13 @@ func (s *SessionWindowService) findWindowForTime(messageTime time.Time) (*Sessio
 		&window.TotalInputTokens,
 		&window.TotalOutputTokens,
 		&window.TotalTokens,
+		&window.TotalCacheCreationTokens,
+		&window.TotalCacheReadTokens,
 		&window.MessageCount,
 		&window.SessionCount,
 		&window.TotalCost,
 		&window.IsActive,
+		&window.Plan,
 		&window.CreatedAt,
 		&window.UpdatedAt,
 	)
@@ -115,13 +211,21 @@ func (s *SessionWindowService) findWindowForTime(messageTime time.Time) (*Sessio
 		return nil, fmt.Errorf("failed to find window for time: %w", err)
 	}
 
+	window.applyPlanLimit()
 	return &window, nil
 }
 
-// RecalculateAllWindows recreates all session windows based on the specification
+// RecalculateAllWindows recreates all session windows based on the specification.
+// Rebuilt windows keep the plan of the existing window they overlap, so historical usage
+// rates are not re-rated against the current plan.
 func (s *SessionWindowService) RecalculateAllWindows() error {
+	existingPlans, err := s.getWindowPlans()
+	if err != nil {
+		return err
+	}
+
 	// 1. 既存のSessionWindowとリレーションを全てクリア
-	err := s.relationService.ClearAllRelations()
+	err = s.relationService.ClearAllRelations()
 	if err != nil {
 		return fmt.Errorf("failed to clear existing relations: %w", err)
 	}
@@ -143,11 +247,8 @@ func (s *SessionWindowService) RecalculateAllWindows() error {
 			break
 		}
 
-		// 3. そのメッセージの時刻から5時間のSessionWindowを作成（分単位切り捨て）
-		windowStart := s.truncateToMinute(oldestMessage.Timestamp)
-		tempWindowEnd := windowStart.Add(WINDOW_DURATION)
-		// WindowEndも時間単位で切り捨て（例：10:28 -> 10:00）
-		windowEnd := s.truncateToHour(tempWindowEnd)
+		// 3. そのメッセージの時刻から5時間のSessionWindowを作成（分単位切り捨て、終了は時間単位切り捨て）
+		windowStart, windowEnd := s.windowBoundsForTime(oldestMessage.Timestamp)
 		// ResetTimeはWindowEndと同じ（時間単位切り捨て）
 		resetTime := windowEnd
 
@@ -157,6 +258,7 @@ func (s *SessionWindowService) RecalculateAllWindows() error {
 			WindowEnd:   windowEnd,
 			ResetTime:   resetTime,
 			IsActive:    true,
+			Plan:        existingPlans.planFor(windowStart, windowEnd),
 		}
 
 		// 4. SessionWindowをデータベースに挿入
@@ -181,6 +283,58 @@ func (s *SessionWindowService) RecalculateAllWindows() error {
 	return nil
 }
 
+// windowPlan is the plan recorded on a window, kept across window recalculation
+type windowPlan struct {
+	start time.Time
+	end   time.Time
+	plan  string
+}
+
+type windowPlans []windowPlan
+
+// getWindowPlans returns the plans recorded on existing windows, oldest first
+func (s *SessionWindowService) getWindowPlans() (windowPlans, error) {
+	rows, err := s.db.Query(`
+		SELECT window_start, window_end, plan
+		FROM session_windows
+		WHERE plan IS NOT NULL AND plan != ''
+		ORDER BY window_start ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get window plans: %w", err)
+	}
+	defer rows.Close()
+
+	var plans windowPlans
+	for rows.Next() {
+		var wp windowPlan
+		if err := rows.Scan(&wp.start, &wp.end, &wp.plan); err != nil {
+			return nil, fmt.Errorf("failed to scan window plan: %w", err)
+		}
+		plans = append(plans, wp)
+	}
+	return plans, rows.Err()
+}
+
+// planFor returns the plan of the first recorded window overlapping [start, end),
+// or the current plan when no recorded window overlaps it
+func (plans windowPlans) planFor(start, end time.Time) string {
+	for _, wp := range plans {
+		if wp.start.Before(end) && start.Before(wp.end) {
+			return wp.plan
+		}
+	}
+	return CurrentPlan()
+}
+
+// CountWindows returns the number of session windows
+func (s *SessionWindowService) CountWindows() (int, error) {
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM session_windows").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count session windows: %w", err)
+	}
+	return count, nil
+}
+
 // getOldestUnassignedMessage gets the oldest message not assigned to any session window
 func (s *SessionWindowService) getOldestUnassignedMessage() (*Message, error) {
 	query := `
@@ -223,8 +377,8 @@ func (s *SessionWindowService) truncateToHour(t time.Time) time.Time {
 func (s *SessionWindowService) insertWindow(window *SessionWindow) error {
 	query := `
 		INSERT INTO session_windows (
-			id, window_start, window_end, reset_time, is_active
-		) VALUES (?, ?, ?, ?, ?)
+			id, window_start, window_end, reset_time, is_active, plan
+		) VALUES (?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := s.db.Exec(query,
@@ -233,6 +387,7 @@ func (s *SessionWindowService) insertWindow(window *SessionWindow) error {
 		window.WindowEnd,
 		window.ResetTime,
 		window.IsActive,
+		window.Plan,
 	)
 
 	return err
@@ -297,19 +452,7 @@ func (s *SessionWindowService) GetOrCreateWindowForMessage(messageTime time.Time
 	}
 
 	// 適合するウィンドウがない場合、このメッセージ時間を基準にウィンドウを作成
-	windowStart := s.truncateToMinute(messageTime)
-	tempWindowEnd := windowStart.Add(WINDOW_DURATION)
-	// WindowEndも分単位を切り捨てて時間単位にする（例：10:20 -> 10:00）
-	windowEnd := s.truncateToHour(tempWindowEnd)
-
-	// 同じ時間範囲のウィンドウが既に存在するかチェック（競合状態回避）
-	existingWindow, err = s.findWindowForTime(windowStart)
-	if err != nil {
-		return nil, err
-	}
-	if existingWindow != nil {
-		return existingWindow, nil
-	}
+	windowStart, windowEnd := s.windowBoundsForTime(messageTime)
 
 	// 新しいウィンドウを作成
 	// ResetTimeはWindowEndと同じ（両方とも時間単位で切り捨て）
@@ -321,14 +464,149 @@ func (s *SessionWindowService) GetOrCreateWindowForMessage(messageTime time.Time
 		WindowEnd:   windowEnd,
 		ResetTime:   resetTime,
 		IsActive:    true,
+		Plan:        CurrentPlan(),
+	}
+
+	// 並行する同期が同じ時間範囲のウィンドウを作成した場合はそちらを返す
+	created, err := s.createWindow(window)
+	if err != nil {
+		return nil, err
+	}
+
+	created.applyPlanLimit()
+	return created, nil
+}
+
+// createWindow inserts the window unless one with the same time range already exists, and
+// returns whichever window holds the range. The unique index on (window_start, window_end)
+// lets only one of several concurrent callers insert; the others read the winner.
+func (s *SessionWindowService) createWindow(window *SessionWindow) (*SessionWindow, error) {
+	insertErr := s.insertWindowIfAbsent(window)
+
+	// A losing insert either did nothing or failed with a transaction conflict
+	existing, err := s.findWindowForTime(window.WindowStart)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && existing.WindowStart.Equal(window.WindowStart) && existing.WindowEnd.Equal(window.WindowEnd) {
+		return existing, nil
+	}
+	if insertErr != nil {
+		return nil, fmt.Errorf("failed to insert window: %w", insertErr)
+	}
+	return nil, fmt.Errorf("failed to find window starting at %s after insert", window.WindowStart)
+}
+
+// insertWindowIfAbsent inserts the window in a transaction, doing nothing if its time range is taken
+func (s *SessionWindowService) insertWindowIfAbsent(window *SessionWindow) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO session_windows (
+			id, window_start, window_end, reset_time, is_active, plan
+		) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING
+	`,
+		window.ID,
+		window.WindowStart,
+		window.WindowEnd,
+		window.ResetTime,
+		window.IsActive,
+		window.Plan,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// windowBoundsForTime returns the start and end of a new window anchored at the given time
+func (s *SessionWindowService) windowBoundsForTime(t time.Time) (time.Time, time.Time) {
+	windowStart := s.truncateToMinute(t)
+	tempWindowEnd := windowStart.Add(WINDOW_DURATION)
+	// WindowEndも分単位を切り捨てて時間単位にする（例：10:20 -> 10:00）
+	windowEnd := s.truncateToHour(tempWindowEnd)
+	return windowStart, windowEnd
+}
+
+// WindowPreview is a window RecalculateAllWindows would create, with the messages it would hold
+type WindowPreview struct {
+	WindowStart  time.Time `json:"window_start"`
+	WindowEnd    time.Time `json:"window_end"`
+	ResetTime    time.Time `json:"reset_time"`
+	MessageCount int       `json:"message_count"`
+}
+
+// PreviewWindows returns the windows RecalculateAllWindows would create that overlap
+// [from, to), without changing anything. Windows depend on every earlier message, so the
+// recalculation is replayed from the oldest message; the last window also counts messages
+// after to that it would hold.
+func (s *SessionWindowService) PreviewWindows(from, to time.Time) ([]WindowPreview, error) {
+	rows, err := s.db.Query(`SELECT timestamp FROM messages ORDER BY timestamp ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages for preview: %w", err)
 	}
+	defer rows.Close()
+
+	previews := []WindowPreview{}
+	var current *WindowPreview
+	for rows.Next() {
+		var timestamp time.Time
+		if err := rows.Scan(&timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan message timestamp: %w", err)
+		}
 
-	err = s.insertWindow(window)
+		// Same as the recalculation: the oldest message outside the current window starts a new one
+		if current == nil || !timestamp.Before(current.WindowEnd) {
+			if !timestamp.Before(to) {
+				break
+			}
+			if current != nil && current.WindowEnd.After(from) {
+				previews = append(previews, *current)
+			}
+			windowStart, windowEnd := s.windowBoundsForTime(timestamp)
+			current = &WindowPreview{WindowStart: windowStart, WindowEnd: windowEnd, ResetTime: windowEnd}
+		}
+		current.MessageCount++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over messages for preview: %w", err)
+	}
+
+	if current != nil && current.WindowEnd.After(from) {
+		previews = append(previews, *current)
+	}
+
+	return previews, nil
+}
+
+// ResolveWindowForTime returns the existing window containing the given time.
+// If none exists, it returns the window that would be created for that time without persisting it.
+func (s *SessionWindowService) ResolveWindowForTime(t time.Time) (existing *SessionWindow, proposed *SessionWindow, err error) {
+	existing, err = s.findWindowForTime(t)
 	if err != nil {
-		return nil, fmt.Errorf("failed to insert window: %w", err)
+		return nil, nil, err
+	}
+	if existing != nil {
+		return existing, nil, nil
+	}
+
+	windowStart, windowEnd := s.windowBoundsForTime(t)
+	proposed = &SessionWindow{
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+		ResetTime:   windowEnd,
+		IsActive:    true,
+		Plan:        CurrentPlan(),
 	}
+	proposed.applyPlanLimit()
 
-	return window, nil
+	return nil, proposed, nil
 }
 
 // UpdateWindowStats recalculates and updates the statistics for a window using time-based calculation
@@ -350,6 +628,11 @@ func (s *SessionWindowService) UpdateWindowStats(windowID string) error {
 		totalCost = 0.0
 	}
 
+	totalTokensExpr := "m.input_tokens + m.output_tokens"
+	if s.includeCacheTokens {
+		totalTokensExpr = "m.input_tokens + m.output_tokens + COALESCE(m.cache_creation_input_tokens, 0) + COALESCE(m.cache_read_input_tokens, 0)"
+	}
+
 	// Calculate stats using relation table
 	query := `
 		UPDATE session_windows 
@@ -367,7 +650,19 @@ func (s *SessionWindowService) UpdateWindowStats(windowID string) error {
 				WHERE swm.session_window_id = ?
 			),
 			total_tokens = (
-				SELECT COALESCE(SUM(m.input_tokens + m.output_tokens), 0) 
+				SELECT COALESCE(SUM(` + totalTokensExpr + `), 0) 
+				FROM messages m
+				INNER JOIN session_window_messages swm ON m.id = swm.message_id
+				WHERE swm.session_window_id = ?
+			),
+			total_cache_creation_tokens = (
+				SELECT COALESCE(SUM(m.cache_creation_input_tokens), 0) 
+				FROM messages m
+				INNER JOIN session_window_messages swm ON m.id = swm.message_id
+				WHERE swm.session_window_id = ?
+			),
+			total_cache_read_tokens = (
+				SELECT COALESCE(SUM(m.cache_read_input_tokens), 0) 
 				FROM messages m
 				INNER JOIN session_window_messages swm ON m.id = swm.message_id
 				WHERE swm.session_window_id = ?
@@ -393,6 +688,8 @@ func (s *SessionWindowService) UpdateWindowStats(windowID string) error {
 		windowID,  // total_input_tokens
 		windowID,  // total_output_tokens
 		windowID,  // total_tokens
+		windowID,  // total_cache_creation_tokens
+		windowID,  // total_cache_read_tokens
 		windowID,  // message_count
 		windowID,  // session_count
 		totalCost, // total_cost
@@ -423,53 +720,17 @@ func (s *SessionWindowService) calculateWindowCost(windowStart, windowEnd time.T
 
 // calculateWindowCostByID calculates the total cost for messages in a specific window by ID
 func (s *SessionWindowService) calculateWindowCostByID(windowID string) (float64, error) {
-	pricingCalculator := NewPricingCalculator()
-
 	query := `
-		SELECT 
-			m.model,
-			COALESCE(SUM(m.input_tokens), 0) as total_input_tokens,
-			COALESCE(SUM(m.output_tokens), 0) as total_output_tokens,
-			COALESCE(SUM(m.cache_creation_input_tokens), 0) as total_cache_creation_tokens,
-			COALESCE(SUM(m.cache_read_input_tokens), 0) as total_cache_read_tokens
+		SELECT COALESCE(SUM(m.cost), 0)
 		FROM messages m
 		INNER JOIN session_window_messages swm ON m.id = swm.message_id
 		WHERE swm.session_window_id = ?
 		AND m.message_role = 'assistant'
-		AND m.model IS NOT NULL
-		GROUP BY m.model
 	`
 
-	rows, err := s.db.Query(query, windowID)
-	if err != nil {
-		return 0.0, fmt.Errorf("failed to query messages for cost calculation: %w", err)
-	}
-	defer rows.Close()
-
 	var totalCost float64
-
-	for rows.Next() {
-		var model string
-		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int
-
-		err := rows.Scan(&model, &inputTokens, &outputTokens, &cacheCreationTokens, &cacheReadTokens)
-		if err != nil {
-			return 0.0, fmt.Errorf("failed to scan message data for cost calculation: %w", err)
-		}
-
-		cost := pricingCalculator.CalculateCost(
-			model,
-			inputTokens,
-			outputTokens,
-			cacheCreationTokens,
-			cacheReadTokens,
-		)
-
-		totalCost += cost
-	}
-
-	if err := rows.Err(); err != nil {
-		return 0.0, fmt.Errorf("error iterating over messages for cost calculation: %w", err)
+	if err := s.db.QueryRow(query, windowID).Scan(&totalCost); err != nil {
+		return 0.0, fmt.Errorf("failed to query messages for cost calculation: %w", err)
 	}
 
 	return totalCost, nil
@@ -502,8 +763,10 @@ func (s *SessionWindowService) GetRecentWindows(limit int) ([]*SessionWindow, er
 		SELECT 
 			id, window_start, window_end, reset_time,
 			total_input_tokens, total_output_tokens, total_tokens,
+			COALESCE(total_cache_creation_tokens, 0) as total_cache_creation_tokens,
+			COALESCE(total_cache_read_tokens, 0) as total_cache_read_tokens,
 			message_count, session_count, COALESCE(total_cost, 0) as total_cost, is_active,
-			created_at, updated_at
+			COALESCE(plan, '') as plan, created_at, updated_at
 		FROM session_windows 
 		ORDER BY window_start DESC
 		LIMIT ?
@@ -527,10 +790,13 @@ func (s *SessionWindowService) GetRecentWindows(limit int) ([]*SessionWindow, er
 			&window.TotalInputTokens,
 			&window.TotalOutputTokens,
 			&window.TotalTokens,
+			&window.TotalCacheCreationTokens,
+			&window.TotalCacheReadTokens,
 			&window.MessageCount,
 			&window.SessionCount,
 			&window.TotalCost,
 			&window.IsActive,
+			&window.Plan,
 			&window.CreatedAt,
 			&window.UpdatedAt,
 		)
@@ -538,12 +804,105 @@ func (s *SessionWindowService) GetRecentWindows(limit int) ([]*SessionWindow, er
 			return nil, fmt.Errorf("failed to scan window: %w", err)
 		}
 
+		window.applyPlanLimit()
 		windows = append(windows, &window)
 	}
 
 	return windows, nil
 }
 
+// windowCSVFlushRows is how many CSV rows are written between flushes to the client
+const windowCSVFlushRows = 100
+
+// WindowCSVHeader lists the columns written by ExportWindowsCSV
+var WindowCSVHeader = []string{
+	"window_start", "window_end", "total_input_tokens", "total_output_tokens",
+	"total_tokens", "message_count", "session_count", "total_cost",
+}
+
+// ExportWindowsCSV writes every session window, oldest first, as CSV rows to w.
+// from and to optionally bound window_start (inclusive). Rows are streamed from the
+// query and flushed in batches, so nothing is written if the query itself fails.
+func (s *SessionWindowService) ExportWindowsCSV(w io.Writer, from, to *time.Time) error {
+	query := `
+		SELECT 
+			window_start, window_end, total_input_tokens, total_output_tokens, total_tokens,
+			message_count, session_count, COALESCE(total_cost, 0) as total_cost
+		FROM session_windows
+		WHERE 1=1`
+	var args []interface{}
+	if from != nil {
+		query += " AND window_start >= ?"
+		args = append(args, *from)
+	}
+	if to != nil {
+		query += " AND window_start <= ?"
+		args = append(args, *to)
+	}
+	query += " ORDER BY window_start ASC"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query windows for export: %w", err)
+	}
+	defer rows.Close()
+
+	// Push each batch to the client when the writer supports it (e.g. an HTTP response)
+	flusher, _ := w.(interface{ Flush() })
+	writer := csv.NewWriter(w)
+	flush := func() error {
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to write windows CSV: %w", err)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	if err := writer.Write(WindowCSVHeader); err != nil {
+		return fmt.Errorf("failed to write windows CSV: %w", err)
+	}
+
+	count := 0
+	for rows.Next() {
+		var windowStart, windowEnd time.Time
+		var inputTokens, outputTokens, totalTokens, messageCount, sessionCount int
+		var totalCost float64
+		if err := rows.Scan(&windowStart, &windowEnd, &inputTokens, &outputTokens, &totalTokens,
+			&messageCount, &sessionCount, &totalCost); err != nil {
+			return fmt.Errorf("failed to scan window for export: %w", err)
+		}
+
+		record := []string{
+			windowStart.UTC().Format(time.RFC3339),
+			windowEnd.UTC().Format(time.RFC3339),
+			strconv.Itoa(inputTokens),
+			strconv.Itoa(outputTokens),
+			strconv.Itoa(totalTokens),
+			strconv.Itoa(messageCount),
+			strconv.Itoa(sessionCount),
+			strconv.FormatFloat(totalCost, 'f', 6, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write windows CSV: %w", err)
+		}
+
+		count++
+		if count%windowCSVFlushRows == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating over windows for export: %w", err)
+	}
+
+	return flush()
+}
+
 // deactivateWindow marks a window as inactive
 func (s *SessionWindowService) deactivateWindow(windowID string) error {
 	query := `
@@ -565,13 +924,29 @@ func (s *SessionWindowService) roundToNextHour(t time.Time) time.Time {
 	return t.Truncate(time.Hour)
 }
 
+// GetUpcomingResetTime returns the reset time of the active window, or nil if there is
+// no active window or its reset time has already passed
+func (s *SessionWindowService) GetUpcomingResetTime() (*time.Time, error) {
+	window, err := s.GetActiveWindow()
+	if err != nil {
+		return nil, err
+	}
+	if window == nil || !window.ResetTime.After(time.Now()) {
+		return nil, nil
+	}
+	resetTime := window.ResetTime
+	return &resetTime, nil
+}
+
 // GetActiveWindow returns the currently active session window
 func (s *SessionWindowService) GetActiveWindow() (*SessionWindow, error) {
 	query := `
 		SELECT id, window_start, window_end, reset_time, 
 		       total_input_tokens, total_output_tokens, total_tokens,
+		       COALESCE(total_cache_creation_tokens, 0) as total_cache_creation_tokens,
+		       COALESCE(total_cache_read_tokens, 0) as total_cache_read_tokens,
 		       message_count, session_count, total_cost, is_active,
-		       created_at, updated_at
+		       COALESCE(plan, '') as plan, created_at, updated_at
 		FROM session_windows
 		WHERE is_active = true
 		ORDER BY window_start DESC
@@ -587,10 +962,13 @@ func (s *SessionWindowService) GetActiveWindow() (*SessionWindow, error) {
 		&window.TotalInputTokens,
 		&window.TotalOutputTokens,
 		&window.TotalTokens,
+		&window.TotalCacheCreationTokens,
+		&window.TotalCacheReadTokens,
 		&window.MessageCount,
 		&window.SessionCount,
 		&window.TotalCost,
 		&window.IsActive,
+		&window.Plan,
 		&window.CreatedAt,
 		&window.UpdatedAt,
 	)
@@ -602,5 +980,6 @@ func (s *SessionWindowService) GetActiveWindow() (*SessionWindow, error) {
 		return nil, fmt.Errorf("failed to get active window: %w", err)
 	}
 	
+	window.applyPlanLimit()
 	return &window, nil
 }
@@ -0,0 +1,642 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ccdash-backend/internal/models"
+)
+
+func TestResolveWindowForTime_InWindow(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewSessionWindowService(db)
+
+	windowStart := time.Date(2025, 7, 15, 10, 0, 0, 0, time.UTC)
+	windowEnd := time.Date(2025, 7, 15, 15, 0, 0, 0, time.UTC)
+	_, err := db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, is_active)
+		VALUES (?, ?, ?, ?, ?)
+	`, "window-1", windowStart, windowEnd, windowEnd, true)
+	if err != nil {
+		t.Fatalf("Failed to insert test window: %v", err)
+	}
+
+	existing, proposed, err := service.ResolveWindowForTime(windowStart.Add(2 * time.Hour))
+	if err != nil {
+		t.Fatalf("ResolveWindowForTime failed: %v", err)
+	}
+
+	if existing == nil {
+		t.Fatal("Expected an existing window")
+	}
+	if existing.ID != "window-1" {
+		t.Errorf("Expected window-1, got %s", existing.ID)
+	}
+	if proposed != nil {
+		t.Error("Expected no proposed window when an existing window matches")
+	}
+}
+
+func TestGetActiveWindowStatus(t *testing.T) {
+	setTestPlan(t, models.PlanPro)
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewSessionWindowService(db)
+
+	windowStart := time.Date(2025, 7, 15, 10, 0, 0, 0, time.UTC)
+	windowEnd := windowStart.Add(WINDOW_DURATION)
+	now := windowStart.Add(90 * time.Minute)
+
+	status, err := service.GetActiveWindowStatus(now)
+	if err != nil {
+		t.Fatalf("GetActiveWindowStatus failed: %v", err)
+	}
+	if status != nil {
+		t.Fatalf("Expected no status without windows, got %+v", status)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, total_tokens, is_active, plan)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, "window-1", windowStart, windowEnd, windowEnd, 3500, true, models.PlanPro)
+	if err != nil {
+		t.Fatalf("Failed to insert test window: %v", err)
+	}
+
+	status, err = service.GetActiveWindowStatus(now)
+	if err != nil {
+		t.Fatalf("GetActiveWindowStatus failed: %v", err)
+	}
+	if status == nil || status.Window.ID != "window-1" {
+		t.Fatalf("Expected status for window-1, got %+v", status)
+	}
+	if status.UsageLimit != CLAUDE_PRO_LIMIT || status.UsageRate != 0.5 || status.TokensRemaining != CLAUDE_PRO_LIMIT-3500 {
+		t.Errorf("Expected half of the pro limit used, got %+v", status)
+	}
+	if status.SecondsUntilReset != int64((WINDOW_DURATION - 90*time.Minute).Seconds()) {
+		t.Errorf("Expected %v until reset, got %d seconds", WINDOW_DURATION-90*time.Minute, status.SecondsUntilReset)
+	}
+
+	// Usage over the limit keeps the rate above 1.0 but never reports negative remaining tokens
+	if _, err := db.Exec(`UPDATE session_windows SET total_tokens = ? WHERE id = ?`, CLAUDE_PRO_LIMIT*3/2, "window-1"); err != nil {
+		t.Fatalf("Failed to update test window: %v", err)
+	}
+	status, err = service.GetActiveWindowStatus(now)
+	if err != nil {
+		t.Fatalf("GetActiveWindowStatus failed: %v", err)
+	}
+	if status.UsageRate != 1.5 || status.TokensRemaining != 0 {
+		t.Errorf("Expected rate 1.5 and no remaining tokens, got rate %v and %d remaining", status.UsageRate, status.TokensRemaining)
+	}
+
+	// After the reset time the window is no longer active, even if still flagged
+	status, err = service.GetActiveWindowStatus(windowEnd.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetActiveWindowStatus failed: %v", err)
+	}
+	if status != nil {
+		t.Errorf("Expected no status after reset, got %+v", status)
+	}
+}
+
+func TestResolveWindowForTime_OutOfWindow(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewSessionWindowService(db)
+
+	windowStart := time.Date(2025, 7, 15, 10, 0, 0, 0, time.UTC)
+	windowEnd := time.Date(2025, 7, 15, 15, 0, 0, 0, time.UTC)
+	_, err := db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, is_active)
+		VALUES (?, ?, ?, ?, ?)
+	`, "window-1", windowStart, windowEnd, windowEnd, true)
+	if err != nil {
+		t.Fatalf("Failed to insert test window: %v", err)
+	}
+
+	// window_end is exclusive, so a timestamp after it falls outside
+	target := time.Date(2025, 7, 15, 16, 28, 45, 0, time.UTC)
+	existing, proposed, err := service.ResolveWindowForTime(target)
+	if err != nil {
+		t.Fatalf("ResolveWindowForTime failed: %v", err)
+	}
+
+	if existing != nil {
+		t.Fatalf("Expected no existing window, got %s", existing.ID)
+	}
+	if proposed == nil {
+		t.Fatal("Expected a proposed window")
+	}
+
+	expectedStart := time.Date(2025, 7, 15, 16, 28, 0, 0, time.UTC)
+	expectedEnd := time.Date(2025, 7, 15, 21, 0, 0, 0, time.UTC)
+	if !proposed.WindowStart.Equal(expectedStart) {
+		t.Errorf("Expected proposed start %v, got %v", expectedStart, proposed.WindowStart)
+	}
+	if !proposed.WindowEnd.Equal(expectedEnd) {
+		t.Errorf("Expected proposed end %v, got %v", expectedEnd, proposed.WindowEnd)
+	}
+	if !proposed.ResetTime.Equal(expectedEnd) {
+		t.Errorf("Expected proposed reset time %v, got %v", expectedEnd, proposed.ResetTime)
+	}
+
+	// Resolving must not persist the proposed window
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM session_windows").Scan(&count); err != nil {
+		t.Fatalf("Failed to count windows: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 window after resolution, got %d", count)
+	}
+}
+
+func TestUpdateWindowStats_CacheTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS session_window_messages (
+			id TEXT PRIMARY KEY,
+			session_window_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(session_window_id, message_id)
+		);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to extend test schema: %v", err)
+	}
+
+	windowStart := time.Date(2025, 7, 15, 10, 0, 0, 0, time.UTC)
+	windowEnd := time.Date(2025, 7, 15, 15, 0, 0, 0, time.UTC)
+
+	_, err = db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) 
+		VALUES (?, ?, ?, ?)
+	`, "cache-session", "test-project", "/test/path", windowStart)
+	if err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO session_windows (id, window_start, window_end, reset_time, is_active)
+		VALUES (?, ?, ?, ?, ?)
+	`, "cache-window", windowStart, windowEnd, windowEnd, true)
+	if err != nil {
+		t.Fatalf("Failed to insert test window: %v", err)
+	}
+
+	// Cache-heavy messages: cache tokens dwarf regular input/output
+	testMessages := []struct {
+		id            string
+		input         int
+		output        int
+		cacheCreation int
+		cacheRead     int
+	}{
+		{"cache-msg-1", 10, 100, 5000, 20000},
+		{"cache-msg-2", 5, 50, 0, 30000},
+	}
+
+	relationService := NewSessionWindowMessageService(db)
+	for i, msg := range testMessages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens, cache_creation_input_tokens, cache_read_input_tokens, model) 
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, msg.id, "cache-session", "assistant", "test", windowStart.Add(time.Duration(i+1)*time.Minute),
+			msg.input, msg.output, msg.cacheCreation, msg.cacheRead, "claude-sonnet-4-20250514")
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+		if err := relationService.AddMessageToWindow("cache-window", msg.id); err != nil {
+			t.Fatalf("Failed to relate message to window: %v", err)
+		}
+	}
+
+	service := NewSessionWindowService(db)
+
+	// Disabled: total_tokens only counts input + output
+	service.SetIncludeCacheTokens(false)
+	if err := service.UpdateWindowStats("cache-window"); err != nil {
+		t.Fatalf("UpdateWindowStats failed: %v", err)
+	}
+
+	window, err := service.findWindowForTime(windowStart.Add(time.Hour))
+	if err != nil || window == nil {
+		t.Fatalf("Failed to load window: %v", err)
+	}
+	if window.TotalTokens != 165 {
+		t.Errorf("Expected 165 total tokens without cache, got %d", window.TotalTokens)
+	}
+	if window.TotalCacheCreationTokens != 5000 {
+		t.Errorf("Expected 5000 cache creation tokens, got %d", window.TotalCacheCreationTokens)
+	}
+	if window.TotalCacheReadTokens != 50000 {
+		t.Errorf("Expected 50000 cache read tokens, got %d", window.TotalCacheReadTokens)
+	}
+
+	// Enabled: total_tokens includes cache creation and read tokens
+	service.SetIncludeCacheTokens(true)
+	if err := service.UpdateWindowStats("cache-window"); err != nil {
+		t.Fatalf("UpdateWindowStats failed: %v", err)
+	}
+
+	window, err = service.findWindowForTime(windowStart.Add(time.Hour))
+	if err != nil || window == nil {
+		t.Fatalf("Failed to load window: %v", err)
+	}
+	if window.TotalTokens != 55165 {
+		t.Errorf("Expected 55165 total tokens with cache, got %d", window.TotalTokens)
+	}
+}
+
+// flushCountingBuffer records how often the CSV export flushes to the client
+type flushCountingBuffer struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (b *flushCountingBuffer) Flush() {
+	b.flushes++
+}
+
+func TestExportWindowsCSV(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewSessionWindowService(db)
+
+	base := time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		start := base.Add(time.Duration(i) * 5 * time.Hour)
+		_, err := db.Exec(`
+			INSERT INTO session_windows (id, window_start, window_end, reset_time, total_input_tokens, total_output_tokens,
+				total_tokens, message_count, session_count, total_cost, is_active)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, false)
+		`, fmt.Sprintf("window-%d", i), start, start.Add(5*time.Hour), start.Add(5*time.Hour),
+			100*(i+1), 10*(i+1), 110*(i+1), i+1, 1, 0.5*float64(i+1))
+		if err != nil {
+			t.Fatalf("Failed to insert window: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := service.ExportWindowsCSV(&out, nil, nil); err != nil {
+		t.Fatalf("ExportWindowsCSV failed: %v", err)
+	}
+	expected := "window_start,window_end,total_input_tokens,total_output_tokens,total_tokens,message_count,session_count,total_cost\n" +
+		"2025-07-15T00:00:00Z,2025-07-15T05:00:00Z,100,10,110,1,1,0.500000\n" +
+		"2025-07-15T05:00:00Z,2025-07-15T10:00:00Z,200,20,220,2,1,1.000000\n" +
+		"2025-07-15T10:00:00Z,2025-07-15T15:00:00Z,300,30,330,3,1,1.500000\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected CSV:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+
+	// from/to bound window_start inclusively
+	from := base.Add(5 * time.Hour)
+	to := base.Add(10 * time.Hour)
+	out.Reset()
+	if err := service.ExportWindowsCSV(&out, &from, &to); err != nil {
+		t.Fatalf("ExportWindowsCSV with range failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "2025-07-15T05:00:00Z") || !strings.HasPrefix(lines[2], "2025-07-15T10:00:00Z") {
+		t.Errorf("Expected header and the two windows in range, got:\n%s", out.String())
+	}
+
+	// Large exports are flushed in batches instead of buffered whole
+	for i := 3; i < 250; i++ {
+		start := base.Add(time.Duration(i) * 5 * time.Hour)
+		_, err := db.Exec(`INSERT INTO session_windows (id, window_start, window_end, reset_time, is_active) VALUES (?, ?, ?, ?, false)`,
+			fmt.Sprintf("window-%d", i), start, start.Add(5*time.Hour), start.Add(5*time.Hour))
+		if err != nil {
+			t.Fatalf("Failed to insert window: %v", err)
+		}
+	}
+	streamed := &flushCountingBuffer{}
+	if err := service.ExportWindowsCSV(streamed, nil, nil); err != nil {
+		t.Fatalf("ExportWindowsCSV failed: %v", err)
+	}
+	if streamed.flushes != 3 {
+		t.Errorf("Expected 3 flushes for 250 rows, got %d", streamed.flushes)
+	}
+	if lines := strings.Count(streamed.String(), "\n"); lines != 251 {
+		t.Errorf("Expected 251 CSV lines, got %d", lines)
+	}
+}
+
+func TestPreviewWindows_MatchesRecalculation(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS session_window_messages (
+			id TEXT PRIMARY KEY,
+			session_window_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(session_window_id, message_id)
+		);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to extend test schema: %v", err)
+	}
+
+	service := NewSessionWindowService(db)
+
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('preview-session', 'p', '/p', ?)`,
+		time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+
+	// Windows: 08:13-13:00 (3 messages), 13:30-18:00 (2), 21:05-02:00 (1), next day 09:00-14:00 (1)
+	timestamps := []time.Time{
+		time.Date(2025, 7, 15, 8, 13, 42, 0, time.UTC),
+		time.Date(2025, 7, 15, 9, 0, 0, 0, time.UTC),
+		time.Date(2025, 7, 15, 12, 59, 59, 0, time.UTC),
+		time.Date(2025, 7, 15, 13, 30, 0, 0, time.UTC),
+		time.Date(2025, 7, 15, 17, 0, 0, 0, time.UTC),
+		time.Date(2025, 7, 15, 21, 5, 0, 0, time.UTC),
+		time.Date(2025, 7, 16, 9, 0, 0, 0, time.UTC),
+	}
+	for i, ts := range timestamps {
+		_, err := db.Exec(`INSERT INTO messages (id, session_id, message_role, timestamp) VALUES (?, 'preview-session', 'user', ?)`,
+			fmt.Sprintf("preview-msg-%d", i), ts)
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+	}
+
+	// The range starts inside the first window and ends before the last one
+	from := time.Date(2025, 7, 15, 10, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 7, 16, 0, 0, 0, 0, time.UTC)
+
+	preview, err := service.PreviewWindows(from, to)
+	if err != nil {
+		t.Fatalf("PreviewWindows failed: %v", err)
+	}
+
+	var windowCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM session_windows").Scan(&windowCount); err != nil {
+		t.Fatalf("Failed to count windows: %v", err)
+	}
+	if windowCount != 0 {
+		t.Fatalf("Expected preview not to create windows, found %d", windowCount)
+	}
+
+	if err := service.RecalculateAllWindows(); err != nil {
+		t.Fatalf("RecalculateAllWindows failed: %v", err)
+	}
+
+	rows, err := db.Query(`
+		SELECT sw.window_start, sw.window_end, sw.reset_time, COUNT(swm.message_id)
+		FROM session_windows sw
+		LEFT JOIN session_window_messages swm ON sw.id = swm.session_window_id
+		WHERE sw.window_end > ? AND sw.window_start < ?
+		GROUP BY sw.id, sw.window_start, sw.window_end, sw.reset_time
+		ORDER BY sw.window_start`, from, to)
+	if err != nil {
+		t.Fatalf("Failed to query recalculated windows: %v", err)
+	}
+	defer rows.Close()
+
+	var actual []WindowPreview
+	for rows.Next() {
+		var w WindowPreview
+		if err := rows.Scan(&w.WindowStart, &w.WindowEnd, &w.ResetTime, &w.MessageCount); err != nil {
+			t.Fatalf("Failed to scan window: %v", err)
+		}
+		actual = append(actual, w)
+	}
+
+	if len(actual) != 3 {
+		t.Fatalf("Expected 3 recalculated windows in range, got %d: %+v", len(actual), actual)
+	}
+	if len(preview) != len(actual) {
+		t.Fatalf("Expected preview of %d windows, got %d: %+v", len(actual), len(preview), preview)
+	}
+	for i := range actual {
+		p, a := preview[i], actual[i]
+		if !p.WindowStart.Equal(a.WindowStart) || !p.WindowEnd.Equal(a.WindowEnd) || !p.ResetTime.Equal(a.ResetTime) || p.MessageCount != a.MessageCount {
+			t.Errorf("Window %d: preview %+v does not match recalculation %+v", i, p, a)
+		}
+	}
+}
+
+func TestValidateWindows(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS session_window_messages (
+			id TEXT PRIMARY KEY,
+			session_window_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(session_window_id, message_id)
+		);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to extend test schema: %v", err)
+	}
+
+	service := NewSessionWindowService(db)
+	insertWindow := func(id string, start, end time.Time) {
+		_, err := db.Exec(`
+			INSERT INTO session_windows (id, window_start, window_end, reset_time, is_active)
+			VALUES (?, ?, ?, ?, ?)
+		`, id, start, end, end, true)
+		if err != nil {
+			t.Fatalf("Failed to insert window %s: %v", id, err)
+		}
+	}
+
+	base := time.Date(2025, 7, 15, 10, 0, 0, 0, time.UTC)
+	// Consecutive windows only touch at the edge
+	insertWindow("w1", base, base.Add(5*time.Hour))
+	insertWindow("w2", base.Add(5*time.Hour), base.Add(10*time.Hour))
+
+	validation, err := service.ValidateWindows()
+	if err != nil {
+		t.Fatalf("ValidateWindows failed: %v", err)
+	}
+	if !validation.Valid || validation.WindowCount != 2 || len(validation.Overlaps) != 0 {
+		t.Errorf("Expected adjacent windows to be valid, got %+v", validation)
+	}
+
+	// w3 overlaps w1 and w2, w4 duplicates w1's range
+	insertWindow("w3", base.Add(3*time.Hour), base.Add(8*time.Hour))
+	insertWindow("w4", base, base.Add(5*time.Hour))
+
+	relationService := NewSessionWindowMessageService(db)
+	for _, relation := range []struct{ window, message string }{
+		{"w1", "m1"}, {"w4", "m1"}, {"w2", "m2"}, {"w3", "m2"}, {"w1", "m3"},
+	} {
+		if err := relationService.AddMessageToWindow(relation.window, relation.message); err != nil {
+			t.Fatalf("Failed to relate message: %v", err)
+		}
+	}
+
+	validation, err = service.ValidateWindows()
+	if err != nil {
+		t.Fatalf("ValidateWindows failed: %v", err)
+	}
+	if validation.Valid {
+		t.Error("Expected overlapping windows to be invalid")
+	}
+
+	pairs := map[string]bool{}
+	for _, overlap := range validation.Overlaps {
+		pairs[overlap.FirstID+"-"+overlap.SecondID] = true
+	}
+	for _, expected := range []string{"w1-w3", "w1-w4", "w2-w3", "w3-w4"} {
+		if !pairs[expected] {
+			t.Errorf("Expected overlap %s to be reported, got %+v", expected, validation.Overlaps)
+		}
+	}
+	if validation.OverlapCount != 4 || len(validation.Overlaps) != 4 {
+		t.Errorf("Expected 4 overlapping pairs, got %d (%d listed)", validation.OverlapCount, len(validation.Overlaps))
+	}
+
+	if validation.MultiWindowMessageCount != 2 || len(validation.MultiWindowMessages) != 2 {
+		t.Fatalf("Expected 2 messages in multiple windows, got %+v", validation.MultiWindowMessages)
+	}
+	m1 := validation.MultiWindowMessages[0]
+	if m1.MessageID != "m1" || len(m1.WindowIDs) != 2 || m1.WindowIDs[0] != "w1" || m1.WindowIDs[1] != "w4" {
+		t.Errorf("Expected m1 in w1 and w4, got %+v", m1)
+	}
+}
+
+func TestGetOrCreateWindowForMessage_Concurrent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`CREATE UNIQUE INDEX idx_session_windows_range ON session_windows(window_start, window_end)`)
+	if err != nil {
+		t.Fatalf("Failed to create window range index: %v", err)
+	}
+
+	service := NewSessionWindowService(db)
+	messageTime := time.Date(2025, 7, 15, 10, 20, 30, 0, time.UTC)
+
+	const workers = 20
+	var wg sync.WaitGroup
+	windowIDs := make([]string, workers)
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			window, err := service.GetOrCreateWindowForMessage(messageTime)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			windowIDs[i] = window.ID
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("Worker %d failed: %v", i, err)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM session_windows").Scan(&count); err != nil {
+		t.Fatalf("Failed to count windows: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("Expected exactly 1 window, got %d", count)
+	}
+
+	for i, id := range windowIDs {
+		if id != windowIDs[0] {
+			t.Errorf("Worker %d got window %s, expected %s", i, id, windowIDs[0])
+		}
+	}
+}
+
+func TestRecalculateAllWindows_KeepsPlans(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS session_window_messages (
+			id TEXT PRIMARY KEY,
+			session_window_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(session_window_id, message_id)
+		);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to extend test schema: %v", err)
+	}
+
+	service := NewSessionWindowService(db)
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES ('plan-session', 'p', '/p', ?)`,
+		time.Date(2025, 7, 15, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Failed to insert session: %v", err)
+	}
+
+	// The first window is recorded on max5, the second after switching to pro
+	base := time.Date(2025, 7, 15, 9, 0, 0, 0, time.UTC)
+	messages := []struct {
+		timestamp time.Time
+		plan      string
+	}{
+		{base, models.PlanMax5},
+		{base.Add(10 * time.Minute), models.PlanMax5},
+		{base.Add(6 * time.Hour), models.PlanPro},
+	}
+	for i, m := range messages {
+		_, err := db.Exec(`INSERT INTO messages (id, session_id, message_role, timestamp) VALUES (?, 'plan-session', 'user', ?)`,
+			fmt.Sprintf("plan-msg-%d", i), m.timestamp)
+		if err != nil {
+			t.Fatalf("Failed to insert message: %v", err)
+		}
+		setTestPlan(t, m.plan)
+		if _, err := service.GetOrCreateWindowForMessage(m.timestamp); err != nil {
+			t.Fatalf("Failed to create window: %v", err)
+		}
+	}
+
+	// Upgrade after the windows were recorded
+	setTestPlan(t, models.PlanMax20)
+	if err := service.RecalculateAllWindows(); err != nil {
+		t.Fatalf("RecalculateAllWindows failed: %v", err)
+	}
+
+	rows, err := db.Query(`SELECT plan FROM session_windows ORDER BY window_start`)
+	if err != nil {
+		t.Fatalf("Failed to query windows: %v", err)
+	}
+	defer rows.Close()
+
+	var plans []string
+	for rows.Next() {
+		var plan string
+		if err := rows.Scan(&plan); err != nil {
+			t.Fatalf("Failed to scan plan: %v", err)
+		}
+		plans = append(plans, plan)
+	}
+	if len(plans) != 2 || plans[0] != models.PlanMax5 || plans[1] != models.PlanPro {
+		t.Errorf("Expected recalculated windows to keep plans [max5 pro], got %v", plans)
+	}
+}
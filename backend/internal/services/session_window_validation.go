@@ -0,0 +1,126 @@
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// MAX_WINDOW_VALIDATION_ISSUES caps how many overlaps and multi-window messages are listed.
+// The counts always cover every issue found.
+const MAX_WINDOW_VALIDATION_ISSUES = 1000
+
+// WindowOverlap is a pair of windows whose [window_start, window_end) ranges overlap.
+// Duplicate windows covering the same range are reported as overlaps too.
+type WindowOverlap struct {
+	FirstID     string    `json:"first_id"`
+	FirstStart  time.Time `json:"first_start"`
+	FirstEnd    time.Time `json:"first_end"`
+	SecondID    string    `json:"second_id"`
+	SecondStart time.Time `json:"second_start"`
+	SecondEnd   time.Time `json:"second_end"`
+}
+
+// MultiWindowMessage is a message assigned to more than one window
+type MultiWindowMessage struct {
+	MessageID string   `json:"message_id"`
+	WindowIDs []string `json:"window_ids"`
+}
+
+// WindowValidation lists the inconsistencies found in the session windows
+type WindowValidation struct {
+	Valid                   bool                 `json:"valid"`
+	WindowCount             int                  `json:"window_count"`
+	OverlapCount            int                  `json:"overlap_count"`
+	Overlaps                []WindowOverlap      `json:"overlaps"`
+	MultiWindowMessageCount int                  `json:"multi_window_message_count"`
+	MultiWindowMessages     []MultiWindowMessage `json:"multi_window_messages"`
+}
+
+// ValidateWindows reports pairs of windows whose time ranges overlap and messages
+// assigned to more than one window, neither of which should exist. It changes nothing.
+func (s *SessionWindowService) ValidateWindows() (*WindowValidation, error) {
+	validation := &WindowValidation{
+		Overlaps:            []WindowOverlap{},
+		MultiWindowMessages: []MultiWindowMessage{},
+	}
+
+	var err error
+	if validation.WindowCount, err = s.CountWindows(); err != nil {
+		return nil, err
+	}
+
+	// Ranges are half-open, so windows that only touch at an edge do not overlap
+	overlapCondition := `
+		FROM session_windows a
+		JOIN session_windows b
+			ON a.id < b.id
+			AND a.window_start < b.window_end
+			AND b.window_start < a.window_end
+	`
+	if err := s.db.QueryRow(`SELECT COUNT(*) ` + overlapCondition).Scan(&validation.OverlapCount); err != nil {
+		return nil, fmt.Errorf("failed to count overlapping windows: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT a.id, a.window_start, a.window_end, b.id, b.window_start, b.window_end
+		`+overlapCondition+`
+		ORDER BY a.window_start, b.window_start, a.id, b.id
+		LIMIT ?
+	`, MAX_WINDOW_VALIDATION_ISSUES)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query overlapping windows: %w", err)
+	}
+	for rows.Next() {
+		var overlap WindowOverlap
+		if err := rows.Scan(&overlap.FirstID, &overlap.FirstStart, &overlap.FirstEnd,
+			&overlap.SecondID, &overlap.SecondStart, &overlap.SecondEnd); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan overlapping windows: %w", err)
+		}
+		validation.Overlaps = append(validation.Overlaps, overlap)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("error iterating over overlapping windows: %w", err)
+	}
+	rows.Close()
+
+	multiWindowCondition := `
+		SELECT message_id
+		FROM session_window_messages
+		GROUP BY message_id
+		HAVING COUNT(DISTINCT session_window_id) > 1
+	`
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM (` + multiWindowCondition + `)`).Scan(&validation.MultiWindowMessageCount); err != nil {
+		return nil, fmt.Errorf("failed to count messages in multiple windows: %w", err)
+	}
+
+	rows, err = s.db.Query(`
+		SELECT DISTINCT message_id, session_window_id
+		FROM session_window_messages
+		WHERE message_id IN (`+multiWindowCondition+` ORDER BY message_id LIMIT ?)
+		ORDER BY message_id, session_window_id
+	`, MAX_WINDOW_VALIDATION_ISSUES)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages in multiple windows: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var messageID, windowID string
+		if err := rows.Scan(&messageID, &windowID); err != nil {
+			return nil, fmt.Errorf("failed to scan message in multiple windows: %w", err)
+		}
+		last := len(validation.MultiWindowMessages) - 1
+		if last < 0 || validation.MultiWindowMessages[last].MessageID != messageID {
+			validation.MultiWindowMessages = append(validation.MultiWindowMessages, MultiWindowMessage{MessageID: messageID})
+			last++
+		}
+		validation.MultiWindowMessages[last].WindowIDs = append(validation.MultiWindowMessages[last].WindowIDs, windowID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over messages in multiple windows: %w", err)
+	}
+
+	validation.Valid = validation.OverlapCount == 0 && validation.MultiWindowMessageCount == 0
+	return validation, nil
+}
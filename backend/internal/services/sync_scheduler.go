@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SyncInProgress reports whether a log sync is running
+func SyncInProgress() bool {
+	return atomic.LoadInt32(&activeSyncs) > 0
+}
+
+// SyncScheduler periodically diff-syncs the Claude logs so the dashboard stays current
+type SyncScheduler struct {
+	diffSyncService *DiffSyncService
+	interval        time.Duration
+
+	ticker *time.Ticker
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewSyncScheduler creates a new sync scheduler running every interval
+func NewSyncScheduler(diffSyncService *DiffSyncService, interval time.Duration) *SyncScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &SyncScheduler{
+		diffSyncService: diffSyncService,
+		interval:        interval,
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+}
+
+// Start starts the scheduler. The first sync runs after one interval.
+func (ss *SyncScheduler) Start() {
+	log.Printf("Starting sync scheduler with interval: %v", ss.interval)
+
+	ss.ticker = time.NewTicker(ss.interval)
+
+	ss.wg.Add(1)
+	go ss.schedulerLoop()
+}
+
+// Stop stops the scheduler, waiting for a running sync to finish
+func (ss *SyncScheduler) Stop() {
+	log.Println("Stopping sync scheduler")
+
+	ss.cancel()
+	if ss.ticker != nil {
+		ss.ticker.Stop()
+	}
+	ss.wg.Wait()
+
+	log.Println("Sync scheduler stopped")
+}
+
+// schedulerLoop is the main scheduler loop
+func (ss *SyncScheduler) schedulerLoop() {
+	defer ss.wg.Done()
+
+	for {
+		select {
+		case <-ss.ctx.Done():
+			return
+		case <-ss.ticker.C:
+			ss.runSync()
+		}
+	}
+}
+
+// runSync syncs all logs unless initialization or another sync is in progress.
+// It returns whether a sync ran.
+func (ss *SyncScheduler) runSync() bool {
+	if GetGlobalInitializationService().IsInitializing() {
+		log.Println("Skipping auto-sync: initialization in progress")
+		return false
+	}
+	if SyncInProgress() {
+		log.Println("Skipping auto-sync: a log sync is already in progress")
+		return false
+	}
+
+	stats, err := ss.diffSyncService.SyncAllLogs()
+	if err != nil {
+		log.Printf("Auto-sync failed: %v", err)
+		return true
+	}
+
+	log.Printf("Auto-sync completed: %d files processed, %d new lines", stats.ProcessedFiles, stats.NewLines)
+	return true
+}
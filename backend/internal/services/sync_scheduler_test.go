@@ -0,0 +1,68 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSyncScheduler_RunSync(t *testing.T) {
+	db, diffSyncService := setupTestDBForBatchSync(t)
+	defer db.Close()
+
+	claudeDir := t.TempDir()
+	t.Setenv("CLAUDE_PROJECTS_DIR", claudeDir)
+
+	projectDir := filepath.Join(claudeDir, "-test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatalf("Failed to create project dir: %v", err)
+	}
+	data := `{"uuid":"auto-1","sessionId":"auto-session","userType":"human","cwd":"/test-project","timestamp":"2024-01-01T10:00:00Z","message":{"role":"user","content":"Hi"}}`
+	if err := os.WriteFile(filepath.Join(projectDir, "auto.jsonl"), []byte(data+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write log file: %v", err)
+	}
+
+	scheduler := NewSyncScheduler(diffSyncService, 0)
+
+	countMessages := func() int {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&count); err != nil {
+			t.Fatalf("Failed to count messages: %v", err)
+		}
+		return count
+	}
+
+	// Skipped while another sync is running
+	atomic.AddInt32(&activeSyncs, 1)
+	ran := scheduler.runSync()
+	atomic.AddInt32(&activeSyncs, -1)
+	if ran {
+		t.Error("Expected auto-sync to be skipped while a sync is in progress")
+	}
+
+	// Skipped while initializing
+	initService := GetGlobalInitializationService()
+	initService.mu.Lock()
+	savedState := initService.state
+	initService.mu.Unlock()
+	initService.StartInitialization()
+	ran = scheduler.runSync()
+	initService.mu.Lock()
+	initService.state = savedState
+	initService.mu.Unlock()
+	if ran {
+		t.Error("Expected auto-sync to be skipped during initialization")
+	}
+
+	if count := countMessages(); count != 0 {
+		t.Fatalf("Expected no messages before auto-sync ran, got %d", count)
+	}
+
+	if !scheduler.runSync() {
+		t.Fatal("Expected auto-sync to run")
+	}
+	if count := countMessages(); count != 1 {
+		t.Errorf("Expected 1 message after auto-sync, got %d", count)
+	}
+}
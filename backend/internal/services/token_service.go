@@ -3,6 +3,7 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
 	
 	"ccdash-backend/internal/models"
@@ -25,6 +26,18 @@ const (
 	CLAUDE_MAX5_LIMIT = 35000
 	CLAUDE_MAX20_LIMIT = 140000
 	WINDOW_DURATION = 5 * time.Hour
+
+	// MAX_TOKEN_USAGE_RANGE caps range queries to avoid expensive scans
+	MAX_TOKEN_USAGE_RANGE = 90 * 24 * time.Hour
+
+	// Daily usage series length: default and maximum number of days
+	DEFAULT_DAILY_USAGE_DAYS = 30
+	MAX_DAILY_USAGE_DAYS     = 365
+
+	// Burn rate sampling for exhaustion projections
+	BURN_RATE_SAMPLE_PERIOD  = time.Hour
+	MIN_BURN_RATE_SAMPLE     = 5 * time.Minute
+	FULL_CONFIDENCE_MESSAGES = 10
 )
 
 func (s *TokenService) GetCurrentTokenUsage() (*models.TokenUsage, error) {
@@ -45,6 +58,7 @@ func (s *TokenService) GetCurrentTokenUsage() (*models.TokenUsage, error) {
 			OutputTokens:   0,
 			UsageLimit:     s.getUsageLimit(),
 			UsageRate:      0,
+			Plan:           CurrentPlan(),
 			WindowStart:    now,
 			WindowEnd:      now.Add(WINDOW_DURATION),
 			ActiveSessions: 0,
@@ -62,6 +76,7 @@ func (s *TokenService) GetCurrentTokenUsage() (*models.TokenUsage, error) {
 			OutputTokens:   0,
 			UsageLimit:     s.getUsageLimit(),
 			UsageRate:      0,
+			Plan:           CurrentPlan(),
 			WindowStart:    now,
 			WindowEnd:      now.Add(WINDOW_DURATION),
 			ActiveSessions: 0,
@@ -70,9 +85,9 @@ func (s *TokenService) GetCurrentTokenUsage() (*models.TokenUsage, error) {
 		}, nil
 	}
 	
-	// 現在のウィンドウの統計情報を使用
-	usageLimit := s.getUsageLimit()
-	usageRate := float64(currentWindow.TotalTokens) / float64(usageLimit)
+	// 現在のウィンドウの統計情報を使用（制限はウィンドウ作成時のプランに基づく）
+	usageLimit := currentWindow.UsageLimit
+	usageRate := currentWindow.UsageRate
 	
 	// ウィンドウ内のメッセージのコストを計算
 	totalCost, err := s.calculateWindowCost(currentWindow.ID)
@@ -82,21 +97,56 @@ func (s *TokenService) GetCurrentTokenUsage() (*models.TokenUsage, error) {
 	}
 	
 	return &models.TokenUsage{
-		TotalTokens:    currentWindow.TotalTokens,
-		InputTokens:    currentWindow.TotalInputTokens,
-		OutputTokens:   currentWindow.TotalOutputTokens,
-		UsageLimit:     usageLimit,
-		UsageRate:      usageRate,
-		WindowStart:    currentWindow.WindowStart,
-		WindowEnd:      currentWindow.WindowEnd,
-		ActiveSessions: currentWindow.SessionCount,
-		TotalCost:      totalCost,
-		TotalMessages:  currentWindow.MessageCount,
+		TotalTokens:              currentWindow.TotalTokens,
+		InputTokens:              currentWindow.TotalInputTokens,
+		OutputTokens:             currentWindow.TotalOutputTokens,
+		CacheCreationInputTokens: currentWindow.TotalCacheCreationTokens,
+		CacheReadInputTokens:     currentWindow.TotalCacheReadTokens,
+		UsageLimit:               usageLimit,
+		UsageRate:                usageRate,
+		Plan:                     currentWindow.Plan,
+		WindowStart:              currentWindow.WindowStart,
+		WindowEnd:                currentWindow.WindowEnd,
+		ActiveSessions:           currentWindow.SessionCount,
+		TotalCost:                totalCost,
+		TotalMessages:            currentWindow.MessageCount,
 	}, nil
 }
 
 func (s *TokenService) getUsageLimit() int {
-	return CLAUDE_PRO_LIMIT
+	return UsageLimitForPlan(CurrentPlan())
+}
+
+var (
+	currentPlanMu sync.RWMutex
+	currentPlan   = models.PlanPro
+)
+
+// SetPlan sets the plan new windows are recorded with. plan must be one of models.Plans;
+// Config validates CCDASH_PLAN before it gets here.
+func SetPlan(plan string) {
+	currentPlanMu.Lock()
+	defer currentPlanMu.Unlock()
+	currentPlan = plan
+}
+
+// CurrentPlan returns the configured plan, pro unless set with SetPlan
+func CurrentPlan() string {
+	currentPlanMu.RLock()
+	defer currentPlanMu.RUnlock()
+	return currentPlan
+}
+
+// UsageLimitForPlan returns the per-window token limit for a plan
+func UsageLimitForPlan(plan string) int {
+	switch plan {
+	case models.PlanMax5:
+		return CLAUDE_MAX5_LIMIT
+	case models.PlanMax20:
+		return CLAUDE_MAX20_LIMIT
+	default:
+		return CLAUDE_PRO_LIMIT
+	}
 }
 
 // roundToNextHour は時刻を次の正時（0分）に切り上げます
@@ -266,102 +316,545 @@ func (s *TokenService) UpdateSessionTokens(sessionID string) error {
 // calculateWindowCost calculates the total cost for messages in a session window
 func (s *TokenService) calculateWindowCost(windowID string) (float64, error) {
 	query := `
-		SELECT 
-			m.model,
-			COALESCE(SUM(m.input_tokens), 0) as total_input_tokens,
-			COALESCE(SUM(m.output_tokens), 0) as total_output_tokens,
-			COALESCE(SUM(m.cache_creation_input_tokens), 0) as total_cache_creation_tokens,
-			COALESCE(SUM(m.cache_read_input_tokens), 0) as total_cache_read_tokens
+		SELECT COALESCE(SUM(m.cost), 0)
 		FROM messages m
 		INNER JOIN session_window_messages swm ON m.id = swm.message_id
 		WHERE swm.session_window_id = ? 
 		AND m.message_role = 'assistant'
-		AND m.model IS NOT NULL
-		GROUP BY m.model
 	`
 	
-	rows, err := s.db.Query(query, windowID)
-	if err != nil {
+	var totalCost float64
+	if err := s.db.QueryRow(query, windowID).Scan(&totalCost); err != nil {
 		return 0.0, fmt.Errorf("failed to query messages for cost calculation: %w", err)
 	}
-	defer rows.Close()
+	
+	return totalCost, nil
+}
+
+// CalculateSessionCost calculates the total cost for a specific session
+func (s *TokenService) CalculateSessionCost(sessionID string) (float64, error) {
+	query := `
+		SELECT COALESCE(SUM(cost), 0)
+		FROM messages 
+		WHERE session_id = ? 
+		AND message_role = 'assistant'
+	`
 	
 	var totalCost float64
+	if err := s.db.QueryRow(query, sessionID).Scan(&totalCost); err != nil {
+		return 0.0, fmt.Errorf("failed to query messages for session cost calculation: %w", err)
+	}
 	
+	return totalCost, nil
+}
+
+// CalculateMessageCost calculates the cost of a single assistant message.
+// Messages without a model are costed under the configured null-model fallback, if any.
+func (s *TokenService) CalculateMessageCost(sessionID string, model *string, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int) float64 {
+	if model == nil || *model == "" {
+		model = s.fallbackModel(sessionID)
+	}
+	return s.pricingCalculator.CalculateMessageCost(model, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens)
+}
+
+// fallbackModel resolves the model used to cost a null-model message in the given session
+func (s *TokenService) fallbackModel(sessionID string) *string {
+	fallback := getNullModelFallback()
+	if fallback == "" {
+		return nil
+	}
+	if fallback != NullModelFallbackSession {
+		return &fallback
+	}
+
+	var model string
+	err := s.db.QueryRow(`
+		SELECT model
+		FROM messages
+		WHERE session_id = ?
+		AND message_role = 'assistant'
+		AND model IS NOT NULL AND model != ''
+		GROUP BY model
+		ORDER BY COUNT(*) DESC, model ASC
+		LIMIT 1
+	`, sessionID).Scan(&model)
+	if err != nil {
+		// No known model in the session (or lookup failed): leave the message uncosted
+		return nil
+	}
+	return &model
+}
+
+// BackfillMessageCosts recomputes the stored cost of every assistant message.
+// Run it after adding the cost column or changing pricing to refresh existing rows.
+func (s *TokenService) BackfillMessageCosts() (int, error) {
+	return s.backfillMessageCosts("")
+}
+
+// backfillMessageCosts recomputes the stored cost of the assistant messages of one
+// session, or of every session when sessionID is empty
+func (s *TokenService) backfillMessageCosts(sessionID string) (int, error) {
+	query := `
+		SELECT id, session_id, model, input_tokens, output_tokens, cache_creation_input_tokens, cache_read_input_tokens
+		FROM messages
+		WHERE message_role = 'assistant'
+	`
+	var args []interface{}
+	if sessionID != "" {
+		query += " AND session_id = ?"
+		args = append(args, sessionID)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query messages for cost backfill: %w", err)
+	}
+
+	type messageUsage struct {
+		id                  string
+		sessionID           string
+		model               *string
+		inputTokens         int
+		outputTokens        int
+		cacheCreationTokens int
+		cacheReadTokens     int
+	}
+	var messages []messageUsage
+	for rows.Next() {
+		var m messageUsage
+		if err := rows.Scan(&m.id, &m.sessionID, &m.model, &m.inputTokens, &m.outputTokens, &m.cacheCreationTokens, &m.cacheReadTokens); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan message for cost backfill: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, fmt.Errorf("error iterating over messages for cost backfill: %w", err)
+	}
+	rows.Close()
+
+	// Resolve costs before writing so the session fallback sees the original data
+	costs := make(map[string]float64, len(messages))
+	for _, m := range messages {
+		costs[m.id] = s.CalculateMessageCost(m.sessionID, m.model, m.inputTokens, m.outputTokens, m.cacheCreationTokens, m.cacheReadTokens)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin cost backfill transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for id, cost := range costs {
+		if _, err := tx.Exec(`UPDATE messages SET cost = ? WHERE id = ?`, cost, id); err != nil {
+			return 0, fmt.Errorf("failed to update cost for message %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit cost backfill: %w", err)
+	}
+
+	return len(costs), nil
+}
+
+// GetTokenUsageInRange aggregates token usage and cost for assistant messages within [from, to)
+func (s *TokenService) GetTokenUsageInRange(from, to time.Time) (*models.TokenUsageRange, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid time range: from must be before to")
+	}
+	if to.Sub(from) > MAX_TOKEN_USAGE_RANGE {
+		return nil, fmt.Errorf("invalid time range: range must not exceed %d days", int(MAX_TOKEN_USAGE_RANGE.Hours()/24))
+	}
+
+	query := `
+		SELECT 
+			COALESCE(model, '') as model,
+			COALESCE(SUM(input_tokens), 0) as total_input_tokens,
+			COALESCE(SUM(output_tokens), 0) as total_output_tokens,
+			COALESCE(SUM(cache_creation_input_tokens), 0) as total_cache_creation_tokens,
+			COALESCE(SUM(cache_read_input_tokens), 0) as total_cache_read_tokens,
+			COUNT(*) as message_count
+		FROM messages 
+		WHERE timestamp >= ? AND timestamp < ?
+		AND message_role = 'assistant'
+		GROUP BY model
+	`
+
+	rows, err := s.db.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query token usage in range: %w", err)
+	}
+	defer rows.Close()
+
+	usage := &models.TokenUsageRange{
+		From: from,
+		To:   to,
+	}
+
 	for rows.Next() {
 		var model string
-		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int
-		
-		err := rows.Scan(&model, &inputTokens, &outputTokens, &cacheCreationTokens, &cacheReadTokens)
+		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens, messageCount int
+
+		err := rows.Scan(&model, &inputTokens, &outputTokens, &cacheCreationTokens, &cacheReadTokens, &messageCount)
 		if err != nil {
-			return 0.0, fmt.Errorf("failed to scan message data for cost calculation: %w", err)
+			return nil, fmt.Errorf("failed to scan token usage in range: %w", err)
+		}
+
+		usage.InputTokens += inputTokens
+		usage.OutputTokens += outputTokens
+		usage.CacheCreationInputTokens += cacheCreationTokens
+		usage.CacheReadInputTokens += cacheReadTokens
+		usage.TotalMessages += messageCount
+		if model != "" {
+			usage.TotalCost += s.pricingCalculator.CalculateCost(model, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens)
 		}
-		
-		cost := s.pricingCalculator.CalculateCost(
-			model,
-			inputTokens,
-			outputTokens,
-			cacheCreationTokens,
-			cacheReadTokens,
-		)
-		
-		totalCost += cost
 	}
-	
+
 	if err := rows.Err(); err != nil {
-		return 0.0, fmt.Errorf("error iterating over messages for cost calculation: %w", err)
+		return nil, fmt.Errorf("error iterating over token usage in range: %w", err)
 	}
-	
-	return totalCost, nil
+
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	usage.TotalCost = roundToDecimals(usage.TotalCost, 6)
+
+	return usage, nil
 }
 
-// CalculateSessionCost calculates the total cost for a specific session
-func (s *TokenService) CalculateSessionCost(sessionID string) (float64, error) {
+// GetDailyTokenUsageInRange returns token usage within [from, to) grouped by day
+func (s *TokenService) GetDailyTokenUsageInRange(from, to time.Time) ([]models.DailyTokenUsage, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid time range: from must be before to")
+	}
+	if to.Sub(from) > MAX_TOKEN_USAGE_RANGE {
+		return nil, fmt.Errorf("invalid time range: range must not exceed %d days", int(MAX_TOKEN_USAGE_RANGE.Hours()/24))
+	}
+
 	query := `
 		SELECT 
-			model,
+			strftime(timestamp, '%Y-%m-%d') as day,
+			COALESCE(model, '') as model,
 			COALESCE(SUM(input_tokens), 0) as total_input_tokens,
 			COALESCE(SUM(output_tokens), 0) as total_output_tokens,
 			COALESCE(SUM(cache_creation_input_tokens), 0) as total_cache_creation_tokens,
-			COALESCE(SUM(cache_read_input_tokens), 0) as total_cache_read_tokens
+			COALESCE(SUM(cache_read_input_tokens), 0) as total_cache_read_tokens,
+			COUNT(*) as message_count
 		FROM messages 
-		WHERE session_id = ? 
+		WHERE timestamp >= ? AND timestamp < ?
 		AND message_role = 'assistant'
-		AND model IS NOT NULL
-		GROUP BY model
+		GROUP BY day, model
+		ORDER BY day ASC
 	`
-	
-	rows, err := s.db.Query(query, sessionID)
+
+	rows, err := s.db.Query(query, from, to)
 	if err != nil {
-		return 0.0, fmt.Errorf("failed to query messages for session cost calculation: %w", err)
+		return nil, fmt.Errorf("failed to query daily token usage: %w", err)
 	}
 	defer rows.Close()
-	
+
+	var daily []models.DailyTokenUsage
+	dayIndex := make(map[string]int)
+
+	for rows.Next() {
+		var day, model string
+		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens, messageCount int
+
+		err := rows.Scan(&day, &model, &inputTokens, &outputTokens, &cacheCreationTokens, &cacheReadTokens, &messageCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan daily token usage: %w", err)
+		}
+
+		idx, exists := dayIndex[day]
+		if !exists {
+			daily = append(daily, models.DailyTokenUsage{Date: day})
+			idx = len(daily) - 1
+			dayIndex[day] = idx
+		}
+
+		entry := &daily[idx]
+		entry.InputTokens += inputTokens
+		entry.OutputTokens += outputTokens
+		entry.CacheCreationInputTokens += cacheCreationTokens
+		entry.CacheReadInputTokens += cacheReadTokens
+		entry.TotalTokens += inputTokens + outputTokens
+		entry.TotalMessages += messageCount
+		if model != "" {
+			entry.TotalCost = roundToDecimals(entry.TotalCost+s.pricingCalculator.CalculateCost(model, inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens), 6)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over daily token usage: %w", err)
+	}
+
+	return daily, nil
+}
+
+// GetDailyUsage returns assistant token usage and cost per calendar day for the last days
+// days up to and including today, oldest first. Days are in now's timezone and days without
+// messages are included with zeros.
+func (s *TokenService) GetDailyUsage(days int, now time.Time) ([]models.DailyUsage, error) {
+	if days < 1 || days > MAX_DAILY_USAGE_DAYS {
+		return nil, fmt.Errorf("invalid days: must be between 1 and %d", MAX_DAILY_USAGE_DAYS)
+	}
+
+	loc := now.Location()
+	firstDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, -(days - 1))
+	end := firstDay.AddDate(0, 0, days)
+
+	series := make([]models.DailyUsage, days)
+	dayIndex := make(map[string]int, days)
+	for i := range series {
+		date := firstDay.AddDate(0, 0, i).Format("2006-01-02")
+		series[i] = models.DailyUsage{Date: date}
+		dayIndex[date] = i
+	}
+
+	query := `
+		SELECT 
+			` + localBucketExpr + ` as bucket,
+			COALESCE(SUM(input_tokens), 0) as total_input_tokens,
+			COALESCE(SUM(output_tokens), 0) as total_output_tokens,
+			COALESCE(SUM(cost), 0) as total_cost,
+			COUNT(*) as message_count
+		FROM messages
+		WHERE timestamp >= ? AND timestamp < ?
+		AND message_role = 'assistant'
+		GROUP BY bucket
+	`
+
+	rows, err := s.db.Query(query, firstDay.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily usage: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket time.Time
+		var inputTokens, outputTokens, messageCount int
+		var cost float64
+
+		if err := rows.Scan(&bucket, &inputTokens, &outputTokens, &cost, &messageCount); err != nil {
+			return nil, fmt.Errorf("failed to scan daily usage: %w", err)
+		}
+
+		idx, ok := dayIndex[localBucketTime(bucket, loc).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+
+		day := &series[idx]
+		day.InputTokens += inputTokens
+		day.OutputTokens += outputTokens
+		day.TotalTokens += inputTokens + outputTokens
+		day.Cost += cost
+		day.MessageCount += messageCount
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over daily usage: %w", err)
+	}
+
+	for i := range series {
+		series[i].Cost = roundToDecimals(series[i].Cost, 6)
+	}
+
+	return series, nil
+}
+
+// localBucketExpr groups message timestamps, which are stored in UTC, into 15 minute buckets.
+// Every UTC offset is a multiple of 15 minutes, so each bucket falls within one local hour
+// and day, including across DST changes. Convert scanned buckets with localBucketTime.
+const localBucketExpr = `time_bucket(INTERVAL '15 minutes', timestamp)`
+
+// localBucketTime returns the start of a bucket scanned from localBucketExpr in loc
+func localBucketTime(bucket time.Time, loc *time.Location) time.Time {
+	return time.Date(bucket.Year(), bucket.Month(), bucket.Day(), bucket.Hour(), bucket.Minute(), 0, 0, time.UTC).In(loc)
+}
+
+// GetModelUsageBreakdown returns token usage and cost per model for all assistant messages,
+// ordered by cost descending. Messages without a model are grouped under UnknownModelKey.
+func (s *TokenService) GetModelUsageBreakdown() ([]models.ModelUsage, error) {
+	query := `
+		SELECT 
+			COALESCE(model, ?) as model,
+			COALESCE(SUM(input_tokens), 0) as total_input_tokens,
+			COALESCE(SUM(output_tokens), 0) as total_output_tokens,
+			COALESCE(SUM(cache_creation_input_tokens), 0) as total_cache_creation_tokens,
+			COALESCE(SUM(cache_read_input_tokens), 0) as total_cache_read_tokens,
+			COUNT(*) as message_count,
+			COALESCE(SUM(cost), 0) as total_cost
+		FROM messages
+		WHERE message_role = 'assistant'
+		GROUP BY 1
+		ORDER BY total_cost DESC, model ASC
+	`
+
+	rows, err := s.db.Query(query, UnknownModelKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query model usage: %w", err)
+	}
+	defer rows.Close()
+
+	breakdown := []models.ModelUsage{}
 	var totalCost float64
-	
+	var totalTokens int
+
 	for rows.Next() {
-		var model string
-		var inputTokens, outputTokens, cacheCreationTokens, cacheReadTokens int
-		
-		err := rows.Scan(&model, &inputTokens, &outputTokens, &cacheCreationTokens, &cacheReadTokens)
+		var usage models.ModelUsage
+		err := rows.Scan(&usage.Model, &usage.InputTokens, &usage.OutputTokens,
+			&usage.CacheCreationInputTokens, &usage.CacheReadInputTokens, &usage.MessageCount, &usage.TotalCost)
 		if err != nil {
-			return 0.0, fmt.Errorf("failed to scan message data for session cost calculation: %w", err)
+			return nil, fmt.Errorf("failed to scan model usage: %w", err)
 		}
-		
-		cost := s.pricingCalculator.CalculateCost(
-			model,
-			inputTokens,
-			outputTokens,
-			cacheCreationTokens,
-			cacheReadTokens,
-		)
-		
-		totalCost += cost
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+		usage.TotalCost = roundToDecimals(usage.TotalCost, 6)
+
+		totalCost += usage.TotalCost
+		totalTokens += usage.TotalTokens
+		breakdown = append(breakdown, usage)
 	}
-	
+
 	if err := rows.Err(); err != nil {
-		return 0.0, fmt.Errorf("error iterating over messages for session cost calculation: %w", err)
+		return nil, fmt.Errorf("error iterating over model usage: %w", err)
 	}
-	
-	return totalCost, nil
-}
\ No newline at end of file
+
+	for i := range breakdown {
+		if totalCost > 0 {
+			breakdown[i].CostShare = roundToDecimals(breakdown[i].TotalCost/totalCost, 6)
+		}
+		if totalTokens > 0 {
+			breakdown[i].TokenShare = roundToDecimals(float64(breakdown[i].TotalTokens)/float64(totalTokens), 6)
+		}
+	}
+
+	return breakdown, nil
+}
+
+// GetMonthlyCosts calculates costs for the calendar month containing t, in t's timezone.
+// Only messages within the month are counted, so sessions spanning the month edge are split.
+func (s *TokenService) GetMonthlyCosts(t time.Time) (*models.MonthlyCostSummary, error) {
+	monthStart := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	query := `
+		SELECT 
+			m.model,
+			COALESCE(s.project_name, '') as project_name,
+			COALESCE(SUM(m.cost), 0) as total_cost
+		FROM messages m
+		LEFT JOIN sessions s ON m.session_id = s.id
+		WHERE m.timestamp >= ? AND m.timestamp < ?
+		AND m.message_role = 'assistant'
+		AND m.model IS NOT NULL
+		GROUP BY m.model, s.project_name
+	`
+
+	rows, err := s.db.Query(query, monthStart, monthEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages for monthly cost calculation: %w", err)
+	}
+	defer rows.Close()
+
+	summary := &models.MonthlyCostSummary{
+		MonthStart: monthStart,
+		MonthEnd:   monthEnd,
+		Currency:   "USD",
+		ByModel:    make(map[string]float64),
+		ByProject:  make(map[string]float64),
+	}
+
+	for rows.Next() {
+		var model, projectName string
+		var cost float64
+
+		err := rows.Scan(&model, &projectName, &cost)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message data for monthly cost calculation: %w", err)
+		}
+
+		summary.CurrentMonthCost += cost
+		summary.ByModel[model] = roundToDecimals(summary.ByModel[model]+cost, 6)
+		summary.ByProject[projectName] = roundToDecimals(summary.ByProject[projectName]+cost, 6)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over messages for monthly cost calculation: %w", err)
+	}
+
+	summary.CurrentMonthCost = roundToDecimals(summary.CurrentMonthCost, 6)
+
+	return summary, nil
+}
+
+// GetProjectedExhaustion combines the recent burn rate with the tokens remaining in the
+// active window to estimate when the usage limit will be hit
+func (s *TokenService) GetProjectedExhaustion() (*models.ExhaustionProjection, error) {
+	usage, err := s.GetCurrentTokenUsage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current token usage: %w", err)
+	}
+
+	now := time.Now().UTC()
+	projection := &models.ExhaustionProjection{
+		GeneratedAt:     now,
+		WindowStart:     usage.WindowStart,
+		WindowEnd:       usage.WindowEnd,
+		TotalTokens:     usage.TotalTokens,
+		UsageLimit:      usage.UsageLimit,
+		RemainingTokens: usage.UsageLimit - usage.TotalTokens,
+	}
+	if projection.RemainingTokens < 0 {
+		projection.RemainingTokens = 0
+	}
+
+	// Sample the last hour, or since the window started if that is more recent
+	sampleStart := now.Add(-BURN_RATE_SAMPLE_PERIOD)
+	if usage.WindowStart.After(sampleStart) {
+		sampleStart = usage.WindowStart
+	}
+	sampleDuration := now.Sub(sampleStart)
+	if sampleDuration < MIN_BURN_RATE_SAMPLE {
+		sampleDuration = MIN_BURN_RATE_SAMPLE
+	}
+
+	query := `
+		SELECT 
+			COALESCE(SUM(input_tokens + output_tokens), 0) as total_tokens,
+			COUNT(*) as message_count
+		FROM messages
+		WHERE timestamp >= ?
+		AND message_role = 'assistant'
+	`
+
+	var sampleTokens, sampleMessages int
+	if err := s.db.QueryRow(query, sampleStart).Scan(&sampleTokens, &sampleMessages); err != nil {
+		return nil, fmt.Errorf("failed to calculate burn rate: %w", err)
+	}
+
+	projection.BurnRatePerHour = roundToDecimals(float64(sampleTokens)/sampleDuration.Hours(), 2)
+
+	// Confidence grows with both the sampled time span and the number of messages
+	timeFactor := sampleDuration.Hours() / BURN_RATE_SAMPLE_PERIOD.Hours()
+	if timeFactor > 1 {
+		timeFactor = 1
+	}
+	messageFactor := float64(sampleMessages) / FULL_CONFIDENCE_MESSAGES
+	if messageFactor > 1 {
+		messageFactor = 1
+	}
+	projection.Confidence = roundToDecimals(timeFactor*messageFactor, 2)
+
+	if projection.BurnRatePerHour <= 0 {
+		return projection, nil
+	}
+
+	hoursToLimit := float64(projection.RemainingTokens) / projection.BurnRatePerHour
+	exhaustion := now.Add(time.Duration(hoursToLimit * float64(time.Hour)))
+	minutes := int(hoursToLimit * 60)
+	projection.ProjectedExhaustion = &exhaustion
+	projection.MinutesToExhaustion = &minutes
+	projection.ExhaustsBeforeReset = exhaustion.Before(usage.WindowEnd)
+
+	return projection, nil
+}
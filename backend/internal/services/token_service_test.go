@@ -2,9 +2,12 @@ package services
 
 import (
 	"database/sql"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"ccdash-backend/internal/models"
 	_ "github.com/marcboeker/go-duckdb"
 )
 
@@ -38,6 +41,7 @@ func setupTestDB(t *testing.T) *sql.DB {
 			timestamp TIMESTAMP,
 			input_tokens INTEGER DEFAULT 0,
 			output_tokens INTEGER DEFAULT 0,
+			cost DOUBLE DEFAULT 0.0,
 			FOREIGN KEY (session_id) REFERENCES sessions(id)
 		);
 
@@ -67,6 +71,10 @@ func setupTestDB(t *testing.T) *sql.DB {
 			scheduled_at TIMESTAMP,
 			schedule_type TEXT,
 			schedule_params TEXT,
+			output_rules TEXT,
+			webhook_url TEXT,
+			resource_limits TEXT,
+			env TEXT,
 			FOREIGN KEY (project_id) REFERENCES projects(id)
 		);
 
@@ -78,10 +86,13 @@ func setupTestDB(t *testing.T) *sql.DB {
 			total_input_tokens INTEGER DEFAULT 0,
 			total_output_tokens INTEGER DEFAULT 0,
 			total_tokens INTEGER DEFAULT 0,
+			total_cache_creation_tokens INTEGER DEFAULT 0,
+			total_cache_read_tokens INTEGER DEFAULT 0,
 			message_count INTEGER DEFAULT 0,
 			session_count INTEGER DEFAULT 0,
 			total_cost DOUBLE DEFAULT 0.0,
 			is_active BOOLEAN DEFAULT false,
+			plan TEXT,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		);
@@ -95,6 +106,18 @@ func setupTestDB(t *testing.T) *sql.DB {
 	return db
 }
 
+// addMessageModelColumns extends the test messages table with model and cache token columns
+func addMessageModelColumns(t *testing.T, db *sql.DB) {
+	_, err := db.Exec(`
+		ALTER TABLE messages ADD COLUMN model TEXT;
+		ALTER TABLE messages ADD COLUMN cache_creation_input_tokens INTEGER DEFAULT 0;
+		ALTER TABLE messages ADD COLUMN cache_read_input_tokens INTEGER DEFAULT 0;
+	`)
+	if err != nil {
+		t.Fatalf("Failed to extend messages table: %v", err)
+	}
+}
+
 func TestNewTokenService(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -120,6 +143,52 @@ func TestGetUsageLimit(t *testing.T) {
 	}
 }
 
+// setTestPlan sets the configured plan for the duration of the test
+func setTestPlan(t *testing.T, plan string) {
+	t.Helper()
+	previous := CurrentPlan()
+	SetPlan(plan)
+	t.Cleanup(func() { SetPlan(previous) })
+}
+
+func TestGetUsageLimit_Plans(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewTokenService(db)
+	if plan := CurrentPlan(); plan != models.PlanPro {
+		t.Errorf("Expected default plan %s, got %s", models.PlanPro, plan)
+	}
+
+	tests := []struct {
+		plan     string
+		expected int
+	}{
+		{models.PlanPro, CLAUDE_PRO_LIMIT},
+		{models.PlanMax5, CLAUDE_MAX5_LIMIT},
+		{models.PlanMax20, CLAUDE_MAX20_LIMIT},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.plan, func(t *testing.T) {
+			setTestPlan(t, tt.plan)
+			if plan := CurrentPlan(); plan != tt.plan {
+				t.Errorf("Expected plan %s, got %s", tt.plan, plan)
+			}
+			if limit := service.getUsageLimit(); limit != tt.expected {
+				t.Errorf("Expected usage limit %d, got %d", tt.expected, limit)
+			}
+			if limit := UsageLimitForPlan(tt.plan); limit != tt.expected {
+				t.Errorf("Expected UsageLimitForPlan(%s) = %d, got %d", tt.plan, tt.expected, limit)
+			}
+		})
+	}
+
+	if models.IsValidPlan("enterprise") || !models.IsValidPlan(models.PlanMax20) {
+		t.Error("Expected only pro, max5 and max20 to be valid plans")
+	}
+}
+
 func TestGetCurrentTokenUsage_NoMessages(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -220,6 +289,73 @@ func TestGetCurrentTokenUsage_WithMessages(t *testing.T) {
 	}
 }
 
+func TestGetCurrentTokenUsage_CacheTokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS session_window_messages (
+			id TEXT PRIMARY KEY,
+			session_window_id TEXT NOT NULL,
+			message_id TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(session_window_id, message_id)
+		);
+	`); err != nil {
+		t.Fatalf("Failed to extend test schema: %v", err)
+	}
+
+	messageTime := time.Now().UTC().Add(-30 * time.Minute)
+	if _, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time)
+		VALUES (?, ?, ?, ?)
+	`, "cache-session", "test-project", "/test/path", messageTime); err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+
+	windowService := NewSessionWindowService(db)
+	windowService.SetIncludeCacheTokens(false)
+	window, err := windowService.GetOrCreateWindowForMessage(messageTime)
+	if err != nil {
+		t.Fatalf("Failed to create window: %v", err)
+	}
+
+	model := "claude-sonnet-4-20250514"
+	calculator := NewPricingCalculator()
+	cost := calculator.CalculateCost(model, 10, 100, 5000, 20000)
+	if _, err := db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens, cache_creation_input_tokens, cache_read_input_tokens, model, cost)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, "cache-msg", "cache-session", "assistant", "test", messageTime, 10, 100, 5000, 20000, model, cost); err != nil {
+		t.Fatalf("Failed to insert test message: %v", err)
+	}
+	if err := NewSessionWindowMessageService(db).AddMessageToWindow(window.ID, "cache-msg"); err != nil {
+		t.Fatalf("Failed to relate message to window: %v", err)
+	}
+	if err := windowService.UpdateWindowStats(window.ID); err != nil {
+		t.Fatalf("UpdateWindowStats failed: %v", err)
+	}
+
+	usage, err := NewTokenService(db).GetCurrentTokenUsage()
+	if err != nil {
+		t.Fatalf("GetCurrentTokenUsage failed: %v", err)
+	}
+	if usage.CacheCreationInputTokens != 5000 {
+		t.Errorf("Expected 5000 cache creation tokens, got %d", usage.CacheCreationInputTokens)
+	}
+	if usage.CacheReadInputTokens != 20000 {
+		t.Errorf("Expected 20000 cache read tokens, got %d", usage.CacheReadInputTokens)
+	}
+	if usage.TotalTokens != 110 {
+		t.Errorf("Expected 110 total tokens counted against the limit, got %d", usage.TotalTokens)
+	}
+	// The cost prices cache tokens, so it exceeds the cost of input and output alone
+	if usage.TotalCost != cost || cost <= calculator.CalculateCost(model, 10, 100, 0, 0) {
+		t.Errorf("Expected cost %f including cache tokens, got %f", cost, usage.TotalCost)
+	}
+}
+
 func TestGetCurrentTokenUsage_OutsideWindow(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -598,4 +734,497 @@ func TestTokenResetTimeIntegration(t *testing.T) {
 	
 	t.Logf("Message at %v -> Reset at %v", 
 		messageTime.Format("15:04"), usage.WindowEnd.Format("15:04"))
-}
\ No newline at end of file
+}
+func TestGetTokenUsageInRange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+
+	service := NewTokenService(db)
+
+	base := time.Date(2025, 7, 1, 10, 0, 0, 0, time.UTC)
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) 
+		VALUES (?, ?, ?, ?)
+	`, "range-session", "test-project", "/test/path", base)
+	if err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+
+	testMessages := []struct {
+		id        string
+		role      string
+		timestamp time.Time
+		input     int
+		output    int
+		cacheRead int
+	}{
+		{"range-msg-1", "assistant", base, 100, 200, 1000},
+		{"range-msg-2", "assistant", base.Add(26 * time.Hour), 50, 60, 0},
+		{"range-msg-3", "user", base.Add(time.Hour), 10, 0, 0},
+		{"range-msg-4", "assistant", base.Add(-time.Hour), 999, 999, 0}, // outside range
+	}
+
+	for _, msg := range testMessages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens, cache_read_input_tokens, model) 
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, msg.id, "range-session", msg.role, "test", msg.timestamp, msg.input, msg.output, msg.cacheRead, "claude-sonnet-4-20250514")
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	from := base
+	to := base.Add(72 * time.Hour)
+
+	usage, err := service.GetTokenUsageInRange(from, to)
+	if err != nil {
+		t.Fatalf("GetTokenUsageInRange failed: %v", err)
+	}
+
+	if usage.InputTokens != 150 {
+		t.Errorf("Expected 150 input tokens, got %d", usage.InputTokens)
+	}
+	if usage.OutputTokens != 260 {
+		t.Errorf("Expected 260 output tokens, got %d", usage.OutputTokens)
+	}
+	if usage.CacheReadInputTokens != 1000 {
+		t.Errorf("Expected 1000 cache read tokens, got %d", usage.CacheReadInputTokens)
+	}
+	if usage.TotalMessages != 2 {
+		t.Errorf("Expected 2 messages, got %d", usage.TotalMessages)
+	}
+	if usage.TotalCost <= 0 {
+		t.Errorf("Expected positive total cost, got %f", usage.TotalCost)
+	}
+
+	daily, err := service.GetDailyTokenUsageInRange(from, to)
+	if err != nil {
+		t.Fatalf("GetDailyTokenUsageInRange failed: %v", err)
+	}
+	if len(daily) != 2 {
+		t.Fatalf("Expected 2 days, got %d", len(daily))
+	}
+	if daily[0].Date != "2025-07-01" || daily[0].TotalTokens != 300 {
+		t.Errorf("Unexpected first day: %+v", daily[0])
+	}
+	if daily[1].Date != "2025-07-02" || daily[1].TotalTokens != 110 {
+		t.Errorf("Unexpected second day: %+v", daily[1])
+	}
+}
+
+func TestGetTokenUsageInRange_InvalidRange(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewTokenService(db)
+	now := time.Now()
+
+	if _, err := service.GetTokenUsageInRange(now, now.Add(-time.Hour)); err == nil {
+		t.Error("Expected error when from is after to")
+	}
+	if _, err := service.GetTokenUsageInRange(now.Add(-MAX_TOKEN_USAGE_RANGE-time.Hour), now); err == nil {
+		t.Error("Expected error when range exceeds the maximum")
+	}
+}
+
+func TestGetMonthlyCosts_MonthBoundary(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+
+	service := NewTokenService(db)
+
+	loc := time.FixedZone("JST", 9*60*60)
+	monthStart := time.Date(2025, 8, 1, 0, 0, 0, 0, loc)
+
+	// Session spanning the July/August edge in local time
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) 
+		VALUES (?, ?, ?, ?), (?, ?, ?, ?)
+	`, "edge-session", "project-a", "/a", monthStart.Add(-time.Hour),
+		"other-session", "project-b", "/b", monthStart.Add(48*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to insert test sessions: %v", err)
+	}
+
+	testMessages := []struct {
+		id        string
+		sessionID string
+		timestamp time.Time
+		model     string
+		input     int
+		output    int
+	}{
+		{"edge-msg-july", "edge-session", monthStart.Add(-30 * time.Minute), "claude-opus-4-20250514", 1_000_000, 0},
+		{"edge-msg-aug", "edge-session", monthStart.Add(30 * time.Minute), "claude-sonnet-4-20250514", 1_000_000, 0},
+		{"other-msg", "other-session", monthStart.Add(48 * time.Hour), "claude-opus-4-20250514", 0, 1_000_000},
+		{"next-month-msg", "other-session", monthStart.AddDate(0, 1, 0), "claude-opus-4-20250514", 1_000_000, 0},
+	}
+
+	for _, msg := range testMessages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens, model) 
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, msg.id, msg.sessionID, "assistant", "test", msg.timestamp, msg.input, msg.output, msg.model)
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	if _, err := service.BackfillMessageCosts(); err != nil {
+		t.Fatalf("BackfillMessageCosts failed: %v", err)
+	}
+
+	summary, err := service.GetMonthlyCosts(monthStart.Add(10 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetMonthlyCosts failed: %v", err)
+	}
+
+	if !summary.MonthStart.Equal(monthStart) {
+		t.Errorf("Expected month start %v, got %v", monthStart, summary.MonthStart)
+	}
+
+	// Sonnet input (3.0) + Opus output (75.0); July and September messages are excluded
+	if summary.CurrentMonthCost != 78.0 {
+		t.Errorf("Expected current month cost 78.0, got %f", summary.CurrentMonthCost)
+	}
+	if summary.ByModel["claude-sonnet-4-20250514"] != 3.0 {
+		t.Errorf("Expected sonnet cost 3.0, got %f", summary.ByModel["claude-sonnet-4-20250514"])
+	}
+	if summary.ByModel["claude-opus-4-20250514"] != 75.0 {
+		t.Errorf("Expected opus cost 75.0, got %f", summary.ByModel["claude-opus-4-20250514"])
+	}
+	if summary.ByProject["project-a"] != 3.0 {
+		t.Errorf("Expected project-a cost 3.0, got %f", summary.ByProject["project-a"])
+	}
+	if summary.ByProject["project-b"] != 75.0 {
+		t.Errorf("Expected project-b cost 75.0, got %f", summary.ByProject["project-b"])
+	}
+}
+
+func TestGetDailyUsage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	service := NewTokenService(db)
+
+	loc := time.FixedZone("JST", 9*60*60)
+	now := time.Date(2025, 8, 10, 12, 0, 0, 0, loc)
+
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES (?, ?, ?, ?)`,
+		"session-1", "test-project", "/test/path", now); err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+
+	testMessages := []struct {
+		id        string
+		role      string
+		timestamp time.Time
+		input     int
+		output    int
+		cost      float64
+	}{
+		{"before-range", "assistant", time.Date(2025, 8, 7, 23, 0, 0, 0, loc), 999, 999, 9.9},
+		{"day1-late", "assistant", time.Date(2025, 8, 8, 23, 30, 0, 0, loc), 100, 50, 0.5},
+		// Still August 8th in UTC, but August 9th locally
+		{"day2-early", "assistant", time.Date(2025, 8, 9, 0, 30, 0, 0, loc), 200, 100, 1.25},
+		{"day2-user", "user", time.Date(2025, 8, 9, 1, 0, 0, 0, loc), 10, 0, 0},
+	}
+	for _, msg := range testMessages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens, cost) 
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, msg.id, "session-1", msg.role, "test", msg.timestamp, msg.input, msg.output, msg.cost)
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	series, err := service.GetDailyUsage(3, now)
+	if err != nil {
+		t.Fatalf("GetDailyUsage failed: %v", err)
+	}
+
+	// The day without activity is filled with zeros
+	expected := []models.DailyUsage{
+		{Date: "2025-08-08", InputTokens: 100, OutputTokens: 50, TotalTokens: 150, Cost: 0.5, MessageCount: 1},
+		{Date: "2025-08-09", InputTokens: 200, OutputTokens: 100, TotalTokens: 300, Cost: 1.25, MessageCount: 1},
+		{Date: "2025-08-10"},
+	}
+	if len(series) != len(expected) {
+		t.Fatalf("Expected %d days, got %d: %+v", len(expected), len(series), series)
+	}
+	for i, want := range expected {
+		if series[i] != want {
+			t.Errorf("Day %d: expected %+v, got %+v", i, want, series[i])
+		}
+	}
+
+	for _, days := range []int{0, MAX_DAILY_USAGE_DAYS + 1} {
+		if _, err := service.GetDailyUsage(days, now); err == nil {
+			t.Errorf("Expected days=%d to be rejected", days)
+		}
+	}
+
+	series, err = service.GetDailyUsage(MAX_DAILY_USAGE_DAYS, now)
+	if err != nil {
+		t.Fatalf("GetDailyUsage failed for the maximum range: %v", err)
+	}
+	if len(series) != MAX_DAILY_USAGE_DAYS || series[len(series)-1].Date != "2025-08-10" {
+		t.Errorf("Expected %d days ending today, got %d ending %s", MAX_DAILY_USAGE_DAYS, len(series), series[len(series)-1].Date)
+	}
+}
+
+func TestGetModelUsageBreakdown(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+
+	service := NewTokenService(db)
+
+	breakdown, err := service.GetModelUsageBreakdown()
+	if err != nil {
+		t.Fatalf("GetModelUsageBreakdown failed: %v", err)
+	}
+	if breakdown == nil || len(breakdown) != 0 {
+		t.Errorf("Expected an empty breakdown without messages, got %#v", breakdown)
+	}
+
+	now := time.Now()
+	if _, err := db.Exec(`INSERT INTO sessions (id, project_name, project_path, start_time) VALUES (?, ?, ?, ?)`,
+		"session-1", "test-project", "/test/path", now); err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+
+	testMessages := []struct {
+		id     string
+		role   string
+		model  interface{}
+		input  int
+		output int
+		cost   float64
+	}{
+		{"opus-1", "assistant", "claude-opus-4-20250514", 1000, 500, 6.0},
+		{"opus-2", "assistant", "claude-opus-4-20250514", 500, 0, 2.0},
+		{"sonnet-1", "assistant", "claude-sonnet-4-20250514", 2000, 500, 2.0},
+		{"no-model", "assistant", nil, 0, 0, 0},
+		{"user-1", "user", nil, 100, 0, 0},
+	}
+	for _, msg := range testMessages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens, model, cost) 
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, msg.id, "session-1", msg.role, "test", now, msg.input, msg.output, msg.model, msg.cost)
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	breakdown, err = service.GetModelUsageBreakdown()
+	if err != nil {
+		t.Fatalf("GetModelUsageBreakdown failed: %v", err)
+	}
+
+	// Ordered by cost descending; user messages are excluded and a missing model is grouped as unknown
+	expected := []models.ModelUsage{
+		{Model: "claude-opus-4-20250514", InputTokens: 1500, OutputTokens: 500, TotalTokens: 2000, MessageCount: 2, TotalCost: 8.0, CostShare: 0.8, TokenShare: 0.444444},
+		{Model: "claude-sonnet-4-20250514", InputTokens: 2000, OutputTokens: 500, TotalTokens: 2500, MessageCount: 1, TotalCost: 2.0, CostShare: 0.2, TokenShare: 0.555556},
+		{Model: UnknownModelKey, MessageCount: 1},
+	}
+	if len(breakdown) != len(expected) {
+		t.Fatalf("Expected %d models, got %d: %+v", len(expected), len(breakdown), breakdown)
+	}
+	for i, want := range expected {
+		if breakdown[i] != want {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, want, breakdown[i])
+		}
+	}
+}
+
+func TestCalculateSessionCost_PricingOverride(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) 
+		VALUES (?, ?, ?, ?)
+	`, "override-session", "project-a", "/a", time.Now())
+	if err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+	_, err = db.Exec(`
+		INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens, model) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, "override-msg", "override-session", "assistant", "test", time.Now(), 1_000_000, 1_000_000, "claude-3-5-sonnet")
+	if err != nil {
+		t.Fatalf("Failed to insert test message: %v", err)
+	}
+
+	// Default sonnet pricing: 3.0 input + 15.0 output
+	if _, err := NewTokenService(db).BackfillMessageCosts(); err != nil {
+		t.Fatalf("BackfillMessageCosts failed: %v", err)
+	}
+	cost, err := NewTokenService(db).CalculateSessionCost("override-session")
+	if err != nil {
+		t.Fatalf("CalculateSessionCost failed: %v", err)
+	}
+	if cost != 18.0 {
+		t.Errorf("Expected default cost 18.0, got %f", cost)
+	}
+
+	// Override only the input rate; output keeps the default
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	if err := os.WriteFile(path, []byte(`{"claude-3-5-sonnet": {"input": 1.0}}`), 0644); err != nil {
+		t.Fatalf("Failed to write pricing overrides: %v", err)
+	}
+	if err := LoadPricingOverrides(path); err != nil {
+		t.Fatalf("LoadPricingOverrides failed: %v", err)
+	}
+	defer LoadPricingOverrides("")
+
+	// Stored costs only pick up new rates once backfilled
+	if _, err := NewTokenService(db).BackfillMessageCosts(); err != nil {
+		t.Fatalf("BackfillMessageCosts failed: %v", err)
+	}
+	cost, err = NewTokenService(db).CalculateSessionCost("override-session")
+	if err != nil {
+		t.Fatalf("CalculateSessionCost failed: %v", err)
+	}
+	if cost != 16.0 {
+		t.Errorf("Expected overridden cost 16.0, got %f", cost)
+	}
+}
+
+func TestLoadPricingOverrides_InvalidKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.json")
+	if err := os.WriteFile(path, []byte(`{"claude-3-5-sonnet": {"prompt": 1.0}}`), 0644); err != nil {
+		t.Fatalf("Failed to write pricing overrides: %v", err)
+	}
+	if err := LoadPricingOverrides(path); err == nil {
+		LoadPricingOverrides("")
+		t.Error("Expected error for unknown pricing key")
+	}
+}
+
+func TestBackfillMessageCosts_NullModelFallback(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	addMessageModelColumns(t, db)
+
+	_, err := db.Exec(`
+		INSERT INTO sessions (id, project_name, project_path, start_time) 
+		VALUES (?, ?, ?, ?)
+	`, "fallback-session", "project-a", "/a", time.Now())
+	if err != nil {
+		t.Fatalf("Failed to insert test session: %v", err)
+	}
+
+	now := time.Now()
+	testMessages := []struct {
+		id     string
+		model  interface{}
+		input  int
+		output int
+	}{
+		{"fallback-opus-1", "claude-opus-4-20250514", 1_000_000, 0},
+		{"fallback-opus-2", "claude-opus-4-20250514", 0, 0},
+		{"fallback-haiku", "claude-3-haiku", 0, 0},
+		{"fallback-null", nil, 1_000_000, 0},
+	}
+	for i, msg := range testMessages {
+		_, err := db.Exec(`
+			INSERT INTO messages (id, session_id, message_role, content, timestamp, input_tokens, output_tokens, model) 
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, msg.id, "fallback-session", "assistant", "test", now.Add(time.Duration(i)*time.Minute), msg.input, msg.output, msg.model)
+		if err != nil {
+			t.Fatalf("Failed to insert test message: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		fallback string
+		expected float64
+	}{
+		// Only the opus input is costed (15.0)
+		{"disabled", "", 15.0},
+		// The null-model message is costed as opus, the session's most common model
+		{"session", NullModelFallbackSession, 30.0},
+		// The null-model message is costed under the configured model (sonnet input 3.0)
+		{"fixed model", "claude-3-5-sonnet", 18.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetNullModelFallback(tt.fallback)
+			defer SetNullModelFallback("")
+
+			service := NewTokenService(db)
+			if _, err := service.BackfillMessageCosts(); err != nil {
+				t.Fatalf("BackfillMessageCosts failed: %v", err)
+			}
+
+			cost, err := service.CalculateSessionCost("fallback-session")
+			if err != nil {
+				t.Fatalf("CalculateSessionCost failed: %v", err)
+			}
+			if cost != tt.expected {
+				t.Errorf("Expected session cost %f, got %f", tt.expected, cost)
+			}
+		})
+	}
+}
+
+func TestGetCurrentTokenUsage_WindowKeepsCreationPlan(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	// Create the active window while on the pro plan
+	setTestPlan(t, models.PlanPro)
+	windowService := NewSessionWindowService(db)
+	window, err := windowService.GetOrCreateWindowForMessage(time.Now().UTC().Add(-30 * time.Minute))
+	if err != nil {
+		t.Fatalf("Failed to create window: %v", err)
+	}
+	if window.Plan != models.PlanPro {
+		t.Fatalf("Expected window plan %s, got %s", models.PlanPro, window.Plan)
+	}
+	if _, err := db.Exec(`UPDATE session_windows SET total_tokens = 3500 WHERE id = ?`, window.ID); err != nil {
+		t.Fatalf("Failed to update window tokens: %v", err)
+	}
+
+	// Upgrade after the window was created
+	setTestPlan(t, models.PlanMax5)
+
+	usage, err := NewTokenService(db).GetCurrentTokenUsage()
+	if err != nil {
+		t.Fatalf("GetCurrentTokenUsage failed: %v", err)
+	}
+	if usage.Plan != models.PlanPro || usage.UsageLimit != CLAUDE_PRO_LIMIT {
+		t.Errorf("Expected pro plan with limit %d, got %s with limit %d", CLAUDE_PRO_LIMIT, usage.Plan, usage.UsageLimit)
+	}
+	if usage.UsageRate != 0.5 {
+		t.Errorf("Expected usage rate 0.5 against the pro limit, got %f", usage.UsageRate)
+	}
+
+	windows, err := windowService.GetRecentWindows(10)
+	if err != nil {
+		t.Fatalf("GetRecentWindows failed: %v", err)
+	}
+	if len(windows) != 1 || windows[0].UsageLimit != CLAUDE_PRO_LIMIT || windows[0].UsageRate != 0.5 {
+		t.Errorf("Expected historical window rated against the pro limit, got %+v", windows)
+	}
+
+	// New windows pick up the current plan
+	if NewTokenService(db).getUsageLimit() != CLAUDE_MAX5_LIMIT {
+		t.Errorf("Expected current limit %d after switching to max5", CLAUDE_MAX5_LIMIT)
+	}
+}
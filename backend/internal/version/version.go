@@ -0,0 +1,58 @@
+// Package version holds build information injected at build time, e.g.
+//
+//	go build -ldflags "-X ccdash-backend/internal/version.Version=v1.0.0 \
+//	  -X ccdash-backend/internal/version.Commit=$(git rev-parse HEAD) \
+//	  -X ccdash-backend/internal/version.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X; builds without ldflags keep these defaults
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info describes the running build
+type Info struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	BuildTime  string `json:"build_time"`
+	CommitTime string `json:"commit_time,omitempty"` // From the VCS stamp; empty if unknown
+	GoVersion  string `json:"go_version"`
+}
+
+// Get returns the running build's information. Without ldflags, the commit falls back to
+// the VCS stamp Go embeds when building from a git checkout, if any. The stamp only has
+// the commit time, so it is reported as CommitTime and never as the build time.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range buildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.Commit == "unknown":
+				info.Commit = setting.Value
+			case setting.Key == "vcs.time":
+				info.CommitTime = setting.Value
+			}
+		}
+	}
+
+	return info
+}
+
+// String formats the build information for logs and bug reports
+func (i Info) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", i.Version, i.Commit, i.BuildTime, i.GoVersion)
+}
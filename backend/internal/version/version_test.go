@@ -0,0 +1,36 @@
+package version
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	oldVersion, oldCommit, oldBuildTime := Version, Commit, BuildTime
+	defer func() {
+		Version, Commit, BuildTime = oldVersion, oldCommit, oldBuildTime
+	}()
+
+	Version, Commit, BuildTime = "v1.2.3", "abc123", "2025-08-01T00:00:00Z"
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "abc123" || info.BuildTime != "2025-08-01T00:00:00Z" {
+		t.Errorf("Expected injected build information, got %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected Go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+	if s := info.String(); !strings.Contains(s, "v1.2.3") || !strings.Contains(s, "abc123") {
+		t.Errorf("Expected version and commit in %q", s)
+	}
+}
+
+func TestGet_Defaults(t *testing.T) {
+	info := Get()
+	if info.Version != "dev" {
+		t.Errorf("Expected dev version without ldflags, got %s", info.Version)
+	}
+	if info.Commit == "" || info.BuildTime != "unknown" {
+		t.Errorf("Expected commit default and an unknown build time, got %+v", info)
+	}
+}
@@ -0,0 +1,2 @@
+-- Remove output_rules column from jobs
+ALTER TABLE jobs DROP COLUMN IF EXISTS output_rules;
@@ -0,0 +1,2 @@
+-- Add output_rules column for per-job output match rules
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS output_rules TEXT;
@@ -0,0 +1,2 @@
+-- Remove webhook_url column from jobs
+ALTER TABLE jobs DROP COLUMN IF EXISTS webhook_url;
@@ -0,0 +1,2 @@
+-- Add webhook_url column for job completion notifications
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS webhook_url TEXT;
@@ -0,0 +1,2 @@
+-- Remove per-message cost column from messages
+ALTER TABLE messages DROP COLUMN IF EXISTS cost;
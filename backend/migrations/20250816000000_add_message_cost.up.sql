@@ -0,0 +1,2 @@
+-- Add per-message cost column maintained during sync
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS cost DOUBLE DEFAULT 0.0;
@@ -0,0 +1,2 @@
+-- Remove plan column from session_windows
+ALTER TABLE session_windows DROP COLUMN IF EXISTS plan;
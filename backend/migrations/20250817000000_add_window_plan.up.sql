@@ -0,0 +1,2 @@
+-- Record the plan in effect when each session window was created
+ALTER TABLE session_windows ADD COLUMN IF NOT EXISTS plan TEXT;
@@ -0,0 +1,2 @@
+-- Remove per-job resource limits
+ALTER TABLE jobs DROP COLUMN IF EXISTS resource_limits;
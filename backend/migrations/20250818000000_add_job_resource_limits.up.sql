@@ -0,0 +1,2 @@
+-- Add per-job resource limits (JSON) applied with setrlimit on Unix
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS resource_limits TEXT;
@@ -0,0 +1,3 @@
+-- Drop session_tags table and its index
+DROP INDEX IF EXISTS idx_session_tags_tag;
+DROP TABLE IF EXISTS session_tags;
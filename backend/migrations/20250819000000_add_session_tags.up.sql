@@ -0,0 +1,10 @@
+-- Add free-form tags on sessions, unique per session
+CREATE TABLE IF NOT EXISTS session_tags (
+    session_id VARCHAR NOT NULL,
+    tag VARCHAR NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (session_id, tag)
+);
+
+-- Create index for filtering sessions by tag
+CREATE INDEX IF NOT EXISTS idx_session_tags_tag ON session_tags(tag);
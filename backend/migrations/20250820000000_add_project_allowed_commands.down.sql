@@ -0,0 +1,2 @@
+-- Drop project_allowed_commands table
+DROP TABLE IF EXISTS project_allowed_commands;
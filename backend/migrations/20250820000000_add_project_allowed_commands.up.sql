@@ -0,0 +1,7 @@
+-- Add per-project command whitelist overrides
+CREATE TABLE IF NOT EXISTS project_allowed_commands (
+    project_id VARCHAR NOT NULL,
+    command VARCHAR NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, command)
+);
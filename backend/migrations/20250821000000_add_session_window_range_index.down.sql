@@ -0,0 +1,2 @@
+-- Drop unique session window range index
+DROP INDEX IF EXISTS idx_session_windows_range;
@@ -0,0 +1,2 @@
+-- Allow only one session window per time range so concurrent syncs cannot create duplicates
+CREATE UNIQUE INDEX IF NOT EXISTS idx_session_windows_range ON session_windows(window_start, window_end);
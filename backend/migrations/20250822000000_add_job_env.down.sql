@@ -0,0 +1,2 @@
+-- Remove per-job environment variables
+ALTER TABLE jobs DROP COLUMN IF EXISTS env;
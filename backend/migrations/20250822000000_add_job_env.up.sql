@@ -0,0 +1,2 @@
+-- Add per-job environment variables (JSON) set on top of the passthrough allowlist
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS env TEXT;
@@ -0,0 +1,3 @@
+-- Remove cache token totals from session_windows
+ALTER TABLE session_windows DROP COLUMN IF EXISTS total_cache_read_tokens;
+ALTER TABLE session_windows DROP COLUMN IF EXISTS total_cache_creation_tokens;
@@ -0,0 +1,3 @@
+-- Track cache token totals on session windows so they can be counted like the cost basis
+ALTER TABLE session_windows ADD COLUMN IF NOT EXISTS total_cache_creation_tokens INTEGER DEFAULT 0;
+ALTER TABLE session_windows ADD COLUMN IF NOT EXISTS total_cache_read_tokens INTEGER DEFAULT 0;
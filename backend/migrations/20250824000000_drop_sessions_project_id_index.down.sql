@@ -0,0 +1,2 @@
+-- Restore the sessions project_id index
+CREATE INDEX IF NOT EXISTS idx_sessions_project_id ON sessions (project_id);
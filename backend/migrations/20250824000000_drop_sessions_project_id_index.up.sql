@@ -0,0 +1,3 @@
+-- DuckDB cannot update an indexed column of a row that messages reference through a foreign key,
+-- so an index on sessions.project_id blocks moving sessions between projects in a transaction
+DROP INDEX IF EXISTS idx_sessions_project_id;
@@ -5,6 +5,8 @@ export interface TokenUsage {
   total_tokens: number
   input_tokens: number
   output_tokens: number
+  cache_creation_input_tokens: number
+  cache_read_input_tokens: number
   usage_limit: number
   usage_rate: number
   window_start: string
@@ -130,6 +132,16 @@ export interface Job {
   project?: Project
 }
 
+// Rough usage guess returned when a job is created; not a prediction
+export interface JobEstimate {
+  is_estimate: boolean
+  basis: 'project_history' | 'global_history' | 'command_only'
+  sample_jobs: number
+  estimated_tokens: number
+  estimated_cost: number | null
+  note: string
+}
+
 export interface CreateJobRequest {
   project_id: string
   command: string
@@ -144,6 +156,9 @@ export interface CreateJobRequest {
 export interface JobFilters {
   project_id?: string
   status?: string
+  command_contains?: string
+  created_after?: string  // RFC3339, inclusive
+  created_before?: string // RFC3339, exclusive
   limit?: number
   offset?: number
 }
@@ -315,8 +330,8 @@ class ApiClient {
   }
 
   // Jobs API
-  async createJob(request: CreateJobRequest): Promise<{ job: Job, message: string }> {
-    return this.request<{ job: Job, message: string }>('/jobs', {
+  async createJob(request: CreateJobRequest): Promise<{ job: Job, estimate: JobEstimate | null, message: string }> {
+    return this.request<{ job: Job, estimate: JobEstimate | null, message: string }>('/jobs', {
       method: 'POST',
       body: JSON.stringify(request),
     })
@@ -328,6 +343,9 @@ class ApiClient {
       const params = new URLSearchParams()
       if (filters.project_id) params.append('project_id', filters.project_id)
       if (filters.status) params.append('status', filters.status)
+      if (filters.command_contains) params.append('command_contains', filters.command_contains)
+      if (filters.created_after) params.append('created_after', filters.created_after)
+      if (filters.created_before) params.append('created_before', filters.created_before)
       if (filters.limit) params.append('limit', filters.limit.toString())
       if (filters.offset) params.append('offset', filters.offset.toString())
       if (params.toString()) {
@@ -347,6 +365,12 @@ class ApiClient {
     })
   }
 
+  async rerunJob(id: string): Promise<{ job: Job, job_id: string, rerun_of: string, message: string }> {
+    return this.request<{ job: Job, job_id: string, rerun_of: string, message: string }>(`/jobs/${id}/rerun`, {
+      method: 'POST',
+    })
+  }
+
   async deleteJob(id: string): Promise<{ message: string }> {
     return this.request<{ message: string }>(`/jobs/${id}`, {
       method: 'DELETE',
@@ -401,6 +425,7 @@ export const api = {
     getAll: (filters?: JobFilters) => apiClient.getJobs(filters),
     getById: (id: string) => apiClient.getJob(id),
     cancel: (id: string) => apiClient.cancelJob(id),
+    rerun: (id: string) => apiClient.rerunJob(id),
     delete: (id: string) => apiClient.deleteJob(id),
     getQueueStatus: () => apiClient.getJobQueueStatus(),
   },